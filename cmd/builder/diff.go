@@ -0,0 +1,128 @@
+package cmd
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/bhojpur/iso/pkg/burner"
+	"github.com/spf13/cobra"
+	"github.com/twpayne/go-vfs"
+	"gopkg.in/yaml.v2"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <a> <b>",
+	Short: "show the differences between two ISO images, specs or manifests",
+	Long: `Compares two ISO images, ISO specifications or ISO manifests and reports
+the package additions, removals and version changes per stage, the file changes
+in the rootfs squashfs and the boot configuration changes. It exits with a
+non-zero status when differences are found.
+
+	$ isomake diff old.iso new.iso
+	$ isomake diff iso.yaml new.iso.manifest.yaml -o json
+`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		src, err := burner.LoadManifest(args[0], vfs.OSFS)
+		checkErr(err)
+		dst, err := burner.LoadManifest(args[1], vfs.OSFS)
+		checkErr(err)
+
+		diff := burner.Diff(src, dst)
+
+		switch output {
+		case "json":
+			b, err := json.MarshalIndent(diff, "", "  ")
+			checkErr(err)
+			fmt.Println(string(b))
+		case "yaml":
+			b, err := yaml.Marshal(diff)
+			checkErr(err)
+			fmt.Println(string(b))
+		default:
+			printDiff(diff)
+		}
+
+		if !diff.Empty() {
+			os.Exit(1)
+		}
+	},
+}
+
+func printDiff(diff *burner.ManifestDiff) {
+	if diff.Empty() {
+		fmt.Println("No differences found")
+		return
+	}
+
+	for _, stage := range []string{burner.StageRootfs, burner.StageUEFI, burner.StageIsoImage} {
+		d, ok := diff.Stages[stage]
+		if !ok {
+			continue
+		}
+		fmt.Printf("Packages (%s):\n", stage)
+		for _, p := range d.Added {
+			fmt.Printf("  + %s\n", p)
+		}
+		for _, p := range d.Removed {
+			fmt.Printf("  - %s\n", p)
+		}
+		for _, p := range d.Changed {
+			fmt.Printf("  ~ %s/%s %s -> %s\n", p.Category, p.Name, p.From, p.To)
+		}
+	}
+
+	printFilesDiff("Files (rootfs)", diff.Files)
+
+	if len(diff.Boot) > 0 {
+		fmt.Println("Boot configuration:")
+		for _, b := range diff.Boot {
+			fmt.Printf("  ~ %s: %q -> %q\n", b.Field, b.From, b.To)
+		}
+	}
+	printFilesDiff("Files (boot)", diff.BootFiles)
+}
+
+func printFilesDiff(title string, d burner.FilesDiff) {
+	if len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 {
+		return
+	}
+	fmt.Printf("%s:\n", title)
+	for _, f := range d.Added {
+		fmt.Printf("  + %s (%d bytes)\n", f.Path, f.Size)
+	}
+	for _, f := range d.Removed {
+		fmt.Printf("  - %s (%d bytes)\n", f.Path, f.Size)
+	}
+	for _, f := range d.Changed {
+		fmt.Printf("  ~ %s (%d -> %d bytes)\n", f.Path, f.FromSize, f.ToSize)
+	}
+}
+
+func init() {
+	diffCmd.Flags().StringP("output", "o", "terminal", "Output format ( Defaults: terminal, available: json,yaml )")
+	rootCmd.AddCommand(diffCmd)
+}
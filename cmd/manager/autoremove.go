@@ -0,0 +1,78 @@
+package cmd
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"github.com/bhojpur/iso/cmd/manager/util"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	installer "github.com/bhojpur/iso/pkg/manager/installer"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var autoremoveCmd = &cobra.Command{
+	Use:   "autoremove",
+	Short: "Uninstall the dependencies which are not needed anymore",
+	Long: `Uninstall the packages which were installed as dependencies, and are not required anymore
+by any explicitly installed package:
+
+	$ isomgr autoremove
+
+The install reason of a package can be changed with isomgr mark.`,
+	Args: cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("force", cmd.Flags().Lookup("force"))
+		viper.BindPFlag("yes", cmd.Flags().Lookup("yes"))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		force := viper.GetBool("force")
+		yes := viper.GetBool("yes")
+		keepProtected, _ := cmd.Flags().GetBool("keep-protected-files")
+
+		util.DefaultContext.Config.ConfigProtectSkip = !keepProtected
+
+		util.DefaultContext.Config.Solver.Implementation = types.SolverSingleCoreSimple
+
+		inst := installer.NewBhojpurInstaller(installer.BhojpurInstallerOptions{
+			Concurrency:                 util.DefaultContext.Config.General.Concurrency,
+			SolverOptions:               util.DefaultContext.Config.Solver,
+			Force:                       force,
+			Ask:                         !yes,
+			PreserveSystemEssentialData: true,
+			Context:                     util.DefaultContext,
+		})
+
+		system := util.NewSystem(util.DefaultContext.Config)
+
+		if err := inst.Autoremove(system); err != nil {
+			util.DefaultContext.Fatal("Error: " + err.Error())
+		}
+	},
+}
+
+func init() {
+	autoremoveCmd.Flags().Bool("force", false, "Force uninstall")
+	autoremoveCmd.Flags().BoolP("yes", "y", false, "Don't ask questions")
+	autoremoveCmd.Flags().BoolP("keep-protected-files", "k", false, "Keep package protected files around")
+
+	RootCmd.AddCommand(autoremoveCmd)
+}
@@ -0,0 +1,75 @@
+package cmd_bundle
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	helpers "github.com/bhojpur/iso/cmd/manager/helpers"
+	"github.com/bhojpur/iso/cmd/manager/util"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	"github.com/bhojpur/iso/pkg/manager/installer"
+
+	"github.com/spf13/cobra"
+)
+
+func NewBundleCreateCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "create <pkg1> <pkg2> ...",
+		Short: "Create an offline bundle",
+		Long: `Resolves the packages and all their dependencies against the configured repositories,
+downloads the artifacts and writes them in a self-contained repository archive:
+
+		$ isomgr bundle create -o bundle.tar utils/busybox utils/yq`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var toBundle types.Packages
+
+			for _, a := range args {
+				pack, err := helpers.ParsePackageStr(a)
+				if err != nil {
+					util.DefaultContext.Fatal("Invalid package string ", a, ": ", err.Error())
+				}
+				toBundle = append(toBundle, pack)
+			}
+
+			output, _ := cmd.Flags().GetString("output")
+			nodeps, _ := cmd.Flags().GetBool("nodeps")
+			relax, _ := cmd.Flags().GetBool("relax")
+
+			inst := installer.NewBhojpurInstaller(installer.BhojpurInstallerOptions{
+				Concurrency:         util.DefaultContext.Config.General.Concurrency,
+				SolverOptions:       util.DefaultContext.Config.Solver,
+				NoDeps:              nodeps,
+				Relaxed:             relax,
+				PackageRepositories: util.DefaultContext.Config.SystemRepositories,
+				Context:             util.DefaultContext,
+			})
+
+			if err := inst.Bundle(toBundle, output); err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+		},
+	}
+	c.Flags().StringP("output", "o", "bundle.tar", "Path of the bundle archive to create")
+	c.Flags().Bool("nodeps", false, "Don't consider package dependencies (harmful!)")
+	c.Flags().Bool("relax", false, "Relax installation constraints")
+
+	return c
+}
@@ -0,0 +1,77 @@
+package cmd_bundle
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	helpers "github.com/bhojpur/iso/cmd/manager/helpers"
+	"github.com/bhojpur/iso/cmd/manager/util"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	"github.com/bhojpur/iso/pkg/manager/installer"
+
+	"github.com/spf13/cobra"
+)
+
+func NewBundleInstallCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "install <bundle> [pkg1] [pkg2] ...",
+		Short: "Install packages from an offline bundle",
+		Long: `Installs the packages of an offline bundle, without using the configured repositories:
+
+		$ isomgr bundle install bundle.tar
+
+To install only some of the packages of the bundle:
+
+		$ isomgr bundle install bundle.tar utils/yq`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var toInstall types.Packages
+
+			for _, a := range args[1:] {
+				pack, err := helpers.ParsePackageStr(a)
+				if err != nil {
+					util.DefaultContext.Fatal("Invalid package string ", a, ": ", err.Error())
+				}
+				toInstall = append(toInstall, pack)
+			}
+
+			yes, _ := cmd.Flags().GetBool("yes")
+			force, _ := cmd.Flags().GetBool("force")
+
+			inst := installer.NewBhojpurInstaller(installer.BhojpurInstallerOptions{
+				Concurrency:                 util.DefaultContext.Config.General.Concurrency,
+				SolverOptions:               util.DefaultContext.Config.Solver,
+				Force:                       force,
+				PreserveSystemEssentialData: true,
+				Ask:                         !yes,
+				Context:                     util.DefaultContext,
+			})
+
+			system := util.NewSystem(util.DefaultContext.Config)
+			if err := inst.InstallBundle(args[0], toInstall, system); err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+		},
+	}
+	c.Flags().BoolP("yes", "y", false, "Don't ask questions")
+	c.Flags().Bool("force", false, "Skip errors and keep going (potentially harmful)")
+
+	return c
+}
@@ -0,0 +1,62 @@
+package cmd_cache
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"os"
+
+	"github.com/bhojpur/iso/cmd/manager/util"
+	"github.com/bhojpur/iso/pkg/manager/installer"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCacheExportCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "export <dir> [<pkg1> <pkg2> ...]",
+		Short: "Export cached artifacts as a local repository",
+		Long: `Write the cached artifacts in a directory, together with the repository metadata,
+so that it can be used as a local repository. Without packages, the whole cache is exported:
+
+		$ isomgr cache export /srv/repo
+		$ isomgr cache export /srv/repo utils/busybox utils/yq`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			name, _ := cmd.Flags().GetString("name")
+			dst := args[0]
+
+			entries := cacheEntries(args[1:])
+			if len(entries) == 0 {
+				util.DefaultContext.Fatal("Error: no cached artifacts to export")
+			}
+
+			if err := os.MkdirAll(dst, os.ModePerm); err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+			if err := installer.ExportCache(util.DefaultContext, entries, name, dst); err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+		},
+	}
+	c.Flags().String("name", "cache", "Name of the exported repository")
+
+	return c
+}
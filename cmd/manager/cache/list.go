@@ -0,0 +1,131 @@
+package cmd_cache
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	helpers "github.com/bhojpur/iso/cmd/manager/helpers"
+	"github.com/bhojpur/iso/cmd/manager/util"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
+	units "github.com/docker/go-units"
+	"gopkg.in/yaml.v2"
+
+	"github.com/spf13/cobra"
+)
+
+type cacheEntry struct {
+	Package  string    `json:"package" yaml:"package"`
+	File     string    `json:"file" yaml:"file"`
+	Size     int64     `json:"size" yaml:"size"`
+	LastUsed time.Time `json:"last_used" yaml:"last_used"`
+}
+
+// cacheEntries returns the entries of the packages cache, filtered by the given package strings
+func cacheEntries(args []string) []*artifact.CacheEntry {
+	entries, err := artifact.NewCache(util.DefaultContext.Config.System.PkgsCachePath).Entries()
+	if err != nil {
+		util.DefaultContext.Fatal("Error: failed reading the packages cache: " + err.Error())
+	}
+	if len(args) == 0 {
+		return entries
+	}
+
+	res := []*artifact.CacheEntry{}
+	for _, a := range args {
+		sel, err := helpers.ParsePackageStr(a)
+		if err != nil {
+			util.DefaultContext.Fatal("Invalid package string ", a, ": ", err.Error())
+		}
+		for _, e := range entries {
+			p := e.Artifact.CompileSpec.GetPackage()
+			if p == nil || !sel.AtomMatches(p) {
+				continue
+			}
+			if match, _ := p.VersionMatchSelector(sel.GetVersion(), nil); match {
+				res = append(res, e)
+			}
+		}
+	}
+	return res
+}
+
+func entryPackage(e *artifact.CacheEntry) string {
+	if p := e.Artifact.CompileSpec.GetPackage(); p != nil {
+		return p.HumanReadableString()
+	}
+	return ""
+}
+
+func NewCacheListCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "list [<pkg1> <pkg2> ...]",
+		Short: "List the cached artifacts",
+		Long: `List the artifacts of the packages cache with the packages they belong to,
+the least recently used first:
+
+		$ isomgr cache list
+		$ isomgr cache list utils/busybox`,
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString("output")
+
+			entries := cacheEntries(args)
+			res := []cacheEntry{}
+			var total int64
+			for _, e := range entries {
+				res = append(res, cacheEntry{Package: entryPackage(e), File: e.Artifact.Path, Size: e.Size, LastUsed: e.LastUsed})
+				total += e.Size
+			}
+
+			switch out {
+			case "json":
+				b, err := json.MarshalIndent(res, "", "  ")
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				fmt.Println(string(b))
+			case "yaml":
+				b, err := yaml.Marshal(res)
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				fmt.Println(string(b))
+			default:
+				if len(res) == 0 {
+					util.DefaultContext.Info("No cached artifacts")
+					return
+				}
+				t := &util.TableWriter{}
+				t.AppendRow([]string{"Package", "Size", "Last used"})
+				for _, e := range res {
+					t.AppendRow([]string{e.Package, units.HumanSize(float64(e.Size)), e.LastUsed.Local().Format(time.RFC1123)})
+				}
+				t.Render()
+				util.DefaultContext.Info(fmt.Sprintf("%d artifacts, %s", len(res), units.HumanSize(float64(total))))
+			}
+		},
+	}
+	c.Flags().StringP("output", "o", "terminal", "Output format ( Defaults: terminal, available: json,yaml )")
+
+	return c
+}
@@ -0,0 +1,81 @@
+package cmd_cache
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+
+	"github.com/bhojpur/iso/cmd/manager/util"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
+	units "github.com/docker/go-units"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCachePruneCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "prune",
+		Short: "Evict old artifacts from the cache",
+		Long: `Evict the artifacts not used for longer than max_age, then the least recently used ones
+until the cache fits in max_size. The limits default to the system configuration:
+
+		$ isomgr cache prune
+		$ isomgr cache prune --max-size 1GB --max-age 7d`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			system := util.DefaultContext.Config.System
+			if cmd.Flags().Changed("max-size") {
+				system.MaxSize, _ = cmd.Flags().GetString("max-size")
+			}
+			if cmd.Flags().Changed("max-age") {
+				system.MaxAge, _ = cmd.Flags().GetString("max-age")
+			}
+
+			maxSize, err := system.CacheMaxSize()
+			if err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+			maxAge, err := system.CacheMaxAge()
+			if err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+			if maxSize == 0 && maxAge == 0 {
+				util.DefaultContext.Info("No cache limits configured, nothing to prune")
+				return
+			}
+
+			removed, err := artifact.NewCache(system.PkgsCachePath).Prune(maxSize, maxAge)
+			if err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+			var freed int64
+			for _, e := range removed {
+				util.DefaultContext.Info("Evicted", entryPackage(e))
+				freed += e.Size
+			}
+			util.DefaultContext.Success(fmt.Sprintf("Evicted %d artifacts, %s freed", len(removed), units.HumanSize(float64(freed))))
+		},
+	}
+	c.Flags().String("max-size", "", "Maximum size of the cache (e.g. 5GB)")
+	c.Flags().String("max-age", "", "Maximum age of the cached artifacts since their last use (e.g. 30d)")
+
+	return c
+}
@@ -0,0 +1,74 @@
+package cmd_cache
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+
+	"github.com/bhojpur/iso/cmd/manager/util"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCacheVerifyCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "verify [<pkg1> <pkg2> ...]",
+		Short: "Verify the checksums of the cached artifacts",
+		Long: `Check the cached artifacts against the checksums published by their repositories:
+
+		$ isomgr cache verify
+
+To evict the corrupted artifacts, so that they are downloaded again:
+
+		$ isomgr cache verify --remove`,
+		Run: func(cmd *cobra.Command, args []string) {
+			remove, _ := cmd.Flags().GetBool("remove")
+
+			corrupted := []*artifact.CacheEntry{}
+			for _, e := range cacheEntries(args) {
+				if err := e.Verify(); err != nil {
+					util.DefaultContext.Error(fmt.Sprintf("%s: %s", entryPackage(e), err.Error()))
+					corrupted = append(corrupted, e)
+					continue
+				}
+				util.DefaultContext.Debug(entryPackage(e), "OK")
+			}
+
+			if len(corrupted) == 0 {
+				util.DefaultContext.Success("All cached artifacts are valid")
+				return
+			}
+
+			if remove {
+				if err := artifact.NewCache(util.DefaultContext.Config.System.PkgsCachePath).Remove(corrupted...); err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				util.DefaultContext.Info(fmt.Sprintf("Evicted %d corrupted artifacts", len(corrupted)))
+				return
+			}
+			util.DefaultContext.Fatal(fmt.Sprintf("Found %d corrupted artifacts", len(corrupted)))
+		},
+	}
+	c.Flags().Bool("remove", false, "Evict the corrupted artifacts from the cache")
+
+	return c
+}
@@ -0,0 +1,188 @@
+package cmd
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bhojpur/iso/cmd/manager/util"
+	installer "github.com/bhojpur/iso/pkg/manager/installer"
+	"gopkg.in/yaml.v2"
+
+	"github.com/spf13/cobra"
+)
+
+var configUpdateCmd = &cobra.Command{
+	Use:   "config-update",
+	Short: "Review and merge the updates of config-protected files",
+	Long: `When a package ships a new version of a config-protected file changed in the system,
+the new version is written next to it as ._cfgNNNN_<name>. Review the pending updates,
+showing their diff, and keep the installed file, replace it or merge the two:
+
+	$ isomgr config-update
+
+Merges are three-way, against the version of the file shipped last. To list the pending
+updates:
+
+	$ isomgr config-update --list
+
+For automation, a policy applies the same choice to all the files. With the merge policy,
+files with conflicting changes are left pending:
+
+	$ isomgr config-update --policy merge
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		list, _ := cmd.Flags().GetBool("list")
+		policy, _ := cmd.Flags().GetString("policy")
+		out, _ := cmd.Flags().GetString("output")
+
+		switch policy {
+		case "", "keep", "replace", "merge":
+		default:
+			util.DefaultContext.Fatal("Invalid policy '" + policy + "', available: keep, replace, merge")
+		}
+
+		system := util.NewSystem(util.DefaultContext.Config)
+		pending, err := system.PendingConfigs(util.DefaultContext)
+		if err != nil {
+			util.DefaultContext.Fatal("Error: " + err.Error())
+		}
+
+		if list {
+			switch out {
+			case "json":
+				b, err := json.MarshalIndent(pending, "", "  ")
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				fmt.Println(string(b))
+			case "yaml":
+				b, err := yaml.Marshal(pending)
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				fmt.Println(string(b))
+			default:
+				for _, p := range pending {
+					fmt.Println("/"+p.Path, "("+strings.Join(p.Updates, ", ")+")")
+				}
+			}
+			return
+		}
+
+		if len(pending) == 0 {
+			util.DefaultContext.Success("No config updates pending")
+			return
+		}
+
+		left := 0
+		for _, p := range pending {
+			var resolved bool
+			var err error
+			if policy != "" {
+				resolved, err = applyConfigPolicy(system, p, policy)
+			} else {
+				resolved, err = reviewConfig(system, p)
+			}
+			if err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+			if !resolved {
+				left++
+			}
+		}
+		if left > 0 {
+			util.DefaultContext.Warning(fmt.Sprintf("%d config updates left pending", left))
+		}
+	},
+}
+
+// applyConfigPolicy resolves a pending update without asking. It returns false when the update is left pending.
+func applyConfigPolicy(system *installer.System, p *installer.PendingConfig, policy string) (bool, error) {
+	switch policy {
+	case "keep":
+		util.DefaultContext.Info("Keeping /" + p.Path)
+		return true, system.KeepConfig(p)
+	case "replace":
+		util.DefaultContext.Info("Replacing /" + p.Path)
+		return true, system.ReplaceConfig(p)
+	}
+
+	merged, conflicts, err := system.MergeConfig(p)
+	if err != nil {
+		return false, err
+	}
+	if conflicts {
+		util.DefaultContext.Warning("Conflicting changes in /" + p.Path + ", leaving its update pending")
+		return false, nil
+	}
+	util.DefaultContext.Info("Merging /" + p.Path)
+	return true, system.ApplyConfig(p, merged)
+}
+
+// reviewConfig shows the diff of a pending update and asks what to do with it
+func reviewConfig(system *installer.System, p *installer.PendingConfig) (bool, error) {
+	d, err := system.ConfigDiff(p)
+	if err != nil {
+		return false, err
+	}
+	fmt.Print(d)
+
+	switch prompt("/" + p.Path + ": [k]eep installed, [r]eplace with the update, [m]erge, [s]kip ? ") {
+	case "k", "keep":
+		return true, system.KeepConfig(p)
+	case "r", "replace":
+		return true, system.ReplaceConfig(p)
+	case "m", "merge":
+		merged, conflicts, err := system.MergeConfig(p)
+		if err != nil {
+			return false, err
+		}
+		if conflicts {
+			fmt.Print(merged)
+			if prompt("The changes conflict: [w]rite the merge with conflict markers, [s]kip ? ") != "w" {
+				return false, nil
+			}
+		}
+		return true, system.ApplyConfig(p, merged)
+	}
+	return false, nil
+}
+
+func prompt(question string) string {
+	var input string
+	util.DefaultContext.Info(question)
+	if _, err := fmt.Scanln(&input); err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(input))
+}
+
+func init() {
+	configUpdateCmd.Flags().Bool("list", false, "List the pending updates")
+	configUpdateCmd.Flags().String("policy", "", "Resolve all the updates without asking ( available: keep,replace,merge )")
+	configUpdateCmd.Flags().StringP("output", "o", "terminal", "Output format with --list ( Defaults: terminal, available: json,yaml )")
+
+	RootCmd.AddCommand(configUpdateCmd)
+}
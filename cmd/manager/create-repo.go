@@ -21,13 +21,16 @@ package cmd
 // THE SOFTWARE.
 
 import (
+	"crypto/ed25519"
 	"os"
 	"path/filepath"
 
 	helpers "github.com/bhojpur/iso/cmd/manager/helpers"
 	"github.com/bhojpur/iso/cmd/manager/util"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
 	"github.com/bhojpur/iso/pkg/manager/compiler"
 	"github.com/bhojpur/iso/pkg/manager/compiler/types/compression"
+	"github.com/bhojpur/iso/pkg/manager/helpers/sign"
 	installer "github.com/bhojpur/iso/pkg/manager/installer"
 
 	//	. "github.com/bhojpur/iso/pkg/manager/logger"
@@ -56,6 +59,40 @@ Change compression method:
 	
 	$ isomgr create-repo --tree-compression gzip --meta-compression gzip
 
+Generate binary deltas between consecutive versions of the packages, so clients
+which have the previous version in cache download only the changes:
+
+	$ isomgr create-repo --deltas
+
+Each revision publishes a diff of the tree and of the package index with the previous one,
+so clients with an older revision in cache apply the diffs instead of downloading the whole
+tree. The diffs of the last 10 revisions are kept by default, 0 disables them:
+
+	$ isomgr create-repo --diff-history 20
+
+Sign the repository with an ed25519 key generated with "isomgr keys generate". The clients
+trusting the public key verify the signature on sync. While rotating keys, sign with both
+the old and the new key:
+
+	$ isomgr create-repo --sign-key repo.key
+	$ isomgr create-repo --sign-key old.key --sign-key new.key
+
+Sign also each artifact of the repository:
+
+	$ isomgr create-repo --sign-key repo.key --sign-artifacts
+
+Upload the repository to a bucket of an S3 compatible object storage. The credentials
+are read from the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables, or
+from the auth section of the repository with --repo:
+
+	$ isomgr create-repo --type s3 --output s3://bucket/prefix --s3-endpoint https://minio.example.com --s3-path-style
+
+Push the repository to a registry, storing the packages and the metadata as OCI artifacts
+tagged with their file names. The credentials are read from the docker configuration, or
+from the auth section of the repository with --repo:
+
+	$ isomgr create-repo --type oci --output quay.io/org/repo
+
 Create a repository from the metadata description defined in the iso.yaml config file:
 
 	$ isomgr create-repo --repo repository1
@@ -99,6 +136,19 @@ Create a repository from the metadata description defined in the iso.yaml config
 		source_repo := viper.GetString("repo")
 		backendType := viper.GetString("backend")
 		fromRepo, _ := cmd.Flags().GetBool("from-repositories")
+		deltas, _ := cmd.Flags().GetBool("deltas")
+		diffHistory, _ := cmd.Flags().GetInt("diff-history")
+		signKeyFiles, _ := cmd.Flags().GetStringSlice("sign-key")
+		signArtifacts, _ := cmd.Flags().GetBool("sign-artifacts")
+		if signArtifacts && len(signKeyFiles) == 0 {
+			util.DefaultContext.Fatal("--sign-artifacts requires at least one --sign-key")
+		}
+		signKeys := []ed25519.PrivateKey{}
+		for _, f := range signKeyFiles {
+			k, err := sign.ReadPrivateKey(f)
+			helpers.CheckErr(err)
+			signKeys = append(signKeys, k)
+		}
 
 		treeFile := installer.NewDefaultTreeRepositoryFile()
 		metaFile := installer.NewDefaultMetaRepositoryFile()
@@ -107,6 +157,9 @@ Create a repository from the metadata description defined in the iso.yaml config
 		force := viper.GetBool("force-push")
 		imagePush := viper.GetBool("push-images")
 		snapshotID, _ := cmd.Flags().GetString("snapshot-id")
+		s3Endpoint, _ := cmd.Flags().GetString("s3-endpoint")
+		s3Region, _ := cmd.Flags().GetString("s3-region")
+		s3PathStyle, _ := cmd.Flags().GetBool("s3-path-style")
 
 		opts := []installer.RepositoryOption{
 			installer.WithSource(viper.GetString("packages")),
@@ -117,6 +170,11 @@ Create a repository from the metadata description defined in the iso.yaml config
 			installer.WithDatabase(pkg.NewInMemoryDatabase(false)),
 			installer.WithCompilerBackend(compilerBackend),
 			installer.FromMetadata(viper.GetBool("from-metadata")),
+			installer.WithDeltas(deltas),
+			installer.WithDiffHistory(diffHistory),
+			installer.WithSigningKeys(signKeys...),
+			installer.WithSignedArtifacts(signArtifacts),
+			installer.WithSnapshotID(snapshotID),
 			installer.WithContext(util.DefaultContext),
 		}
 
@@ -140,6 +198,8 @@ Create a repository from the metadata description defined in the iso.yaml config
 				installer.WithUrls(lrepo.Urls...),
 				installer.WithPriority(lrepo.Priority),
 				installer.WithTree(treePaths...),
+				installer.WithAuthentication(lrepo.Authentication),
+				installer.WithS3(lrepo.S3),
 			)
 
 		} else {
@@ -152,6 +212,14 @@ Create a repository from the metadata description defined in the iso.yaml config
 			)
 		}
 
+		if s3Endpoint != "" || s3Region != "" || s3PathStyle {
+			opts = append(opts, installer.WithS3(&types.BhojpurRepositoryS3{
+				Endpoint:  s3Endpoint,
+				Region:    s3Region,
+				PathStyle: s3PathStyle,
+			}))
+		}
+
 		repo, err = installer.GenerateRepository(opts...)
 		helpers.CheckErr(err)
 
@@ -170,7 +238,6 @@ Create a repository from the metadata description defined in the iso.yaml config
 		if metaName != "" {
 			metaFile.SetFileName(metaName)
 		}
-		repo.SetSnapshotID(snapshotID)
 		repo.SetRepositoryFile(installer.REPOFILE_TREE_KEY, treeFile)
 		repo.SetRepositoryFile(installer.REPOFILE_META_KEY, metaFile)
 
@@ -186,16 +253,16 @@ func init() {
 
 	createrepoCmd.Flags().String("packages", filepath.Join(path, "build"), "Packages folder (output from build)")
 	createrepoCmd.Flags().StringSliceP("tree", "t", []string{path}, "Path of the source trees to use.")
-	createrepoCmd.Flags().String("output", filepath.Join(path, "build"), "Destination for generated archives. With 'docker' repository type, it should be an image reference (e.g 'foo/bar')")
+	createrepoCmd.Flags().String("output", filepath.Join(path, "build"), "Destination for generated archives. With 'docker' repository type, it should be an image reference (e.g 'foo/bar'), with 's3' a bucket URL (e.g 's3://bucket/prefix'), with 'oci' a registry repository (e.g 'quay.io/org/repo')")
 	createrepoCmd.Flags().String("name", "bhojpur", "Repository name")
 	createrepoCmd.Flags().String("descr", "bhojpur", "Repository description")
 	createrepoCmd.Flags().StringSlice("urls", []string{}, "Repository URLs")
-	createrepoCmd.Flags().String("type", "disk", "Repository type (disk, http, docker)")
+	createrepoCmd.Flags().String("type", "disk", "Repository type (disk, http, docker, s3, oci)")
 	createrepoCmd.Flags().Bool("reset-revision", false, "Reset repository revision.")
 	createrepoCmd.Flags().String("repo", "", "Use repository defined in configuration.")
 	createrepoCmd.Flags().String("backend", "docker", "backend used (docker,img)")
 
-	createrepoCmd.Flags().Bool("force-push", false, "Force overwrite of docker images if already present online")
+	createrepoCmd.Flags().Bool("force-push", false, "Force overwrite of docker images, s3 and oci artifacts if already present online")
 	createrepoCmd.Flags().Bool("push-images", false, "Enable/Disable docker image push for docker repositories")
 	createrepoCmd.Flags().Bool("from-metadata", false, "Consider metadata files from the packages folder while indexing the new tree")
 
@@ -205,6 +272,13 @@ func init() {
 	createrepoCmd.Flags().String("meta-filename", installer.REPOSITORY_METAFILE+".tar", "Repository metadata filename")
 	createrepoCmd.Flags().Bool("from-repositories", false, "Consume the user-defined repositories to pull specfiles from")
 	createrepoCmd.Flags().String("snapshot-id", "", "Unique ID to use when creating repository snapshots")
+	createrepoCmd.Flags().Bool("deltas", false, "Generate binary deltas between consecutive versions of the packages")
+	createrepoCmd.Flags().Int("diff-history", 10, "Number of revisions to keep the tree and index diffs of (0 disables them)")
+	createrepoCmd.Flags().StringSlice("sign-key", []string{}, "Private key file to sign the repository with (can be repeated)")
+	createrepoCmd.Flags().Bool("sign-artifacts", false, "Sign also each artifact of the repository")
+	createrepoCmd.Flags().String("s3-endpoint", "", "Object storage endpoint of s3 repositories (AWS S3 if empty)")
+	createrepoCmd.Flags().String("s3-region", "", "Object storage region of s3 repositories")
+	createrepoCmd.Flags().Bool("s3-path-style", false, "Address the bucket in the URL path, as required by most self-hosted object storages")
 
 	RootCmd.AddCommand(createrepoCmd)
 }
@@ -39,11 +39,15 @@ func NewDatabaseGetCommand() *cobra.Command {
 		$ isomgr database get system/foo
 
 To return also files:
-		$ isomgr database get --files system/foo`,
+		$ isomgr database get --files system/foo
+
+To return also the finalizer and the output of its last run:
+		$ isomgr database get --finalizer system/foo`,
 		Args: cobra.OnlyValidArgs,
 
 		Run: func(cmd *cobra.Command, args []string) {
 			showFiles, _ := cmd.Flags().GetBool("files")
+			showFinalizer, _ := cmd.Flags().GetBool("finalizer")
 
 			systemDB := util.SystemDB(util.DefaultContext.Config)
 
@@ -74,11 +78,23 @@ To return also files:
 						}
 						fmt.Println("files:\n" + string(b))
 					}
+					if showFinalizer {
+						f, err := systemDB.GetPackageFinalizer(p)
+						if err != nil {
+							continue
+						}
+						b, err := yaml.Marshal(map[string]string{"finalizer": f.Finalizer, "output": f.Output, "error": f.Error})
+						if err != nil {
+							continue
+						}
+						fmt.Println(string(b))
+					}
 				}
 			}
 		},
 	}
 	c.Flags().Bool("files", false, "Show package files.")
+	c.Flags().Bool("finalizer", false, "Show package finalizer and the output of its last run.")
 
 	return c
 }
@@ -0,0 +1,48 @@
+package cmd
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	. "github.com/bhojpur/iso/cmd/manager/finalizer"
+
+	"github.com/spf13/cobra"
+)
+
+var finalizerGroupCmd = &cobra.Command{
+	Use:   "finalizer [command] [OPTIONS]",
+	Short: "Manage package finalizers",
+	Long: `Finalizers run after packages are installed and before they are removed.
+Their output is stored in the system database, and can be displayed with:
+
+	$ isomgr database get --finalizer system/foo
+
+Finalizers are stopped after the timeout set in their finalizer.yaml, or the
+finalizer_timeout of the configuration:
+
+	finalizer_timeout: 10m
+`,
+}
+
+func init() {
+	RootCmd.AddCommand(finalizerGroupCmd)
+
+	finalizerGroupCmd.AddCommand(
+		NewFinalizerRunCommand(),
+	)
+}
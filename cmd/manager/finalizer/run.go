@@ -0,0 +1,66 @@
+package cmd_finalizer
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	helpers "github.com/bhojpur/iso/cmd/manager/helpers"
+	"github.com/bhojpur/iso/cmd/manager/util"
+
+	"github.com/spf13/cobra"
+)
+
+func NewFinalizerRunCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "run <package>",
+		Short: "Run again the finalizer of an installed package",
+		Long: `Runs again the finalizer stored for an installed package, e.g. after fixing
+the issue which made it fail during the install:
+
+		$ isomgr finalizer run system/foo`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			system := util.NewSystem(util.DefaultContext.Config)
+
+			failed := false
+			for _, a := range args {
+				pack, err := helpers.ParsePackageStr(a)
+				if err != nil {
+					util.DefaultContext.Fatal("Invalid package string ", a, ": ", err.Error())
+				}
+
+				packs, err := system.Database.FindPackages(pack)
+				if err != nil || len(packs) == 0 {
+					util.DefaultContext.Fatal("Package ", a, " is not installed")
+				}
+
+				for _, p := range packs {
+					if err := system.RunFinalizer(util.DefaultContext, p); err != nil {
+						util.DefaultContext.Error("Finalizer of ", p.HumanReadableString(), " failed: ", err.Error())
+						failed = true
+					}
+				}
+			}
+			if failed {
+				util.DefaultContext.Fatal("Error: finalizers failed")
+			}
+		},
+	}
+
+	return c
+}
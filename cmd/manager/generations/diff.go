@@ -0,0 +1,109 @@
+package cmd_generations
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/bhojpur/iso/cmd/manager/util"
+	"github.com/bhojpur/iso/pkg/manager/installer"
+	"gopkg.in/yaml.v2"
+
+	"github.com/spf13/cobra"
+)
+
+func NewGenerationsDiffCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "diff <n> [m]",
+		Short: "Show the package changes between two generations",
+		Long: `Show the packages added, removed or changed going from generation n to generation m:
+
+		$ isomgr generations diff 3 5
+
+When m is omitted, generation n is compared with the packages currently installed:
+
+		$ isomgr generations diff 3`,
+		Args: cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString("output")
+
+			system := util.NewSystem(util.DefaultContext.Config)
+			src, err := loadGeneration(system, args[0])
+			if err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+
+			var dst *installer.Generation
+			if len(args) == 2 {
+				dst, err = loadGeneration(system, args[1])
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+			} else {
+				dst = system.CurrentGeneration(src)
+			}
+
+			diff := installer.DiffGenerations(src, dst)
+
+			switch out {
+			case "json":
+				b, err := json.MarshalIndent(diff, "", "  ")
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				fmt.Println(string(b))
+			case "yaml":
+				b, err := yaml.Marshal(diff)
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				fmt.Println(string(b))
+			default:
+				if diff.Empty() {
+					util.DefaultContext.Info("No differences found")
+					return
+				}
+				for _, p := range diff.Added {
+					fmt.Printf("+ %s/%s-%s\n", p.Category, p.Name, p.Version)
+				}
+				for _, p := range diff.Removed {
+					fmt.Printf("- %s/%s-%s\n", p.Category, p.Name, p.Version)
+				}
+				for _, p := range diff.Changed {
+					fmt.Printf("~ %s/%s %s -> %s\n", p.Category, p.Name, p.From, p.To)
+				}
+			}
+		},
+	}
+	c.Flags().StringP("output", "o", "terminal", "Output format ( Defaults: terminal, available: json,yaml )")
+
+	return c
+}
+
+func loadGeneration(s *installer.System, n string) (*installer.Generation, error) {
+	id, err := strconv.Atoi(n)
+	if err != nil {
+		return nil, fmt.Errorf("invalid generation '%s'", n)
+	}
+	return s.Generation(id)
+}
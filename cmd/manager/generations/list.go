@@ -0,0 +1,87 @@
+package cmd_generations
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bhojpur/iso/cmd/manager/util"
+	"gopkg.in/yaml.v2"
+
+	"github.com/spf13/cobra"
+)
+
+func NewGenerationsListCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "list",
+		Short: "List the generations of the system",
+		Long: `List the recorded generations of the system, oldest first:
+
+		$ isomgr generations list`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString("output")
+
+			system := util.NewSystem(util.DefaultContext.Config)
+			generations, err := system.Generations()
+			if err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+
+			switch out {
+			case "json":
+				b, err := json.MarshalIndent(generations, "", "  ")
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				fmt.Println(string(b))
+			case "yaml":
+				b, err := yaml.Marshal(generations)
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				fmt.Println(string(b))
+			default:
+				if len(generations) == 0 {
+					util.DefaultContext.Info("No generations recorded")
+					return
+				}
+				t := &util.TableWriter{}
+				t.AppendRow([]string{"Generation", "Date", "Packages", "Command"})
+				for _, g := range generations {
+					t.AppendRow([]string{
+						strconv.Itoa(g.ID),
+						g.Timestamp.Local().Format(time.RFC1123),
+						strconv.Itoa(len(g.Packages)),
+						g.Command,
+					})
+				}
+				t.Render()
+			}
+		},
+	}
+	c.Flags().StringP("output", "o", "terminal", "Output format ( Defaults: terminal, available: json,yaml )")
+
+	return c
+}
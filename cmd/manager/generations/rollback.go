@@ -0,0 +1,74 @@
+package cmd_generations
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"github.com/bhojpur/iso/cmd/manager/util"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	"github.com/bhojpur/iso/pkg/manager/installer"
+
+	"github.com/spf13/cobra"
+)
+
+func NewGenerationsRollbackCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "rollback <n>",
+		Short: "Roll back the system to a previous generation",
+		Long: `Installs, removes and swaps packages to bring the system back to the package set
+of generation n. The rollback is recorded as a new generation:
+
+		$ isomgr generations rollback 3`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			yes, _ := cmd.Flags().GetBool("yes")
+			force, _ := cmd.Flags().GetBool("force")
+			downloadOnly, _ := cmd.Flags().GetBool("download-only")
+
+			system := util.NewSystem(util.DefaultContext.Config)
+			g, err := loadGeneration(system, args[0])
+			if err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+
+			util.DefaultContext.Config.Solver.Implementation = types.SolverSingleCoreSimple
+
+			inst := installer.NewBhojpurInstaller(installer.BhojpurInstallerOptions{
+				Concurrency:                 util.DefaultContext.Config.General.Concurrency,
+				SolverOptions:               util.DefaultContext.Config.Solver,
+				Force:                       force,
+				PreserveSystemEssentialData: true,
+				Ask:                         !yes,
+				DownloadOnly:                downloadOnly,
+				PackageRepositories:         util.DefaultContext.Config.SystemRepositories,
+				Context:                     util.DefaultContext,
+			})
+
+			if err := inst.Rollback(system, g.ID); err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+		},
+	}
+	c.Flags().BoolP("yes", "y", false, "Don't ask questions")
+	c.Flags().Bool("force", false, "Skip errors and keep going (potentially harmful)")
+	c.Flags().Bool("download-only", false, "Download only")
+
+	return c
+}
@@ -0,0 +1,46 @@
+package cmd
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	. "github.com/bhojpur/iso/cmd/manager/history"
+
+	"github.com/spf13/cobra"
+)
+
+var historyGroupCmd = &cobra.Command{
+	Use:   "history [command] [OPTIONS]",
+	Short: "Show and undo the transactions applied to the system",
+	Long: `Every install, uninstall, upgrade, replace, reclaim or rollback is recorded in the
+history of the system, with the user and the command which ran it, the packages added,
+removed or upgraded, and its outcome. Recorded transactions can be inspected and undone.
+`,
+}
+
+func init() {
+	RootCmd.AddCommand(historyGroupCmd)
+
+	historyGroupCmd.AddCommand(
+		NewHistoryListCommand(),
+		NewHistoryShowCommand(),
+		NewHistoryUndoCommand(),
+	)
+}
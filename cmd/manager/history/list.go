@@ -0,0 +1,89 @@
+package cmd_history
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bhojpur/iso/cmd/manager/util"
+	"gopkg.in/yaml.v2"
+
+	"github.com/spf13/cobra"
+)
+
+func NewHistoryListCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "list",
+		Short: "List the transactions applied to the system",
+		Long: `List the recorded transactions, oldest first:
+
+		$ isomgr history list`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString("output")
+
+			system := util.NewSystem(util.DefaultContext.Config)
+			history, err := system.History()
+			if err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+
+			switch out {
+			case "json":
+				b, err := json.MarshalIndent(history, "", "  ")
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				fmt.Println(string(b))
+			case "yaml":
+				b, err := yaml.Marshal(history)
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				fmt.Println(string(b))
+			default:
+				if len(history) == 0 {
+					util.DefaultContext.Info("No transactions recorded")
+					return
+				}
+				t := &util.TableWriter{}
+				t.AppendRow([]string{"ID", "Date", "User", "Operation", "Changes", "Outcome"})
+				for _, r := range history {
+					t.AppendRow([]string{
+						strconv.Itoa(r.ID),
+						r.Timestamp.Local().Format(time.RFC1123),
+						r.User,
+						r.Operation,
+						fmt.Sprintf("+%d -%d ~%d", len(r.Changes.Added), len(r.Changes.Removed), len(r.Changes.Changed)),
+						r.Outcome,
+					})
+				}
+				t.Render()
+			}
+		},
+	}
+	c.Flags().StringP("output", "o", "terminal", "Output format ( Defaults: terminal, available: json,yaml )")
+
+	return c
+}
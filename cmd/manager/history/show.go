@@ -0,0 +1,99 @@
+package cmd_history
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bhojpur/iso/cmd/manager/util"
+	"github.com/bhojpur/iso/pkg/manager/installer"
+	"gopkg.in/yaml.v2"
+
+	"github.com/spf13/cobra"
+)
+
+func NewHistoryShowCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "show <id>",
+		Short: "Show a transaction applied to the system",
+		Long: `Show who ran a transaction, when, and the packages it added, removed or upgraded:
+
+		$ isomgr history show 3`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString("output")
+
+			system := util.NewSystem(util.DefaultContext.Config)
+			r, err := loadHistoryRecord(system, args[0])
+			if err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+
+			switch out {
+			case "json":
+				b, err := json.MarshalIndent(r, "", "  ")
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				fmt.Println(string(b))
+			case "yaml":
+				b, err := yaml.Marshal(r)
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				fmt.Println(string(b))
+			default:
+				fmt.Printf("Transaction: %d\n", r.ID)
+				fmt.Printf("Date: %s\n", r.Timestamp.Local().Format(time.RFC1123))
+				fmt.Printf("User: %s\n", r.User)
+				fmt.Printf("Command: %s\n", r.Command)
+				fmt.Printf("Operation: %s\n", r.Operation)
+				fmt.Printf("Outcome: %s\n", r.Outcome)
+				if r.Error != "" {
+					fmt.Printf("Error: %s\n", r.Error)
+				}
+				for _, p := range r.Changes.Added {
+					fmt.Printf("+ %s/%s-%s\n", p.Category, p.Name, p.Version)
+				}
+				for _, p := range r.Changes.Removed {
+					fmt.Printf("- %s/%s-%s\n", p.Category, p.Name, p.Version)
+				}
+				for _, p := range r.Changes.Changed {
+					fmt.Printf("~ %s/%s %s -> %s\n", p.Category, p.Name, p.From, p.To)
+				}
+			}
+		},
+	}
+	c.Flags().StringP("output", "o", "terminal", "Output format ( Defaults: terminal, available: json,yaml )")
+
+	return c
+}
+
+func loadHistoryRecord(s *installer.System, n string) (*installer.HistoryRecord, error) {
+	id, err := strconv.Atoi(n)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction '%s'", n)
+	}
+	return s.HistoryRecord(id)
+}
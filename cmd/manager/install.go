@@ -34,6 +34,10 @@ import (
 var installCmd = &cobra.Command{
 	Use:   "install <pkg1> <pkg2> ...",
 	Short: "Install a package",
+	// Skip processing output
+	Annotations: map[string]string{
+		util.CommandProcessOutput: "",
+	},
 	Long: `Installs one or more packages without asking questions:
 
 	$ isomgr install -y utils/busybox utils/yq ...
@@ -49,6 +53,10 @@ To not install deps of a package:
 To force install a package:
 	
 	$ isomgr install --force utils/busybox ...
+
+To display the changes as json, without applying them:
+
+	$ isomgr install --pretend -o json utils/busybox ...
 `,
 	Aliases: []string{"i"},
 	PreRun: func(cmd *cobra.Command, args []string) {
@@ -72,6 +80,7 @@ To force install a package:
 		nodeps := viper.GetBool("nodeps")
 		onlydeps := viper.GetBool("onlydeps")
 		yes := viper.GetBool("yes")
+		pretend, output := util.PlanFlags(cmd)
 		downloadOnly, _ := cmd.Flags().GetBool("download-only")
 		relax, _ := cmd.Flags().GetBool("relax")
 
@@ -85,20 +94,22 @@ To force install a package:
 			OnlyDeps:                    onlydeps,
 			PreserveSystemEssentialData: true,
 			DownloadOnly:                downloadOnly,
-			Ask:                         !yes,
+			Ask:                         !yes && !pretend,
+			Pretend:                     pretend,
 			Relaxed:                     relax,
 			PackageRepositories:         util.DefaultContext.Config.SystemRepositories,
 			Context:                     util.DefaultContext,
 		})
 
-		system := &installer.System{
-			Database: util.SystemDB(util.DefaultContext.Config),
-			Target:   util.DefaultContext.Config.System.Rootfs,
-		}
+		system := util.NewSystem(util.DefaultContext.Config)
 		err := inst.Install(toInstall, system)
 		if err != nil {
 			util.DefaultContext.Fatal("Error: " + err.Error())
 		}
+
+		if pretend {
+			util.PrintPlan(inst.Plan(), output)
+		}
 	},
 }
 
@@ -114,6 +125,7 @@ func init() {
 	installCmd.Flags().Bool("download-only", false, "Download only")
 	installCmd.Flags().StringArray("finalizer-env", []string{},
 		"Set finalizer environment in the format key=value.")
+	util.AddPlanFlags(installCmd)
 
 	RootCmd.AddCommand(installCmd)
 }
@@ -0,0 +1,51 @@
+package cmd
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	. "github.com/bhojpur/iso/cmd/manager/keys"
+
+	"github.com/spf13/cobra"
+)
+
+var keysGroupCmd = &cobra.Command{
+	Use:   "keys [command] [OPTIONS]",
+	Short: "Manage the keys trusted to sign repositories",
+	Long: `Repositories can be signed with ed25519 keys by "isomgr create-repo --sign-key". The
+signatures are checked on sync and on download against the public keys trusted by the
+repository, which are the ones listed in the "trusted_keys" field of its configuration
+and the ones added with "isomgr keys add", stored in the keys folder of the system database path.
+
+To rotate a key, sign the repository with both the old and the new key, trust the new key
+on the clients, then stop signing with the old key and remove it.
+`,
+}
+
+func init() {
+	RootCmd.AddCommand(keysGroupCmd)
+
+	keysGroupCmd.AddCommand(
+		NewKeysGenerateCommand(),
+		NewKeysAddCommand(),
+		NewKeysRemoveCommand(),
+		NewKeysListCommand(),
+	)
+}
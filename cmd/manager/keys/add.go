@@ -0,0 +1,60 @@
+package cmd_keys
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"github.com/bhojpur/iso/cmd/manager/util"
+	"github.com/bhojpur/iso/pkg/manager/helpers/sign"
+	installer "github.com/bhojpur/iso/pkg/manager/installer"
+
+	"github.com/spf13/cobra"
+)
+
+func NewKeysAddCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "add <repository> <public key file> ...",
+		Short: "Trust public keys to sign a repository",
+		Long: `Trust public keys to sign a repository. Once a repository trusts a key, its
+metadata has to be signed by one of its trusted keys:
+
+		$ isomgr keys add myrepo repo.pub`,
+		Args: cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, err := util.DefaultContext.Config.GetSystemRepository(args[0]); err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+
+			for _, f := range args[1:] {
+				pub, err := sign.ReadPublicKey(f)
+				if err != nil {
+					util.DefaultContext.Fatal("Invalid public key ", f, ": ", err.Error())
+				}
+				id, err := installer.AddTrustedKey(util.DefaultContext, args[0], pub)
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				util.DefaultContext.Info(":key: Repository", args[0], "trusts key", id)
+			}
+		},
+	}
+
+	return c
+}
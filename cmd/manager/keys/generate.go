@@ -0,0 +1,58 @@
+package cmd_keys
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+
+	"github.com/bhojpur/iso/cmd/manager/util"
+	"github.com/bhojpur/iso/pkg/manager/helpers/sign"
+
+	"github.com/spf13/cobra"
+)
+
+func NewKeysGenerateCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "generate <name>",
+		Short: "Generate a key pair to sign repositories",
+		Long: `Generate an ed25519 key pair: the private key is written to <name>.key, and
+the public key to trust on the clients to <name>.pub:
+
+		$ isomgr keys generate repo
+		$ isomgr create-repo --sign-key repo.key`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			pub, priv, err := sign.GenerateKey()
+			if err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+			if err := sign.WritePrivateKey(args[0]+".key", priv); err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+			if err := sign.WritePublicKey(args[0]+".pub", pub); err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+			util.DefaultContext.Info(fmt.Sprintf(":key: Generated key %s in %s.key and %s.pub", sign.KeyID(pub), args[0], args[0]))
+		},
+	}
+
+	return c
+}
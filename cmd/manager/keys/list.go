@@ -0,0 +1,92 @@
+package cmd_keys
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bhojpur/iso/cmd/manager/util"
+	installer "github.com/bhojpur/iso/pkg/manager/installer"
+	"gopkg.in/yaml.v2"
+
+	"github.com/spf13/cobra"
+)
+
+func NewKeysListCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "list [repository]",
+		Short: "List the keys trusted by the repositories",
+		Long: `List the keys trusted by all the repositories, or by the given one:
+
+		$ isomgr keys list
+		$ isomgr keys list myrepo`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString("output")
+
+			keys := []installer.TrustedKey{}
+			for _, repo := range util.DefaultContext.Config.SystemRepositories {
+				if len(args) > 0 && repo.Name != args[0] {
+					continue
+				}
+				repoKeys, err := installer.NewSystemRepository(repo).ListTrustedKeys(util.DefaultContext)
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				keys = append(keys, repoKeys...)
+			}
+
+			switch out {
+			case "json":
+				b, err := json.MarshalIndent(keys, "", "  ")
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				fmt.Println(string(b))
+			case "yaml":
+				b, err := yaml.Marshal(keys)
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				fmt.Println(string(b))
+			default:
+				if len(keys) == 0 {
+					util.DefaultContext.Info("No trusted keys")
+					return
+				}
+				t := &util.TableWriter{}
+				t.AppendRow([]string{"Repository", "Key ID", "Source"})
+				for _, k := range keys {
+					source := "isomgr keys add"
+					if k.Configured {
+						source = "configuration"
+					}
+					t.AppendRow([]string{k.Repository, k.ID, source})
+				}
+				t.Render()
+			}
+		},
+	}
+	c.Flags().StringP("output", "o", "terminal", "Output format ( Defaults: terminal, available: json,yaml )")
+
+	return c
+}
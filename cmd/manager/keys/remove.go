@@ -0,0 +1,50 @@
+package cmd_keys
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"github.com/bhojpur/iso/cmd/manager/util"
+	installer "github.com/bhojpur/iso/pkg/manager/installer"
+
+	"github.com/spf13/cobra"
+)
+
+func NewKeysRemoveCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "remove <repository> <key id> ...",
+		Short: "Revoke the trust in keys of a repository",
+		Long: `Revoke the trust in keys added with "isomgr keys add". The keys listed in the
+repository configuration have to be removed from it:
+
+		$ isomgr keys remove myrepo 1a2b3c4d5e6f7a8b`,
+		Args: cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, id := range args[1:] {
+				if err := installer.RemoveTrustedKey(util.DefaultContext, args[0], id); err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				util.DefaultContext.Info(":key: Repository", args[0], "doesn't trust key", id, "anymore")
+			}
+		},
+	}
+
+	return c
+}
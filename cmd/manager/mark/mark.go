@@ -0,0 +1,80 @@
+package cmd_mark
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	helpers "github.com/bhojpur/iso/cmd/manager/helpers"
+	"github.com/bhojpur/iso/cmd/manager/util"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+
+	"github.com/spf13/cobra"
+)
+
+func NewMarkExplicitCommand() *cobra.Command {
+	return newMarkCommand("explicit", types.InstallReasonExplicit,
+		"Mark packages as explicitly installed",
+		`Mark packages as explicitly installed, so they are not uninstalled by isomgr autoremove:
+
+		$ isomgr mark explicit system/foo`)
+}
+
+func NewMarkDependencyCommand() *cobra.Command {
+	c := newMarkCommand("dep", types.InstallReasonDependency,
+		"Mark packages as installed dependencies",
+		`Mark packages as installed dependencies, so they are uninstalled by isomgr autoremove
+when no explicitly installed package requires them:
+
+		$ isomgr mark dep system/foo`)
+	c.Aliases = []string{"dependency"}
+	return c
+}
+
+func newMarkCommand(use, reason, short, long string) *cobra.Command {
+	var c = &cobra.Command{
+		Use:   use + " <package> ...",
+		Short: short,
+		Long:  long,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			system := util.NewSystem(util.DefaultContext.Config)
+
+			for _, a := range args {
+				pack, err := helpers.ParsePackageStr(a)
+				if err != nil {
+					util.DefaultContext.Fatal("Invalid package string ", a, ": ", err.Error())
+				}
+
+				installed, err := system.Database.FindPackages(pack)
+				if err != nil || len(installed) == 0 {
+					util.DefaultContext.Fatal("Package ", a, " is not installed")
+				}
+				for _, p := range installed {
+					if err := system.SetInstallReason(p, reason); err != nil {
+						util.DefaultContext.Fatal("Error: " + err.Error())
+					}
+					util.DefaultContext.Info("Marked", p.HumanReadableString(), "as", reason)
+				}
+			}
+		},
+	}
+
+	return c
+}
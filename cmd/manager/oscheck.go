@@ -21,6 +21,7 @@ package cmd
 // THE SOFTWARE.
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -32,6 +33,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
 )
 
 var osCheckCmd = &cobra.Command{
@@ -44,6 +46,12 @@ var osCheckCmd = &cobra.Command{
 To reinstall packages in the list:
 	
 	$ isomgr oscheck --reinstall
+
+To also verify the checksum, the mode, the owner and the symlink target of the installed files:
+
+	$ isomgr oscheck --content
+
+Changes to config-protected files are reported apart, and don't require a reinstall.
 `,
 	Aliases: []string{"i"},
 	PreRun: func(cmd *cobra.Command, args []string) {
@@ -60,31 +68,36 @@ To reinstall packages in the list:
 
 		downloadOnly, _ := cmd.Flags().GetBool("download-only")
 
-		system := &installer.System{
-			Database: util.SystemDB(util.DefaultContext.Config),
-			Target:   util.DefaultContext.Config.System.Rootfs,
-		}
-		packs := system.OSCheck(util.DefaultContext)
-		if !util.DefaultContext.Config.General.Quiet {
-			if len(packs) == 0 {
-				util.DefaultContext.Success("All good!")
-				os.Exit(0)
+		system := util.NewSystem(util.DefaultContext.Config)
+
+		var packs types.Packages
+		content, _ := cmd.Flags().GetBool("content")
+		out, _ := cmd.Flags().GetString("output")
+		if content {
+			packs = checkContent(system, out)
+		} else {
+			packs = system.OSCheck(util.DefaultContext)
+			if !util.DefaultContext.Config.General.Quiet {
+				if len(packs) == 0 {
+					util.DefaultContext.Success("All good!")
+					os.Exit(0)
+				} else {
+					util.DefaultContext.Info("Following packages are missing files or are incomplete:")
+					for _, p := range packs {
+						util.DefaultContext.Info(p.HumanReadableString())
+					}
+				}
 			} else {
-				util.DefaultContext.Info("Following packages are missing files or are incomplete:")
+				var s []string
 				for _, p := range packs {
-					util.DefaultContext.Info(p.HumanReadableString())
+					s = append(s, p.HumanReadableString())
 				}
+				fmt.Println(strings.Join(s, " "))
 			}
-		} else {
-			var s []string
-			for _, p := range packs {
-				s = append(s, p.HumanReadableString())
-			}
-			fmt.Println(strings.Join(s, " "))
 		}
 
 		reinstall, _ := cmd.Flags().GetBool("reinstall")
-		if reinstall {
+		if reinstall && len(packs) > 0 {
 
 			// Strip version for reinstall
 			toInstall := types.Packages{}
@@ -117,9 +130,55 @@ To reinstall packages in the list:
 	},
 }
 
+// checkContent prints the report of the installed files which don't match
+// their packages, and returns the packages that need a reinstall
+func checkContent(system *installer.System, out string) (packs types.Packages) {
+	checks := system.OSCheckContent(util.DefaultContext)
+	for _, c := range checks {
+		if c.Broken() {
+			packs = append(packs, c.GetPackage())
+		}
+	}
+
+	switch out {
+	case "json":
+		b, err := json.MarshalIndent(checks, "", "  ")
+		if err != nil {
+			util.DefaultContext.Fatal("Error: " + err.Error())
+		}
+		fmt.Println(string(b))
+	case "yaml":
+		b, err := yaml.Marshal(checks)
+		if err != nil {
+			util.DefaultContext.Fatal("Error: " + err.Error())
+		}
+		fmt.Println(string(b))
+	default:
+		if len(checks) == 0 {
+			util.DefaultContext.Success("All good!")
+			return
+		}
+		for _, c := range checks {
+			util.DefaultContext.Info(c.Package)
+			if c.Unverified {
+				fmt.Println("  files metadata not recorded, only checking for missing files")
+			}
+			for _, f := range c.Files {
+				fmt.Println("  "+f.Status, f.Path, f.Detail)
+			}
+			for _, f := range c.ConfigProtected {
+				fmt.Println("  "+f.Status, "(config-protected)", f.Path, f.Detail)
+			}
+		}
+	}
+	return
+}
+
 func init() {
 
 	osCheckCmd.Flags().Bool("reinstall", false, "reinstall")
+	osCheckCmd.Flags().Bool("content", false, "Verify the content and the attributes of the installed files")
+	osCheckCmd.Flags().StringP("output", "o", "terminal", "Output format with --content ( Defaults: terminal, available: json,yaml )")
 
 	osCheckCmd.Flags().Bool("onlydeps", false, "Consider **only** package dependencies")
 	osCheckCmd.Flags().Bool("force", false, "Skip errors and keep going (potentially harmful)")
@@ -0,0 +1,48 @@
+package cmd
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	. "github.com/bhojpur/iso/cmd/manager/pin"
+
+	"github.com/spf13/cobra"
+)
+
+var pinGroupCmd = &cobra.Command{
+	Use:   "pin [command] [OPTIONS]",
+	Short: "Manage pinned packages",
+	Long: `Pinned packages are frozen at a version, or a version range, while the rest of the
+system is upgraded. Pins are enforced by the solver on install and upgrade, and are stored
+as files in the pins folder of the system database path, one for each package:
+
+	category: system
+	name: kernel
+	version: ">=5.10,<5.11"
+`,
+}
+
+func init() {
+	RootCmd.AddCommand(pinGroupCmd)
+
+	pinGroupCmd.AddCommand(
+		NewPinAddCommand(),
+		NewPinRemoveCommand(),
+		NewPinListCommand(),
+	)
+}
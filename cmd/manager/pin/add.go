@@ -0,0 +1,68 @@
+package cmd_pin
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	helpers "github.com/bhojpur/iso/cmd/manager/helpers"
+	"github.com/bhojpur/iso/cmd/manager/util"
+
+	"github.com/spf13/cobra"
+)
+
+func NewPinAddCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "add <package>[selector] ...",
+		Short: "Pin packages to a version or a version range",
+		Long: `Pin packages to a version or a version range, the solver won't pick any other version
+of the packages on install and upgrade:
+
+		$ isomgr pin add system/kernel@">=5.10,<5.11"
+		$ isomgr pin add "=system/glibc-2.33"
+
+Without a version, the package is pinned to the installed version:
+
+		$ isomgr pin add system/kernel`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			system := util.NewSystem(util.DefaultContext.Config)
+
+			for _, a := range args {
+				pack, err := helpers.ParsePackageStr(a)
+				if err != nil {
+					util.DefaultContext.Fatal("Invalid package string ", a, ": ", err.Error())
+				}
+
+				if pack.GetVersion() == ">=0" {
+					installed, err := system.Database.FindPackageVersions(pack)
+					if err != nil || len(installed) == 0 {
+						util.DefaultContext.Fatal("Package ", a, " is not installed, specify the version to pin it to")
+					}
+					pack.SetVersion(installed.Best(nil).GetVersion())
+				}
+
+				if err := system.AddPin(pack); err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				util.DefaultContext.Info(":pushpin: Pinned", pack.GetCategory()+"/"+pack.GetName(), "to version", pack.GetVersion())
+			}
+		},
+	}
+
+	return c
+}
@@ -0,0 +1,82 @@
+package cmd_pin
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bhojpur/iso/cmd/manager/util"
+	"gopkg.in/yaml.v2"
+
+	"github.com/spf13/cobra"
+)
+
+func NewPinListCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:   "list",
+		Short: "List the pinned packages",
+		Long: `List the pinned packages of the system:
+
+		$ isomgr pin list`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString("output")
+
+			system := util.NewSystem(util.DefaultContext.Config)
+			pins, err := system.Pins()
+			if err != nil {
+				util.DefaultContext.Fatal("Error: " + err.Error())
+			}
+
+			switch out {
+			case "json":
+				b, err := json.MarshalIndent(pins, "", "  ")
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				fmt.Println(string(b))
+			case "yaml":
+				b, err := yaml.Marshal(pins)
+				if err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				fmt.Println(string(b))
+			default:
+				if len(pins) == 0 {
+					util.DefaultContext.Info("No pinned packages")
+					return
+				}
+				t := &util.TableWriter{}
+				t.AppendRow([]string{"Package", "Version", "Installed"})
+				for _, p := range pins {
+					installed := ""
+					if vers, err := system.Database.FindPackageVersions(p.Package()); err == nil && len(vers) > 0 {
+						installed = vers.Best(nil).GetVersion()
+					}
+					t.AppendRow([]string{p.Category + "/" + p.Name, p.Version, installed})
+				}
+				t.Render()
+			}
+		},
+	}
+	c.Flags().StringP("output", "o", "terminal", "Output format ( Defaults: terminal, available: json,yaml )")
+
+	return c
+}
@@ -0,0 +1,55 @@
+package cmd_pin
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	helpers "github.com/bhojpur/iso/cmd/manager/helpers"
+	"github.com/bhojpur/iso/cmd/manager/util"
+
+	"github.com/spf13/cobra"
+)
+
+func NewPinRemoveCommand() *cobra.Command {
+	var c = &cobra.Command{
+		Use:     "remove <package> ...",
+		Short:   "Remove the pin of packages",
+		Aliases: []string{"rm"},
+		Long: `Remove the pin of packages, so they can be upgraded again:
+
+		$ isomgr pin remove system/kernel`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			system := util.NewSystem(util.DefaultContext.Config)
+
+			for _, a := range args {
+				pack, err := helpers.ParsePackageStr(a)
+				if err != nil {
+					util.DefaultContext.Fatal("Invalid package string ", a, ": ", err.Error())
+				}
+
+				if err := system.RemovePin(pack); err != nil {
+					util.DefaultContext.Fatal("Error: " + err.Error())
+				}
+				util.DefaultContext.Info("Removed pin of", pack.GetCategory()+"/"+pack.GetName())
+			}
+		},
+	}
+
+	return c
+}
@@ -54,10 +54,7 @@ It scans the target file system, and if finds a match with a package available i
 			Context:                     util.DefaultContext,
 		})
 
-		system := &installer.System{
-			Database: util.SystemDB(util.DefaultContext.Config),
-			Target:   util.DefaultContext.Config.System.Rootfs,
-		}
+		system := util.NewSystem(util.DefaultContext.Config)
 		err := inst.Reclaim(system)
 		if err != nil {
 			util.DefaultContext.Fatal("Error: " + err.Error())
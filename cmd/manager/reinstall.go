@@ -34,6 +34,10 @@ import (
 var reinstallCmd = &cobra.Command{
 	Use:   "reinstall <pkg1> <pkg2> <pkg3>",
 	Short: "reinstall a set of packages",
+	// Skip processing output
+	Annotations: map[string]string{
+		util.CommandProcessOutput: "",
+	},
 	Long: `Reinstall a group of packages in the system:
 
 	$ isomgr reinstall -y system/busybox shells/bash system/coreutils ...
@@ -52,6 +56,7 @@ var reinstallCmd = &cobra.Command{
 		force := viper.GetBool("force")
 		onlydeps := viper.GetBool("onlydeps")
 		yes := viper.GetBool("yes")
+		pretend, output := util.PlanFlags(cmd)
 
 		downloadOnly, _ := cmd.Flags().GetBool("download-only")
 		installed, _ := cmd.Flags().GetBool("installed")
@@ -65,13 +70,14 @@ var reinstallCmd = &cobra.Command{
 			Force:                       force,
 			OnlyDeps:                    onlydeps,
 			PreserveSystemEssentialData: true,
-			Ask:                         !yes,
+			Ask:                         !yes && !pretend,
+			Pretend:                     pretend,
 			DownloadOnly:                downloadOnly,
 			Context:                     util.DefaultContext,
 			PackageRepositories:         util.DefaultContext.Config.SystemRepositories,
 		})
 
-		system := &installer.System{Database: util.SystemDB(util.DefaultContext.Config), Target: util.DefaultContext.Config.System.Rootfs}
+		system := util.NewSystem(util.DefaultContext.Config)
 
 		if installed {
 			for _, p := range system.Database.World() {
@@ -95,6 +101,10 @@ var reinstallCmd = &cobra.Command{
 		if err != nil {
 			util.DefaultContext.Fatal("Error: " + err.Error())
 		}
+
+		if pretend {
+			util.PrintPlan(inst.Plan(), output)
+		}
 	},
 }
 
@@ -104,6 +114,7 @@ func init() {
 	reinstallCmd.Flags().Bool("installed", false, "Reinstall installed packages")
 	reinstallCmd.Flags().BoolP("yes", "y", false, "Don't ask questions")
 	reinstallCmd.Flags().Bool("download-only", false, "Download only")
+	util.AddPlanFlags(reinstallCmd)
 
 	RootCmd.AddCommand(reinstallCmd)
 }
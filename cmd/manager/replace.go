@@ -32,8 +32,12 @@ import (
 )
 
 var replaceCmd = &cobra.Command{
-	Use:     "replace <pkg1> <pkg2> --for <pkg3> --for <pkg4> ...",
-	Short:   "replace a set of packages",
+	Use:   "replace <pkg1> <pkg2> --for <pkg3> --for <pkg4> ...",
+	Short: "replace a set of packages",
+	// Skip processing output
+	Annotations: map[string]string{
+		util.CommandProcessOutput: "",
+	},
 	Aliases: []string{"r"},
 	Long: `Replaces one or a group of packages without asking questions:
 
@@ -56,6 +60,7 @@ var replaceCmd = &cobra.Command{
 		nodeps := viper.GetBool("nodeps")
 		onlydeps := viper.GetBool("onlydeps")
 		yes := viper.GetBool("yes")
+		pretend, output := util.PlanFlags(cmd)
 		downloadOnly, _ := cmd.Flags().GetBool("download-only")
 
 		for _, a := range args {
@@ -85,17 +90,22 @@ var replaceCmd = &cobra.Command{
 			Force:                       force,
 			OnlyDeps:                    onlydeps,
 			PreserveSystemEssentialData: true,
-			Ask:                         !yes,
+			Ask:                         !yes && !pretend,
+			Pretend:                     pretend,
 			DownloadOnly:                downloadOnly,
 			PackageRepositories:         util.DefaultContext.Config.SystemRepositories,
 			Context:                     util.DefaultContext,
 		})
 
-		system := &installer.System{Database: util.SystemDB(util.DefaultContext.Config), Target: util.DefaultContext.Config.System.Rootfs}
+		system := util.NewSystem(util.DefaultContext.Config)
 		err := inst.Swap(toUninstall, toAdd, system)
 		if err != nil {
 			util.DefaultContext.Fatal("Error: " + err.Error())
 		}
+
+		if pretend {
+			util.PrintPlan(inst.Plan(), output)
+		}
 	},
 }
 
@@ -107,6 +117,7 @@ func init() {
 	replaceCmd.Flags().BoolP("yes", "y", false, "Don't ask questions")
 	replaceCmd.Flags().StringSlice("for", []string{}, "Packages that has to be installed in place of others")
 	replaceCmd.Flags().Bool("download-only", false, "Download only")
+	util.AddPlanFlags(replaceCmd)
 
 	RootCmd.AddCommand(replaceCmd)
 }
@@ -38,6 +38,7 @@ func init() {
 		NewRepoAddCommand(),
 		NewRepoGetCommand(),
 		NewRepoListCommand(),
+		NewRepoSnapshotsCommand(),
 		NewRepoUpdateCommand(),
 	)
 }
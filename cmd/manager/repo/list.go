@@ -76,12 +76,18 @@ func NewRepoListCommand() *cobra.Command {
 					if repo.Cached {
 
 						r := installer.NewSystemRepository(repo)
-						localRepo, _ := r.ReadSpecFile(filepath.Join(repobasedir,
-							installer.REPOSITORY_SPECFILE))
+						specFile := installer.REPOSITORY_SPECFILE
+						if repo.Snapshot != "" {
+							specFile = installer.SnapshotIndexFile(repo.Snapshot)
+						}
+						localRepo, _ := r.ReadSpecFile(filepath.Join(repobasedir, specFile))
 						if localRepo != nil {
 							tsec, _ := strconv.ParseInt(localRepo.GetLastUpdate(), 10, 64)
 							repoRevision = pterm.LightRed(localRepo.GetRevision()) +
 								" - " + pterm.LightGreen(time.Unix(tsec, 0).String())
+							if repo.Snapshot != "" {
+								repoRevision += " (snapshot " + repo.Snapshot + ")"
+							}
 						}
 					}
 
@@ -0,0 +1,106 @@
+package cmd_repo
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bhojpur/iso/cmd/manager/util"
+	installer "github.com/bhojpur/iso/pkg/manager/installer"
+	"github.com/ghodss/yaml"
+
+	"github.com/spf13/cobra"
+)
+
+func NewRepoSnapshotsCommand() *cobra.Command {
+	var ans = &cobra.Command{
+		Use:   "snapshots [OPTIONS] name",
+		Short: "List the snapshots published in a repository.",
+		Example: `
+# List the snapshots of repo1
+$> isomgr repo snapshots repo1
+`,
+		Long: `List the snapshots published in a repository, from the oldest to the newest.
+
+A snapshot is created each time the repository is generated, with the ID given to
+"isomgr create-repo --snapshot-id" or the generation time. Pinning a repository to a
+snapshot with the snapshot field of its configuration holds the clients on that
+repository state until the field is changed:
+
+  name: "repo1"
+  type: "http"
+  snapshot: "20220101120000"
+  urls:
+    - "https://example.com/repo1"`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			o, _ := cmd.Flags().GetString("output")
+
+			repo, err := util.DefaultContext.Config.GetSystemRepository(args[0])
+			if err != nil {
+				util.DefaultContext.Fatal(err.Error())
+			}
+
+			snapshots, err := installer.NewSystemRepository(*repo).Snapshots(util.DefaultContext)
+			if err != nil {
+				util.DefaultContext.Fatal(err.Error())
+			}
+
+			switch strings.ToLower(o) {
+			case "json":
+				b, _ := json.Marshal(snapshots)
+				fmt.Println(string(b))
+			case "yaml":
+				b, _ := yaml.Marshal(snapshots)
+				fmt.Println(string(b))
+			default:
+				if repo.Snapshot != "" {
+					if _, ok := snapshots.Get(repo.Snapshot); !ok {
+						util.DefaultContext.Warning("The repository is pinned to the snapshot", repo.Snapshot, "which is not available")
+					}
+				}
+
+				t := &util.TableWriter{}
+				t.AppendRow([]string{"Snapshot", "Revision", "Last update", "Pinned"})
+				for _, s := range snapshots.Snapshots {
+					lastUpdate := s.LastUpdate
+					if tsec, err := strconv.ParseInt(s.LastUpdate, 10, 64); err == nil {
+						lastUpdate = time.Unix(tsec, 0).Format(time.RFC1123)
+					}
+					pinned := ""
+					if s.ID == repo.Snapshot {
+						pinned = "*"
+					}
+					t.AppendRow([]string{s.ID, strconv.Itoa(s.Revision), lastUpdate, pinned})
+				}
+				t.Render()
+			}
+		},
+	}
+
+	ans.Flags().StringP("output", "o", "terminal", "Output format ( Defaults: terminal, available: json,yaml )")
+
+	return ans
+}
@@ -21,7 +21,10 @@ package cmd_repo
 // THE SOFTWARE.
 
 import (
+	"time"
+
 	"github.com/bhojpur/iso/cmd/manager/util"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
 	installer "github.com/bhojpur/iso/pkg/manager/installer"
 
 	"github.com/spf13/cobra"
@@ -37,7 +40,17 @@ $> isomgr repo update
 
 # Update only repo1 and repo2
 $> isomgr repo update repo1 repo2
+
+# Report which repositories are stale, without updating them
+$> isomgr repo update --check
 `,
+		Long: `Update a specific cached repository or all cached repositories.
+
+The metadata of the repositories are refreshed automatically once their refresh_interval
+(24h by default) elapsed since the last sync. On automatic refreshes and with --force=false,
+the metadata are downloaded again only if they changed, with conditional requests for http
+repositories and by comparing the image digests for docker repositories. When a refresh
+fails and metadata of a previous sync are available, they are used in its place.`,
 		Aliases: []string{"up"},
 		PreRun: func(cmd *cobra.Command, args []string) {
 		},
@@ -45,6 +58,12 @@ $> isomgr repo update repo1 repo2
 
 			ignore, _ := cmd.Flags().GetBool("ignore-errors")
 			force, _ := cmd.Flags().GetBool("force")
+			check, _ := cmd.Flags().GetBool("check")
+
+			if check {
+				checkRepositories(args, ignore)
+				return
+			}
 
 			if len(args) > 0 {
 				for _, rname := range args {
@@ -78,6 +97,61 @@ $> isomgr repo update repo1 repo2
 
 	repoUpdate.Flags().BoolP("ignore-errors", "i", false, "Ignore errors on sync repositories.")
 	repoUpdate.Flags().BoolP("force", "f", true, "Force resync.")
+	repoUpdate.Flags().Bool("check", false, "Report which repositories are stale, without updating them.")
 
 	return repoUpdate
 }
+
+// checkRepositories reports the freshness of the given repositories, or of
+// all the cached and enabled ones
+func checkRepositories(names []string, ignore bool) {
+	repos := []types.BhojpurRepository{}
+	if len(names) > 0 {
+		for _, rname := range names {
+			repo, err := util.DefaultContext.Config.GetSystemRepository(rname)
+			if err != nil && !ignore {
+				util.DefaultContext.Fatal(err.Error())
+			} else if err != nil {
+				continue
+			}
+			repos = append(repos, *repo)
+		}
+	} else {
+		for _, repo := range util.DefaultContext.Config.SystemRepositories {
+			if repo.Cached && repo.Enable {
+				repos = append(repos, repo)
+			}
+		}
+	}
+
+	t := &util.TableWriter{}
+	t.AppendRow([]string{"Repository", "Last sync", "Refresh interval", "Status"})
+	for _, repo := range repos {
+		f, err := installer.NewSystemRepository(repo).CheckFreshness(util.DefaultContext)
+		if err != nil && !ignore {
+			util.DefaultContext.Fatal("Error on checking repository " + repo.Name + ": " + err.Error())
+		}
+
+		lastSync := "never"
+		if !f.LastSync.IsZero() {
+			lastSync = f.LastSync.Format(time.RFC1123)
+		}
+		var status string
+		switch {
+		case err != nil:
+			status = "check failed: " + err.Error()
+		case f.LastSync.IsZero():
+			status = "stale (never synced)"
+		case f.Changed:
+			status = "stale (changed remotely)"
+		case f.Expired:
+			status = "stale (refresh interval elapsed)"
+		case f.Checked:
+			status = "up to date"
+		default:
+			status = "up to date (not checked remotely)"
+		}
+		t.AppendRow([]string{repo.Name, lastSync, f.RefreshInterval.String(), status})
+	}
+	t.Render()
+}
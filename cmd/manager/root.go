@@ -90,6 +90,10 @@ To build a package, from a tree definition:
 
 		util.DisplayVersionBanner(util.DefaultContext, version, license)
 
+		if err := util.RecoverTransaction(util.DefaultContext); err != nil {
+			util.DefaultContext.Warning("failed recovering interrupted transaction:", err.Error())
+		}
+
 		viper.BindPFlag("plugin", cmd.Flags().Lookup("plugin"))
 
 		plugin := viper.GetStringSlice("plugin")
@@ -99,7 +99,7 @@ func sys() *installer.System {
 	if s != nil {
 		return s
 	}
-	s = &installer.System{Database: util.SystemDB(util.DefaultContext.Config), Target: util.DefaultContext.Config.System.Rootfs}
+	s = util.NewSystem(util.DefaultContext.Config)
 	return s
 }
 
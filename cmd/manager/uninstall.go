@@ -31,8 +31,12 @@ import (
 )
 
 var uninstallCmd = &cobra.Command{
-	Use:     "uninstall <pkg> <pkg2> ...",
-	Short:   "Uninstall a package or a list of packages",
+	Use:   "uninstall <pkg> <pkg2> ...",
+	Short: "Uninstall a package or a list of packages",
+	// Skip processing output
+	Annotations: map[string]string{
+		util.CommandProcessOutput: "",
+	},
 	Long:    `Uninstall packages`,
 	Aliases: []string{"rm", "un"},
 	PreRun: func(cmd *cobra.Command, args []string) {
@@ -58,6 +62,7 @@ var uninstallCmd = &cobra.Command{
 		checkconflicts, _ := cmd.Flags().GetBool("conflictscheck")
 		fullClean, _ := cmd.Flags().GetBool("full-clean")
 		yes := viper.GetBool("yes")
+		pretend, output := util.PlanFlags(cmd)
 		keepProtected, _ := cmd.Flags().GetBool("keep-protected-files")
 
 		util.DefaultContext.Config.ConfigProtectSkip = !keepProtected
@@ -74,16 +79,21 @@ var uninstallCmd = &cobra.Command{
 			FullUninstall:               full,
 			FullCleanUninstall:          fullClean,
 			CheckConflicts:              checkconflicts,
-			Ask:                         !yes,
+			Ask:                         !yes && !pretend,
+			Pretend:                     pretend,
 			PreserveSystemEssentialData: true,
 			Context:                     util.DefaultContext,
 		})
 
-		system := &installer.System{Database: util.SystemDB(util.DefaultContext.Config), Target: util.DefaultContext.Config.System.Rootfs}
+		system := util.NewSystem(util.DefaultContext.Config)
 
 		if err := inst.Uninstall(system, toRemove...); err != nil {
 			util.DefaultContext.Fatal("Error: " + err.Error())
 		}
+
+		if pretend {
+			util.PrintPlan(inst.Plan(), output)
+		}
 	},
 }
 
@@ -97,6 +107,7 @@ func init() {
 	uninstallCmd.Flags().Bool("solver-concurrent", false, "Use concurrent solver (experimental)")
 	uninstallCmd.Flags().BoolP("yes", "y", false, "Don't ask questions")
 	uninstallCmd.Flags().BoolP("keep-protected-files", "k", false, "Keep package protected files around")
+	util.AddPlanFlags(uninstallCmd)
 
 	RootCmd.AddCommand(uninstallCmd)
 }
@@ -30,8 +30,12 @@ import (
 )
 
 var upgradeCmd = &cobra.Command{
-	Use:     "upgrade",
-	Short:   "Upgrades the system",
+	Use:   "upgrade",
+	Short: "Upgrades the system",
+	// Skip processing output
+	Annotations: map[string]string{
+		util.CommandProcessOutput: "",
+	},
 	Aliases: []string{"u"},
 	PreRun: func(cmd *cobra.Command, args []string) {
 
@@ -50,6 +54,7 @@ var upgradeCmd = &cobra.Command{
 		osCheck, _ := cmd.Flags().GetBool("oscheck")
 
 		yes := viper.GetBool("yes")
+		pretend, output := util.PlanFlags(cmd)
 		downloadOnly, _ := cmd.Flags().GetBool("download-only")
 
 		util.DefaultContext.Config.Solver.Implementation = types.SolverSingleCoreSimple
@@ -66,17 +71,22 @@ var upgradeCmd = &cobra.Command{
 			RemoveUnavailableOnUpgrade:  clean,
 			UpgradeNewRevisions:         sync,
 			PreserveSystemEssentialData: true,
-			Ask:                         !yes,
+			Ask:                         !yes && !pretend,
+			Pretend:                     pretend,
 			AutoOSCheck:                 osCheck,
 			DownloadOnly:                downloadOnly,
 			PackageRepositories:         util.DefaultContext.Config.SystemRepositories,
 			Context:                     util.DefaultContext,
 		})
 
-		system := &installer.System{Database: util.SystemDB(util.DefaultContext.Config), Target: util.DefaultContext.Config.System.Rootfs}
+		system := util.NewSystem(util.DefaultContext.Config)
 		if err := inst.Upgrade(system); err != nil {
 			util.DefaultContext.Fatal("Error: " + err.Error())
 		}
+
+		if pretend {
+			util.PrintPlan(inst.Plan(), output)
+		}
 	},
 }
 
@@ -91,6 +101,7 @@ func init() {
 	upgradeCmd.Flags().BoolP("yes", "y", false, "Don't ask questions")
 	upgradeCmd.Flags().Bool("download-only", false, "Download only")
 	upgradeCmd.Flags().Bool("oscheck", false, "Perform automatically oschecks after upgrades")
+	util.AddPlanFlags(upgradeCmd)
 
 	RootCmd.AddCommand(upgradeCmd)
 }
@@ -23,6 +23,7 @@ package util
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/marcsauter/single"
@@ -70,6 +71,11 @@ func HandleLock() {
 
 	for _, lockedCmd := range lockedCommands {
 		if os.Args[1] == lockedCmd {
+			if os.Geteuid() != 0 {
+				// Unprivileged users get their own lock, as they can only
+				// operate on prefixes they own
+				single.Lockfile = filepath.Join(os.TempDir(), fmt.Sprintf("isomgr-%d.lock", os.Geteuid()))
+			}
 			s := single.New("isomgr")
 			if err := s.CheckLock(); err != nil && err == single.ErrAlreadyRunning {
 				fmt.Println("another instance of the app is already running, exiting")
@@ -228,6 +228,7 @@ func setDefaults(viper *viper.Viper) {
 	viper.SetDefault("general.show_build_output", true)
 	viper.SetDefault("general.fatal_warnings", false)
 	viper.SetDefault("general.http_timeout", 360)
+	viper.SetDefault("general.http_retries", 3)
 
 	u, err := user.Current()
 	// os/user doesn't work in from scratch environments
@@ -240,6 +241,7 @@ func setDefaults(viper *viper.Viper) {
 	viper.SetDefault("system.database_engine", "boltdb")
 	viper.SetDefault("system.database_path", "/var/cache/bhojpur")
 	viper.SetDefault("system.rootfs", "/")
+	viper.SetDefault("system.rootless", false)
 	viper.SetDefault("system.tmpdir_base", filepath.Join(os.TempDir(), "tmpiso"))
 	viper.SetDefault("system.pkgs_cache_path", "packages")
 
@@ -278,6 +280,7 @@ func InitViper(RootCmd *cobra.Command) {
 	pflags.String("system-dbpath", "", "System db path")
 	pflags.String("system-target", "", "System rootpath")
 	pflags.String("system-engine", "", "System DB engine")
+	pflags.Bool("rootless", false, "Install into the system target without root privileges")
 
 	pflags.String("solver-type", "", "Solver strategy ( Defaults none, available: "+solver.AvailableResolvers+" )")
 	pflags.Float32("solver-rate", 0.7, "Solver learning rate")
@@ -292,6 +295,7 @@ func InitViper(RootCmd *cobra.Command) {
 	viper.BindPFlag("system.database_path", pflags.Lookup("system-dbpath"))
 	viper.BindPFlag("system.rootfs", pflags.Lookup("system-target"))
 	viper.BindPFlag("system.database_engine", pflags.Lookup("system-engine"))
+	viper.BindPFlag("system.rootless", pflags.Lookup("rootless"))
 	viper.BindPFlag("solver.type", pflags.Lookup("solver-type"))
 	viper.BindPFlag("solver.discount", pflags.Lookup("solver-discount"))
 	viper.BindPFlag("solver.rate", pflags.Lookup("solver-rate"))
@@ -0,0 +1,111 @@
+package util
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/json"
+	"fmt"
+
+	units "github.com/docker/go-units"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	installer "github.com/bhojpur/iso/pkg/manager/installer"
+)
+
+// AddPlanFlags adds to a command the flags to display its plan instead of applying it.
+// The command needs the CommandProcessOutput annotation.
+func AddPlanFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("pretend", false, "Display the changes without applying them")
+	cmd.Flags().StringP("output", "o", "terminal", "Output format of --pretend ( Defaults: terminal, available: json,yaml )")
+}
+
+// PlanFlags returns the values of the flags added by AddPlanFlags
+func PlanFlags(cmd *cobra.Command) (pretend bool, output string) {
+	pretend, _ = cmd.Flags().GetBool("pretend")
+	output, _ = cmd.Flags().GetString("output")
+	if output != "terminal" && !pretend {
+		DefaultContext.Fatal("--output requires --pretend")
+	}
+	return
+}
+
+// PrintPlan displays the plan computed by an installer running with pretend,
+// as terminal output, json or yaml
+func PrintPlan(plan *installer.Plan, output string) {
+	switch output {
+	case "json":
+		b, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			DefaultContext.Fatal("Error: " + err.Error())
+		}
+		fmt.Println(string(b))
+	case "yaml":
+		b, err := yaml.Marshal(plan)
+		if err != nil {
+			DefaultContext.Fatal("Error: " + err.Error())
+		}
+		fmt.Println(string(b))
+	default:
+		if plan.Empty() {
+			DefaultContext.Info("Nothing to do")
+			return
+		}
+
+		removed := map[string]installer.PlanPackage{}
+		for _, p := range plan.Remove {
+			removed[p.Category+"/"+p.Name] = p
+		}
+		installed := map[string]bool{}
+
+		t := &TableWriter{}
+		t.AppendRow([]string{"Package", "Old version", "New version", "Repository", "Size"})
+		for _, p := range plan.Install {
+			size := ""
+			if p.Size > 0 {
+				size = units.HumanSize(float64(p.Size))
+			}
+			old := removed[p.Category+"/"+p.Name].Version
+			installed[p.Category+"/"+p.Name] = true
+			t.AppendRow([]string{p.Category + "/" + p.Name, pterm.LightRed(old), pterm.LightGreen(p.Version), p.Repository, size})
+		}
+		for _, p := range plan.Remove {
+			if !installed[p.Category+"/"+p.Name] {
+				t.AppendRow([]string{p.Category + "/" + p.Name, pterm.LightRed(p.Version), "", "", ""})
+			}
+		}
+		fmt.Println()
+		t.Render()
+		fmt.Println()
+
+		if len(plan.Finalizers) > 0 {
+			DefaultContext.Info("Finalizers that are going to run:")
+			l := &ListWriter{}
+			for _, f := range plan.Finalizers {
+				l.AppendItem(pterm.BulletListItem{Level: 0, Text: f})
+			}
+			l.Render()
+		}
+		DefaultContext.Info(fmt.Sprintf("%d to install, %d to remove, %s to download",
+			len(plan.Install), len(plan.Remove), units.HumanSize(float64(plan.DownloadSize))))
+	}
+}
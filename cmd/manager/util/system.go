@@ -23,8 +23,10 @@ package util
 import (
 	"path/filepath"
 
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
 	"github.com/bhojpur/iso/pkg/manager/api/core/types"
 	pkg "github.com/bhojpur/iso/pkg/manager/database"
+	"github.com/bhojpur/iso/pkg/manager/installer"
 )
 
 func SystemDB(c *types.BhojpurConfig) types.PackageDatabase {
@@ -36,3 +38,26 @@ func SystemDB(c *types.BhojpurConfig) types.PackageDatabase {
 		return pkg.NewInMemoryDatabase(true)
 	}
 }
+
+// NewSystem returns the installer system for the configured rootfs and database
+func NewSystem(c *types.BhojpurConfig) *installer.System {
+	return &installer.System{
+		Database:       SystemDB(c),
+		Target:         c.System.Rootfs,
+		JournalDir:     filepath.Join(c.System.DatabasePath, installer.TransactionJournalDir),
+		GenerationsDir: filepath.Join(c.System.DatabasePath, installer.GenerationsDir),
+		PinsDir:        filepath.Join(c.System.DatabasePath, installer.PinsDir),
+		KeepHistory:    true,
+		ProtectedDir:   filepath.Join(c.System.DatabasePath, installer.ProtectedFilesDir),
+	}
+}
+
+// RecoverTransaction rolls back the changes of an installer transaction
+// which was interrupted, if any
+func RecoverTransaction(ctx *context.Context) error {
+	s := NewSystem(ctx.Config)
+	if !s.HasPendingTransaction() {
+		return nil
+	}
+	return s.Recover(ctx)
+}
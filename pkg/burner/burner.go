@@ -91,7 +91,7 @@ func ensureDirs(path string) {
 	}
 }
 
-func prepareRootfs(s *schema.SystemSpec, fs vfs.FS, tempOverlayfs string) error {
+func prepareRootfs(s *schema.SystemSpec, fs vfs.FS, m *Manifest, tempOverlayfs string) error {
 	if s.RootfsImage != "" {
 		image := s.RootfsImage
 		if !strings.Contains(image, ":") {
@@ -111,9 +111,11 @@ func prepareRootfs(s *schema.SystemSpec, fs vfs.FS, tempOverlayfs string) error
 		}
 	} else if len(s.Packages.Rootfs) > 0 {
 		info(":steaming_bowl: Installing Bhojpur ISO packages")
-		if err := BhojpurInstall(tempOverlayfs, s.Packages.Rootfs, s.Repository.Packages, s.Packages.KeepBhojpurDB, fs, s); err != nil {
+		installed, err := BhojpurInstall(tempOverlayfs, s.Packages.Rootfs, s.Repository.Packages, s.Packages.KeepBhojpurDB, fs, s)
+		if err != nil {
 			return err
 		}
+		m.Stages[StageRootfs] = installed
 	}
 
 	if s.Overlay.Rootfs != "" {
@@ -129,14 +131,16 @@ func prepareRootfs(s *schema.SystemSpec, fs vfs.FS, tempOverlayfs string) error
 	return nil
 }
 
-func prepareUEFI(s *schema.SystemSpec, fs vfs.FS, tempISO, tempUEFI, kernelFile, initrdFile string) error {
+func prepareUEFI(s *schema.SystemSpec, fs vfs.FS, m *Manifest, tempISO, tempUEFI, kernelFile, initrdFile string) error {
 
 	if s.UEFIImage == "" {
 		// Generate efi image
 		info(":superhero: Installing EFI packages")
-		if err := BhojpurInstall(tempUEFI, s.Packages.UEFI, s.Repository.Packages, false, fs, s); err != nil {
+		installed, err := BhojpurInstall(tempUEFI, s.Packages.UEFI, s.Repository.Packages, false, fs, s)
+		if err != nil {
 			return err
 		}
+		m.Stages[StageUEFI] = installed
 
 		// FIXME this is a hack to keep backward compatibility. The Bhojpur ISO assumes
 		// systemd-boot for EFI boot when syslinux is being used. Systemd-boot is not capable to load
@@ -197,11 +201,13 @@ func prepareUEFI(s *schema.SystemSpec, fs vfs.FS, tempISO, tempUEFI, kernelFile,
 	return nil
 }
 
-func prepareISO(s *schema.SystemSpec, fs vfs.FS, tempISO, tempOverlayfs, kernelFile, initrdFile string) error {
+func prepareISO(s *schema.SystemSpec, fs vfs.FS, m *Manifest, tempISO, tempOverlayfs, kernelFile, initrdFile string) error {
 	info(":thinking:Populating ISO folder")
-	if err := BhojpurInstall(tempISO, s.Packages.IsoImage, s.Repository.Packages, false, fs, s); err != nil {
+	installed, err := BhojpurInstall(tempISO, s.Packages.IsoImage, s.Repository.Packages, false, fs, s)
+	if err != nil {
 		return err
 	}
+	m.Stages[StageIsoImage] = installed
 
 	info(":superhero:Copying BIOS kernels")
 	if err := utils.CopyFile(kernelFile, filepath.Join(tempISO, "boot", "kernel.xz"), fs); err != nil {
@@ -212,6 +218,11 @@ func prepareISO(s *schema.SystemSpec, fs vfs.FS, tempISO, tempOverlayfs, kernelF
 		return err
 	}
 
+	rootfs, _ := fs.RawPath(tempOverlayfs)
+	if m.Files, err = scanDir(rootfs); err != nil {
+		return errors.Wrap(err, "while listing rootfs files")
+	}
+
 	info(":tv:Create squashfs")
 	if err := CreateSquashfs(filepath.Join(tempISO, "rootfs.squashfs"), tempOverlayfs, s.SquashfsOptions, fs); err != nil {
 		return err
@@ -223,7 +234,13 @@ func prepareISO(s *schema.SystemSpec, fs vfs.FS, tempISO, tempOverlayfs, kernelF
 			return err
 		}
 	}
-	return nil
+
+	boot, _ := fs.RawPath(filepath.Join(tempISO, "boot"))
+	if m.Boot.Files, err = scanDir(boot); err != nil {
+		return errors.Wrap(err, "while listing boot files")
+	}
+
+	return WriteManifest(m, filepath.Join(tempISO, ManifestName), fs)
 }
 
 func Burn(s *schema.SystemSpec, fs vfs.FS) error {
@@ -257,19 +274,21 @@ func Burn(s *schema.SystemSpec, fs vfs.FS) error {
 		return err
 	}
 
+	m := NewManifest(s)
+
 	info(":steaming_bowl: Installing Overlay packages")
-	if err := prepareRootfs(s, fs, tempOverlayfs); err != nil {
+	if err := prepareRootfs(s, fs, m, tempOverlayfs); err != nil {
 		return err
 	}
 
 	kernelFile := filepath.Join(tempOverlayfs, "boot", s.Initramfs.KernelFile)
 	initrdFile := filepath.Join(tempOverlayfs, "boot", s.Initramfs.RootfsFile)
 
-	if err := prepareUEFI(s, fs, tempISO, tempUEFI, kernelFile, initrdFile); err != nil {
+	if err := prepareUEFI(s, fs, m, tempISO, tempUEFI, kernelFile, initrdFile); err != nil {
 		return err
 	}
 
-	if err := prepareISO(s, fs, tempISO, tempOverlayfs, kernelFile, initrdFile); err != nil {
+	if err := prepareISO(s, fs, m, tempISO, tempOverlayfs, kernelFile, initrdFile); err != nil {
 		return err
 	}
 
@@ -279,5 +298,9 @@ func Burn(s *schema.SystemSpec, fs vfs.FS) error {
 		fs.RemoveAll(s.ISOName())
 	}
 
-	return GenISO(s, tempISO, fs)
+	if err := GenISO(s, tempISO, fs); err != nil {
+		return err
+	}
+
+	return WriteManifest(m, s.ISOName()+ManifestSuffix, fs)
 }
@@ -0,0 +1,179 @@
+package burner
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"sort"
+
+	"github.com/bhojpur/iso/pkg/manager/helpers/pkgdiff"
+)
+
+// PackageChange is a package whose version differs between two manifests
+type PackageChange struct {
+	Category string `yaml:"category" json:"category"`
+	Name     string `yaml:"name" json:"name"`
+	From     string `yaml:"from" json:"from"`
+	To       string `yaml:"to" json:"to"`
+}
+
+// StageDiff lists the package changes of a single stage
+type StageDiff struct {
+	Added   []ManifestPackage `yaml:"added,omitempty" json:"added,omitempty"`
+	Removed []ManifestPackage `yaml:"removed,omitempty" json:"removed,omitempty"`
+	Changed []PackageChange   `yaml:"changed,omitempty" json:"changed,omitempty"`
+}
+
+// FileChange is a file whose content differs between two manifests
+type FileChange struct {
+	Path     string `yaml:"path" json:"path"`
+	FromSize int64  `yaml:"from_size" json:"from_size"`
+	ToSize   int64  `yaml:"to_size" json:"to_size"`
+}
+
+// FilesDiff lists the file level changes between two manifests
+type FilesDiff struct {
+	Added   []ManifestFile `yaml:"added,omitempty" json:"added,omitempty"`
+	Removed []ManifestFile `yaml:"removed,omitempty" json:"removed,omitempty"`
+	Changed []FileChange   `yaml:"changed,omitempty" json:"changed,omitempty"`
+}
+
+// BootChange is a boot setting which differs between two manifests
+type BootChange struct {
+	Field string `yaml:"field" json:"field"`
+	From  string `yaml:"from" json:"from"`
+	To    string `yaml:"to" json:"to"`
+}
+
+// ManifestDiff is the result of the comparison of two manifests
+type ManifestDiff struct {
+	Stages    map[string]*StageDiff `yaml:"stages,omitempty" json:"stages,omitempty"`
+	Files     FilesDiff             `yaml:"files" json:"files"`
+	Boot      []BootChange          `yaml:"boot,omitempty" json:"boot,omitempty"`
+	BootFiles FilesDiff             `yaml:"boot_files" json:"boot_files"`
+}
+
+// Empty returns true if the two compared manifests are equivalent
+func (d *ManifestDiff) Empty() bool {
+	return len(d.Stages) == 0 && len(d.Boot) == 0 && d.Files.empty() && d.BootFiles.empty()
+}
+
+func (d FilesDiff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares the manifest src against dst and reports the changes needed to go from the first to the second
+func Diff(src, dst *Manifest) *ManifestDiff {
+	res := &ManifestDiff{Stages: map[string]*StageDiff{}}
+
+	stages := map[string]interface{}{}
+	for s := range src.Stages {
+		stages[s] = nil
+	}
+	for s := range dst.Stages {
+		stages[s] = nil
+	}
+	for s := range stages {
+		if d := diffPackages(src.Stages[s], dst.Stages[s]); d != nil {
+			res.Stages[s] = d
+		}
+	}
+
+	res.Files = diffFiles(src.Files, dst.Files)
+	res.BootFiles = diffFiles(src.Boot.Files, dst.Boot.Files)
+
+	for _, f := range []struct{ name, from, to string }{
+		{"boot_file", src.Boot.BootFile, dst.Boot.BootFile},
+		{"boot_catalog", src.Boot.BootCatalog, dst.Boot.BootCatalog},
+		{"isohybrid_mbr", src.Boot.IsoHybridMBR, dst.Boot.IsoHybridMBR},
+		{"kernel_file", src.Boot.KernelFile, dst.Boot.KernelFile},
+		{"rootfs_file", src.Boot.RootfsFile, dst.Boot.RootfsFile},
+		{"label", src.Boot.Label, dst.Boot.Label},
+	} {
+		if f.from != f.to {
+			res.Boot = append(res.Boot, BootChange{Field: f.name, From: f.from, To: f.to})
+		}
+	}
+
+	return res
+}
+
+func diffPackages(src, dst []ManifestPackage) *StageDiff {
+	diff := pkgdiff.Compare(manifestPackages(src), manifestPackages(dst))
+
+	res := &StageDiff{}
+	for _, p := range diff.Added {
+		res.Added = append(res.Added, ManifestPackage(p))
+	}
+	for _, p := range diff.Removed {
+		res.Removed = append(res.Removed, ManifestPackage(p))
+	}
+	for _, c := range diff.Changed {
+		// Specs don't need to pin versions, compare them only when both are known
+		if c.From == "" || c.To == "" {
+			continue
+		}
+		res.Changed = append(res.Changed, PackageChange(c))
+	}
+
+	if len(res.Added) == 0 && len(res.Removed) == 0 && len(res.Changed) == 0 {
+		return nil
+	}
+	return res
+}
+
+func manifestPackages(packages []ManifestPackage) []pkgdiff.Package {
+	res := make([]pkgdiff.Package, len(packages))
+	for i, p := range packages {
+		res[i] = pkgdiff.Package(p)
+	}
+	return res
+}
+
+// diffFiles compares two file lists by content hash (or link target for symlinks)
+func diffFiles(src, dst []ManifestFile) (res FilesDiff) {
+	srcFiles, dstFiles := map[string]ManifestFile{}, map[string]ManifestFile{}
+	for _, f := range src {
+		srcFiles[f.Path] = f
+	}
+	for _, f := range dst {
+		dstFiles[f.Path] = f
+	}
+
+	for p, f := range dstFiles {
+		old, exists := srcFiles[p]
+		switch {
+		case !exists:
+			res.Added = append(res.Added, f)
+		case old.Sha256 != f.Sha256 || old.Link != f.Link:
+			res.Changed = append(res.Changed, FileChange{Path: p, FromSize: old.Size, ToSize: f.Size})
+		}
+	}
+	for p, f := range srcFiles {
+		if _, exists := dstFiles[p]; !exists {
+			res.Removed = append(res.Removed, f)
+		}
+	}
+
+	sortFiles(res.Added)
+	sortFiles(res.Removed)
+	sort.SliceStable(res.Changed, func(i, j int) bool { return res.Changed[i].Path < res.Changed[j].Path })
+	return
+}
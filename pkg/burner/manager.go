@@ -27,6 +27,7 @@ import (
 	"strings"
 
 	"github.com/bhojpur/iso/pkg/schema"
+	"github.com/pkg/errors"
 	"github.com/twpayne/go-vfs"
 )
 
@@ -63,33 +64,41 @@ func BhojpurImageUnpack(image, destination string) error {
 	return runEnv(fmt.Sprintf("isomgr util unpack %s %s", image, destination))
 }
 
-func BhojpurInstall(rootfs string, packages []string, repositories []string, keepDB bool, fs vfs.FS, spec *schema.SystemSpec) error {
+// BhojpurInstall installs the packages in the given rootfs and returns the
+// packages found in the Bhojpur ISO database afterwards
+func BhojpurInstall(rootfs string, packages []string, repositories []string, keepDB bool, fs vfs.FS, spec *schema.SystemSpec) ([]ManifestPackage, error) {
 	cfgFile := filepath.Join(rootfs, "iso.yaml")
 	cfgRaw, _ := fs.RawPath(cfgFile)
 
 	if err := copyConfig(cfgFile, rootfs, fs, spec); err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(repositories) > 0 {
 		if err := run(fmt.Sprintf("isomgr install --no-spinner --config %s %s", cfgRaw, strings.Join(repositories, " "))); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	if len(packages) > 0 {
 		if err := run(fmt.Sprintf("isomgr install --no-spinner --config %s %s", cfgRaw, strings.Join(packages, " "))); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	if err := run(fmt.Sprintf("isomgr --config %s cleanup", cfgRaw)); err != nil {
-		return err
+		return nil, err
+	}
+
+	dbRaw, _ := fs.RawPath(filepath.Join(rootfs, "isodb", "iso.db"))
+	installed, err := installedPackages(dbRaw)
+	if err != nil {
+		return nil, errors.Wrap(err, "while reading installed packages")
 	}
 
 	if keepDB {
 		if err := vfs.MkdirAll(fs, filepath.Join(rootfs, "var", "bhojpur"), os.ModePerm); err != nil {
-			return err
+			return nil, err
 		}
 		if _, err := fs.Stat(filepath.Join(rootfs, "var", "bhojpur", "db")); err == nil {
 			fs.RemoveAll(filepath.Join(rootfs, "var", "bhojpur", "db"))
@@ -100,5 +109,5 @@ func BhojpurInstall(rootfs string, packages []string, repositories []string, kee
 	}
 	fs.Remove(cfgFile)
 	fs.Remove(filepath.Join(rootfs, "bhojpur", "repos.conf.d"))
-	return nil
+	return installed, nil
 }
@@ -0,0 +1,367 @@
+package burner
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bhojpur/iso/pkg/manager/database"
+	"github.com/bhojpur/iso/pkg/schema"
+	"github.com/pkg/errors"
+	"github.com/twpayne/go-vfs"
+	"gopkg.in/yaml.v2"
+
+	diskfs "github.com/diskfs/go-diskfs"
+	"github.com/diskfs/go-diskfs/filesystem"
+	"github.com/diskfs/go-diskfs/filesystem/squashfs"
+)
+
+const (
+	// ManifestName is the name of the manifest embedded in the ISO root
+	ManifestName = "manifest.yaml"
+	// ManifestSuffix is appended to the ISO name for the manifest written next to it
+	ManifestSuffix = ".manifest.yaml"
+
+	StageRootfs   = "rootfs"
+	StageUEFI     = "uefi"
+	StageIsoImage = "isoimage"
+)
+
+// Manifest describes the content of an ISO: the packages installed in
+// each stage, the files shipped in the rootfs squashfs and the boot configuration.
+type Manifest struct {
+	Name   string                       `yaml:"name" json:"name"`
+	Stages map[string][]ManifestPackage `yaml:"stages" json:"stages"`
+	Files  []ManifestFile               `yaml:"files,omitempty" json:"files,omitempty"`
+	Boot   BootConfig                   `yaml:"boot" json:"boot"`
+}
+
+// ManifestPackage is a package installed in one of the ISO stages
+type ManifestPackage struct {
+	Category string `yaml:"category" json:"category"`
+	Name     string `yaml:"name" json:"name"`
+	Version  string `yaml:"version,omitempty" json:"version,omitempty"`
+}
+
+// ManifestFile is a file shipped in the ISO, identified by its content hash
+type ManifestFile struct {
+	Path   string `yaml:"path" json:"path"`
+	Size   int64  `yaml:"size" json:"size"`
+	Sha256 string `yaml:"sha256,omitempty" json:"sha256,omitempty"`
+	Link   string `yaml:"link,omitempty" json:"link,omitempty"`
+}
+
+// BootConfig holds the boot settings of the spec and the files found in the
+// boot folder of the ISO
+type BootConfig struct {
+	BootFile     string         `yaml:"boot_file" json:"boot_file"`
+	BootCatalog  string         `yaml:"boot_catalog" json:"boot_catalog"`
+	IsoHybridMBR string         `yaml:"isohybrid_mbr" json:"isohybrid_mbr"`
+	KernelFile   string         `yaml:"kernel_file" json:"kernel_file"`
+	RootfsFile   string         `yaml:"rootfs_file" json:"rootfs_file"`
+	Label        string         `yaml:"label" json:"label"`
+	Files        []ManifestFile `yaml:"files,omitempty" json:"files,omitempty"`
+}
+
+func (p ManifestPackage) key() string {
+	return fmt.Sprintf("%s/%s", p.Category, p.Name)
+}
+
+func (p ManifestPackage) String() string {
+	if p.Version == "" {
+		return p.key()
+	}
+	return fmt.Sprintf("%s@%s", p.key(), p.Version)
+}
+
+// NewManifest returns an empty manifest for the given spec
+func NewManifest(s *schema.SystemSpec) *Manifest {
+	return &Manifest{
+		Name:   s.ISOName(),
+		Stages: map[string][]ManifestPackage{},
+		Boot: BootConfig{
+			BootFile:     s.BootFile,
+			BootCatalog:  s.BootCatalog,
+			IsoHybridMBR: s.IsoHybridMBR,
+			KernelFile:   s.Initramfs.KernelFile,
+			RootfsFile:   s.Initramfs.RootfsFile,
+			Label:        s.Label,
+		},
+	}
+}
+
+// ManifestFromSpec computes a manifest from a spec file alone. Package
+// versions are the ones requested in the spec, and no file is listed.
+func ManifestFromSpec(s *schema.SystemSpec) *Manifest {
+	m := NewManifest(s)
+	stages := map[string][]string{
+		StageRootfs:   s.Packages.Rootfs,
+		StageUEFI:     s.Packages.UEFI,
+		StageIsoImage: s.Packages.IsoImage,
+	}
+	for stage, pkgs := range stages {
+		if len(pkgs) == 0 {
+			continue
+		}
+		for _, p := range append(append([]string{}, s.Repository.Packages...), pkgs...) {
+			m.Stages[stage] = append(m.Stages[stage], packageFromString(p))
+		}
+	}
+	return m
+}
+
+func packageFromString(s string) ManifestPackage {
+	p := ManifestPackage{}
+	if i := strings.Index(s, "@"); i >= 0 {
+		p.Version = s[i+1:]
+		s = s[:i]
+	}
+	if i := strings.Index(s, "/"); i >= 0 {
+		p.Category = s[:i]
+		s = s[i+1:]
+	}
+	p.Name = s
+	return p
+}
+
+// installedPackages reads the packages installed in the Bhojpur ISO database
+// of the given rootfs. A missing database means no package was installed.
+func installedPackages(dbPath string) ([]ManifestPackage, error) {
+	res := []ManifestPackage{}
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		return res, nil
+	} else if err != nil {
+		return nil, err
+	}
+	db := database.NewBoltDatabase(dbPath)
+
+	for _, p := range db.World() {
+		res = append(res, ManifestPackage{Category: p.GetCategory(), Name: p.GetName(), Version: p.GetVersion()})
+	}
+	sortPackages(res)
+	return res, nil
+}
+
+func sortPackages(p []ManifestPackage) {
+	sort.SliceStable(p, func(i, j int) bool { return p[i].key() < p[j].key() })
+}
+
+func sortFiles(f []ManifestFile) {
+	sort.SliceStable(f, func(i, j int) bool { return f[i].Path < f[j].Path })
+}
+
+func hashReader(r io.Reader) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), n, nil
+}
+
+// scanDir returns the files found in the given directory with their content hashes
+func scanDir(dir string) ([]ManifestFile, error) {
+	res := []ManifestFile{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		f := ManifestFile{Path: "/" + filepath.ToSlash(rel), Size: info.Size()}
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			f.Link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			fd, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer fd.Close()
+			if f.Sha256, _, err = hashReader(fd); err != nil {
+				return errors.Wrapf(err, "while hashing %s", path)
+			}
+		}
+		res = append(res, f)
+		return nil
+	})
+	sortFiles(res)
+	return res, err
+}
+
+// scanFS returns the regular files found in a go-diskfs filesystem with their content hashes
+func scanFS(fs filesystem.FileSystem, dir string) ([]ManifestFile, error) {
+	res := []ManifestFile{}
+	entries, err := fs.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while reading %s", dir)
+	}
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		if e.IsDir() {
+			files, err := scanFS(fs, p)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, files...)
+			continue
+		}
+		f := ManifestFile{Path: p, Size: e.Size()}
+		if e.Mode().IsRegular() {
+			fd, err := fs.OpenFile(p, os.O_RDONLY)
+			if err != nil {
+				return nil, errors.Wrapf(err, "while opening %s", p)
+			}
+			f.Sha256, f.Size, err = hashReader(fd)
+			fd.Close()
+			if err != nil {
+				return nil, errors.Wrapf(err, "while hashing %s", p)
+			}
+		}
+		res = append(res, f)
+	}
+	sortFiles(res)
+	return res, nil
+}
+
+// WriteManifest writes the manifest in YAML form to the given path
+func WriteManifest(m *Manifest, path string, fs vfs.FS) error {
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return fs.WriteFile(path, b, 0644)
+}
+
+// LoadManifest returns the manifest of an ISO image, a spec file or a manifest file.
+// ISO images are read from their embedded manifest, or from the manifest written
+// next to them. If none is available the files are read from the ISO itself.
+func LoadManifest(path string, fs vfs.FS) (*Manifest, error) {
+	if strings.HasSuffix(path, ".iso") {
+		return manifestFromISO(path, fs)
+	}
+
+	b, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, errors.Wrapf(err, "while reading %s", path)
+	}
+	if _, ok := raw["stages"]; ok {
+		m := &Manifest{}
+		if err := yaml.Unmarshal(b, m); err != nil {
+			return nil, errors.Wrapf(err, "while reading manifest %s", path)
+		}
+		return m, nil
+	}
+
+	spec, err := schema.LoadFromFile(path, fs)
+	if err != nil {
+		return nil, err
+	}
+	return ManifestFromSpec(spec), nil
+}
+
+func manifestFromISO(path string, fs vfs.FS) (*Manifest, error) {
+	raw, err := fs.RawPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := diskfs.OpenWithMode(raw, diskfs.ReadOnly)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while opening %s", path)
+	}
+	iso, err := d.GetFilesystem(0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while reading ISO filesystem of %s", path)
+	}
+
+	if f, err := iso.OpenFile("/"+ManifestName, os.O_RDONLY); err == nil {
+		defer f.Close()
+		b, err := ioutil.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		m := &Manifest{}
+		return m, yaml.Unmarshal(b, m)
+	}
+
+	if _, err := fs.Stat(path + ManifestSuffix); err == nil {
+		return LoadManifest(path+ManifestSuffix, fs)
+	}
+
+	m := &Manifest{Name: filepath.Base(path), Stages: map[string][]ManifestPackage{}}
+	m.Boot.Label = iso.Label()
+	if m.Boot.Files, err = scanFS(iso, "/boot"); err != nil {
+		return nil, err
+	}
+	if m.Files, err = squashfsFiles(iso); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// squashfsFiles extracts the rootfs squashfs from the ISO and lists its files
+func squashfsFiles(iso filesystem.FileSystem) ([]ManifestFile, error) {
+	src, err := iso.OpenFile("/rootfs.squashfs", os.O_RDONLY)
+	if err != nil {
+		return nil, errors.Wrap(err, "no rootfs.squashfs found in the ISO")
+	}
+	defer src.Close()
+
+	tmp, err := ioutil.TempFile("", "bhojpur-iso-squashfs")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, src)
+	if err != nil {
+		return nil, errors.Wrap(err, "while extracting rootfs.squashfs")
+	}
+
+	sqs, err := squashfs.Read(tmp, size, 0, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "while reading rootfs.squashfs")
+	}
+	return scanFS(sqs, "/")
+}
@@ -0,0 +1,172 @@
+package burner_test
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/bhojpur/iso/pkg/burner"
+	"github.com/bhojpur/iso/pkg/schema"
+	"github.com/twpayne/go-vfs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Manifest", func() {
+	Context("From spec", func() {
+		It("lists the requested packages of each stage", func() {
+			spec := &schema.SystemSpec{
+				ImageName: "test",
+				Label:     "TEST",
+				Repository: schema.Repository{
+					Packages: []string{"repository/bhojpur"},
+				},
+				Packages: schema.Packages{
+					Rootfs: []string{"system/kernel@5.10", "utils/busybox"},
+					UEFI:   []string{"live/grub2-efi-image"},
+				},
+			}
+
+			m := ManifestFromSpec(spec)
+			Expect(m.Name).To(Equal("test.iso"))
+			Expect(m.Boot.Label).To(Equal("TEST"))
+			Expect(m.Files).To(BeEmpty())
+			Expect(m.Stages).To(Equal(map[string][]ManifestPackage{
+				StageRootfs: {
+					{Category: "repository", Name: "bhojpur"},
+					{Category: "system", Name: "kernel", Version: "5.10"},
+					{Category: "utils", Name: "busybox"},
+				},
+				StageUEFI: {
+					{Category: "repository", Name: "bhojpur"},
+					{Category: "live", Name: "grub2-efi-image"},
+				},
+			}))
+		})
+	})
+
+	Context("Loading", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "manifest")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("loads manifest files", func() {
+			m := &Manifest{
+				Name:   "test.iso",
+				Stages: map[string][]ManifestPackage{StageRootfs: {{Category: "system", Name: "kernel", Version: "5.10"}}},
+				Files:  []ManifestFile{{Path: "/etc/hostname", Size: 5, Sha256: "abc"}},
+				Boot:   BootConfig{Label: "TEST"},
+			}
+			path := filepath.Join(dir, "test.iso"+ManifestSuffix)
+			Expect(WriteManifest(m, path, vfs.OSFS)).To(Succeed())
+
+			loaded, err := LoadManifest(path, vfs.OSFS)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loaded).To(Equal(m))
+		})
+
+		It("loads spec files", func() {
+			path := filepath.Join(dir, "iso.yaml")
+			Expect(ioutil.WriteFile(path, []byte(`
+image_name: test
+label: TEST
+packages:
+  rootfs:
+  - system/kernel@5.10
+`), os.ModePerm)).To(Succeed())
+
+			m, err := LoadManifest(path, vfs.OSFS)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m.Name).To(Equal("test.iso"))
+			Expect(m.Boot.BootFile).To(Equal("boot/syslinux/isolinux.bin"))
+			Expect(m.Stages).To(Equal(map[string][]ManifestPackage{
+				StageRootfs: {{Category: "system", Name: "kernel", Version: "5.10"}},
+			}))
+		})
+
+		It("fails on missing files", func() {
+			_, err := LoadManifest(filepath.Join(dir, "missing.yaml"), vfs.OSFS)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Diff", func() {
+		It("reports package, file and boot changes", func() {
+			src := &Manifest{
+				Stages: map[string][]ManifestPackage{StageRootfs: {
+					{Category: "system", Name: "kernel", Version: "5.10"},
+					{Category: "utils", Name: "busybox", Version: "1.33"},
+				}},
+				Files: []ManifestFile{
+					{Path: "/etc/hostname", Size: 5, Sha256: "abc"},
+					{Path: "/etc/issue", Size: 3, Sha256: "def"},
+				},
+				Boot: BootConfig{Label: "OLD"},
+			}
+			dst := &Manifest{
+				Stages: map[string][]ManifestPackage{StageRootfs: {
+					{Category: "system", Name: "kernel", Version: "5.15"},
+					{Category: "utils", Name: "curl", Version: "7.80"},
+				}},
+				Files: []ManifestFile{
+					{Path: "/etc/hostname", Size: 6, Sha256: "abd"},
+					{Path: "/etc/motd", Size: 1, Sha256: "ghi"},
+				},
+				Boot: BootConfig{Label: "NEW"},
+			}
+
+			d := Diff(src, dst)
+			Expect(d.Empty()).To(BeFalse())
+			Expect(d.Stages).To(Equal(map[string]*StageDiff{
+				StageRootfs: {
+					Added:   []ManifestPackage{{Category: "utils", Name: "curl", Version: "7.80"}},
+					Removed: []ManifestPackage{{Category: "utils", Name: "busybox", Version: "1.33"}},
+					Changed: []PackageChange{{Category: "system", Name: "kernel", From: "5.10", To: "5.15"}},
+				},
+			}))
+			Expect(d.Files.Added).To(Equal([]ManifestFile{{Path: "/etc/motd", Size: 1, Sha256: "ghi"}}))
+			Expect(d.Files.Removed).To(Equal([]ManifestFile{{Path: "/etc/issue", Size: 3, Sha256: "def"}}))
+			Expect(d.Files.Changed).To(Equal([]FileChange{{Path: "/etc/hostname", FromSize: 5, ToSize: 6}}))
+			Expect(d.Boot).To(Equal([]BootChange{{Field: "label", From: "OLD", To: "NEW"}}))
+
+			Expect(Diff(src, src).Empty()).To(BeTrue())
+		})
+
+		It("doesn't compare versions missing on one side", func() {
+			spec := &Manifest{Stages: map[string][]ManifestPackage{StageRootfs: {{Category: "system", Name: "kernel"}}}}
+			built := &Manifest{Stages: map[string][]ManifestPackage{StageRootfs: {{Category: "system", Name: "kernel", Version: "5.10"}}}}
+
+			Expect(Diff(spec, built).Empty()).To(BeTrue())
+			Expect(Diff(built, spec).Empty()).To(BeTrue())
+		})
+	})
+})
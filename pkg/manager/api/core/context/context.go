@@ -125,7 +125,11 @@ func (c *Context) WithLoggingContext(name string) types.Context {
 	ctx := *c
 	ctxCopy := &ctx
 	ctxCopy.Config = &configCopy
-	ctxCopy.annotations = ctx.annotations
+	// Annotations set on the copy don't leak to the original context
+	ctxCopy.annotations = make(map[string]interface{}, len(c.annotations))
+	for k, v := range c.annotations {
+		ctxCopy.annotations[k] = v
+	}
 
 	ctxCopy.Logger, _ = c.Logger.Copy(logger.WithContext(name))
 
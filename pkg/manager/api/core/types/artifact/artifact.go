@@ -24,7 +24,9 @@ import (
 	"archive/tar"
 	"bufio"
 	"bytes"
+	"crypto/ed25519"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -51,6 +53,7 @@ import (
 	compilerspec "github.com/bhojpur/iso/pkg/manager/compiler/types/spec"
 	"github.com/bhojpur/iso/pkg/manager/helpers"
 	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
+	"github.com/bhojpur/iso/pkg/manager/helpers/sign"
 	containerdCompression "github.com/containerd/containerd/archive/compression"
 
 	"github.com/pkg/errors"
@@ -71,6 +74,11 @@ type PackageArtifact struct {
 	Files             []string                             `json:"files"`
 	PackageCacheImage string                               `json:"package_cacheimage"`
 	Runtime           *types.Package                       `json:"runtime,omitempty"`
+	Deltas            []ArtifactDelta                      `json:"deltas,omitempty" yaml:"deltas,omitempty"`
+	// Size is the size in bytes of the artifact file
+	Size int64 `json:"size,omitempty" yaml:"size,omitempty"`
+	// Signatures of the artifact checksums, see SignaturePayload
+	Signatures sign.Signatures `json:"signatures,omitempty" yaml:"signatures,omitempty"`
 }
 
 func ImageToArtifact(ctx types.Context, img v1.Image, t compression.Implementation, output string, filter func(h *tar.Header) (bool, error)) (*PackageArtifact, error) {
@@ -109,6 +117,9 @@ func NewPackageArtifactFromYaml(data []byte) (*PackageArtifact, error) {
 }
 
 func (a *PackageArtifact) Hash() error {
+	if fi, err := os.Stat(a.Path); err == nil {
+		a.Size = fi.Size()
+	}
 	return a.Checksums.Generate(a)
 }
 
@@ -125,6 +136,35 @@ func (a *PackageArtifact) Verify() error {
 	return nil
 }
 
+// SignaturePayload returns the content signed by the artifact signatures: the
+// package fingerprint and the artifact checksums, which Verify checks against the file
+func (a *PackageArtifact) SignaturePayload() []byte {
+	payload := ""
+	if a.CompileSpec != nil && a.CompileSpec.Package != nil {
+		payload = a.CompileSpec.Package.GetFingerPrint() + "\n"
+	}
+	for _, c := range a.Checksums.List() {
+		payload += c[0] + ":" + c[1] + "\n"
+	}
+	return []byte(payload)
+}
+
+// Sign signs the artifact checksums with the given keys, replacing
+// any previous signature
+func (a *PackageArtifact) Sign(keys ...ed25519.PrivateKey) error {
+	if len(a.Checksums) == 0 {
+		return errors.New("artifact without checksums can't be signed")
+	}
+	a.Signatures = sign.Sign(a.SignaturePayload(), keys...)
+	return nil
+}
+
+// VerifySignatures checks that the artifact checksums are signed by
+// at least one of the keys of the keyring
+func (a *PackageArtifact) VerifySignatures(k sign.Keyring) error {
+	return k.Verify(a.SignaturePayload(), a.Signatures)
+}
+
 func (a *PackageArtifact) WriteYAML(dst string) error {
 	// First compute checksum of artifact. When we write the yaml we want to write up-to-date informations.
 	err := a.Hash()
@@ -237,85 +277,69 @@ func (a *PackageArtifact) GenerateFinalImage(ctx types.Context, imageName string
 // and a concurrency parameter.
 func (a *PackageArtifact) Compress(src string, concurrency int) error {
 	switch a.CompressionType {
-
-	case compression.Zstandard:
+	case compression.Zstandard, compression.GZip:
 		err := helpers.Tar(src, a.Path)
 		if err != nil {
 			return err
 		}
-		original, err := os.Open(a.Path)
-		if err != nil {
-			return err
-		}
-		defer original.Close()
+		return a.CompressTarball(concurrency)
 
-		zstdFile := a.getCompressedName()
-		bufferedReader := bufio.NewReader(original)
-
-		// Open a file for writing.
-		dst, err := os.Create(zstdFile)
-		if err != nil {
-			return err
-		}
-
-		enc, err := zstd.NewWriter(dst)
-		if err != nil {
-			return err
-		}
-		_, err = io.Copy(enc, bufferedReader)
-		if err != nil {
-			enc.Close()
-			return err
-		}
-		if err := enc.Close(); err != nil {
-			return err
-		}
-
-		os.RemoveAll(a.Path) // Remove original
-		//	Debug("Removed artifact", a.Path)
+	// Defaults to tar only (covers when "none" is supplied)
+	default:
+		return helpers.Tar(src, a.getCompressedName())
+	}
+}
 
-		a.Path = zstdFile
+// CompressTarball compresses the tarball at the artifact Path according to the
+// artifact compression type, and points the Path to the compressed file.
+// The same tarball always gives the same compressed file, so artifacts
+// rebuilt from their tarball match the checksums of the published ones.
+func (a *PackageArtifact) CompressTarball(concurrency int) error {
+	compressed := a.getCompressedName()
+	if compressed == a.Path {
 		return nil
-	case compression.GZip:
-		err := helpers.Tar(src, a.Path)
-		if err != nil {
-			return err
-		}
-		original, err := os.Open(a.Path)
-		if err != nil {
-			return err
-		}
-		defer original.Close()
+	}
 
-		gzipfile := a.getCompressedName()
-		bufferedReader := bufio.NewReader(original)
+	original, err := os.Open(a.Path)
+	if err != nil {
+		return err
+	}
+	defer original.Close()
+	bufferedReader := bufio.NewReader(original)
+
+	// Open a file for writing.
+	dst, err := os.Create(compressed)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
 
-		// Open a file for writing.
-		dst, err := os.Create(gzipfile)
+	var w io.WriteCloser
+	switch a.CompressionType {
+	case compression.Zstandard:
+		enc, err := zstd.NewWriter(dst)
 		if err != nil {
 			return err
 		}
+		w = enc
+	case compression.GZip:
 		// Create gzip writer.
-		w := gzip.NewWriter(dst)
-		w.SetConcurrency(1<<20, concurrency)
-		defer w.Close()
-		defer dst.Close()
-		_, err = io.Copy(w, bufferedReader)
-		if err != nil {
-			return err
-		}
-		w.Close()
-		os.RemoveAll(a.Path) // Remove original
-		//	Debug("Removed artifact", a.Path)
-		//	a.CompressedPath = gzipfile
-		a.Path = gzipfile
-		return nil
-		//a.Path = gzipfile
+		enc := gzip.NewWriter(dst)
+		enc.SetConcurrency(1<<20, concurrency)
+		w = enc
+	}
 
-	// Defaults to tar only (covers when "none" is supplied)
-	default:
-		return helpers.Tar(src, a.getCompressedName())
+	if _, err := io.Copy(w, bufferedReader); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
 	}
+
+	os.RemoveAll(a.Path) // Remove original
+	a.Path = compressed
+	return nil
 }
 
 func (a *PackageArtifact) getCompressedName() string {
@@ -432,6 +456,69 @@ func replaceFileTarWrapper(dst string, inputTarStream io.ReadCloser, mods []stri
 	return pipeReader
 }
 
+// rootlessTarWrapper rewrites the entries of a tar stream so they can be unpacked without
+// privileges: files are owned by the current user, device nodes are replaced by empty files
+// and the extended attributes outside of the user namespace are dropped
+func rootlessTarWrapper(ctx types.Context, inputTarStream io.ReadCloser) io.ReadCloser {
+	pipeReader, pipeWriter := io.Pipe()
+	uid, gid := os.Geteuid(), os.Getegid()
+
+	go func() {
+		tarReader := tar.NewReader(inputTarStream)
+		tarWriter := tar.NewWriter(pipeWriter)
+		defer inputTarStream.Close()
+
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				pipeWriter.CloseWithError(err)
+				return
+			}
+
+			header.Uid, header.Gid = uid, gid
+			header.Uname, header.Gname = "", ""
+			if header.Typeflag == tar.TypeChar || header.Typeflag == tar.TypeBlock {
+				ctx.Debug("Rootless: replacing device node", header.Name, "with an empty file")
+				header.Typeflag = tar.TypeReg
+				header.Mode &= 07777
+				header.Devmajor, header.Devminor = 0, 0
+				header.Size = 0
+			}
+			for k := range header.PAXRecords {
+				if strings.HasPrefix(k, "SCHILY.xattr.") && !strings.HasPrefix(k, "SCHILY.xattr.user.") {
+					delete(header.PAXRecords, k)
+				}
+			}
+			for k := range header.Xattrs {
+				if !strings.HasPrefix(k, "user.") {
+					delete(header.Xattrs, k)
+				}
+			}
+
+			if err := tarWriter.WriteHeader(header); err != nil {
+				pipeWriter.CloseWithError(err)
+				return
+			}
+			if header.Size > 0 {
+				if _, err := pools.Copy(tarWriter, tarReader); err != nil {
+					pipeWriter.CloseWithError(err)
+					return
+				}
+			}
+		}
+
+		if err := tarWriter.Close(); err != nil {
+			pipeWriter.CloseWithError(err)
+			return
+		}
+		pipeWriter.Close()
+	}()
+	return pipeReader
+}
+
 func tarModifierWrapperFunc(ctx types.Context) func(dst, path string, header *tar.Header, content io.Reader) (*tar.Header, []byte, error) {
 	return func(dst, path string, header *tar.Header, content io.Reader) (*tar.Header, []byte, error) {
 		// If the destination path already exists I rename target file name with postfix.
@@ -480,7 +567,7 @@ func tarModifierWrapperFunc(ctx types.Context) func(dst, path string, header *ta
 					name := filepath.Join(filepath.Join(filepath.Dir(path),
 						fmt.Sprintf("._cfg%04d_%s", i, filepath.Base(path))))
 
-					if fileHelper.Exists(name) {
+					if fileHelper.Exists(filepath.Join(dst, name)) {
 						continue
 					}
 					ctx.Info(fmt.Sprintf("Found protected file %s. Creating %s.", destPath,
@@ -544,6 +631,9 @@ func (a *PackageArtifact) Unpack(ctx types.Context, dst string, keepPerms bool)
 	}
 
 	replacerArchive := replaceFileTarWrapper(dst, decompressed, protectedFiles, mod)
+	if ctx.GetConfig().System.Rootless {
+		replacerArchive = rootlessTarWrapper(ctx, replacerArchive)
+	}
 
 	// or with filter?
 	// func(header *tar.Header) (bool, error) {
@@ -601,3 +691,50 @@ func (a *PackageArtifact) FileList() ([]string, error) {
 	}
 	return files, nil
 }
+
+// FileMetadata returns the checksum and the attributes of the files in the artifact,
+// in the same order as FileList
+func (a *PackageArtifact) FileMetadata() ([]types.FileMetadata, error) {
+	var res []types.FileMetadata
+
+	archiveFile, err := os.Open(a.Path)
+	if err != nil {
+		return res, errors.Wrap(err, "Cannot open "+a.Path)
+	}
+	defer archiveFile.Close()
+
+	decompressed, err := containerdCompression.DecompressStream(archiveFile)
+	if err != nil {
+		return res, errors.Wrap(err, "Cannot open "+a.Path)
+	}
+	defer decompressed.Close()
+	tr := tar.NewReader(decompressed)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return []types.FileMetadata{}, err
+		}
+		finfo := hdr.FileInfo()
+		if finfo.Mode().IsDir() {
+			continue
+		}
+
+		m := types.FileMetadata{Path: hdr.Name, Mode: finfo.Mode(), Uid: hdr.Uid, Gid: hdr.Gid}
+		switch {
+		case hdr.Typeflag == tar.TypeSymlink || hdr.Typeflag == tar.TypeLink:
+			m.Link = hdr.Linkname
+		case finfo.Mode().IsRegular():
+			h := sha256.New()
+			if _, err := io.Copy(h, tr); err != nil {
+				return []types.FileMetadata{}, errors.Wrapf(err, "while reading %s from %s", hdr.Name, a.Path)
+			}
+			m.Sha256 = fmt.Sprintf("%x", h.Sum(nil))
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
@@ -21,9 +21,11 @@ package artifact_test
 // THE SOFTWARE.
 
 import (
+	"archive/tar"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"syscall"
 
 	"github.com/bhojpur/iso/pkg/manager/api/core/types"
 
@@ -222,6 +224,81 @@ RUN echo bar > /test2`))
 			Expect(fileHelper.DirectoryIsEmpty(result)).To(BeTrue())
 		})
 
+		It("Reads the files metadata", func() {
+			tmpdir, err := ioutil.TempDir(os.TempDir(), "artifact")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(tmpdir) // clean up
+
+			tmpWork, err := ioutil.TempDir(os.TempDir(), "artifact2")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(tmpWork) // clean up
+
+			Expect(os.MkdirAll(filepath.Join(tmpdir, "bin"), os.ModePerm)).ToNot(HaveOccurred())
+			Expect(ioutil.WriteFile(filepath.Join(tmpdir, "bin", "test"), []byte("test"), 0755)).ToNot(HaveOccurred())
+			Expect(os.Symlink("bin/test", filepath.Join(tmpdir, "test"))).ToNot(HaveOccurred())
+
+			a := NewPackageArtifact(filepath.Join(tmpWork, "fake.tar"))
+			Expect(a.Compress(tmpdir, 1)).ToNot(HaveOccurred())
+
+			files, err := a.FileList()
+			Expect(err).ToNot(HaveOccurred())
+			metadata, err := a.FileMetadata()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(metadata)).To(Equal(len(files)))
+			Expect(len(metadata)).To(Equal(2))
+
+			for _, m := range metadata {
+				switch m.Path {
+				case "bin/test":
+					Expect(m.Sha256).To(Equal("9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"))
+					Expect(m.Mode.Perm()).To(Equal(os.FileMode(0755)))
+					Expect(m.Link).To(BeEmpty())
+				case "test":
+					Expect(m.Mode & os.ModeSymlink).ToNot(BeZero())
+					Expect(m.Link).To(Equal("bin/test"))
+					Expect(m.Sha256).To(BeEmpty())
+				default:
+					Fail("unexpected file " + m.Path)
+				}
+			}
+		})
+
+		It("Unpacks without privileges in rootless mode", func() {
+			tmpWork, err := ioutil.TempDir(os.TempDir(), "artifact")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(tmpWork) // clean up
+
+			f, err := os.Create(filepath.Join(tmpWork, "rootless.tar"))
+			Expect(err).ToNot(HaveOccurred())
+			tw := tar.NewWriter(f)
+			Expect(tw.WriteHeader(&tar.Header{Name: "etc/", Mode: 0755, Uid: 1234, Gid: 1234, Typeflag: tar.TypeDir})).ToNot(HaveOccurred())
+			Expect(tw.WriteHeader(&tar.Header{Name: "dev/", Mode: 0755, Typeflag: tar.TypeDir})).ToNot(HaveOccurred())
+			Expect(tw.WriteHeader(&tar.Header{Name: "etc/foo", Mode: 0644, Size: 4, Uid: 1234, Gid: 1234, Typeflag: tar.TypeReg})).ToNot(HaveOccurred())
+			_, err = tw.Write([]byte("test"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tw.WriteHeader(&tar.Header{Name: "dev/null", Mode: 0666, Typeflag: tar.TypeChar, Devmajor: 1, Devminor: 3})).ToNot(HaveOccurred())
+			Expect(tw.Close()).ToNot(HaveOccurred())
+			Expect(f.Close()).ToNot(HaveOccurred())
+
+			ctx := context.NewContext()
+			ctx.Config.System.Rootless = true
+
+			result := filepath.Join(tmpWork, "result")
+			Expect(os.MkdirAll(result, os.ModePerm)).ToNot(HaveOccurred())
+			a := NewPackageArtifact(filepath.Join(tmpWork, "rootless.tar"))
+			Expect(a.Unpack(ctx, result, false)).ToNot(HaveOccurred())
+
+			info, err := os.Lstat(filepath.Join(result, "etc", "foo"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(int(info.Sys().(*syscall.Stat_t).Uid)).To(Equal(os.Geteuid()))
+			Expect(fileHelper.Read(filepath.Join(result, "etc", "foo"))).To(Equal("test"))
+
+			info, err = os.Lstat(filepath.Join(result, "dev", "null"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Mode().IsRegular()).To(BeTrue())
+			Expect(info.Size()).To(BeZero())
+		})
+
 		It("Retrieves uncompressed name", func() {
 			a := NewPackageArtifact("foo.tar.gz")
 			a.CompressionType = (compression.GZip)
@@ -22,20 +22,49 @@ package artifact
 
 import (
 	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
 
 	"github.com/pkg/errors"
 	"github.com/rancher-sandbox/gofilecache"
+	"gopkg.in/yaml.v3"
 )
 
+// cacheIndexDir is the directory of the cache holding the metadata of the cached artifacts
+const cacheIndexDir = "index"
+
 type ArtifactCache struct {
 	gofilecache.Cache
+	dir string
+}
+
+// CacheEntry is an artifact stored in the cache. The Artifact path
+// points to the cached file.
+type CacheEntry struct {
+	ID       string
+	Artifact *PackageArtifact
+	Size     int64
+	LastUsed time.Time
+
+	output string
+}
+
+// cacheIndexEntry is the metadata stored for each artifact put in the cache
+type cacheIndexEntry struct {
+	Output   string           `yaml:"output"`
+	Artifact *PackageArtifact `yaml:"artifact"`
 }
 
 func NewCache(dir string) *ArtifactCache {
-	return &ArtifactCache{Cache: *gofilecache.InitCache(dir)}
+	return &ArtifactCache{Cache: *gofilecache.InitCache(dir), dir: dir}
 }
 
 func (c *ArtifactCache) cacheID(a *PackageArtifact) [64]byte {
@@ -64,5 +93,190 @@ func (c *ArtifactCache) Put(a *PackageArtifact) (gofilecache.OutputID, int64, er
 		return [64]byte{}, 0, errors.Wrapf(err, "failed opening %s", a.Path)
 	}
 	defer file.Close()
-	return c.Cache.Put(c.cacheID(a), file)
+
+	id := c.cacheID(a)
+	out, size, err := c.Cache.Put(id, file)
+	if err != nil {
+		return out, size, err
+	}
+	return out, size, c.index(id, out, a)
+}
+
+// index records the metadata of an artifact stored in the cache, so that
+// cached files can be mapped back to the packages they belong to
+func (c *ArtifactCache) index(id, out [64]byte, a *PackageArtifact) error {
+	meta := a.ShallowCopy()
+	meta.Path = cachedFileName(a)
+	meta.Dependencies = nil
+
+	b, err := yaml.Marshal(cacheIndexEntry{Output: hex.EncodeToString(out[:]), Artifact: meta})
+	if err != nil {
+		return errors.Wrap(err, "while serializing cache index entry")
+	}
+	dir := filepath.Join(c.dir, cacheIndexDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, hex.EncodeToString(id[:])+".yaml"), b, 0644)
+}
+
+// cachedFileName returns the file name of the artifact as published in repositories
+func cachedFileName(a *PackageArtifact) string {
+	if a.CompileSpec == nil || a.CompileSpec.Package == nil {
+		return filepath.Base(a.Path)
+	}
+	n := NewPackageArtifact(a.CompileSpec.Package.GetFingerPrint() + ".package.tar")
+	n.CompressionType = a.CompressionType
+	return n.getCompressedName()
+}
+
+func (c *ArtifactCache) fileName(id string, key string) string {
+	return filepath.Join(c.dir, id[:2], id+"-"+key)
+}
+
+// Entries returns the artifacts stored in the cache, the least recently used first.
+// Artifacts stored before the cache index was introduced are not listed.
+func (c *ArtifactCache) Entries() ([]*CacheEntry, error) {
+	files, err := ioutil.ReadDir(filepath.Join(c.dir, cacheIndexDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	res := []*CacheEntry{}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".yaml") {
+			continue
+		}
+		id := strings.TrimSuffix(f.Name(), ".yaml")
+		e, err := c.entry(id)
+		if err != nil {
+			// Entry evicted behind our back, drop its metadata
+			os.Remove(filepath.Join(c.dir, cacheIndexDir, f.Name()))
+			continue
+		}
+		res = append(res, e)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].LastUsed.Before(res[j].LastUsed) })
+	return res, nil
+}
+
+func (c *ArtifactCache) entry(id string) (*CacheEntry, error) {
+	dat, err := ioutil.ReadFile(filepath.Join(c.dir, cacheIndexDir, id+".yaml"))
+	if err != nil {
+		return nil, err
+	}
+	idx := cacheIndexEntry{}
+	if err := yaml.Unmarshal(dat, &idx); err != nil {
+		return nil, err
+	}
+	if idx.Artifact == nil || len(idx.Output) < 2 {
+		return nil, errors.New("invalid cache index entry")
+	}
+
+	action, err := os.Stat(c.fileName(id, "a"))
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.Stat(c.fileName(idx.Output, "d"))
+	if err != nil {
+		return nil, err
+	}
+
+	e := &CacheEntry{ID: id, Artifact: idx.Artifact, Size: data.Size(), LastUsed: action.ModTime(), output: idx.Output}
+	if data.ModTime().After(e.LastUsed) {
+		e.LastUsed = data.ModTime()
+	}
+	e.Artifact.Path = c.fileName(idx.Output, "d")
+	return e, nil
+}
+
+// Remove evicts the given entries from the cache
+func (c *ArtifactCache) Remove(entries ...*CacheEntry) error {
+	all, err := c.Entries()
+	if err != nil {
+		return err
+	}
+	removed := map[string]interface{}{}
+	for _, e := range entries {
+		removed[e.ID] = nil
+	}
+	// Cached files are content addressed: keep the ones still referenced by other entries
+	referenced := map[string]interface{}{}
+	for _, e := range all {
+		if _, ok := removed[e.ID]; !ok {
+			referenced[e.output] = nil
+		}
+	}
+
+	for _, e := range entries {
+		if err := os.Remove(c.fileName(e.ID, "a")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if _, ok := referenced[e.output]; !ok {
+			if err := os.Remove(c.fileName(e.output, "d")); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Remove(filepath.Join(c.dir, cacheIndexDir, e.ID+".yaml")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prune evicts the artifacts which weren't used for longer than maxAge, and then
+// the least recently used ones until the cache fits in maxSize. A zero value disables the
+// corresponding bound. It returns the evicted entries.
+func (c *ArtifactCache) Prune(maxSize int64, maxAge time.Duration) ([]*CacheEntry, error) {
+	entries, err := c.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+
+	toRemove := []*CacheEntry{}
+	cutoff := time.Now().Add(-maxAge)
+	for _, e := range entries {
+		if (maxAge > 0 && e.LastUsed.Before(cutoff)) || (maxSize > 0 && total > maxSize) {
+			toRemove = append(toRemove, e)
+			total -= e.Size
+		}
+	}
+
+	return toRemove, c.Remove(toRemove...)
+}
+
+// Verify checks the cached file of an entry against the checksums of its artifact
+func (e *CacheEntry) Verify() error {
+	if len(e.Artifact.Checksums) == 0 {
+		return nil
+	}
+	return e.Artifact.Verify()
+}
+
+// Export copies the cached file of an entry and its metadata in dst, in the layout of a repository
+func (e *CacheEntry) Export(dst string) error {
+	if e.Artifact.CompileSpec == nil || e.Artifact.CompileSpec.Package == nil {
+		return errors.New("cache entry has no package metadata")
+	}
+
+	meta := e.Artifact.ShallowCopy()
+	meta.Path = cachedFileName(e.Artifact)
+	if err := fileHelper.CopyFile(e.Artifact.Path, filepath.Join(dst, meta.Path)); err != nil {
+		return errors.Wrap(err, "while copying cached artifact")
+	}
+
+	b, err := yaml.Marshal(meta)
+	if err != nil {
+		return errors.Wrap(err, "while serializing artifact metadata")
+	}
+	return ioutil.WriteFile(filepath.Join(dst, meta.CompileSpec.Package.GetMetadataFilePath()), b, 0644)
 }
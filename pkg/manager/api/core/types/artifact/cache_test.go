@@ -24,6 +24,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/bhojpur/iso/pkg/manager/api/core/types"
 
@@ -92,5 +93,58 @@ var _ = Describe("Cache", func() {
 			_, err = cache.Get(c)
 			Expect(err).ToNot(HaveOccurred())
 		})
+
+		It("Indexes, verifies and prunes artifacts", func() {
+			tmpdir, err := ioutil.TempDir(os.TempDir(), "test")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(tmpdir) // clean up
+
+			cache := NewCache(filepath.Join(tmpdir, "cache"))
+
+			put := func(name, content string) *PackageArtifact {
+				a := NewPackageArtifact(filepath.Join(tmpdir, name))
+				Expect(ioutil.WriteFile(a.Path, []byte(content), os.ModePerm)).ToNot(HaveOccurred())
+				Expect(a.Hash()).ToNot(HaveOccurred())
+				a.CompileSpec = &compilerspec.BhojpurCompilationSpec{Package: &types.Package{Name: name, Category: "test", Version: "1.0"}}
+				_, _, err := cache.Put(a)
+				Expect(err).ToNot(HaveOccurred())
+				return a
+			}
+			put("old", "old content")
+			put("new", "new content")
+
+			entries, err := cache.Entries()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(entries)).To(Equal(2))
+
+			// Make "old" the least recently used one
+			for _, e := range entries {
+				if e.Artifact.CompileSpec.GetPackage().GetName() == "old" {
+					past := time.Now().Add(-48 * time.Hour)
+					Expect(os.Chtimes(e.Artifact.Path, past, past)).ToNot(HaveOccurred())
+					Expect(os.Chtimes(filepath.Join(tmpdir, "cache", e.ID[:2], e.ID+"-a"), past, past)).ToNot(HaveOccurred())
+				}
+				Expect(e.Verify()).ToNot(HaveOccurred())
+			}
+
+			removed, err := cache.Prune(0, 24*time.Hour)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(removed)).To(Equal(1))
+			Expect(removed[0].Artifact.CompileSpec.GetPackage().GetName()).To(Equal("old"))
+
+			entries, err = cache.Entries()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(entries)).To(Equal(1))
+
+			Expect(ioutil.WriteFile(entries[0].Artifact.Path, []byte("corrupted"), os.ModePerm)).ToNot(HaveOccurred())
+			Expect(entries[0].Verify()).To(HaveOccurred())
+
+			removed, err = cache.Prune(1, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(removed)).To(Equal(1))
+			entries, err = cache.Entries()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
 	})
 })
@@ -0,0 +1,414 @@
+package artifact
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	compilerspec "github.com/bhojpur/iso/pkg/manager/compiler/types/spec"
+	containerdCompression "github.com/containerd/containerd/archive/compression"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+const (
+	deltaMagic     = "ISODELTA1"
+	deltaBlockSize = 2048
+	// deltaMaxLiteral is the size of unmatched data after which it is written
+	// in the delta without waiting for the next match
+	deltaMaxLiteral = 1024 * 1024
+	deltaChunkSize  = 32 * 1024
+
+	deltaOpCopy    byte = 'C'
+	deltaOpLiteral byte = 'L'
+	deltaOpEnd     byte = 'E'
+)
+
+// ArtifactDelta is a binary delta which rebuilds an artifact
+// from the artifact of a previous version of the same package
+type ArtifactDelta struct {
+	// FromVersion is the version of the package the delta applies to
+	FromVersion   string    `json:"fromversion"`
+	FromChecksums Checksums `json:"fromchecksums"`
+	// Path is the file name of the delta in the repository
+	Path      string    `json:"path"`
+	Checksums Checksums `json:"checksums"`
+}
+
+// DeltaBase returns the artifact the given delta applies to
+func (a *PackageArtifact) DeltaBase(d ArtifactDelta) *PackageArtifact {
+	base := &PackageArtifact{Checksums: d.FromChecksums}
+	if a.CompileSpec != nil && a.CompileSpec.Package != nil {
+		p := a.CompileSpec.Package.Clone()
+		p.SetVersion(d.FromVersion)
+		base.CompileSpec = &compilerspec.BhojpurCompilationSpec{Package: p}
+	}
+	return base
+}
+
+// GenerateDelta writes to dst a delta which rebuilds the file to from the file from.
+// Compressed artifacts are compared decompressed, as compression spreads any change
+// over the rest of the file. Blocks of from found in to are referenced, the rest is
+// stored literally, and the result is compressed with zstd.
+// from is decompressed in a temporary file next to dst and to is streamed against it,
+// so only the index of the blocks of from is kept in memory.
+func GenerateDelta(from, to, dst string) error {
+	base, cleanup, err := decompressedBase(from, filepath.Dir(dst))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	index, err := indexBlocks(base)
+	if err != nil {
+		return errors.Wrap(err, "Cannot index "+from)
+	}
+
+	nf, err := os.Open(to)
+	if err != nil {
+		return err
+	}
+	defer nf.Close()
+	nr, err := containerdCompression.DecompressStream(nf)
+	if err != nil {
+		return errors.Wrap(err, "Cannot open "+to)
+	}
+	defer nr.Close()
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw, err := zstd.NewWriter(f)
+	if err != nil {
+		return err
+	}
+	w := &deltaWriter{w: bufio.NewWriter(zw)}
+	w.w.WriteString(deltaMagic)
+
+	// buf holds the bytes of to not written in the delta yet: the pending
+	// literal up to i, then the window of deltaBlockSize bytes being matched
+	stream := &deltaStream{r: nr}
+	i := 0
+	var h rollingHash
+	if err := stream.fill(deltaBlockSize); err != nil {
+		return err
+	}
+	if len(stream.buf) >= deltaBlockSize {
+		h = newRollingHash(stream.buf[:deltaBlockSize])
+	}
+	block := make([]byte, deltaBlockSize)
+	for len(stream.buf)-i >= deltaBlockSize {
+		off, found := int64(-1), false
+		for _, candidate := range index[h.sum()] {
+			if _, err := base.ReadAt(block, candidate); err != nil {
+				return err
+			}
+			if bytes.Equal(block, stream.buf[i:i+deltaBlockSize]) {
+				off, found = candidate, true
+				break
+			}
+		}
+
+		if found {
+			w.literal(stream.buf[:i])
+			stream.buf = stream.buf[i+deltaBlockSize:]
+			i = 0
+			n, err := stream.extendMatch(base, off+deltaBlockSize)
+			if err != nil {
+				return err
+			}
+			w.copy(off, deltaBlockSize+n)
+			if err := stream.fill(deltaBlockSize); err != nil {
+				return err
+			}
+			if len(stream.buf) >= deltaBlockSize {
+				h = newRollingHash(stream.buf[:deltaBlockSize])
+			}
+			continue
+		}
+
+		// Don't let unmatched data pile up in memory
+		if i >= deltaMaxLiteral {
+			w.literal(stream.buf[:i])
+			stream.buf = stream.buf[i:]
+			i = 0
+		}
+		if err := stream.fill(i + deltaBlockSize + 1); err != nil {
+			return err
+		}
+		if i+deltaBlockSize < len(stream.buf) {
+			h.roll(stream.buf[i], stream.buf[i+deltaBlockSize])
+		}
+		i++
+	}
+	w.literal(stream.buf)
+	w.w.WriteByte(deltaOpEnd)
+
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// ApplyDelta rebuilds in dst the file described by the delta, generated with GenerateDelta, from the file from.
+// The rebuilt file is decompressed, use CompressTarball to get back the artifact.
+// from is decompressed in a temporary file next to dst, which the copied blocks are read from.
+func ApplyDelta(from, delta, dst string) error {
+	base, cleanup, err := decompressedBase(from, filepath.Dir(dst))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	d, err := os.Open(delta)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	zr, err := zstd.NewReader(d)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	r := bufio.NewReader(zr)
+
+	magic := make([]byte, len(deltaMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != deltaMagic {
+		return errors.New("invalid delta file")
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w := bufio.NewWriter(out)
+
+	for {
+		op, err := r.ReadByte()
+		if err != nil {
+			return errors.Wrap(err, "truncated delta file")
+		}
+		switch op {
+		case deltaOpCopy:
+			off, err := binary.ReadUvarint(r)
+			if err != nil {
+				return errors.Wrap(err, "truncated delta file")
+			}
+			n, err := binary.ReadUvarint(r)
+			if err != nil {
+				return errors.Wrap(err, "truncated delta file")
+			}
+			if off+n > uint64(base.Size()) {
+				return errors.New("delta file references data past the end of the base file")
+			}
+			if _, err := io.Copy(w, io.NewSectionReader(base, int64(off), int64(n))); err != nil {
+				return err
+			}
+		case deltaOpLiteral:
+			n, err := binary.ReadUvarint(r)
+			if err != nil {
+				return errors.Wrap(err, "truncated delta file")
+			}
+			if _, err := io.CopyN(w, r, int64(n)); err != nil {
+				return errors.Wrap(err, "truncated delta file")
+			}
+		case deltaOpEnd:
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			return out.Sync()
+		default:
+			return fmt.Errorf("invalid delta operation %q", op)
+		}
+	}
+}
+
+// decompressedBase decompresses the file at path in a temporary file of dir, which
+// is returned with a function removing it
+func decompressedBase(path, dir string) (*io.SectionReader, func(), error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	r, err := containerdCompression.DecompressStream(f)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Cannot open "+path)
+	}
+	defer r.Close()
+
+	tmp, err := ioutil.TempFile(dir, ".delta-base-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		cleanup()
+		return nil, nil, errors.Wrap(err, "Cannot decompress "+path)
+	}
+	return io.NewSectionReader(tmp, 0, size), cleanup, nil
+}
+
+// indexBlocks returns the offsets of the blocks of base by their rolling hash
+func indexBlocks(base *io.SectionReader) (map[uint32][]int64, error) {
+	index := map[uint32][]int64{}
+	r := bufio.NewReader(io.NewSectionReader(base, 0, base.Size()))
+	block := make([]byte, deltaBlockSize)
+	for off := int64(0); off+deltaBlockSize <= base.Size(); off += deltaBlockSize {
+		if _, err := io.ReadFull(r, block); err != nil {
+			return nil, err
+		}
+		h := newRollingHash(block).sum()
+		index[h] = append(index[h], off)
+	}
+	return index, nil
+}
+
+// deltaStream buffers the file a delta is generated for, reading it as needed
+type deltaStream struct {
+	r     io.Reader
+	buf   []byte
+	chunk []byte
+	eof   bool
+}
+
+// fill reads from the stream until n bytes are buffered or the stream ends
+func (s *deltaStream) fill(n int) error {
+	for len(s.buf) < n && !s.eof {
+		if s.chunk == nil {
+			s.chunk = make([]byte, deltaChunkSize)
+		}
+		m, err := s.r.Read(s.chunk)
+		s.buf = append(s.buf, s.chunk[:m]...)
+		if err == io.EOF {
+			s.eof = true
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extendMatch consumes the bytes of the stream which match base from off on,
+// returning how many they are
+func (s *deltaStream) extendMatch(base *io.SectionReader, off int64) (int64, error) {
+	n := int64(0)
+	chunk := make([]byte, deltaChunkSize)
+	for {
+		if err := s.fill(len(chunk)); err != nil {
+			return n, err
+		}
+		want := len(s.buf)
+		if want > len(chunk) {
+			want = len(chunk)
+		}
+		if want == 0 {
+			return n, nil
+		}
+		m, err := base.ReadAt(chunk[:want], off+n)
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+		k := 0
+		for k < m && chunk[k] == s.buf[k] {
+			k++
+		}
+		s.buf = s.buf[k:]
+		n += int64(k)
+		if k < want {
+			return n, nil
+		}
+	}
+}
+
+type deltaWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (d *deltaWriter) uvarint(v uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	d.write(buf[:binary.PutUvarint(buf, v)])
+}
+
+func (d *deltaWriter) write(b []byte) {
+	if d.err == nil {
+		_, d.err = d.w.Write(b)
+	}
+}
+
+func (d *deltaWriter) copy(off, n int64) {
+	d.write([]byte{deltaOpCopy})
+	d.uvarint(uint64(off))
+	d.uvarint(uint64(n))
+}
+
+func (d *deltaWriter) literal(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	d.write([]byte{deltaOpLiteral})
+	d.uvarint(uint64(len(b)))
+	d.write(b)
+}
+
+// rollingHash is the rsync weak checksum of a window of deltaBlockSize bytes
+type rollingHash struct {
+	a, b uint32
+}
+
+func newRollingHash(block []byte) rollingHash {
+	h := rollingHash{}
+	for i, c := range block {
+		h.a += uint32(c)
+		h.b += uint32(len(block)-i) * uint32(c)
+	}
+	return h
+}
+
+func (h *rollingHash) roll(out, in byte) {
+	h.a = h.a - uint32(out) + uint32(in)
+	h.b = h.b - deltaBlockSize*uint32(out) + h.a
+}
+
+func (h rollingHash) sum() uint32 {
+	return h.a&0xffff | h.b<<16
+}
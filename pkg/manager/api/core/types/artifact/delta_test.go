@@ -0,0 +1,136 @@
+package artifact_test
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	. "github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
+	"github.com/bhojpur/iso/pkg/manager/compiler/types/compression"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Delta", func() {
+	Context("Generation", func() {
+		var tmpdir string
+
+		BeforeEach(func() {
+			var err error
+			tmpdir, err = ioutil.TempDir("", "delta")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpdir)
+		})
+
+		roundTrip := func(old, new []byte) int64 {
+			from, to := filepath.Join(tmpdir, "from"), filepath.Join(tmpdir, "to")
+			delta, result := filepath.Join(tmpdir, "delta"), filepath.Join(tmpdir, "result")
+			Expect(ioutil.WriteFile(from, old, os.ModePerm)).To(Succeed())
+			Expect(ioutil.WriteFile(to, new, os.ModePerm)).To(Succeed())
+
+			Expect(GenerateDelta(from, to, delta)).To(Succeed())
+			Expect(ApplyDelta(from, delta, result)).To(Succeed())
+
+			content, err := ioutil.ReadFile(result)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bytes.Equal(content, new)).To(BeTrue())
+
+			info, err := os.Stat(delta)
+			Expect(err).ToNot(HaveOccurred())
+			return info.Size()
+		}
+
+		It("rebuilds a file with small changes from a small delta", func() {
+			r := rand.New(rand.NewSource(42))
+			old := make([]byte, 512*1024)
+			r.Read(old)
+
+			new := append([]byte{}, old[:100000]...)
+			new = append(new, []byte("some inserted content")...)
+			new = append(new, old[100000:300000]...)
+			new = append(new, old[310000:]...)
+			new[400000] ^= 0xff
+
+			Expect(roundTrip(old, new)).To(BeNumerically("<", 16*1024))
+		})
+
+		It("handles files smaller than a block and empty files", func() {
+			roundTrip([]byte("foo"), []byte("bar"))
+			roundTrip([]byte{}, []byte("bar"))
+			roundTrip([]byte("foo"), []byte{})
+		})
+
+		for _, t := range []compression.Implementation{compression.GZip, compression.Zstandard} {
+			t := t
+			It("rebuilds "+string(t)+" artifacts from a delta of their content", func() {
+				r := rand.New(rand.NewSource(42))
+				content := []byte{}
+				for i := 0; i < 50000; i++ {
+					content = append(content, []byte(fmt.Sprintf("line %d: %d\n", i, r.Intn(100)))...)
+				}
+
+				build := func(name string, content []byte) *PackageArtifact {
+					src := filepath.Join(tmpdir, name+"-src")
+					Expect(os.MkdirAll(src, os.ModePerm)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(src, "file"), content, os.ModePerm)).To(Succeed())
+
+					a := NewPackageArtifact(filepath.Join(tmpdir, name+".package.tar"))
+					a.CompressionType = t
+					Expect(a.Compress(src, 1)).To(Succeed())
+					Expect(a.Hash()).To(Succeed())
+					return a
+				}
+				old := build("old", content)
+				content[200000] = '#'
+				new := build("new", content)
+
+				delta := filepath.Join(tmpdir, "delta")
+				Expect(GenerateDelta(old.Path, new.Path, delta)).To(Succeed())
+				info, err := os.Stat(delta)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(info.Size()).To(BeNumerically("<", 16*1024))
+
+				rebuilt := NewPackageArtifact(filepath.Join(tmpdir, "rebuilt"))
+				rebuilt.CompressionType = t
+				rebuilt.Checksums = new.Checksums
+				Expect(ApplyDelta(old.Path, delta, rebuilt.Path)).To(Succeed())
+				Expect(rebuilt.CompressTarball(1)).To(Succeed())
+				Expect(rebuilt.Verify()).To(Succeed())
+			})
+		}
+
+		It("rejects invalid deltas", func() {
+			from, delta := filepath.Join(tmpdir, "from"), filepath.Join(tmpdir, "delta")
+			Expect(ioutil.WriteFile(from, []byte("foo"), os.ModePerm)).To(Succeed())
+			Expect(ioutil.WriteFile(delta, []byte("not a delta"), os.ModePerm)).To(Succeed())
+			Expect(ApplyDelta(from, delta, filepath.Join(tmpdir, "result"))).ToNot(Succeed())
+		})
+	})
+})
@@ -27,10 +27,14 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bhojpur/iso/pkg/manager/api/core/config"
 	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
 
+	units "github.com/docker/go-units"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 )
@@ -66,6 +70,7 @@ type BhojpurGeneralConfig struct {
 	ShowBuildOutput bool `yaml:"show_build_output,omitempty" mapstructure:"show_build_output"`
 	FatalWarns      bool `yaml:"fatal_warnings,omitempty" mapstructure:"fatal_warnings"`
 	HTTPTimeout     int  `yaml:"http_timeout,omitempty" mapstructure:"http_timeout"`
+	HTTPRetries     int  `yaml:"http_retries,omitempty" mapstructure:"http_retries"`
 	Quiet           bool `yaml:"quiet" mapstructure:"quiet"`
 }
 
@@ -94,6 +99,55 @@ type BhojpurSystemConfig struct {
 	Rootfs         string `yaml:"rootfs" mapstructure:"rootfs"`
 	PkgsCachePath  string `yaml:"pkgs_cache_path" mapstructure:"pkgs_cache_path"`
 	TmpDirBase     string `yaml:"tmpdir_base" mapstructure:"tmpdir_base"`
+
+	// Rootless installs packages in a rootfs owned by the current user: the files
+	// ownership is ignored, device nodes are replaced by empty files and
+	// finalizers run in user namespaces
+	Rootless bool `yaml:"rootless,omitempty" mapstructure:"rootless"`
+
+	// MaxSize is the maximum size of the packages cache (e.g. 5GB).
+	// The least recently used artifacts are evicted first.
+	MaxSize string `yaml:"max_size,omitempty" mapstructure:"max_size"`
+	// MaxAge is the maximum time an artifact is kept in the packages
+	// cache since it was last used (e.g. 720h or 30d)
+	MaxAge string `yaml:"max_age,omitempty" mapstructure:"max_age"`
+}
+
+// CacheMaxSize returns the maximum size in bytes of the packages cache, 0 if unbounded
+func (s *BhojpurSystemConfig) CacheMaxSize() (int64, error) {
+	if s.MaxSize == "" {
+		return 0, nil
+	}
+	size, err := units.RAMInBytes(s.MaxSize)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid cache max_size '%s'", s.MaxSize)
+	}
+	return size, nil
+}
+
+// CacheMaxAge returns the maximum age of the artifacts in the packages cache, 0 if unbounded
+func (s *BhojpurSystemConfig) CacheMaxAge() (time.Duration, error) {
+	if s.MaxAge == "" {
+		return 0, nil
+	}
+	age, err := ParseAge(s.MaxAge)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid cache max_age '%s'", s.MaxAge)
+	}
+	return age, nil
+}
+
+// ParseAge parses a duration, which additionally to the time.ParseDuration
+// units accepts days expressed as 'd' (e.g. 30d)
+func ParseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
 }
 
 // Init reads the config and replace user-defined paths with
@@ -137,6 +191,9 @@ func (s *BhojpurSystemConfig) setRootfs() error {
 	}
 
 	s.Rootfs = p
+	if s.Rootless && s.Rootfs == string(os.PathSeparator) {
+		return errors.New("rootless mode requires a rootfs other than /")
+	}
 	return nil
 }
 
@@ -151,6 +208,12 @@ func (s BhojpurSystemConfig) GetRepoDatabaseDirPath(name string) string {
 	return dbpath
 }
 
+// GetRepoKeysDirPath returns the folder holding the public keys trusted
+// to sign the repository with the given name
+func (s BhojpurSystemConfig) GetRepoKeysDirPath(name string) string {
+	return filepath.Join(s.DatabasePath, "keys", name)
+}
+
 func (s *BhojpurSystemConfig) setDBPath() error {
 	dbpath := filepath.Join(
 		s.Rootfs,
@@ -217,6 +280,9 @@ type BhojpurConfig struct {
 	SystemRepositories   BhojpurRepositories `yaml:"repositories,omitempty" mapstructure:"repositories"`
 
 	FinalizerEnvs Finalizers `json:"finalizer_envs,omitempty" yaml:"finalizer_envs,omitempty" mapstructure:"finalizer_envs,omitempty"`
+	// FinalizerTimeout is the maximum time a package finalizer can run (e.g. 10m),
+	// unless the finalizer sets its own timeout
+	FinalizerTimeout string `json:"finalizer_timeout,omitempty" yaml:"finalizer_timeout,omitempty" mapstructure:"finalizer_timeout,omitempty"`
 
 	ConfigProtectConfFiles []config.ConfigProtectConfFile `yaml:"-" mapstructure:"-"`
 }
@@ -247,6 +313,18 @@ func (c *BhojpurConfig) SetFinalizerEnv(k, v string) {
 	c.FinalizerEnvs = envs
 }
 
+// GetFinalizerTimeout returns the maximum time a package finalizer can run, 0 if unbounded
+func (c BhojpurConfig) GetFinalizerTimeout() (time.Duration, error) {
+	if c.FinalizerTimeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(c.FinalizerTimeout)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid finalizer_timeout '%s'", c.FinalizerTimeout)
+	}
+	return timeout, nil
+}
+
 // YAML returns the config in yaml format
 func (c *BhojpurConfig) YAML() ([]byte, error) {
 	return yaml.Marshal(c)
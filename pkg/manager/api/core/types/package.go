@@ -27,10 +27,12 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bhojpur/iso/pkg/manager/helpers"
 	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
@@ -48,6 +50,14 @@ type PackageAnnotation string
 
 const (
 	ConfigProtectAnnotation PackageAnnotation = "config_protect"
+	// InstallReasonAnnotation is set on the packages of a system database, and
+	// tells if a package was requested explicitly or pulled in as a dependency
+	InstallReasonAnnotation PackageAnnotation = "install_reason"
+)
+
+const (
+	InstallReasonExplicit   = "explicit"
+	InstallReasonDependency = "dependency"
 )
 
 const (
@@ -76,6 +86,43 @@ type PackageFile struct {
 	ID                 int `storm:"id,increment"` // primary key with auto increment
 	PackageFingerprint string
 	Files              []string
+	Metadata           []FileMetadata
+}
+
+// FileMetadata is the content and the attributes of a file installed by a package,
+// as found in the package artifact
+type FileMetadata struct {
+	Path   string      `json:"path"`
+	Sha256 string      `json:"sha256,omitempty"`
+	Mode   os.FileMode `json:"mode"`
+	Uid    int         `json:"uid"`
+	Gid    int         `json:"gid"`
+	Link   string      `json:"link,omitempty"`
+}
+
+// PackageFinalizer is the rendered finalizer of an installed package,
+// along with the outcome of its last run
+type PackageFinalizer struct {
+	ID                 int `storm:"id,increment"` // primary key with auto increment
+	PackageFingerprint string
+	Finalizer          string
+	// Output is the captured stdout and stderr of the last run
+	Output string
+	// Error is set when the last run failed
+	Error string
+}
+
+// TransactionRecord is an installer operation recorded in the history of a system
+type TransactionRecord struct {
+	ID        int `storm:"id,increment"` // primary key with auto increment
+	Timestamp time.Time
+	User      string
+	Command   string
+	Operation string
+	// Changes are the package changes applied by the operation, JSON encoded
+	Changes json.RawMessage
+	Outcome string
+	Error   string
 }
 
 type PackageSet interface {
@@ -94,8 +141,16 @@ type PackageSet interface {
 	RemovePackage(*Package) error
 
 	GetPackageFiles(*Package) ([]string, error)
+	GetPackageFileMetadata(*Package) ([]FileMetadata, error)
 	SetPackageFiles(*PackageFile) error
 	RemovePackageFiles(*Package) error
+
+	GetPackageFinalizer(*Package) (*PackageFinalizer, error)
+	SetPackageFinalizer(*PackageFinalizer) error
+	RemovePackageFinalizer(*Package) error
+
+	GetTransactionRecords() ([]*TransactionRecord, error)
+	SetTransactionRecord(*TransactionRecord) error
 	FindPackageVersions(p *Package) (Packages, error)
 	World() Packages
 
@@ -249,9 +304,42 @@ type Package struct {
 
 	Labels map[string]string `json:"labels,omitempty"` // Affects YAML field names too.
 
+	// Triggers are run once per transaction when the files they watch are touched
+	Triggers []*PackageTrigger `json:"triggers,omitempty"` // Affects YAML field names too.
+
 	TreeDir string `json:"treedir,omitempty"`
 }
 
+// PackageTrigger is a command declared by a package which runs once at the end of
+// a transaction installing, replacing or removing files matching its paths,
+// whatever package they belong to.
+type PackageTrigger struct {
+	// Name identifies the trigger, packages declaring a trigger with the same name share it
+	Name string `json:"name" yaml:"name"`
+	// Paths are the globs of the watched files, relative to the system root.
+	// A file matches when its path, or one of its parent folders, matches a glob.
+	Paths []string `json:"paths" yaml:"paths"`
+	Shell []string `json:"shell,omitempty" yaml:"shell,omitempty"`
+	Run   []string `json:"run" yaml:"run"`
+	// Timeout is the maximum time the trigger can run (e.g. 5m),
+	// it overrides the finalizer_timeout of the configuration
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// Matches returns true if the trigger watches the given file
+func (t *PackageTrigger) Matches(file string) bool {
+	file = filepath.Clean(strings.TrimPrefix(file, string(os.PathSeparator)))
+	for _, glob := range t.Paths {
+		glob = filepath.Clean(strings.TrimPrefix(glob, string(os.PathSeparator)))
+		for f := file; f != "." && f != string(os.PathSeparator); f = filepath.Dir(f) {
+			if ok, _ := filepath.Match(glob, f); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // State represent the package state
 type State string
 
@@ -474,6 +562,35 @@ func (p *Package) AddAnnotation(k, v string) {
 	}
 	p.Annotations[PackageAnnotation(k)] = v
 }
+
+// GetInstallReason returns why the package was installed in the system.
+// Packages installed before the reason was tracked are considered explicit.
+func (p *Package) GetInstallReason() string {
+	if r, ok := p.Annotations[InstallReasonAnnotation]; ok && r != "" {
+		return r
+	}
+	return InstallReasonExplicit
+}
+
+// SetInstallReason records why the package was installed. The annotations are
+// copied, as they might be shared with the clones of the package.
+func (p *Package) SetInstallReason(r string) {
+	annotations := make(map[PackageAnnotation]string, len(p.Annotations)+1)
+	for k, v := range p.Annotations {
+		annotations[k] = v
+	}
+	annotations[InstallReasonAnnotation] = r
+	p.Annotations = annotations
+}
+
+func (p *Package) IsExplicit() bool {
+	return p.GetInstallReason() == InstallReasonExplicit
+}
+
+func (p *Package) GetTriggers() []*PackageTrigger {
+	return p.Triggers
+}
+
 func (p *Package) GetLabels() map[string]string {
 	return p.Labels
 }
@@ -677,6 +794,27 @@ func (set Packages) Best(v version.Versioner) *Package {
 	return versionsMap[sorted[len(sorted)-1]]
 }
 
+// FindPin returns the pin of the set matching the package, if any.
+// Pins are package selectors freezing the version of a package.
+func (set Packages) FindPin(p *Package) *Package {
+	for _, pin := range set {
+		if pin.AtomMatches(p) {
+			return pin
+		}
+	}
+	return nil
+}
+
+// AllowsVersion returns true if the version of the given package satisfies
+// the version, or the version selector, of the package
+func (p *Package) AllowsVersion(m *Package) bool {
+	if !p.IsSelector() {
+		return p.GetVersion() == m.GetVersion()
+	}
+	match, err := p.SelectorMatchVersion(m.GetVersion(), nil)
+	return err == nil && match
+}
+
 func (set Packages) Find(packageName string) (*Package, error) {
 	for _, p := range set {
 		if p.GetPackageName() == packageName {
@@ -23,7 +23,9 @@ package types
 import (
 	"fmt"
 	"runtime"
+	"time"
 
+	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 )
 
@@ -31,6 +33,7 @@ type BhojpurRepository struct {
 	Name           string            `json:"name" yaml:"name" mapstructure:"name"`
 	Description    string            `json:"description,omitempty" yaml:"description,omitempty" mapstructure:"description"`
 	Urls           []string          `json:"urls" yaml:"urls" mapstructure:"urls"`
+	MirrorList     string            `json:"mirrorlist,omitempty" yaml:"mirrorlist,omitempty" mapstructure:"mirrorlist"`
 	Type           string            `json:"type" yaml:"type" mapstructure:"type"`
 	Mode           string            `json:"mode,omitempty" yaml:"mode,omitempty" mapstructure:"mode,omitempty"`
 	Priority       int               `json:"priority,omitempty" yaml:"priority,omitempty" mapstructure:"priority"`
@@ -41,6 +44,23 @@ type BhojpurRepository struct {
 	MetaPath       string            `json:"metapath,omitempty" yaml:"metapath,omitempty" mapstructure:"metapath"`
 	Verify         bool              `json:"verify,omitempty" yaml:"verify,omitempty" mapstructure:"verify"`
 	Arch           string            `json:"arch,omitempty" yaml:"arch,omitempty" mapstructure:"arch"`
+	// TrustedKeys are the base64 encoded ed25519 public keys trusted to sign
+	// the repository, in addition to the ones added with `isomgr keys add`
+	TrustedKeys []string `json:"trusted_keys,omitempty" yaml:"trusted_keys,omitempty" mapstructure:"trusted_keys"`
+	// RefreshInterval is the time after which the cached metadata of the
+	// repository is refreshed (e.g. 6h or 7d), DefaultRefreshInterval if unset
+	RefreshInterval string `json:"refresh_interval,omitempty" yaml:"refresh_interval,omitempty" mapstructure:"refresh_interval"`
+	// TLS are the TLS settings used to connect to http repositories
+	TLS *BhojpurRepositoryTLS `json:"tls,omitempty" yaml:"tls,omitempty" mapstructure:"tls"`
+	// Headers are additional HTTP headers sent to http repositories
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty" mapstructure:"headers"`
+	// S3 are the object storage settings of s3 repositories, whose urls
+	// are in the s3://bucket/prefix form
+	S3 *BhojpurRepositoryS3 `json:"s3,omitempty" yaml:"s3,omitempty" mapstructure:"s3"`
+
+	// Snapshot pins the repository to the snapshot with the given ID, as listed by
+	// `isomgr repo snapshots`, instead of following its latest revision
+	Snapshot string `json:"snapshot,omitempty" yaml:"snapshot,omitempty" mapstructure:"snapshot"`
 
 	ReferenceID string `json:"reference,omitempty" yaml:"reference,omitempty" mapstructure:"reference"`
 
@@ -50,6 +70,44 @@ type BhojpurRepository struct {
 	LastUpdate string `json:"last_update,omitempty" yaml:"-" mapstructure:"-"`
 }
 
+// BhojpurRepositoryTLS holds the TLS settings of a repository
+type BhojpurRepositoryTLS struct {
+	// CACert is the path of a PEM bundle of the certificate authorities
+	// trusted in addition to the system ones
+	CACert string `json:"ca_cert,omitempty" yaml:"ca_cert,omitempty" mapstructure:"ca_cert"`
+	// ClientCert and ClientKey are the paths of the PEM certificate and key
+	// presented to the server for mutual TLS authentication
+	ClientCert string `json:"client_cert,omitempty" yaml:"client_cert,omitempty" mapstructure:"client_cert"`
+	ClientKey  string `json:"client_key,omitempty" yaml:"client_key,omitempty" mapstructure:"client_key"`
+	// InsecureSkipVerify disables the verification of the server certificate, for testing only
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty" mapstructure:"insecure_skip_verify"`
+}
+
+// BhojpurRepositoryS3 holds the object storage settings of a repository
+type BhojpurRepositoryS3 struct {
+	// Endpoint is the URL of the object storage, AWS S3 if empty
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty" mapstructure:"endpoint"`
+	Region   string `json:"region,omitempty" yaml:"region,omitempty" mapstructure:"region"`
+	// PathStyle addresses the buckets in the URL path instead of in the host name,
+	// as required by most of the self-hosted object storages
+	PathStyle bool `json:"path_style,omitempty" yaml:"path_style,omitempty" mapstructure:"path_style"`
+}
+
+// DefaultRefreshInterval is the refresh interval of the repositories which don't set one
+const DefaultRefreshInterval = 24 * time.Hour
+
+// GetRefreshInterval returns the time after which the cached metadata of the repository is refreshed
+func (r *BhojpurRepository) GetRefreshInterval() (time.Duration, error) {
+	if r.RefreshInterval == "" {
+		return DefaultRefreshInterval, nil
+	}
+	interval, err := ParseAge(r.RefreshInterval)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid refresh_interval '%s' of repository %s", r.RefreshInterval, r.Name)
+	}
+	return interval, nil
+}
+
 func (r *BhojpurRepository) String() string {
 	return fmt.Sprintf("[%s] prio: %d, type: %s, enable: %t, cached: %t",
 		r.Name, r.Priority, r.Type, r.Enable, r.Cached)
@@ -56,6 +56,9 @@ type PackageSolver interface {
 type SolverOptions struct {
 	Type        SolverType `yaml:"type,omitempty"`
 	Concurrency int        `yaml:"concurrency,omitempty"`
+
+	// Pins are package selectors freezing the versions the solver can pick
+	Pins Packages `yaml:"-" mapstructure:"-"`
 }
 
 // PackageResolver assists PackageSolver on unsat cases
@@ -21,12 +21,16 @@ package box
 // THE SOFTWARE.
 
 import (
+	"context"
 	b64 "encoding/base64"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
 
@@ -46,6 +50,11 @@ type DefaultBox struct {
 	Args                  []string
 	HostMounts            []string
 	Stdin, Stdout, Stderr bool
+
+	// Output, when set, receives the stdout and stderr of the box in place of the ones of the process
+	Output io.Writer
+	// Timeout kills the box if it is still running after the given time. 0 means no timeout.
+	Timeout time.Duration
 }
 
 func NewBox(cmd string, args, hostmounts, env []string, rootfs string, stdin, stdout, stderr bool) Box {
@@ -155,10 +164,16 @@ func (b *DefaultBox) Run() error {
 
 	if b.Stderr {
 		cmd.Stderr = os.Stderr
+		if b.Output != nil {
+			cmd.Stderr = b.Output
+		}
 	}
 
 	if b.Stdout {
 		cmd.Stdout = os.Stdout
+		if b.Output != nil {
+			cmd.Stdout = b.Output
+		}
 	}
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Cloneflags: syscall.CLONE_NEWNS |
@@ -183,8 +198,40 @@ func (b *DefaultBox) Run() error {
 		},
 	}
 
-	if err := cmd.Run(); err != nil {
+	if err := RunWithTimeout(cmd, b.Timeout); err != nil {
+		if err == context.DeadlineExceeded {
+			return err
+		}
 		return errors.Wrap(err, "Failed running Box command in box.Run")
 	}
 	return nil
 }
+
+// RunWithTimeout runs cmd in its own process group, killing the whole group if it
+// is still running after timeout, so children left behind by the command can't keep
+// its output open. It returns context.DeadlineExceeded on timeout, 0 means no timeout.
+func RunWithTimeout(cmd *exec.Cmd, timeout time.Duration) error {
+	if timeout <= 0 {
+		return cmd.Run()
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	var killed int32
+	timer := time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&killed, 1)
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	})
+	err := cmd.Wait()
+	timer.Stop()
+	if atomic.LoadInt32(&killed) == 1 {
+		return context.DeadlineExceeded
+	}
+	return err
+}
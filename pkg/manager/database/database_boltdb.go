@@ -300,6 +300,21 @@ func (db *BoltDatabase) GetPackageFiles(p *types.Package) ([]string, error) {
 	}
 	return pf.Files, nil
 }
+func (db *BoltDatabase) GetPackageFileMetadata(p *types.Package) ([]types.FileMetadata, error) {
+	bolt, err := storm.Open(db.Path, storm.BoltOptions(0600, &bbolt.Options{Timeout: 30 * time.Second}))
+	if err != nil {
+		return []types.FileMetadata{}, errors.Wrap(err, "Error opening boltdb "+db.Path)
+	}
+	defer bolt.Close()
+
+	files := bolt.From("files")
+	var pf types.PackageFile
+	err = files.One("PackageFingerprint", p.GetFingerPrint(), &pf)
+	if err != nil {
+		return []types.FileMetadata{}, errors.Wrap(err, "While finding files")
+	}
+	return pf.Metadata, nil
+}
 func (db *BoltDatabase) SetPackageFiles(p *types.PackageFile) error {
 	bolt, err := storm.Open(db.Path, storm.BoltOptions(0600, &bbolt.Options{Timeout: 30 * time.Second}))
 	if err != nil {
@@ -326,6 +341,76 @@ func (db *BoltDatabase) RemovePackageFiles(p *types.Package) error {
 	return files.DeleteStruct(&pf)
 }
 
+func (db *BoltDatabase) GetPackageFinalizer(p *types.Package) (*types.PackageFinalizer, error) {
+	bolt, err := storm.Open(db.Path, storm.BoltOptions(0600, &bbolt.Options{Timeout: 30 * time.Second}))
+	if err != nil {
+		return nil, errors.Wrap(err, "Error opening boltdb "+db.Path)
+	}
+	defer bolt.Close()
+
+	finalizers := bolt.From("finalizers")
+	var pf types.PackageFinalizer
+	err = finalizers.One("PackageFingerprint", p.GetFingerPrint(), &pf)
+	if err != nil {
+		return nil, errors.Wrap(err, "While finding finalizer")
+	}
+	return &pf, nil
+}
+func (db *BoltDatabase) SetPackageFinalizer(p *types.PackageFinalizer) error {
+	bolt, err := storm.Open(db.Path, storm.BoltOptions(0600, &bbolt.Options{Timeout: 30 * time.Second}))
+	if err != nil {
+		return errors.Wrap(err, "Error opening boltdb "+db.Path)
+	}
+	defer bolt.Close()
+
+	finalizers := bolt.From("finalizers")
+	var pf types.PackageFinalizer
+	if err := finalizers.One("PackageFingerprint", p.PackageFingerprint, &pf); err == nil {
+		// Replace the finalizer stored for the package
+		p.ID = pf.ID
+	}
+	return finalizers.Save(p)
+}
+func (db *BoltDatabase) RemovePackageFinalizer(p *types.Package) error {
+	bolt, err := storm.Open(db.Path, storm.BoltOptions(0600, &bbolt.Options{Timeout: 30 * time.Second}))
+	if err != nil {
+		return errors.Wrap(err, "Error opening boltdb "+db.Path)
+	}
+	defer bolt.Close()
+
+	finalizers := bolt.From("finalizers")
+	var pf types.PackageFinalizer
+	err = finalizers.One("PackageFingerprint", p.GetFingerPrint(), &pf)
+	if err != nil {
+		return errors.Wrap(err, "While finding finalizer")
+	}
+	return finalizers.DeleteStruct(&pf)
+}
+
+func (db *BoltDatabase) GetTransactionRecords() ([]*types.TransactionRecord, error) {
+	bolt, err := storm.Open(db.Path, storm.BoltOptions(0600, &bbolt.Options{Timeout: 30 * time.Second}))
+	if err != nil {
+		return nil, errors.Wrap(err, "Error opening boltdb "+db.Path)
+	}
+	defer bolt.Close()
+
+	records := []*types.TransactionRecord{}
+	if err := bolt.From("history").All(&records); err != nil {
+		return nil, errors.Wrap(err, "While reading transaction records")
+	}
+	return records, nil
+}
+func (db *BoltDatabase) SetTransactionRecord(r *types.TransactionRecord) error {
+	bolt, err := storm.Open(db.Path, storm.BoltOptions(0600, &bbolt.Options{Timeout: 30 * time.Second}))
+	if err != nil {
+		return errors.Wrap(err, "Error opening boltdb "+db.Path)
+	}
+	defer bolt.Close()
+
+	// Records without an ID get the next one, the others are replaced
+	return bolt.From("history").Save(r)
+}
+
 func (db *BoltDatabase) RemovePackage(p *types.Package) error {
 	bolt, err := storm.Open(db.Path, storm.BoltOptions(0600, &bbolt.Options{Timeout: 30 * time.Second}))
 	if err != nil {
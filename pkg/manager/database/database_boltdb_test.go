@@ -63,6 +63,65 @@ var _ = Describe("BoltDB Database", func() {
 
 		})
 
+		It("Stores package finalizers", func() {
+			a := types.NewPackage("A", "1.0", []*types.Package{}, []*types.Package{})
+			_, err := db.CreatePackage(a)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = db.SetPackageFinalizer(&types.PackageFinalizer{PackageFingerprint: a.GetFingerPrint(), Finalizer: "uninstall: []"})
+			Expect(err).ToNot(HaveOccurred())
+			err = db.SetPackageFinalizer(&types.PackageFinalizer{PackageFingerprint: a.GetFingerPrint(), Finalizer: "install: []"})
+			Expect(err).ToNot(HaveOccurred())
+
+			f, err := db.GetPackageFinalizer(a)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f.Finalizer).To(Equal("install: []"))
+
+			Expect(db.RemovePackageFinalizer(a)).To(Succeed())
+			_, err = db.GetPackageFinalizer(a)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Stores transaction records", func() {
+			records, err := db.GetTransactionRecords()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(records).To(BeEmpty())
+
+			for _, op := range []string{"install", "uninstall"} {
+				Expect(db.SetTransactionRecord(&types.TransactionRecord{Operation: op, Changes: []byte(`{}`)})).To(Succeed())
+			}
+			Expect(db.SetTransactionRecord(&types.TransactionRecord{ID: 1, Operation: "install", Outcome: "failed", Changes: []byte(`{}`)})).To(Succeed())
+
+			records, err = db.GetTransactionRecords()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(records)).To(Equal(2))
+			Expect(records[0].ID).To(Equal(1))
+			Expect(records[0].Outcome).To(Equal("failed"))
+			Expect(records[1].ID).To(Equal(2))
+			Expect(records[1].Operation).To(Equal("uninstall"))
+		})
+
+		It("Stores package files metadata", func() {
+			a := types.NewPackage("A", "1.0", []*types.Package{}, []*types.Package{})
+			_, err := db.CreatePackage(a)
+			Expect(err).ToNot(HaveOccurred())
+
+			metadata := []types.FileMetadata{
+				{Path: "usr/bin/a", Sha256: "abcd", Mode: 0755},
+				{Path: "usr/lib/a", Mode: os.ModeSymlink | 0777, Link: "../bin/a"},
+			}
+			err = db.SetPackageFiles(&types.PackageFile{PackageFingerprint: a.GetFingerPrint(), Files: []string{"usr/bin/a", "usr/lib/a"}, Metadata: metadata})
+			Expect(err).ToNot(HaveOccurred())
+
+			m, err := db.GetPackageFileMetadata(a)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m).To(Equal(metadata))
+
+			Expect(db.RemovePackageFiles(a)).To(Succeed())
+			_, err = db.GetPackageFileMetadata(a)
+			Expect(err).To(HaveOccurred())
+		})
+
 		It("Find package files", func() {
 			a := types.NewPackage("A", "1.0", []*types.Package{}, []*types.Package{})
 			a1 := types.NewPackage("A", "1.1", []*types.Package{}, []*types.Package{})
@@ -32,36 +32,43 @@ import (
 )
 
 var DBInMemoryInstance = &InMemoryDatabase{
-	Mutex:            &sync.Mutex{},
-	FileDatabase:     map[string][]string{},
-	Database:         map[string]string{},
-	CacheNoVersion:   map[string]map[string]interface{}{},
-	ProvidesDatabase: map[string]map[string]*types.Package{},
-	RevDepsDatabase:  map[string]map[string]*types.Package{},
-	cached:           map[string]interface{}{},
+	Mutex:                &sync.Mutex{},
+	FileDatabase:         map[string][]string{},
+	FileMetadataDatabase: map[string][]types.FileMetadata{},
+	FinalizerDatabase:    map[string]types.PackageFinalizer{},
+	Database:             map[string]string{},
+	CacheNoVersion:       map[string]map[string]interface{}{},
+	ProvidesDatabase:     map[string]map[string]*types.Package{},
+	RevDepsDatabase:      map[string]map[string]*types.Package{},
+	cached:               map[string]interface{}{},
 }
 
 type InMemoryDatabase struct {
 	*sync.Mutex
-	Database         map[string]string
-	FileDatabase     map[string][]string
-	CacheNoVersion   map[string]map[string]interface{}
-	ProvidesDatabase map[string]map[string]*types.Package
-	RevDepsDatabase  map[string]map[string]*types.Package
-	cached           map[string]interface{}
+	Database             map[string]string
+	FileDatabase         map[string][]string
+	FileMetadataDatabase map[string][]types.FileMetadata
+	FinalizerDatabase    map[string]types.PackageFinalizer
+	HistoryDatabase      []types.TransactionRecord
+	CacheNoVersion       map[string]map[string]interface{}
+	ProvidesDatabase     map[string]map[string]*types.Package
+	RevDepsDatabase      map[string]map[string]*types.Package
+	cached               map[string]interface{}
 }
 
 func NewInMemoryDatabase(singleton bool) types.PackageDatabase {
 	// In memoryDB is a singleton
 	if !singleton {
 		return &InMemoryDatabase{
-			Mutex:            &sync.Mutex{},
-			FileDatabase:     map[string][]string{},
-			Database:         map[string]string{},
-			CacheNoVersion:   map[string]map[string]interface{}{},
-			ProvidesDatabase: map[string]map[string]*types.Package{},
-			RevDepsDatabase:  map[string]map[string]*types.Package{},
-			cached:           map[string]interface{}{},
+			Mutex:                &sync.Mutex{},
+			FileDatabase:         map[string][]string{},
+			FileMetadataDatabase: map[string][]types.FileMetadata{},
+			FinalizerDatabase:    map[string]types.PackageFinalizer{},
+			Database:             map[string]string{},
+			CacheNoVersion:       map[string]map[string]interface{}{},
+			ProvidesDatabase:     map[string]map[string]*types.Package{},
+			RevDepsDatabase:      map[string]map[string]*types.Package{},
+			cached:               map[string]interface{}{},
 		}
 	}
 	return DBInMemoryInstance
@@ -432,16 +439,79 @@ func (db *InMemoryDatabase) GetPackageFiles(p *types.Package) ([]string, error)
 
 	return pa, nil
 }
+func (db *InMemoryDatabase) GetPackageFileMetadata(p *types.Package) ([]types.FileMetadata, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	pa, ok := db.FileMetadataDatabase[p.GetFingerPrint()]
+	if !ok {
+		return pa, fmt.Errorf("No key found for: %s", p.HumanReadableString())
+	}
+
+	return pa, nil
+}
 func (db *InMemoryDatabase) SetPackageFiles(p *types.PackageFile) error {
 	db.Lock()
 	defer db.Unlock()
 	db.FileDatabase[p.PackageFingerprint] = p.Files
+	db.FileMetadataDatabase[p.PackageFingerprint] = p.Metadata
 	return nil
 }
 func (db *InMemoryDatabase) RemovePackageFiles(p *types.Package) error {
 	db.Lock()
 	defer db.Unlock()
 	delete(db.FileDatabase, p.GetFingerPrint())
+	delete(db.FileMetadataDatabase, p.GetFingerPrint())
+	return nil
+}
+
+func (db *InMemoryDatabase) GetPackageFinalizer(p *types.Package) (*types.PackageFinalizer, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	f, ok := db.FinalizerDatabase[p.GetFingerPrint()]
+	if !ok {
+		return nil, fmt.Errorf("No finalizer found for: %s", p.HumanReadableString())
+	}
+
+	return &f, nil
+}
+func (db *InMemoryDatabase) SetPackageFinalizer(p *types.PackageFinalizer) error {
+	db.Lock()
+	defer db.Unlock()
+	db.FinalizerDatabase[p.PackageFingerprint] = *p
+	return nil
+}
+func (db *InMemoryDatabase) RemovePackageFinalizer(p *types.Package) error {
+	db.Lock()
+	defer db.Unlock()
+	delete(db.FinalizerDatabase, p.GetFingerPrint())
+	return nil
+}
+
+func (db *InMemoryDatabase) GetTransactionRecords() ([]*types.TransactionRecord, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	res := []*types.TransactionRecord{}
+	for i := range db.HistoryDatabase {
+		r := db.HistoryDatabase[i]
+		res = append(res, &r)
+	}
+	return res, nil
+}
+func (db *InMemoryDatabase) SetTransactionRecord(r *types.TransactionRecord) error {
+	db.Lock()
+	defer db.Unlock()
+	if r.ID == 0 {
+		r.ID = len(db.HistoryDatabase) + 1
+		db.HistoryDatabase = append(db.HistoryDatabase, *r)
+		return nil
+	}
+	if r.ID > len(db.HistoryDatabase) {
+		return fmt.Errorf("No transaction record found with id %d", r.ID)
+	}
+	db.HistoryDatabase[r.ID-1] = *r
 	return nil
 }
 
@@ -21,6 +21,8 @@ package database_test
 // THE SOFTWARE.
 
 import (
+	"os"
+
 	"github.com/bhojpur/iso/pkg/manager/api/core/types"
 	. "github.com/bhojpur/iso/pkg/manager/database"
 	. "github.com/onsi/ginkgo/v2"
@@ -109,6 +111,49 @@ var _ = Describe("Database", func() {
 			Expect(pack[0]).To(Equal(a))
 		})
 
+		It("Stores package finalizers", func() {
+			db := NewInMemoryDatabase(false)
+			a := types.NewPackage("A", "1.0", []*types.Package{}, []*types.Package{})
+			_, err := db.CreatePackage(a)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = db.GetPackageFinalizer(a)
+			Expect(err).To(HaveOccurred())
+
+			err = db.SetPackageFinalizer(&types.PackageFinalizer{PackageFingerprint: a.GetFingerPrint(), Finalizer: "uninstall: []"})
+			Expect(err).ToNot(HaveOccurred())
+
+			f, err := db.GetPackageFinalizer(a)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f.Finalizer).To(Equal("uninstall: []"))
+
+			Expect(db.RemovePackageFinalizer(a)).To(Succeed())
+			_, err = db.GetPackageFinalizer(a)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Stores package files metadata", func() {
+			db := NewInMemoryDatabase(false)
+			a := types.NewPackage("A", "1.0", []*types.Package{}, []*types.Package{})
+			_, err := db.CreatePackage(a)
+			Expect(err).ToNot(HaveOccurred())
+
+			metadata := []types.FileMetadata{
+				{Path: "usr/bin/a", Sha256: "abcd", Mode: 0755},
+				{Path: "usr/lib/a", Mode: os.ModeSymlink | 0777, Link: "../bin/a"},
+			}
+			err = db.SetPackageFiles(&types.PackageFile{PackageFingerprint: a.GetFingerPrint(), Files: []string{"usr/bin/a", "usr/lib/a"}, Metadata: metadata})
+			Expect(err).ToNot(HaveOccurred())
+
+			m, err := db.GetPackageFileMetadata(a)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m).To(Equal(metadata))
+
+			Expect(db.RemovePackageFiles(a)).To(Succeed())
+			_, err = db.GetPackageFileMetadata(a)
+			Expect(err).To(HaveOccurred())
+		})
+
 		It("Find specific package candidate", func() {
 			db := NewInMemoryDatabase(false)
 			a := types.NewPackage("A", "1.0", []*types.Package{}, []*types.Package{})
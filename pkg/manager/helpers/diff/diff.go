@@ -0,0 +1,273 @@
+package diff
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+	"strings"
+)
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// op is a line of the edit script turning a into b. a and b are the line
+// indexes in the two texts, set when the line belongs to them.
+type op struct {
+	kind opKind
+	a, b int
+}
+
+// Lines splits a text in lines, keeping their line terminators
+func Lines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lcs returns, for each line of a, the index of the matching line of b in a
+// longest common subsequence of the two, or -1
+func lcs(a, b []string) []int {
+	n, m := len(a), len(b)
+	table := make([][]int, n+1)
+	for i := range table {
+		table[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else if table[i+1][j] >= table[i][j+1] {
+				table[i][j] = table[i+1][j]
+			} else {
+				table[i][j] = table[i][j+1]
+			}
+		}
+	}
+
+	matches := make([]int, n)
+	i, j := 0, 0
+	for i < n {
+		switch {
+		case j < m && a[i] == b[j]:
+			matches[i] = j
+			i++
+			j++
+		case j < m && table[i][j+1] > table[i+1][j]:
+			j++
+		default:
+			matches[i] = -1
+			i++
+		}
+	}
+	return matches
+}
+
+func editScript(a, b []string) []op {
+	matches := lcs(a, b)
+	ops := []op{}
+	j := 0
+	for i := range a {
+		if matches[i] == -1 {
+			ops = append(ops, op{kind: opDelete, a: i, b: -1})
+			continue
+		}
+		for ; j < matches[i]; j++ {
+			ops = append(ops, op{kind: opInsert, a: -1, b: j})
+		}
+		ops = append(ops, op{kind: opEqual, a: i, b: j})
+		j++
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, op{kind: opInsert, a: -1, b: j})
+	}
+	return ops
+}
+
+// Unified returns the unified diff between the texts a and b, with the given
+// lines of context around the changes. It is empty if the texts are equal.
+func Unified(a, b, fromName, toName string, context int) string {
+	la, lb := Lines(a), Lines(b)
+	ops := editScript(la, lb)
+
+	// Number of lines of a and b before each operation
+	aPos, bPos := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for i, o := range ops {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if o.a != -1 {
+			aPos[i+1]++
+		}
+		if o.b != -1 {
+			bPos[i+1]++
+		}
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(ops); {
+		if ops[i].kind == opEqual {
+			i++
+			continue
+		}
+
+		// Changes closer than twice the context lines go in the same hunk
+		last := i
+		for n := i + 1; n < len(ops); n++ {
+			if ops[n].kind == opEqual {
+				continue
+			}
+			if n-last-1 > 2*context {
+				break
+			}
+			last = n
+		}
+		from, to := i-context, last+context+1
+		if from < 0 {
+			from = 0
+		}
+		if to > len(ops) {
+			to = len(ops)
+		}
+
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n",
+			formatRange(aPos[from], aPos[to]-aPos[from]),
+			formatRange(bPos[from], bPos[to]-bPos[from]))
+		for _, o := range ops[from:to] {
+			switch o.kind {
+			case opEqual:
+				writeLine(&sb, " ", la[o.a])
+			case opDelete:
+				writeLine(&sb, "-", la[o.a])
+			case opInsert:
+				writeLine(&sb, "+", lb[o.b])
+			}
+		}
+		i = to
+	}
+	return sb.String()
+}
+
+// formatRange formats a hunk range, from the number of lines before it
+// and its length. Empty ranges refer to the line before them.
+func formatRange(before, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, length)
+}
+
+func writeLine(sb *strings.Builder, prefix, line string) {
+	sb.WriteString(prefix)
+	sb.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// Merge does a three-way merge of the changes from base to ours and from
+// base to theirs. Lines changed differently on both sides are wrapped in
+// conflict markers, and conflicts is set.
+func Merge(base, ours, theirs string) (merged string, conflicts bool) {
+	lbase, lours, ltheirs := Lines(base), Lines(ours), Lines(theirs)
+	mo, mt := lcs(lbase, lours), lcs(lbase, ltheirs)
+
+	var sb strings.Builder
+	i, j, k := 0, 0, 0
+	for {
+		// Next base line kept on both sides
+		b := i
+		for b < len(lbase) && (mo[b] == -1 || mt[b] == -1) {
+			b++
+		}
+		jo, kt := len(lours), len(ltheirs)
+		if b < len(lbase) {
+			jo, kt = mo[b], mt[b]
+		}
+
+		if b == i && jo == j && kt == k {
+			if b == len(lbase) {
+				break
+			}
+			sb.WriteString(lbase[b])
+			i, j, k = b+1, jo+1, kt+1
+			continue
+		}
+
+		chunkBase, chunkOurs, chunkTheirs := lbase[i:b], lours[j:jo], ltheirs[k:kt]
+		switch {
+		case equal(chunkOurs, chunkBase):
+			writeLines(&sb, chunkTheirs)
+		case equal(chunkTheirs, chunkBase), equal(chunkOurs, chunkTheirs):
+			writeLines(&sb, chunkOurs)
+		default:
+			conflicts = true
+			sb.WriteString("<<<<<<< installed\n")
+			writeLines(&sb, terminated(chunkOurs))
+			sb.WriteString("=======\n")
+			writeLines(&sb, terminated(chunkTheirs))
+			sb.WriteString(">>>>>>> new\n")
+		}
+		i, j, k = b, jo, kt
+	}
+	return sb.String(), conflicts
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLines(sb *strings.Builder, lines []string) {
+	for _, l := range lines {
+		sb.WriteString(l)
+	}
+}
+
+// terminated makes sure the last line ends with a newline, before a conflict marker
+func terminated(lines []string) []string {
+	if len(lines) == 0 || strings.HasSuffix(lines[len(lines)-1], "\n") {
+		return lines
+	}
+	res := append([]string{}, lines...)
+	res[len(res)-1] += "\n"
+	return res
+}
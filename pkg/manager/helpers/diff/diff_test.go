@@ -0,0 +1,107 @@
+package diff_test
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	. "github.com/bhojpur/iso/pkg/manager/helpers/diff"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diff", func() {
+	Context("Unified", func() {
+		It("is empty for equal texts", func() {
+			Expect(Unified("a\nb\n", "a\nb\n", "a", "b", 3)).To(BeEmpty())
+		})
+
+		It("reports changed lines with context", func() {
+			a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+			b := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\neleven\n"
+			Expect(Unified(a, b, "old", "new", 1)).To(Equal(`--- old
++++ new
+@@ -4,3 +4,3 @@
+ 4
+-5
++five
+ 6
+@@ -10 +10,2 @@
+ 10
++eleven
+`))
+		})
+
+		It("merges close changes in a hunk", func() {
+			Expect(Unified("a\nb\nc\nd\n", "A\nb\nc\nD\n", "old", "new", 1)).To(Equal(`--- old
++++ new
+@@ -1,4 +1,4 @@
+-a
++A
+ b
+ c
+-d
++D
+`))
+		})
+
+		It("handles empty texts and missing newlines", func() {
+			Expect(Unified("", "a\nb", "old", "new", 3)).To(Equal(`--- old
++++ new
+@@ -0,0 +1,2 @@
++a
++b
+\ No newline at end of file
+`))
+		})
+	})
+
+	Context("Merge", func() {
+		base := "a\nb\nc\nd\ne\n"
+
+		It("applies the changes of both sides", func() {
+			merged, conflicts := Merge(base, "a\nB\nc\nd\ne\n", "a\nb\nc\nD\ne\nf\n")
+			Expect(conflicts).To(BeFalse())
+			Expect(merged).To(Equal("a\nB\nc\nD\ne\nf\n"))
+		})
+
+		It("takes identical changes once", func() {
+			merged, conflicts := Merge(base, "a\nX\nc\nd\ne\n", "a\nX\nc\nd\ne\n")
+			Expect(conflicts).To(BeFalse())
+			Expect(merged).To(Equal("a\nX\nc\nd\ne\n"))
+		})
+
+		It("marks conflicting changes", func() {
+			merged, conflicts := Merge(base, "a\nours\nc\nd\ne\n", "a\ntheirs\nc\nd\ne\n")
+			Expect(conflicts).To(BeTrue())
+			Expect(merged).To(Equal("a\n<<<<<<< installed\nours\n=======\ntheirs\n>>>>>>> new\nc\nd\ne\n"))
+		})
+
+		It("handles removed lines and a missing base", func() {
+			merged, conflicts := Merge(base, "a\nc\nd\ne\n", "a\nb\nc\nd\ne\nf\n")
+			Expect(conflicts).To(BeFalse())
+			Expect(merged).To(Equal("a\nc\nd\ne\nf\n"))
+
+			merged, conflicts = Merge("", "a\n", "b\n")
+			Expect(conflicts).To(BeTrue())
+			Expect(merged).To(Equal("<<<<<<< installed\na\n=======\nb\n>>>>>>> new\n"))
+		})
+	})
+})
@@ -126,6 +126,19 @@ func (s staticAuth) Authorization() (*authn.AuthConfig, error) {
 	}, nil
 }
 
+// ImageDigest returns the digest of a remote image, without pulling it
+func ImageDigest(image string, auth *types.AuthConfig) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	desc, err := remote.Head(ref, remote.WithAuth(staticAuth{auth}))
+	if err != nil {
+		return "", err
+	}
+	return desc.Digest.String(), nil
+}
+
 // UnpackEventData is the data structure to pass for the bus events
 type UnpackEventData struct {
 	Image string
@@ -0,0 +1,212 @@
+package oci
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	ggcrtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ConfigMediaType identifies the manifests of the Bhojpur ISO artifacts
+	ConfigMediaType ggcrtypes.MediaType = "application/vnd.bhojpur.iso.config.v1+json"
+	// PackageMediaType is the media type of the package artifacts
+	PackageMediaType ggcrtypes.MediaType = "application/vnd.bhojpur.iso.package.v1"
+	// FileMediaType is the media type of the repository files (index, trees, signatures)
+	FileMediaType ggcrtypes.MediaType = "application/vnd.bhojpur.iso.file.v1"
+
+	// AnnotationTitle is the standard annotation holding the file name
+	AnnotationTitle = "org.opencontainers.image.title"
+	// AnnotationPackageName, AnnotationPackageCategory and AnnotationPackageVersion
+	// identify the package of an artifact
+	AnnotationPackageName     = "org.bhojpur.iso.package.name"
+	AnnotationPackageCategory = "org.bhojpur.iso.package.category"
+	AnnotationPackageVersion  = "org.bhojpur.iso.package.version"
+	// AnnotationChecksumPrefix prefixes the annotations holding the artifact
+	// checksums, with the algorithm as suffix (e.g. org.bhojpur.iso.checksum.sha256)
+	AnnotationChecksumPrefix = "org.bhojpur.iso.checksum."
+)
+
+// ErrNotFound is returned when the referenced artifact doesn't exist
+var ErrNotFound = errors.New("artifact not found")
+
+// fileLayer is a layer holding a file as is, read from the disk when pushed
+type fileLayer struct {
+	path      string
+	mediaType ggcrtypes.MediaType
+	digest    v1.Hash
+	size      int64
+}
+
+func newFileLayer(path string, mediaType ggcrtypes.MediaType) (*fileLayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return nil, err
+	}
+	return &fileLayer{
+		path:      path,
+		mediaType: mediaType,
+		digest:    v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(h.Sum(nil))},
+		size:      size,
+	}, nil
+}
+
+func (l *fileLayer) Digest() (v1.Hash, error)                { return l.digest, nil }
+func (l *fileLayer) DiffID() (v1.Hash, error)                { return l.digest, nil }
+func (l *fileLayer) Compressed() (io.ReadCloser, error)      { return os.Open(l.path) }
+func (l *fileLayer) Uncompressed() (io.ReadCloser, error)    { return os.Open(l.path) }
+func (l *fileLayer) Size() (int64, error)                    { return l.size, nil }
+func (l *fileLayer) MediaType() (ggcrtypes.MediaType, error) { return l.mediaType, nil }
+
+// Push pushes the file to ref as an OCI artifact with a single layer of the given
+// media type. The annotations are set on the manifest, and the file name is set
+// in the title annotation of the layer. It returns the digest of the manifest.
+func Push(ref, file string, mediaType ggcrtypes.MediaType, annotations map[string]string, opts ...remote.Option) (string, error) {
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+
+	layer, err := newFileLayer(file, mediaType)
+	if err != nil {
+		return "", err
+	}
+
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       layer,
+		MediaType:   mediaType,
+		Annotations: map[string]string{AnnotationTitle: filepath.Base(file)},
+	})
+	if err != nil {
+		return "", err
+	}
+	img = mutate.MediaType(img, ggcrtypes.OCIManifestSchema1)
+	img = mutate.ConfigMediaType(img, ConfigMediaType)
+	if len(annotations) > 0 {
+		img = mutate.Annotations(img, annotations).(v1.Image)
+	}
+
+	if err := remote.Write(r, img, opts...); err != nil {
+		return "", errors.Wrapf(err, "while pushing %s", ref)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return "", err
+	}
+	return digest.String(), nil
+}
+
+// Pull writes in dst the file of the artifact referenced by ref,
+// and returns the digest of its manifest
+func Pull(ref, dst string, opts ...remote.Option) (string, error) {
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+
+	img, err := remote.Image(r, opts...)
+	if err != nil {
+		return "", notFound(err)
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return "", err
+	}
+	if manifest.Config.MediaType != ConfigMediaType || len(manifest.Layers) != 1 {
+		return "", errors.Errorf("%s is not a Bhojpur ISO artifact", ref)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return "", err
+	}
+	blob, err := layers[0].Compressed()
+	if err != nil {
+		return "", errors.Wrapf(err, "while pulling %s", ref)
+	}
+	defer blob.Close()
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, blob); err != nil {
+		return "", errors.Wrapf(err, "while pulling %s", ref)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return "", err
+	}
+	return digest.String(), nil
+}
+
+// Digest returns the digest of the manifest of the artifact referenced by ref
+func Digest(ref string, opts ...remote.Option) (string, error) {
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	desc, err := remote.Head(r, opts...)
+	if err != nil {
+		return "", notFound(err)
+	}
+	return desc.Digest.String(), nil
+}
+
+// Exists returns true if the artifact referenced by ref exists
+func Exists(ref string, opts ...remote.Option) (bool, error) {
+	_, err := Digest(ref, opts...)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// notFound returns ErrNotFound if the registry answered that the reference doesn't exist
+func notFound(err error) error {
+	var terr *transport.Error
+	if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return err
+}
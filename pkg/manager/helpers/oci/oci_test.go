@@ -0,0 +1,91 @@
+package oci_test
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/bhojpur/iso/pkg/manager/helpers/oci"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OCI", func() {
+	var dir, repo string
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "oci")
+		Expect(err).ToNot(HaveOccurred())
+		server = httptest.NewServer(registry.New())
+		repo = strings.TrimPrefix(server.URL, "http://") + "/isomgr/repo"
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("Pushes and pulls artifacts", func() {
+		src := filepath.Join(dir, "app-test-1.0.package.tar")
+		Expect(ioutil.WriteFile(src, []byte("test"), 0644)).ToNot(HaveOccurred())
+
+		exists, err := Exists(repo + ":app")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists).To(BeFalse())
+
+		digest, err := Push(repo+":app", src, PackageMediaType, map[string]string{AnnotationPackageName: "app"})
+		Expect(err).ToNot(HaveOccurred())
+
+		current, err := Digest(repo + ":app")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(current).To(Equal(digest))
+
+		ref, err := name.ParseReference(repo + ":app")
+		Expect(err).ToNot(HaveOccurred())
+		img, err := remote.Image(ref)
+		Expect(err).ToNot(HaveOccurred())
+		manifest, err := img.Manifest()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(manifest.Config.MediaType).To(Equal(ConfigMediaType))
+		Expect(manifest.Annotations).To(HaveKeyWithValue(AnnotationPackageName, "app"))
+		Expect(manifest.Layers).To(HaveLen(1))
+		Expect(manifest.Layers[0].MediaType).To(Equal(PackageMediaType))
+		Expect(manifest.Layers[0].Annotations).To(HaveKeyWithValue(AnnotationTitle, "app-test-1.0.package.tar"))
+
+		dst := filepath.Join(dir, "dst")
+		pulled, err := Pull(repo+":app", dst)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pulled).To(Equal(digest))
+		Expect(ioutil.ReadFile(dst)).To(Equal([]byte("test")))
+
+		_, err = Pull(repo+":missing", dst)
+		Expect(err).To(Equal(ErrNotFound))
+	})
+})
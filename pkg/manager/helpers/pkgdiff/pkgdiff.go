@@ -0,0 +1,99 @@
+package pkgdiff
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"sort"
+)
+
+// Package is a package of a set, identified by its category and name
+type Package struct {
+	Category string `json:"category" yaml:"category"`
+	Name     string `json:"name" yaml:"name"`
+	Version  string `json:"version" yaml:"version"`
+}
+
+// Change is a package whose version differs between two sets
+type Change struct {
+	Category string `json:"category" yaml:"category"`
+	Name     string `json:"name" yaml:"name"`
+	From     string `json:"from" yaml:"from"`
+	To       string `json:"to" yaml:"to"`
+}
+
+// Diff lists the changes between two package sets, sorted by package
+type Diff struct {
+	Added   []Package `json:"added,omitempty" yaml:"added,omitempty"`
+	Removed []Package `json:"removed,omitempty" yaml:"removed,omitempty"`
+	Changed []Change  `json:"changed,omitempty" yaml:"changed,omitempty"`
+}
+
+// Key returns the category/name key identifying the package in a set
+func (p Package) Key() string {
+	return p.Category + "/" + p.Name
+}
+
+// Key returns the category/name key identifying the changed package in a set
+func (c Change) Key() string {
+	return c.Category + "/" + c.Name
+}
+
+// Empty returns true if the two compared sets have the same packages
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Compare reports the changes needed to go from the package set src to dst.
+// Packages found in both sets are changed when their versions differ.
+func Compare(src, dst []Package) *Diff {
+	res := &Diff{}
+	srcPkgs, dstPkgs := map[string]Package{}, map[string]Package{}
+	for _, p := range src {
+		srcPkgs[p.Key()] = p
+	}
+	for _, p := range dst {
+		dstPkgs[p.Key()] = p
+	}
+
+	for k, p := range dstPkgs {
+		old, exists := srcPkgs[k]
+		switch {
+		case !exists:
+			res.Added = append(res.Added, p)
+		case old.Version != p.Version:
+			res.Changed = append(res.Changed, Change{Category: p.Category, Name: p.Name, From: old.Version, To: p.Version})
+		}
+	}
+	for k, p := range srcPkgs {
+		if _, exists := dstPkgs[k]; !exists {
+			res.Removed = append(res.Removed, p)
+		}
+	}
+
+	sortPackages(res.Added)
+	sortPackages(res.Removed)
+	sort.SliceStable(res.Changed, func(i, j int) bool { return res.Changed[i].Key() < res.Changed[j].Key() })
+	return res
+}
+
+func sortPackages(p []Package) {
+	sort.SliceStable(p, func(i, j int) bool { return p[i].Key() < p[j].Key() })
+}
@@ -0,0 +1,61 @@
+package pkgdiff_test
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	. "github.com/bhojpur/iso/pkg/manager/helpers/pkgdiff"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Package diff", func() {
+	Context("Compare", func() {
+		It("is empty for equal sets", func() {
+			set := []Package{{Category: "app", Name: "a", Version: "1.0"}}
+			Expect(Compare(set, set).Empty()).To(BeTrue())
+		})
+
+		It("reports added, removed and changed packages sorted by key", func() {
+			src := []Package{
+				{Category: "app", Name: "c", Version: "1.0"},
+				{Category: "app", Name: "b", Version: "1.0"},
+				{Category: "app", Name: "x", Version: "1.0"},
+			}
+			dst := []Package{
+				{Category: "app", Name: "z", Version: "1.0"},
+				{Category: "app", Name: "a", Version: "1.0"},
+				{Category: "app", Name: "b", Version: "2.0"},
+				{Category: "app", Name: "x", Version: "1.0"},
+			}
+
+			diff := Compare(src, dst)
+			Expect(diff.Added).To(Equal([]Package{{Category: "app", Name: "a", Version: "1.0"}, {Category: "app", Name: "z", Version: "1.0"}}))
+			Expect(diff.Removed).To(Equal([]Package{{Category: "app", Name: "c", Version: "1.0"}}))
+			Expect(diff.Changed).To(Equal([]Change{{Category: "app", Name: "b", From: "1.0", To: "2.0"}}))
+		})
+
+		It("reports a version which is known only on one side as changed", func() {
+			diff := Compare([]Package{{Category: "app", Name: "a"}}, []Package{{Category: "app", Name: "a", Version: "1.0"}})
+			Expect(diff.Changed).To(Equal([]Change{{Category: "app", Name: "a", From: "", To: "1.0"}}))
+		})
+	})
+})
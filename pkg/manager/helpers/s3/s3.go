@@ -0,0 +1,367 @@
+package s3
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Scheme is the scheme of the URLs pointing to a bucket (s3://bucket/prefix)
+	Scheme = "s3"
+	// DefaultRegion is the region used when none is configured
+	DefaultRegion = "us-east-1"
+
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat    = "20060102T150405Z"
+	// emptyPayloadHash is the SHA256 of an empty payload
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// ErrNotFound is returned when the requested object doesn't exist
+var ErrNotFound = errors.New("object not found")
+
+// ErrNotModified is returned by conditional requests when the object didn't change
+var ErrNotModified = errors.New("object not modified")
+
+// Credentials are the credentials used to sign the requests.
+// Requests are sent anonymously if AccessKeyID is empty.
+type Credentials struct {
+	AccessKeyID, SecretAccessKey, SessionToken string
+}
+
+// EnvCredentials returns the credentials set in the standard AWS environment variables
+func EnvCredentials() Credentials {
+	return Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// Client reads and writes the objects of a bucket
+type Client struct {
+	// Endpoint is the URL of the object storage, the AWS S3 endpoint
+	// of the region if empty
+	Endpoint string
+	Region   string
+	Bucket   string
+	// PathStyle addresses the bucket in the URL path (endpoint/bucket/key)
+	// instead of in the host name (bucket.endpoint/key)
+	PathStyle   bool
+	Credentials Credentials
+	HTTPClient  *http.Client
+}
+
+// ParseURL returns the bucket and the prefix of an URL in the s3://bucket/prefix form
+func ParseURL(u string) (bucket, prefix string, err error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", "", err
+	}
+	if parsed.Scheme != Scheme || parsed.Host == "" {
+		return "", "", errors.Errorf("invalid S3 URL '%s', expected s3://bucket/prefix", u)
+	}
+	return parsed.Host, strings.Trim(parsed.Path, "/"), nil
+}
+
+// Key joins the prefix and the name of an object in a key
+func Key(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
+}
+
+func (c *Client) region() string {
+	if c.Region == "" {
+		return DefaultRegion
+	}
+	return c.Region
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// objectURL returns the URL of the object with the given key
+func (c *Client) objectURL(key string) (*url.URL, error) {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", c.region())
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid S3 endpoint '%s'", endpoint)
+	}
+	if c.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.Bucket + "/" + key
+	} else {
+		u.Host = c.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	return u, nil
+}
+
+func (c *Client) newRequest(method, key string, body io.Reader) (*http.Request, error) {
+	u, err := c.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	return http.NewRequest(method, u.String(), body)
+}
+
+// do signs and sends the request, returning an error for unsuccessful responses
+func (c *Client) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	c.SignRequest(req, payloadHash, time.Now())
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	case resp.StatusCode == http.StatusNotModified:
+		resp.Body.Close()
+		return nil, ErrNotModified
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, errors.Errorf("%s %s: %s %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// Get returns the response with the content of the object. If etag is not
+// empty, ErrNotModified is returned if the object didn't change since then.
+// The caller must close the response body.
+func (c *Client) Get(key, etag string) (*http.Response, error) {
+	req, err := c.newRequest(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	return c.do(req, emptyPayloadHash)
+}
+
+// Download writes the content of the object in the file dst, and returns its ETag
+func (c *Client) Download(key, dst string) (string, error) {
+	resp, err := c.Get(key, "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return "", errors.Wrapf(err, "while downloading %s", key)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+// Exists returns true if the object with the given key exists
+func (c *Client) Exists(key string) (bool, error) {
+	req, err := c.newRequest(http.MethodHead, key, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.do(req, emptyPayloadHash)
+	if err == ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// Upload uploads the file src as the object with the given key
+func (c *Client) Upload(src, key string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(http.MethodPut, key, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	resp, err := c.do(req, hex.EncodeToString(h.Sum(nil)))
+	if err != nil {
+		return errors.Wrapf(err, "while uploading %s", key)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// SignRequest signs the request with AWS Signature Version 4. The signed headers are
+// the host, the range and the x-amz-* ones. payloadHash is the hex encoded SHA256 of
+// the request body.
+func (c *Client) SignRequest(req *http.Request, payloadHash string, t time.Time) {
+	if c.Credentials.AccessKeyID == "" {
+		return
+	}
+
+	t = t.UTC()
+	amzDate := t.Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.Credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.Credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "x-amz-") || k == "range" {
+			headers[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := ""
+	for _, k := range names {
+		canonicalHeaders += k + ":" + headers[k] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.EscapedPath()),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{t.Format("20060102"), c.region(), "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		amzDate,
+		scope,
+		hashHex(canonicalRequest),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.Credentials.SecretAccessKey), t.Format("20060102"))
+	key = hmacSHA256(key, c.region())
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm, c.Credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// escapePath encodes the path as required by the canonical request, where
+// only the unreserved characters are left unescaped in each segment
+func escapePath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		unescaped, err := url.PathUnescape(s)
+		if err != nil {
+			unescaped = s
+		}
+		segments[i] = escape(unescaped)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(v url.Values) string {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := []string{}
+	for _, k := range keys {
+		values := v[k]
+		sort.Strings(values)
+		for _, val := range values {
+			parts = append(parts, escape(k)+"="+escape(val))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hashHex(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
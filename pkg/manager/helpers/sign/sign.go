@@ -0,0 +1,253 @@
+package sign
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// SignatureSuffix is the suffix of the detached signature files
+const SignatureSuffix = ".sig"
+
+// Signature is an ed25519 signature, identified by the ID of the key which
+// generated it
+type Signature struct {
+	KeyID     string `json:"keyid" yaml:"keyid"`
+	Signature string `json:"signature" yaml:"signature"`
+}
+
+// Signatures is a set of signatures of the same payload. Signing with more than
+// one key allows rotating keys without breaking the clients that trust only
+// the old or only the new one.
+type Signatures []Signature
+
+// GenerateKey generates a new ed25519 key pair
+func GenerateKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+// KeyID returns the ID of a public key: the first 8 bytes of its sha256 in hex form
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// EncodePublicKey returns the base64 form of a public key, as it is
+// written in the key files and in the repositories configuration
+func EncodePublicKey(pub ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(pub)
+}
+
+// DecodePublicKey parses the base64 form of a public key
+func DecodePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid public key")
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key: expected %d bytes, got %d", ed25519.PublicKeySize, len(b))
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// ReadPublicKey reads a public key file
+func ReadPublicKey(path string) (ed25519.PublicKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecodePublicKey(string(b))
+}
+
+// WritePublicKey writes a public key file
+func WritePublicKey(path string, pub ed25519.PublicKey) error {
+	return ioutil.WriteFile(path, []byte(EncodePublicKey(pub)+"\n"), 0644)
+}
+
+// ReadPrivateKey reads a private key file, holding the base64 form of the key seed
+func ReadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid private key %s", path)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid private key %s: expected %d bytes, got %d", path, ed25519.SeedSize, len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// WritePrivateKey writes a private key file, readable only by the owner
+func WritePrivateKey(path string, priv ed25519.PrivateKey) error {
+	return ioutil.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(priv.Seed())+"\n"), 0600)
+}
+
+// Sign signs the payload with each of the given keys
+func Sign(payload []byte, keys ...ed25519.PrivateKey) Signatures {
+	res := Signatures{}
+	for _, k := range keys {
+		res = append(res, Signature{
+			KeyID:     KeyID(k.Public().(ed25519.PublicKey)),
+			Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(k, payload)),
+		})
+	}
+	return res
+}
+
+// SignFile signs the file with each of the given keys, and writes the
+// signatures next to it, in a file with the SignatureSuffix
+func SignFile(file string, keys ...ed25519.PrivateKey) error {
+	payload, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(Sign(payload, keys...))
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file+SignatureSuffix, data, 0644)
+}
+
+// ReadSignatures reads a detached signature file
+func ReadSignatures(file string) (Signatures, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	res := Signatures{}
+	if err := yaml.Unmarshal(b, &res); err != nil {
+		return nil, errors.Wrapf(err, "invalid signature file %s", file)
+	}
+	return res, nil
+}
+
+// Keyring is a set of trusted public keys, indexed by their ID
+type Keyring map[string]ed25519.PublicKey
+
+// NewKeyring returns a keyring trusting the given base64 encoded public keys
+func NewKeyring(keys ...string) (Keyring, error) {
+	k := Keyring{}
+	for _, s := range keys {
+		pub, err := DecodePublicKey(s)
+		if err != nil {
+			return nil, err
+		}
+		k.Add(pub)
+	}
+	return k, nil
+}
+
+// LoadKeyring returns a keyring trusting the public keys stored in the
+// ".pub" files of a folder. A missing folder is an empty keyring.
+func LoadKeyring(dir string) (Keyring, error) {
+	k := Keyring{}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return k, nil
+		}
+		return nil, err
+	}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".pub" {
+			continue
+		}
+		pub, err := ReadPublicKey(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading %s", f.Name())
+		}
+		k.Add(pub)
+	}
+	return k, nil
+}
+
+// Add trusts the public key
+func (k Keyring) Add(pub ed25519.PublicKey) {
+	k[KeyID(pub)] = pub
+}
+
+// Merge trusts all the keys of the other keyring
+func (k Keyring) Merge(o Keyring) {
+	for id, pub := range o {
+		k[id] = pub
+	}
+}
+
+// IDs returns the sorted IDs of the trusted keys
+func (k Keyring) IDs() []string {
+	res := []string{}
+	for id := range k {
+		res = append(res, id)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Verify checks that the payload is signed by at least one of the trusted keys.
+// Signatures of keys which aren't trusted are ignored, while an invalid signature
+// of a trusted key is always an error.
+func (k Keyring) Verify(payload []byte, sigs Signatures) error {
+	verified := false
+	for _, s := range sigs {
+		pub, ok := k[s.KeyID]
+		if !ok {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(s.Signature)
+		if err != nil || !ed25519.Verify(pub, payload, sig) {
+			return fmt.Errorf("invalid signature from key %s", s.KeyID)
+		}
+		verified = true
+	}
+	if !verified {
+		return errors.New("not signed by any trusted key")
+	}
+	return nil
+}
+
+// VerifyFile checks the file against its detached signature file
+func (k Keyring) VerifyFile(file, signatureFile string) error {
+	payload, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	sigs, err := ReadSignatures(signatureFile)
+	if err != nil {
+		return errors.Wrap(err, "while reading signatures")
+	}
+	return k.Verify(payload, sigs)
+}
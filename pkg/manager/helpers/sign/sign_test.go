@@ -0,0 +1,110 @@
+package sign_test
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/bhojpur/iso/pkg/manager/helpers/sign"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sign", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir(os.TempDir(), "sign")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("Reads back the written keys", func() {
+		pub, priv, err := GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(WritePrivateKey(filepath.Join(dir, "key"), priv)).ToNot(HaveOccurred())
+		Expect(WritePublicKey(filepath.Join(dir, "key.pub"), pub)).ToNot(HaveOccurred())
+
+		readPriv, err := ReadPrivateKey(filepath.Join(dir, "key"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(readPriv).To(Equal(priv))
+
+		readPub, err := ReadPublicKey(filepath.Join(dir, "key.pub"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(readPub).To(Equal(pub))
+
+		k, err := LoadKeyring(dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(k.IDs()).To(Equal([]string{KeyID(pub)}))
+	})
+
+	It("Verifies files signed by trusted keys", func() {
+		pub, priv, err := GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+		file := filepath.Join(dir, "repository.yaml")
+		Expect(ioutil.WriteFile(file, []byte("revision: 1"), 0644)).ToNot(HaveOccurred())
+
+		Expect(SignFile(file, priv)).ToNot(HaveOccurred())
+
+		k, err := NewKeyring(EncodePublicKey(pub))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(k.VerifyFile(file, file+SignatureSuffix)).ToNot(HaveOccurred())
+
+		Expect(ioutil.WriteFile(file, []byte("revision: 2"), 0644)).ToNot(HaveOccurred())
+		Expect(k.VerifyFile(file, file+SignatureSuffix)).To(HaveOccurred())
+	})
+
+	It("Rejects files signed only by untrusted keys", func() {
+		_, priv, err := GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+		pub, _, err := GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+
+		k := Keyring{}
+		k.Add(pub)
+		Expect(k.Verify([]byte("payload"), Sign([]byte("payload"), priv))).To(HaveOccurred())
+	})
+
+	It("Accepts payloads signed with both the old and the new key while rotating", func() {
+		oldPub, oldPriv, err := GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+		newPub, newPriv, err := GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+
+		sigs := Sign([]byte("payload"), oldPriv, newPriv)
+
+		oldKeyring := Keyring{}
+		oldKeyring.Add(oldPub)
+		Expect(oldKeyring.Verify([]byte("payload"), sigs)).ToNot(HaveOccurred())
+
+		newKeyring := Keyring{}
+		newKeyring.Add(newPub)
+		Expect(newKeyring.Verify([]byte("payload"), sigs)).ToNot(HaveOccurred())
+	})
+})
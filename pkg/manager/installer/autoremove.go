@@ -0,0 +1,146 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"sort"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	pkg "github.com/bhojpur/iso/pkg/manager/database"
+	"github.com/bhojpur/iso/pkg/manager/solver"
+
+	"github.com/pkg/errors"
+)
+
+// SetInstallReason records in the system database why an installed package was installed
+func (s *System) SetInstallReason(p *types.Package, reason string) error {
+	if reason != types.InstallReasonExplicit && reason != types.InstallReasonDependency {
+		return errors.Errorf("invalid install reason '%s'", reason)
+	}
+	installed, err := s.Database.FindPackage(p)
+	if err != nil {
+		return errors.Errorf("package %s is not installed", p.HumanReadableString())
+	}
+	if err := s.journalReason(installed); err != nil {
+		return err
+	}
+	installed.SetInstallReason(reason)
+	return errors.Wrap(s.Database.UpdatePackage(installed), "while updating package")
+}
+
+// Orphans returns the packages installed as dependencies which are not
+// required anymore, directly or not, by any explicitly installed package.
+// Dependencies are dropped from the installed set one at a time for as long as
+// the solver finds that the packages left, with their requires, provides and
+// conflicts, don't need them.
+func (l *BhojpurInstaller) Orphans(s *System) (types.Packages, error) {
+	installed, err := s.Database.Copy()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed create temporary in-memory db")
+	}
+	solv := solver.NewResolver(
+		types.SolverOptions{
+			Type:        l.Options.SolverOptions.Implementation,
+			Concurrency: l.Options.Concurrency,
+		},
+		installed,
+		installed,
+		pkg.NewInMemoryDatabase(false),
+		solver.NewSolverFromOptions(l.Options.SolverOptions))
+
+	kept := installed.World()
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].HumanReadableString() < kept[j].HumanReadableString()
+	})
+
+	orphans := types.Packages{}
+	for changed := true; changed; {
+		changed = false
+		for i := 0; i < len(kept); i++ {
+			p := kept[i]
+			if p.IsExplicit() {
+				continue
+			}
+			needed, err := solv.ConflictsWith(p, kept)
+			if err != nil {
+				return nil, errors.Wrapf(err, "while checking if %s is needed", p.HumanReadableString())
+			}
+			if needed {
+				continue
+			}
+			orphans = append(orphans, p)
+			kept = append(kept[:i], kept[i+1:]...)
+			i--
+			changed = true
+		}
+	}
+
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].HumanReadableString() < orphans[j].HumanReadableString()
+	})
+	return orphans, nil
+}
+
+// Autoremove uninstalls the packages installed as dependencies which are not needed anymore
+func (l *BhojpurInstaller) Autoremove(s *System) (err error) {
+	l.Options.Context.Screen("Autoremove")
+
+	orphans, err := l.Orphans(s)
+	if err != nil {
+		return errors.Wrap(err, "while computing packages not needed anymore")
+	}
+	if len(orphans) == 0 {
+		l.Options.Context.Info("Nothing to do")
+		return nil
+	}
+
+	commit, err := l.beginTransaction(s, "autoremove")
+	if err != nil {
+		return err
+	}
+	defer func() { err = commit(err) }()
+
+	// The orphans are computed already, they don't need to be solved again
+	o := Option{
+		NoDeps: true,
+		Force:  l.Options.Force,
+	}
+	toUninstall, uninstall, err := l.generateUninstallFn(o, s, map[string]interface{}{}, orphans...)
+	if err != nil {
+		return errors.Wrap(err, "while computing uninstall")
+	}
+
+	if l.Options.Pretend {
+		return l.addToPlan(nil, toUninstall, nil)
+	}
+
+	if l.Options.Ask {
+		l.Options.Context.Info(":recycle: Packages that are going to be removed from the system:")
+		printList(toUninstall)
+		if l.Options.Context.Ask() {
+			l.Options.Ask = false // Don't prompt anymore
+			return uninstall()
+		} else {
+			return errors.New("Aborted by user")
+		}
+	}
+	return uninstall()
+}
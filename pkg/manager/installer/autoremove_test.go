@@ -0,0 +1,119 @@
+package installer_test
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	pkg "github.com/bhojpur/iso/pkg/manager/database"
+	. "github.com/bhojpur/iso/pkg/manager/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Autoremove", func() {
+	var s *System
+	var inst *BhojpurInstaller
+	var app, lib, libdep, old, legacy *types.Package
+
+	create := func(p *types.Package, reason string) {
+		if reason != "" {
+			p.SetInstallReason(reason)
+		}
+		_, err := s.Database.CreatePackage(p)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s.Database.SetPackageFiles(&types.PackageFile{PackageFingerprint: p.GetFingerPrint()})).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		s = &System{Database: pkg.NewInMemoryDatabase(false), Target: "/"}
+		inst = NewBhojpurInstaller(BhojpurInstallerOptions{Concurrency: 1, Context: context.NewContext()})
+
+		libdep = types.NewPackage("libdep", "1.0", []*types.Package{}, []*types.Package{})
+		lib = types.NewPackage("lib", "1.0", []*types.Package{{Name: "libdep", Version: ">=0"}}, []*types.Package{})
+		app = types.NewPackage("app", "1.0", []*types.Package{{Name: "lib", Version: ">=0"}}, []*types.Package{})
+		old = types.NewPackage("old", "1.0", []*types.Package{}, []*types.Package{})
+		legacy = types.NewPackage("legacy", "1.0", []*types.Package{}, []*types.Package{})
+
+		create(app, types.InstallReasonExplicit)
+		create(lib, types.InstallReasonDependency)
+		create(libdep, types.InstallReasonDependency)
+		create(old, types.InstallReasonDependency)
+		create(legacy, "")
+	})
+
+	It("considers packages without an install reason as explicit", func() {
+		p, err := s.Database.FindPackage(legacy)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p.GetInstallReason()).To(Equal(types.InstallReasonExplicit))
+	})
+
+	It("finds the dependencies not required by explicit packages", func() {
+		orphans, err := inst.Orphans(s)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(orphans)).To(Equal(1))
+		Expect(orphans[0].GetName()).To(Equal("old"))
+	})
+
+	It("keeps the dependencies providing a package required by explicit packages", func() {
+		impl := types.NewPackage("impl", "1.0", []*types.Package{}, []*types.Package{})
+		impl.SetProvides([]*types.Package{{Name: "virtual", Version: ">=0"}})
+		client := types.NewPackage("client", "1.0", []*types.Package{{Name: "virtual", Version: ">=0"}}, []*types.Package{})
+		create(impl, types.InstallReasonDependency)
+		create(client, types.InstallReasonExplicit)
+
+		orphans, err := inst.Orphans(s)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(orphans)).To(Equal(1))
+		Expect(orphans[0].GetName()).To(Equal("old"))
+	})
+
+	It("changes the install reason of packages", func() {
+		Expect(s.SetInstallReason(app, types.InstallReasonDependency)).To(Succeed())
+		Expect(s.SetInstallReason(old, types.InstallReasonExplicit)).To(Succeed())
+
+		p, err := s.Database.FindPackage(old)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p.IsExplicit()).To(BeTrue())
+
+		orphans, err := inst.Orphans(s)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(orphans)).To(Equal(3))
+		Expect(orphans[0].GetName()).To(Equal("app"))
+		Expect(orphans[1].GetName()).To(Equal("lib"))
+		Expect(orphans[2].GetName()).To(Equal("libdep"))
+
+		Expect(s.SetInstallReason(app, "foo")).ToNot(Succeed())
+		Expect(s.SetInstallReason(types.NewPackage("missing", "1.0", nil, nil), types.InstallReasonExplicit)).ToNot(Succeed())
+	})
+
+	It("uninstalls the packages not needed anymore", func() {
+		Expect(inst.Autoremove(s)).To(Succeed())
+		Expect(len(s.Database.World())).To(Equal(4))
+		_, err := s.Database.FindPackage(old)
+		Expect(err).To(HaveOccurred())
+
+		Expect(s.SetInstallReason(app, types.InstallReasonDependency)).To(Succeed())
+		Expect(inst.Autoremove(s)).To(Succeed())
+		Expect(len(s.Database.World())).To(Equal(1))
+		_, err = s.Database.FindPackage(legacy)
+		Expect(err).ToNot(HaveOccurred())
+	})
+})
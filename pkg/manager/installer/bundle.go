@@ -0,0 +1,202 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	pkg "github.com/bhojpur/iso/pkg/manager/database"
+	"github.com/bhojpur/iso/pkg/manager/helpers"
+	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// BundleSpecFile is the file, at the root of a bundle, describing its content
+const BundleSpecFile = "bundle.yaml"
+
+// BundleSpec describes the content of an offline bundle: the disk repository
+// embedded in it and the packages it was created for
+type BundleSpec struct {
+	Repository string              `yaml:"repository"`
+	Packages   []GenerationPackage `yaml:"packages"`
+}
+
+// Bundle resolves the full dependency closure of the given packages against the
+// system repositories and writes a self-contained disk repository with all the
+// artifacts needed to install them in the tar archive dst
+func (l *BhojpurInstaller) Bundle(cp types.Packages, dst string) error {
+	syncedRepos, err := l.SyncRepositories()
+	if err != nil {
+		return err
+	}
+
+	o := Option{
+		NoDeps:   l.Options.NoDeps,
+		OnlyDeps: l.Options.OnlyDeps,
+		Force:    l.Options.Force,
+	}
+
+	// Solve against an empty system, so that the bundle can be installed anywhere
+	match, packages, _, _, err := l.computeInstall(o, syncedRepos, cp, &System{Database: pkg.NewInMemoryDatabase(false)})
+	if err != nil {
+		return err
+	}
+	if len(match) == 0 {
+		return errors.New("no packages to bundle")
+	}
+
+	l.Options.Context.Info("Packages that are going to be bundled:")
+	printMatches(match)
+
+	if err := l.download(syncedRepos, match); err != nil {
+		return errors.Wrap(err, "while downloading packages")
+	}
+
+	repoDir, err := l.Options.Context.TempDir("bundle")
+	if err != nil {
+		return errors.Wrap(err, "while creating bundle folder")
+	}
+	defer os.RemoveAll(repoDir)
+	treeDir, err := l.Options.Context.TempDir("bundle-tree")
+	if err != nil {
+		return errors.Wrap(err, "while creating bundle tree folder")
+	}
+	defer os.RemoveAll(treeDir)
+
+	copiedDefs := map[string]interface{}{}
+	for _, m := range match {
+		if err := l.bundleArtifact(m, repoDir); err != nil {
+			return err
+		}
+
+		// Carry the package definitions (and finalizers) from the repository tree
+		treePackage, err := m.Repository.GetTree().GetDatabase().FindPackage(m.Package)
+		if err != nil || treePackage.Path == "" {
+			continue
+		}
+		if _, done := copiedDefs[treePackage.Path]; done {
+			continue
+		}
+		copiedDefs[treePackage.Path] = nil
+		if err := fileHelper.CopyDir(treePackage.Path, filepath.Join(treeDir, strconv.Itoa(len(copiedDefs)))); err != nil {
+			return errors.Wrapf(err, "while copying definition of %s", m.Package.HumanReadableString())
+		}
+	}
+
+	name := fmt.Sprintf("bundle-%d", time.Now().Unix())
+	repo, err := GenerateRepository(
+		WithName(name),
+		WithDescription("Offline bundle"),
+		WithType(DiskRepositoryType),
+		WithSource(repoDir),
+		WithTree(treeDir),
+		FromMetadata(true),
+		WithDatabase(pkg.NewInMemoryDatabase(false)),
+		WithContext(l.Options.Context),
+	)
+	if err != nil {
+		return errors.Wrap(err, "while generating bundle repository")
+	}
+	if err := repo.Write(l.Options.Context, repoDir, true, true); err != nil {
+		return errors.Wrap(err, "while writing bundle repository")
+	}
+
+	spec := BundleSpec{Repository: name}
+	for _, p := range packages {
+		spec.Packages = append(spec.Packages, GenerationPackage{Category: p.GetCategory(), Name: p.GetName(), Version: p.GetVersion()})
+	}
+	b, err := yaml.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(repoDir, BundleSpecFile), b, 0644); err != nil {
+		return errors.Wrap(err, "while writing bundle spec")
+	}
+
+	if err := helpers.Tar(repoDir, dst); err != nil {
+		return errors.Wrap(err, "while creating bundle archive")
+	}
+	l.Options.Context.Success(fmt.Sprintf("Bundle with %d packages written to %s", len(match), dst))
+	return nil
+}
+
+// bundleArtifact copies the cached artifact of a match and its metadata in the bundle repository
+func (l *BhojpurInstaller) bundleArtifact(m ArtifactMatch, dst string) error {
+	cached, err := m.Repository.Client(l.Options.Context).CacheGet(m.Artifact)
+	if err != nil {
+		return errors.Wrapf(err, "artifact of %s not found in cache", m.Package.HumanReadableString())
+	}
+
+	fileName := filepath.Base(m.Artifact.Path)
+	if err := fileHelper.CopyFile(cached.Path, filepath.Join(dst, fileName)); err != nil {
+		return errors.Wrapf(err, "while copying artifact of %s", m.Package.HumanReadableString())
+	}
+
+	meta := m.Artifact.ShallowCopy()
+	meta.Path = fileName
+	b, err := yaml.Marshal(meta)
+	if err != nil {
+		return errors.Wrapf(err, "while serializing metadata of %s", m.Package.HumanReadableString())
+	}
+	return ioutil.WriteFile(filepath.Join(dst, m.Artifact.CompileSpec.GetPackage().GetMetadataFilePath()), b, 0644)
+}
+
+// InstallBundle installs the packages of an offline bundle created with Bundle,
+// using only the repository embedded in it
+func (l *BhojpurInstaller) InstallBundle(bundle string, cp types.Packages, s *System) error {
+	dir, err := l.Options.Context.TempDir("bundle")
+	if err != nil {
+		return errors.Wrap(err, "while creating bundle folder")
+	}
+	defer os.RemoveAll(dir)
+
+	if err := helpers.Untar(bundle, dir, false); err != nil {
+		return errors.Wrap(err, "while extracting bundle")
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, BundleSpecFile))
+	if err != nil {
+		return errors.Wrap(err, "invalid bundle")
+	}
+	spec := BundleSpec{}
+	if err := yaml.Unmarshal(b, &spec); err != nil {
+		return errors.Wrap(err, "invalid bundle")
+	}
+
+	if len(cp) == 0 {
+		for _, p := range spec.Packages {
+			cp = append(cp, p.Package())
+		}
+	}
+
+	repo := types.NewBhojpurRepository(spec.Repository, DiskRepositoryType, "Offline bundle", []string{dir}, 1, true, false)
+	l.Options.PackageRepositories = types.BhojpurRepositories{*repo}
+
+	return l.Install(cp, s)
+}
@@ -0,0 +1,106 @@
+package installer_test
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	pkg "github.com/bhojpur/iso/pkg/manager/database"
+	"github.com/bhojpur/iso/pkg/manager/helpers"
+	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
+	. "github.com/bhojpur/iso/pkg/manager/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bundles", func() {
+	Context("Round trip", func() {
+		var ctx *context.Context
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "bundle")
+			Expect(err).ToNot(HaveOccurred())
+
+			ctx = context.NewContext()
+			ctx.Config.System.DatabasePath = filepath.Join(dir, "db")
+			ctx.Config.System.PkgsCachePath = filepath.Join(dir, "cache")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("installs the bundled packages without the original repository", func() {
+			// Keep the repository next to its artifacts, so that they can be downloaded
+			repoDir := filepath.Join(dir, "packages")
+			writeTestRepository(ctx, dir, repoDir)
+			repo := types.NewBhojpurRepository("test", DiskRepositoryType, "Test", []string{repoDir}, 1, true, false)
+
+			archive := filepath.Join(dir, "bundle.tar")
+			inst := NewBhojpurInstaller(BhojpurInstallerOptions{Concurrency: 1, Context: ctx, PackageRepositories: types.BhojpurRepositories{*repo}})
+			Expect(inst.Bundle(types.Packages{&types.Package{Name: "app", Category: "test", Version: ">=0"}}, archive)).To(Succeed())
+			Expect(os.RemoveAll(repoDir)).To(Succeed())
+			// Don't reuse the artifacts downloaded while bundling
+			ctx.Config.System.PkgsCachePath = filepath.Join(dir, "install-cache")
+
+			target := filepath.Join(dir, "target")
+			Expect(os.MkdirAll(target, os.ModePerm)).To(Succeed())
+			system := &System{Database: pkg.NewInMemoryDatabase(false), Target: target}
+			inst = NewBhojpurInstaller(BhojpurInstallerOptions{Concurrency: 1, Context: ctx})
+			Expect(inst.InstallBundle(archive, nil, system)).To(Succeed())
+
+			Expect(fileHelper.Read(filepath.Join(target, "app"))).To(Equal("app"))
+			p, err := system.Database.FindPackage(&types.Package{Name: "app", Category: "test", Version: "1.0"})
+			Expect(err).ToNot(HaveOccurred())
+			files, err := system.Database.GetPackageFiles(p)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(files).To(Equal([]string{"app"}))
+		})
+	})
+
+	Context("Installing", func() {
+		It("refuses archives which are not bundles", func() {
+			ctx := context.NewContext()
+
+			src, err := ioutil.TempDir("", "bundle")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(src)
+			Expect(ioutil.WriteFile(filepath.Join(src, "foo"), []byte("bar"), os.ModePerm)).To(Succeed())
+
+			archive := filepath.Join(src, "..", filepath.Base(src)+".tar")
+			Expect(helpers.Tar(src, archive)).To(Succeed())
+			defer os.RemoveAll(archive)
+
+			inst := NewBhojpurInstaller(BhojpurInstallerOptions{Concurrency: 1, Context: ctx})
+			system := &System{Database: pkg.NewInMemoryDatabase(false), Target: src}
+			err = inst.InstallBundle(archive, nil, system)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid bundle"))
+		})
+	})
+})
@@ -0,0 +1,86 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
+	pkg "github.com/bhojpur/iso/pkg/manager/database"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+// PruneCache evicts artifacts from the packages cache according to
+// the max_size and max_age settings of the system configuration
+func PruneCache(ctx types.Context) ([]*artifact.CacheEntry, error) {
+	system := ctx.GetConfig().System
+	maxSize, err := system.CacheMaxSize()
+	if err != nil {
+		return nil, err
+	}
+	maxAge, err := system.CacheMaxAge()
+	if err != nil {
+		return nil, err
+	}
+	if maxSize == 0 && maxAge == 0 {
+		return nil, nil
+	}
+
+	removed, err := artifact.NewCache(system.PkgsCachePath).Prune(maxSize, maxAge)
+	for _, e := range removed {
+		ctx.Debug("Evicted", e.Artifact.CompileSpec.GetPackage().HumanReadableString(), "from the packages cache")
+	}
+	return removed, err
+}
+
+// ExportCache writes the given cache entries in dst as a local repository
+func ExportCache(ctx types.Context, entries []*artifact.CacheEntry, name, dst string) error {
+	var err error
+	for _, e := range entries {
+		if eerr := e.Export(dst); eerr != nil {
+			err = multierror.Append(err, errors.Wrapf(eerr, "while exporting %s", e.Artifact.CompileSpec.GetPackage().HumanReadableString()))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	repo, err := GenerateRepository(
+		WithName(name),
+		WithDescription("Exported from the packages cache"),
+		WithType(DiskRepositoryType),
+		WithSource(dst),
+		FromMetadata(true),
+		WithDatabase(pkg.NewInMemoryDatabase(false)),
+		WithContext(ctx),
+	)
+	if err != nil {
+		return errors.Wrap(err, "while generating repository")
+	}
+	if err := repo.Write(ctx, dst, true, true); err != nil {
+		return errors.Wrap(err, "while writing repository")
+	}
+	ctx.Success(fmt.Sprintf("Exported %d packages to %s", len(entries), dst))
+	return nil
+}
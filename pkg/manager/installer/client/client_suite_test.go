@@ -21,8 +21,12 @@ package client_test
 // THE SOFTWARE.
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -31,3 +35,18 @@ func TestClient(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Client Suite")
 }
+
+// withTempCache points the packages cache of ctx to a temporary directory for
+// each spec, in place of the default one relative to the working directory
+func withTempCache(ctx *context.Context) {
+	var cache string
+	BeforeEach(func() {
+		var err error
+		cache, err = ioutil.TempDir("", "cache")
+		Expect(err).ToNot(HaveOccurred())
+		ctx.Config.System.PkgsCachePath = cache
+	})
+	AfterEach(func() {
+		os.RemoveAll(cache)
+	})
+}
@@ -0,0 +1,103 @@
+package client
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
+	"github.com/pkg/errors"
+)
+
+// deltaSource is a client able to retrieve cached artifacts and repository files
+type deltaSource interface {
+	CacheGet(*artifact.PackageArtifact) (*artifact.PackageArtifact, error)
+	DownloadFile(string) (string, error)
+}
+
+// downloadDelta rebuilds the artifact a from one of its deltas, if the artifact of
+// the version the delta applies to is in the cache. The rebuilt artifact is
+// recompressed and verified against the checksums of the index.
+// It returns the path of the rebuilt artifact.
+func downloadDelta(ctx types.Context, c deltaSource, a *artifact.PackageArtifact) (string, error) {
+	for _, d := range a.Deltas {
+		base, err := c.CacheGet(a.DeltaBase(d))
+		if err != nil {
+			continue
+		}
+		// Docker clients discard the checksums, check the base is the published one
+		base.Checksums = d.FromChecksums
+		if err := base.Verify(); err != nil {
+			ctx.Debug("Cached artifact doesn't match delta", d.Path, "base, skipping it")
+			continue
+		}
+
+		file, err := rebuildFromDelta(ctx, c, a, base, d)
+		if err != nil {
+			ctx.Debug("Failed applying delta", d.Path, ":", err.Error())
+			continue
+		}
+		return file, nil
+	}
+	return "", errors.New("no applicable delta found")
+}
+
+func rebuildFromDelta(ctx types.Context, c deltaSource, a, base *artifact.PackageArtifact, d artifact.ArtifactDelta) (string, error) {
+	deltaFile, err := c.DownloadFile(d.Path)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(deltaFile)
+
+	deltaArtifact := artifact.NewPackageArtifact(deltaFile)
+	deltaArtifact.Checksums = d.Checksums
+	if err := deltaArtifact.Verify(); err != nil {
+		return "", errors.Wrap(err, "delta checksum mismatch")
+	}
+
+	out, err := ctx.TempFile("delta")
+	if err != nil {
+		return "", err
+	}
+	out.Close()
+
+	if err := artifact.ApplyDelta(base.Path, deltaFile, out.Name()); err != nil {
+		os.RemoveAll(out.Name())
+		return "", err
+	}
+
+	rebuilt := a.ShallowCopy()
+	rebuilt.Path = out.Name()
+	if err := rebuilt.CompressTarball(1); err != nil {
+		os.RemoveAll(out.Name())
+		os.RemoveAll(rebuilt.Path)
+		return "", errors.Wrap(err, "while compressing the rebuilt artifact")
+	}
+	if err := rebuilt.Verify(); err != nil {
+		os.RemoveAll(rebuilt.Path)
+		return "", errors.Wrap(err, "rebuilt artifact checksum mismatch")
+	}
+
+	ctx.Info(fmt.Sprintf("Rebuilt %s from delta against version %s", a.GetFileName(), d.FromVersion))
+	return rebuilt.Path, nil
+}
@@ -86,6 +86,14 @@ func (c *DockerClient) DownloadArtifact(a *artifact.PackageArtifact) (*artifact.
 	// We discard checksum, that are checked while during pull and unpack by containerd
 	resultingArtifact.Checksums = artifact.Checksums{}
 
+	if file, err := downloadDelta(c.context, c, a); err == nil {
+		defer os.RemoveAll(file)
+		resultingArtifact.Path = file
+		if _, _, err := c.Cache.Put(resultingArtifact); err == nil {
+			return c.CacheGet(resultingArtifact)
+		}
+	}
+
 	temp, err := c.context.TempDir("image")
 	if err != nil {
 		return nil, err
@@ -143,49 +151,83 @@ func (c *DockerClient) DownloadArtifact(a *artifact.PackageArtifact) (*artifact.
 }
 
 func (c *DockerClient) DownloadFile(name string) (string, error) {
-	var file *os.File = nil
+	var file string
 	var err error
-	var temp string
 	// Files should be in URI/repository:<file>
-	ok := false
-
-	temp, err = c.context.TempDir("tree")
-	if err != nil {
-		return "", err
+	for _, uri := range c.RepoData.Urls {
+		file, _, err = c.downloadFileFrom(uri, name)
+		if err == nil {
+			return file, nil
+		}
 	}
 
+	return "", err
+}
+
+// DownloadFileIfChanged downloads the file only if the digest of the image holding
+// it changed since the download which returned the given validators. It returns
+// ErrNotModified if the image didn't change, otherwise the downloaded file and its
+// new validators.
+func (c *DockerClient) DownloadFileIfChanged(name string, v FileValidators) (string, FileValidators, error) {
+	var err error
 	for _, uri := range c.RepoData.Urls {
-		file, err = c.context.TempFile("DockerClient")
+		imageName := fmt.Sprintf("%s:%s", uri, helpers.SanitizeImageString(name))
+		var digest string
+		digest, err = docker.ImageDigest(imageName, c.auth)
 		if err != nil {
+			c.context.Debug("Failed retrieving the digest of", imageName, ":", err.Error())
 			continue
 		}
+		if v.Digest != "" && digest == v.Digest {
+			c.context.Debug(imageName, "not modified")
+			return "", v, ErrNotModified
+		}
 
-		imageName := fmt.Sprintf("%s:%s", uri, helpers.SanitizeImageString(name))
-		c.context.Info("Downloading", imageName)
-
-		info, err := docker.DownloadAndExtractDockerImage(c.context, imageName, temp, c.auth, c.RepoData.Verify)
-		if err != nil {
-			c.context.Warning(fmt.Sprintf(errImageDownloadMsg, imageName, err.Error()))
-			continue
+		var file string
+		file, digest, err = c.downloadFileFrom(uri, name)
+		if err == nil {
+			return file, FileValidators{Digest: digest}, nil
 		}
+	}
 
-		c.context.Info(fmt.Sprintf("Pulled: %s", info.Target.Digest))
-		c.context.Info(fmt.Sprintf("Size: %s", units.BytesSize(float64(info.Target.Size))))
+	return "", v, err
+}
 
-		c.context.Debug("\nCopying file ", filepath.Join(temp, name), "to", file.Name())
-		err = fileHelper.CopyFile(filepath.Join(temp, name), file.Name())
-		if err != nil {
-			continue
-		}
-		ok = true
-		break
+// downloadFileFrom downloads the file from the image of the given repository URI.
+// It returns the downloaded file and the digest of the image.
+func (c *DockerClient) downloadFileFrom(uri, name string) (string, string, error) {
+	temp, err := c.context.TempDir("tree")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.RemoveAll(temp)
+
+	file, err := c.context.TempFile("DockerClient")
+	if err != nil {
+		return "", "", err
+	}
+	file.Close()
+
+	imageName := fmt.Sprintf("%s:%s", uri, helpers.SanitizeImageString(name))
+	c.context.Info("Downloading", imageName)
+
+	info, err := docker.DownloadAndExtractDockerImage(c.context, imageName, temp, c.auth, c.RepoData.Verify)
+	if err != nil {
+		os.RemoveAll(file.Name())
+		c.context.Warning(fmt.Sprintf(errImageDownloadMsg, imageName, err.Error()))
+		return "", "", err
 	}
 
-	if !ok {
-		return "", err
+	c.context.Info(fmt.Sprintf("Pulled: %s", info.Target.Digest))
+	c.context.Info(fmt.Sprintf("Size: %s", units.BytesSize(float64(info.Target.Size))))
+
+	c.context.Debug("\nCopying file ", filepath.Join(temp, name), "to", file.Name())
+	if err := fileHelper.CopyFile(filepath.Join(temp, name), file.Name()); err != nil {
+		os.RemoveAll(file.Name())
+		return "", "", err
 	}
 
-	return file.Name(), err
+	return file.Name(), info.Target.Digest.String(), nil
 }
 
 func (c *DockerClient) CacheGet(a *artifact.PackageArtifact) (*artifact.PackageArtifact, error) {
@@ -44,6 +44,7 @@ import (
 var _ = Describe("Docker client", func() {
 	Context("With repository", func() {
 		ctx := context.NewContext()
+		withTempCache(ctx)
 
 		repoImage := os.Getenv("UNIT_TEST_DOCKER_IMAGE")
 		var repoURL []string
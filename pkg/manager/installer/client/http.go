@@ -22,18 +22,17 @@ package client
 
 import (
 	"fmt"
+	"io"
 	"math"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
-	"path/filepath"
 	"time"
 
 	"github.com/bhojpur/iso/pkg/manager/api/core/types"
 	"github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
 	"github.com/pkg/errors"
-	"github.com/pterm/pterm"
 
 	"github.com/cavaliercoder/grab"
 )
@@ -53,14 +52,14 @@ func NewHttpClient(r RepoData, ctx types.Context) *HttpClient {
 }
 
 func NewGrabClient(timeout int) *grab.Client {
+	httpClient, _ := newHTTPClient(RepoData{}, timeout)
+	return newGrabClient(httpClient)
+}
+
+func newGrabClient(httpClient *http.Client) *grab.Client {
 	return &grab.Client{
-		UserAgent: "grab",
-		HTTPClient: &http.Client{
-			Timeout: time.Duration(timeout) * time.Second,
-			Transport: &http.Transport{
-				Proxy: http.ProxyFromEnvironment,
-			},
-		},
+		UserAgent:  "grab",
+		HTTPClient: httpClient,
 	}
 }
 
@@ -71,13 +70,23 @@ func (c *HttpClient) prepareReq(dst, url string) (*grab.Request, error) {
 		return nil, err
 	}
 
-	if val, ok := c.RepoData.Authentication["token"]; ok {
-		req.HTTPRequest.Header.Set("Authorization", "token "+val)
-	} else if val, ok := c.RepoData.Authentication["basic"]; ok {
-		req.HTTPRequest.Header.Set("Authorization", "Basic "+val)
+	return req, c.setHeaders(req.HTTPRequest.Header)
+}
+
+// setHeaders sets the custom headers and the authentication of the repository
+func (c *HttpClient) setHeaders(h http.Header) error {
+	for k, v := range c.RepoData.Headers {
+		h.Set(k, v)
 	}
 
-	return req, err
+	auth, err := authorization(c.RepoData.Authentication)
+	if err != nil {
+		return err
+	}
+	if auth != "" {
+		h.Set("Authorization", auth)
+	}
+	return nil
 }
 
 func Round(input float64) float64 {
@@ -87,91 +96,212 @@ func Round(input float64) float64 {
 	return math.Floor(input + 0.5)
 }
 
+// mirrors returns the repository URLs, followed by the ones of its mirror list
+func (c *HttpClient) mirrors(ranking *mirrorRanking, httpClient *http.Client) []string {
+	mirrors := append([]string{}, c.RepoData.Urls...)
+	if c.RepoData.MirrorList != "" {
+		expanded, err := ranking.expand(c.RepoData.MirrorList, httpClient)
+		if err != nil {
+			c.context.Warning("Failed expanding mirror list:", err.Error())
+		}
+		for _, m := range expanded {
+			if !contains(mirrors, m) {
+				mirrors = append(mirrors, m)
+			}
+		}
+	}
+	return mirrors
+}
+
+func contains(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
+
+// DownloadFile downloads the file p from the fastest available mirror.
+// Failed downloads are retried on the other mirrors, resuming the partial
+// download when the server supports it, and then again with an exponential backoff.
 func (c *HttpClient) DownloadFile(p string) (string, error) {
-	var file *os.File = nil
-	var downloaded bool
-	temp, err := c.context.TempDir("download")
+	file, err := c.context.TempFile("HttpClient")
 	if err != nil {
 		return "", err
 	}
-	defer os.RemoveAll(temp)
+	file.Close()
 
-	client := NewGrabClient(c.context.GetConfig().General.HTTPTimeout)
-
-	for _, uri := range c.RepoData.Urls {
-		file, err = c.context.TempFile("HttpClient")
-		if err != nil {
-			c.context.Debug("Failed downloading", p, "from", uri)
-
-			continue
+	httpClient, err := newHTTPClient(c.RepoData, c.context.GetConfig().General.HTTPTimeout)
+	if err != nil {
+		os.RemoveAll(file.Name())
+		return "", err
+	}
+	client := newGrabClient(httpClient)
+	ranking := loadMirrorRanking(c.RepoData.MirrorStats)
+	defer func() {
+		if err := ranking.save(); err != nil {
+			c.context.Debug("Failed saving mirror statistics:", err.Error())
 		}
-		c.context.Debug("Downloading artifact", p, "from", uri)
-
-		u, err := url.Parse(uri)
-		if err != nil {
-			continue
+	}()
+
+	mirrors := ranking.rank(c.mirrors(ranking, httpClient))
+	retries := c.context.GetConfig().General.HTTPRetries
+	backoff := time.Second
+
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			c.context.Debug("Retrying download of", p, "in", backoff.String())
+			time.Sleep(backoff)
+			backoff *= 2
+			mirrors = ranking.rank(mirrors)
 		}
-		u.Path = path.Join(u.Path, p)
 
-		req, err := c.prepareReq(file.Name(), u.String())
-		if err != nil {
-			continue
+		for _, uri := range mirrors {
+			var latency time.Duration
+			latency, err = c.downloadFrom(client, uri, p, file.Name())
+			ranking.record(uri, latency, err)
+			if err == nil {
+				return file.Name(), nil
+			}
+			c.context.Debug("Failed downloading", p, "from", uri, ":", err.Error())
 		}
+	}
 
-		resp := client.Do(req)
+	os.RemoveAll(file.Name())
+	return "", errors.Wrap(err, "artifact not available in any of the specified url locations")
+}
 
-		// Initialize a progressbar only if we have one in the current context
-		var pb *pterm.ProgressbarPrinter
-		pbb := c.context.GetAnnotation("progressbar")
-		switch v := pbb.(type) {
-		case *pterm.ProgressbarPrinter:
-			pb, _ = v.WithTotal(int(resp.Size())).WithTitle(filepath.Base(resp.Request.HTTPRequest.URL.RequestURI())).Start()
+// DownloadFileIfChanged downloads the file p only if it changed since the download which
+// returned the given validators, with a conditional request on the ETag and Last-Modified
+// headers. It returns ErrNotModified if the file didn't change, otherwise the downloaded
+// file and its new validators.
+func (c *HttpClient) DownloadFileIfChanged(p string, v FileValidators) (string, FileValidators, error) {
+	httpClient, err := newHTTPClient(c.RepoData, c.context.GetConfig().General.HTTPTimeout)
+	if err != nil {
+		return "", v, err
+	}
+	ranking := loadMirrorRanking(c.RepoData.MirrorStats)
+
+	for _, uri := range ranking.rank(c.mirrors(ranking, httpClient)) {
+		var file string
+		var updated FileValidators
+		file, updated, err = c.conditionalGet(httpClient, uri, p, v)
+		if err == nil || err == ErrNotModified {
+			return file, updated, err
 		}
+		c.context.Debug("Failed downloading", p, "from", uri, ":", err.Error())
+	}
+	return "", v, errors.Wrap(err, "file not available in any of the specified url locations")
+}
 
-		// start download loop
-		t := time.NewTicker(500 * time.Millisecond)
-		defer t.Stop()
-
-	download_loop:
-
-		for {
-			select {
-			case <-t.C:
-				//	update the progress bar
-				if pb != nil {
-					pb.Increment().Current = int(resp.BytesComplete())
-				}
-			case <-resp.Done:
-				//	update the progress bar
-				if pb != nil {
-					pb.Increment().Current = int(resp.BytesComplete())
-				}
-				// download is complete
-				break download_loop
-			}
-		}
+func (c *HttpClient) conditionalGet(httpClient *http.Client, uri, p string, v FileValidators) (string, FileValidators, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", v, err
+	}
+	u.Path = path.Join(u.Path, p)
 
-		if err = resp.Err(); err != nil {
-			continue
-		}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", v, err
+	}
+	if err := c.setHeaders(req.Header); err != nil {
+		return "", v, err
+	}
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", v, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		c.context.Debug(p, "not modified on", uri)
+		return "", v, ErrNotModified
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return "", v, fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	file, err := c.context.TempFile("HttpClient")
+	if err != nil {
+		return "", v, err
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		os.RemoveAll(file.Name())
+		return "", v, err
+	}
+
+	return file.Name(), FileValidators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
 
-		c.context.Info("Downloaded", p, "of",
-			fmt.Sprintf("%.2f", (float64(resp.BytesComplete())/1000)/1000), "MB (",
-			fmt.Sprintf("%.2f", (float64(resp.BytesPerSecond())/1024)/1024), "MiB/s )")
+// downloadFrom downloads the file p from the given mirror into dst, resuming the
+// download if dst already holds part of it. It returns the latency of the mirror.
+func (c *HttpClient) downloadFrom(client *grab.Client, uri, p, dst string) (time.Duration, error) {
+	c.context.Debug("Downloading artifact", p, "from", uri)
 
-		if pb != nil {
-			// stop the progressbar if active
-			pb.Stop()
+	u, err := url.Parse(uri)
+	if err != nil {
+		return 0, err
+	}
+	u.Path = path.Join(u.Path, p)
+
+	req, err := c.prepareReq(dst, u.String())
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	resp := client.Do(req)
+	latency := time.Since(start)
+
+	// Notify the progress only if requested in the current context
+	progress, _ := c.context.GetAnnotation(DownloadProgressAnnotation).(DownloadProgressFunc)
+
+	// start download loop
+	t := time.NewTicker(500 * time.Millisecond)
+	defer t.Stop()
+
+download_loop:
+
+	for {
+		select {
+		case <-t.C:
+			if progress != nil {
+				progress(resp.BytesComplete(), resp.Size())
+			}
+		case <-resp.Done:
+			if progress != nil {
+				progress(resp.BytesComplete(), resp.Size())
+			}
+			// download is complete
+			break download_loop
 		}
-		//bar.Finish()
-		downloaded = true
-		break
 	}
 
-	if !downloaded {
-		return "", errors.Wrap(err, "artifact not available in any of the specified url locations")
+	if err := resp.Err(); err != nil {
+		return latency, err
+	}
+
+	if resp.DidResume {
+		c.context.Info("Resumed download of", p, "from", uri)
 	}
-	return file.Name(), nil
+	c.context.Info("Downloaded", p, "of",
+		fmt.Sprintf("%.2f", (float64(resp.BytesComplete())/1000)/1000), "MB (",
+		fmt.Sprintf("%.2f", (float64(resp.BytesPerSecond())/1024)/1024), "MiB/s )")
+
+	return latency, nil
 }
 
 func (c *HttpClient) CacheGet(a *artifact.PackageArtifact) (*artifact.PackageArtifact, error) {
@@ -193,9 +323,12 @@ func (c *HttpClient) DownloadArtifact(a *artifact.PackageArtifact) (*artifact.Pa
 		return newart, nil
 	}
 
-	d, err := c.DownloadFile(artifactName)
+	d, err := downloadDelta(c.context, c, a)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed downloading %s", artifactName)
+		d, err = c.DownloadFile(artifactName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed downloading %s", artifactName)
+		}
 	}
 
 	defer os.RemoveAll(d)
@@ -21,13 +21,26 @@ package client_test
 // THE SOFTWARE.
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
 	"github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
 	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
 	. "github.com/bhojpur/iso/pkg/manager/installer/client"
@@ -38,6 +51,7 @@ import (
 var _ = Describe("Http client", func() {
 	Context("With repository", func() {
 		ctx := context.NewContext()
+		withTempCache(ctx)
 
 		It("Downloads single files", func() {
 			// setup small staticfile webserver with content
@@ -75,5 +89,253 @@ var _ = Describe("Http client", func() {
 			os.RemoveAll(path.Path)
 		})
 
+		It("Resumes downloads on the next mirror and ranks mirrors", func() {
+			tmpdir, err := ioutil.TempDir("", "test")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(tmpdir) // clean up
+
+			content := bytes.Repeat([]byte("0123456789"), 100000)
+
+			// flaky mirror, drops the connection halfway
+			flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "1000000")
+				w.Write(content[:len(content)/2])
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			}))
+			defer flaky.Close()
+
+			ranges := []string{}
+			good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					ranges = append(ranges, r.Header.Get("Range"))
+				}
+				http.ServeContent(w, r, "test.bin", time.Now(), bytes.NewReader(content))
+			}))
+			defer good.Close()
+
+			stats := filepath.Join(tmpdir, "mirrors.json")
+			c := NewHttpClient(RepoData{Urls: []string{flaky.URL, good.URL}, MirrorStats: stats}, ctx)
+			path, err := c.DownloadFile("test.bin")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(path)
+
+			dat, err := ioutil.ReadFile(path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dat).To(Equal(content))
+			Expect(ranges).To(ContainElement(HavePrefix("bytes=")))
+
+			dat, err = ioutil.ReadFile(stats)
+			Expect(err).ToNot(HaveOccurred())
+			ranking := struct {
+				Mirrors map[string]MirrorStats
+			}{}
+			Expect(json.Unmarshal(dat, &ranking)).ToNot(HaveOccurred())
+			Expect(ranking.Mirrors[flaky.URL].Failures).To(Equal(1))
+			Expect(ranking.Mirrors[good.URL].Successes).To(Equal(1))
+		})
+
+		It("Keeps the mirror statistics of parallel downloads", func() {
+			tmpdir, err := ioutil.TempDir("", "test")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(tmpdir) // clean up
+			ts := httptest.NewServer(http.FileServer(http.Dir(tmpdir)))
+			defer ts.Close()
+			Expect(ioutil.WriteFile(filepath.Join(tmpdir, "test.txt"), []byte(`test`), os.ModePerm)).To(Succeed())
+
+			stats := filepath.Join(tmpdir, "mirrors.json")
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					c := NewHttpClient(RepoData{Urls: []string{ts.URL}, MirrorStats: stats}, ctx)
+					path, err := c.DownloadFile("test.txt")
+					Expect(err).ToNot(HaveOccurred())
+					os.RemoveAll(path)
+				}()
+			}
+			wg.Wait()
+
+			dat, err := ioutil.ReadFile(stats)
+			Expect(err).ToNot(HaveOccurred())
+			ranking := struct {
+				Mirrors map[string]MirrorStats
+			}{}
+			Expect(json.Unmarshal(dat, &ranking)).ToNot(HaveOccurred())
+			Expect(ranking.Mirrors[ts.URL].Successes).To(Equal(10))
+
+			leftovers, err := filepath.Glob(stats + ".*")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(leftovers).To(BeEmpty())
+		})
+
+		It("Expands mirror lists", func() {
+			tmpdir, err := ioutil.TempDir("", "test")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(tmpdir) // clean up
+			ts := httptest.NewServer(http.FileServer(http.Dir(tmpdir)))
+			defer ts.Close()
+			err = ioutil.WriteFile(filepath.Join(tmpdir, "test.txt"), []byte(`test`), os.ModePerm)
+			Expect(err).ToNot(HaveOccurred())
+			err = ioutil.WriteFile(filepath.Join(tmpdir, "mirrors"), []byte("# mirrors\n"+ts.URL+"\n"), os.ModePerm)
+			Expect(err).ToNot(HaveOccurred())
+
+			c := NewHttpClient(RepoData{MirrorList: ts.URL + "/mirrors", MirrorStats: filepath.Join(tmpdir, "mirrors.json")}, ctx)
+			path, err := c.DownloadFile("test.txt")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fileHelper.Read(path)).To(Equal("test"))
+			os.RemoveAll(path)
+		})
+	})
+
+	Context("With TLS and authentication", func() {
+		ctx := context.NewContext()
+		withTempCache(ctx)
+		var tmpdir string
+
+		BeforeEach(func() {
+			var err error
+			tmpdir, err = ioutil.TempDir("", "test")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpdir)
+		})
+
+		It("Sends custom headers and credentials read from files or the environment", func() {
+			headers := http.Header{}
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				headers = r.Header
+				w.Write([]byte("test"))
+			}))
+			defer ts.Close()
+
+			token := filepath.Join(tmpdir, "token")
+			Expect(ioutil.WriteFile(token, []byte("secret\n"), 0600)).ToNot(HaveOccurred())
+
+			c := NewHttpClient(RepoData{
+				Urls:           []string{ts.URL},
+				Authentication: map[string]string{"bearer_file": token},
+				Headers:        map[string]string{"X-Tenant": "isomgr"},
+			}, ctx)
+			path, err := c.DownloadFile("test.txt")
+			Expect(err).ToNot(HaveOccurred())
+			os.RemoveAll(path)
+			Expect(headers.Get("Authorization")).To(Equal("Bearer secret"))
+			Expect(headers.Get("X-Tenant")).To(Equal("isomgr"))
+
+			os.Setenv("ISOMGR_TEST_TOKEN", "fromenv")
+			defer os.Unsetenv("ISOMGR_TEST_TOKEN")
+			c = NewHttpClient(RepoData{
+				Urls:           []string{ts.URL},
+				Authentication: map[string]string{"token_env": "ISOMGR_TEST_TOKEN"},
+			}, ctx)
+			path, err = c.DownloadFile("test.txt")
+			Expect(err).ToNot(HaveOccurred())
+			os.RemoveAll(path)
+			Expect(headers.Get("Authorization")).To(Equal("token fromenv"))
+
+			c = NewHttpClient(RepoData{
+				Urls:           []string{ts.URL},
+				Authentication: map[string]string{"bearer_env": "ISOMGR_TEST_UNSET_TOKEN"},
+			}, ctx)
+			_, err = c.DownloadFile("test.txt")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Trusts custom CAs and authenticates with client certificates", func() {
+			clientCert, clientKey := writeTestCertificate(tmpdir, "client")
+			clientPEM, err := ioutil.ReadFile(clientCert)
+			Expect(err).ToNot(HaveOccurred())
+			clientCAs := x509.NewCertPool()
+			Expect(clientCAs.AppendCertsFromPEM(clientPEM)).To(BeTrue())
+
+			ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("test"))
+			}))
+			ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+			ts.StartTLS()
+			defer ts.Close()
+
+			ca := filepath.Join(tmpdir, "ca.pem")
+			Expect(ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0644)).ToNot(HaveOccurred())
+
+			// The server certificate is not trusted
+			c := NewHttpClient(RepoData{Urls: []string{ts.URL}}, ctx)
+			_, err = c.DownloadFile("test.txt")
+			Expect(err).To(HaveOccurred())
+
+			// The client doesn't present a certificate
+			c = NewHttpClient(RepoData{Urls: []string{ts.URL}, TLS: &types.BhojpurRepositoryTLS{CACert: ca}}, ctx)
+			_, err = c.DownloadFile("test.txt")
+			Expect(err).To(HaveOccurred())
+
+			c = NewHttpClient(RepoData{
+				Urls: []string{ts.URL},
+				TLS:  &types.BhojpurRepositoryTLS{CACert: ca, ClientCert: clientCert, ClientKey: clientKey},
+			}, ctx)
+			path, err := c.DownloadFile("test.txt")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fileHelper.Read(path)).To(Equal("test"))
+			os.RemoveAll(path)
+
+			c = NewHttpClient(RepoData{
+				Urls: []string{ts.URL},
+				TLS:  &types.BhojpurRepositoryTLS{InsecureSkipVerify: true, ClientCert: clientCert, ClientKey: clientKey},
+			}, ctx)
+			path, err = c.DownloadFile("test.txt")
+			Expect(err).ToNot(HaveOccurred())
+			os.RemoveAll(path)
+
+			c = NewHttpClient(RepoData{Urls: []string{ts.URL}, TLS: &types.BhojpurRepositoryTLS{ClientCert: clientCert}}, ctx)
+			_, err = c.DownloadFile("test.txt")
+			Expect(err).To(MatchError(ContainSubstring("client_key")))
+		})
+	})
+
+	Context("Mirror lists", func() {
+		It("Parses plain mirror lists", func() {
+			Expect(ParseMirrorList([]byte("# comment\nhttp://a/repo\n\n  http://b/repo  \n"))).To(Equal([]string{"http://a/repo", "http://b/repo"}))
+		})
+
+		It("Parses metalinks", func() {
+			metalink := `<?xml version="1.0" encoding="UTF-8"?>
+<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <file name="repository.yaml">
+    <url priority="1">http://a/repo/repository.yaml</url>
+    <url priority="2">http://b/repo/repository.yaml</url>
+  </file>
+</metalink>`
+			Expect(ParseMirrorList([]byte(metalink))).To(Equal([]string{"http://a/repo", "http://b/repo"}))
+		})
 	})
 })
+
+// writeTestCertificate writes a self-signed client certificate and its key in dir
+func writeTestCertificate(dir, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	Expect(err).ToNot(HaveOccurred())
+
+	cert := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+".key")
+	Expect(ioutil.WriteFile(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)).ToNot(HaveOccurred())
+	Expect(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)).ToNot(HaveOccurred())
+	return cert, keyFile
+}
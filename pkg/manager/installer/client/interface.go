@@ -20,8 +20,48 @@ package client
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
 // THE SOFTWARE.
 
+import (
+	"errors"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+)
+
 type RepoData struct {
 	Urls           []string
 	Authentication map[string]string
 	Verify         bool
+	// MirrorList is the URL of a metalink or mirror list expanding into more mirrors
+	MirrorList string
+	// MirrorStats is the file where mirror statistics are persisted
+	MirrorStats string
+	// TLS are the TLS settings of the repository, if any
+	TLS *types.BhojpurRepositoryTLS
+	// Headers are additional HTTP headers sent with each request
+	Headers map[string]string
+	// S3 are the object storage settings of s3 repositories
+	S3 *types.BhojpurRepositoryS3
+}
+
+// DownloadProgressAnnotation is the context annotation holding the DownloadProgressFunc
+// notified while clients download artifacts
+const DownloadProgressAnnotation = "download_progress"
+
+// DownloadProgressFunc is called with the bytes downloaded and the size of an artifact
+type DownloadProgressFunc func(current, total int64)
+
+// FileValidators identify the version of a remote file, so that it's downloaded
+// again only when it changes
+type FileValidators struct {
+	ETag         string `json:"etag,omitempty" yaml:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty" yaml:"last_modified,omitempty"`
+	Digest       string `json:"digest,omitempty" yaml:"digest,omitempty"`
+}
+
+// ErrNotModified is returned by DownloadFileIfChanged when the remote file didn't change
+var ErrNotModified = errors.New("not modified")
+
+// ConditionalClient is implemented by the clients which can download a file only
+// when it changed since the download which returned the given validators
+type ConditionalClient interface {
+	DownloadFileIfChanged(name string, v FileValidators) (string, FileValidators, error)
 }
@@ -56,9 +56,12 @@ func (c *LocalClient) DownloadArtifact(a *artifact.PackageArtifact) (*artifact.P
 		return newart, nil
 	}
 
-	d, err := c.DownloadFile(artifactName)
+	d, err := downloadDelta(c.context, c, a)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed downloading %s", artifactName)
+		d, err = c.DownloadFile(artifactName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed downloading %s", artifactName)
+		}
 	}
 	defer os.RemoveAll(d)
 
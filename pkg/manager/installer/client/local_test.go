@@ -26,7 +26,9 @@ import (
 	"path/filepath"
 
 	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
 	"github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
+	compilerspec "github.com/bhojpur/iso/pkg/manager/compiler/types/spec"
 	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
 	. "github.com/bhojpur/iso/pkg/manager/installer/client"
 	. "github.com/onsi/ginkgo/v2"
@@ -36,6 +38,7 @@ import (
 var _ = Describe("Local client", func() {
 	Context("With repository", func() {
 		ctx := context.NewContext()
+		withTempCache(ctx)
 
 		It("Downloads single files", func() {
 			tmpdir, err := ioutil.TempDir("", "test")
@@ -69,5 +72,50 @@ var _ = Describe("Local client", func() {
 			os.RemoveAll(path.Path)
 		})
 
+		It("Rebuilds artifacts from deltas", func() {
+			tmpdir, err := ioutil.TempDir("", "test")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(tmpdir) // clean up
+			cache, err := ioutil.TempDir("", "cache")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(cache) // clean up
+
+			ctx := context.NewContext()
+			ctx.Config.System.PkgsCachePath = cache
+
+			oldFile := filepath.Join(cache, "a-test-1.package.tar")
+			newFile := filepath.Join(cache, "a-test-2.package.tar")
+			Expect(ioutil.WriteFile(oldFile, []byte("old content of the package"), os.ModePerm)).To(Succeed())
+			Expect(ioutil.WriteFile(newFile, []byte("new content of the package"), os.ModePerm)).To(Succeed())
+			Expect(artifact.GenerateDelta(oldFile, newFile, filepath.Join(tmpdir, "a-test-2.package.tar.from-1.delta"))).To(Succeed())
+
+			old := artifact.NewPackageArtifact(oldFile)
+			old.CompileSpec = &compilerspec.BhojpurCompilationSpec{Package: &types.Package{Name: "a", Category: "test", Version: "1"}}
+			Expect(old.Hash()).To(Succeed())
+			delta := artifact.NewPackageArtifact(filepath.Join(tmpdir, "a-test-2.package.tar.from-1.delta"))
+			Expect(delta.Hash()).To(Succeed())
+			new := artifact.NewPackageArtifact(newFile)
+			Expect(new.Hash()).To(Succeed())
+
+			c := NewLocalClient(RepoData{Urls: []string{tmpdir}}, ctx)
+			_, _, err = c.Cache.Put(old)
+			Expect(err).ToNot(HaveOccurred())
+
+			a := &artifact.PackageArtifact{
+				Path:        "a-test-2.package.tar",
+				CompileSpec: &compilerspec.BhojpurCompilationSpec{Package: &types.Package{Name: "a", Category: "test", Version: "2"}},
+				Checksums:   new.Checksums,
+				Deltas: []artifact.ArtifactDelta{{
+					FromVersion:   "1",
+					FromChecksums: old.Checksums,
+					Path:          "a-test-2.package.tar.from-1.delta",
+					Checksums:     delta.Checksums,
+				}},
+			}
+			// The full artifact is not in the repository, only the delta is
+			path, err := c.DownloadArtifact(a)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fileHelper.Read(path.Path)).To(Equal("new content of the package"))
+		})
 	})
 })
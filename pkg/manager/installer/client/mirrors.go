@@ -0,0 +1,275 @@
+package client
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// mirrorListTTL is how long an expanded mirror list is used before fetching it again
+	mirrorListTTL = 24 * time.Hour
+	// mirrorFailurePenalty is added to the score of a mirror which failed recently
+	mirrorFailurePenalty = 10 * time.Minute
+	// latencyWeight is the weight of the last sample in the latency moving average
+	latencyWeight = 0.3
+)
+
+// MirrorStats tracks the latency and failures of a repository mirror
+type MirrorStats struct {
+	Successes   int       `json:"successes"`
+	Failures    int       `json:"failures"`
+	Latency     float64   `json:"latency"`
+	LastFailure time.Time `json:"last_failure,omitempty"`
+}
+
+// score returns a value used to rank mirrors, lower is better.
+// Mirrors never used have a score of zero, so they are tried at least once.
+func (s *MirrorStats) score() float64 {
+	if s == nil || s.Successes+s.Failures == 0 {
+		return 0
+	}
+	failureRate := float64(s.Failures) / float64(s.Successes+s.Failures)
+	score := s.Latency * (1 + 4*failureRate)
+	if time.Since(s.LastFailure) < mirrorFailurePenalty {
+		score += mirrorFailurePenalty.Seconds()
+	}
+	return score
+}
+
+// mirrorRanking is the set of mirror statistics of a repository, persisted
+// in the repository database folder
+type mirrorRanking struct {
+	sync.Mutex `json:"-"`
+	path       string
+
+	Mirrors    map[string]*MirrorStats `json:"mirrors"`
+	MirrorList []string                `json:"mirror_list,omitempty"`
+	ListUpdate time.Time               `json:"mirror_list_update,omitempty"`
+}
+
+var (
+	rankingsMutex sync.Mutex
+	// rankings holds the mirror statistics loaded by the process, by file,
+	// so that parallel downloads update and save the same ones
+	rankings = map[string]*mirrorRanking{}
+)
+
+func loadMirrorRanking(file string) *mirrorRanking {
+	r := &mirrorRanking{path: file, Mirrors: map[string]*MirrorStats{}}
+	if file == "" {
+		return r
+	}
+
+	rankingsMutex.Lock()
+	defer rankingsMutex.Unlock()
+	if shared, ok := rankings[file]; ok {
+		return shared
+	}
+	rankings[file] = r
+
+	dat, err := ioutil.ReadFile(file)
+	if err != nil {
+		return r
+	}
+	json.Unmarshal(dat, r)
+	if r.Mirrors == nil {
+		r.Mirrors = map[string]*MirrorStats{}
+	}
+	return r
+}
+
+func (r *mirrorRanking) save() error {
+	r.Lock()
+	defer r.Unlock()
+	if r.path == "" {
+		return nil
+	}
+	dat, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	// Each save writes its own temporary file, so that concurrent
+	// processes never rename a partially written one
+	tmp, err := ioutil.TempFile(filepath.Dir(r.path), filepath.Base(r.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp.Name())
+	if _, err := tmp.Write(dat); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+// record updates the statistics of a mirror after a download attempt
+func (r *mirrorRanking) record(mirror string, latency time.Duration, err error) {
+	r.Lock()
+	defer r.Unlock()
+	s, ok := r.Mirrors[mirror]
+	if !ok {
+		s = &MirrorStats{}
+		r.Mirrors[mirror] = s
+	}
+	if err != nil {
+		s.Failures++
+		s.LastFailure = time.Now()
+		return
+	}
+	if s.Successes == 0 {
+		s.Latency = latency.Seconds()
+	} else {
+		s.Latency = latencyWeight*latency.Seconds() + (1-latencyWeight)*s.Latency
+	}
+	s.Successes++
+}
+
+// rank returns the mirrors sorted from the best to the worst one.
+// Mirrors with the same score keep their original order.
+func (r *mirrorRanking) rank(mirrors []string) []string {
+	r.Lock()
+	defer r.Unlock()
+	res := append([]string{}, mirrors...)
+	sort.SliceStable(res, func(i, j int) bool {
+		return r.Mirrors[res[i]].score() < r.Mirrors[res[j]].score()
+	})
+	return res
+}
+
+// expand returns the mirrors of a mirror list or metalink URL. The list is fetched
+// again once expired, and the last known one is used if it can't be retrieved.
+func (r *mirrorRanking) expand(list string, client *http.Client) ([]string, error) {
+	r.Lock()
+	cached, updated := r.MirrorList, r.ListUpdate
+	r.Unlock()
+	if len(cached) > 0 && time.Since(updated) < mirrorListTTL {
+		return cached, nil
+	}
+
+	mirrors, err := fetchMirrorList(list, client)
+	if err != nil {
+		if len(cached) > 0 {
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	r.Lock()
+	r.MirrorList, r.ListUpdate = mirrors, time.Now()
+	r.Unlock()
+	return mirrors, nil
+}
+
+func fetchMirrorList(list string, client *http.Client) ([]string, error) {
+	resp, err := client.Get(list)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while fetching mirror list %s", list)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("while fetching mirror list %s: %s", list, resp.Status)
+	}
+	dat, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while fetching mirror list %s", list)
+	}
+	mirrors := ParseMirrorList(dat)
+	if len(mirrors) == 0 {
+		return nil, errors.Errorf("no mirrors found in %s", list)
+	}
+	return mirrors, nil
+}
+
+// ParseMirrorList returns the mirrors listed in a metalink document, or in a
+// plain text mirror list with one URL per line.
+// Metalink URLs pointing at a file are turned into the URL of their folder.
+func ParseMirrorList(dat []byte) []string {
+	var mirrors []string
+	trimmed := bytes.TrimSpace(dat)
+	if bytes.HasPrefix(trimmed, []byte("<")) {
+		d := xml.NewDecoder(bytes.NewReader(trimmed))
+		inURL := false
+		for {
+			t, err := d.Token()
+			if err != nil {
+				break
+			}
+			switch e := t.(type) {
+			case xml.StartElement:
+				inURL = e.Name.Local == "url"
+			case xml.EndElement:
+				inURL = false
+			case xml.CharData:
+				if !inURL {
+					continue
+				}
+				u, err := url.Parse(strings.TrimSpace(string(e)))
+				if err != nil || u.Scheme == "" {
+					continue
+				}
+				if path.Ext(u.Path) != "" {
+					u.Path = path.Dir(u.Path)
+				}
+				mirrors = append(mirrors, u.String())
+			}
+		}
+		return mirrors
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		mirrors = append(mirrors, line)
+	}
+	return mirrors
+}
+
+// MirrorStatsFile returns the file holding the mirror statistics of a repository
+func MirrorStatsFile(repoDir string) string {
+	return filepath.Join(repoDir, "mirrors.json")
+}
@@ -0,0 +1,182 @@
+package client
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
+	"github.com/bhojpur/iso/pkg/manager/helpers"
+	"github.com/bhojpur/iso/pkg/manager/helpers/oci"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+)
+
+// OCIClient downloads the repository files stored as OCI artifacts in a registry.
+// The repository URLs are registry repositories (e.g. quay.io/org/repo), and each
+// file is tagged with its name.
+type OCIClient struct {
+	RepoData RepoData
+	Cache    *artifact.ArtifactCache
+	context  types.Context
+}
+
+func NewOCIClient(r RepoData, ctx types.Context) *OCIClient {
+	return &OCIClient{
+		RepoData: r,
+		Cache:    artifact.NewCache(ctx.GetConfig().System.PkgsCachePath),
+		context:  ctx,
+	}
+}
+
+// OCIReference returns the reference of the artifact holding the
+// file with the given name in the registry repository uri
+func OCIReference(uri, name string) string {
+	return fmt.Sprintf("%s:%s", uri, helpers.SanitizeImageString(name))
+}
+
+// OCIRemoteOptions returns the options to access the registry with the credentials
+// and the TLS settings of the repository. The credentials are read from the username,
+// password, auth, identitytoken and registrytoken authentication entries as for docker
+// repositories (also from files or environment variables as the other credentials),
+// or otherwise from the docker configuration of the user.
+func OCIRemoteOptions(r RepoData, timeout int) ([]remote.Option, error) {
+	httpClient, err := newHTTPClient(r, timeout)
+	if err != nil {
+		return nil, err
+	}
+	opts := []remote.Option{remote.WithTransport(httpClient.Transport)}
+
+	cfg := authn.AuthConfig{}
+	set := false
+	for key, dst := range map[string]*string{
+		"username":      &cfg.Username,
+		"password":      &cfg.Password,
+		"auth":          &cfg.Auth,
+		"identitytoken": &cfg.IdentityToken,
+		"registrytoken": &cfg.RegistryToken,
+	} {
+		val, ok, err := credential(r.Authentication, key)
+		if err != nil {
+			return nil, err
+		}
+		*dst = val
+		set = set || ok
+	}
+
+	if set {
+		opts = append(opts, remote.WithAuth(authn.FromConfig(cfg)))
+	} else {
+		opts = append(opts, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	}
+	return opts, nil
+}
+
+func (c *OCIClient) DownloadArtifact(a *artifact.PackageArtifact) (*artifact.PackageArtifact, error) {
+	artifactName := path.Base(a.Path)
+
+	newart, err := c.CacheGet(a)
+	// Check if file is already in cache
+	if err == nil {
+		return newart, nil
+	}
+
+	d, err := downloadDelta(c.context, c, a)
+	if err != nil {
+		d, err = c.DownloadFile(artifactName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed downloading %s", artifactName)
+		}
+	}
+
+	defer os.RemoveAll(d)
+	newart.Path = d
+	c.Cache.Put(newart)
+
+	return c.CacheGet(newart)
+}
+
+func (c *OCIClient) CacheGet(a *artifact.PackageArtifact) (*artifact.PackageArtifact, error) {
+	newart := a.ShallowCopy()
+
+	fileName, err := c.Cache.Get(a)
+
+	newart.Path = fileName
+
+	return newart, err
+}
+
+func (c *OCIClient) DownloadFile(name string) (string, error) {
+	file, _, err := c.DownloadFileIfChanged(name, FileValidators{})
+	return file, err
+}
+
+// DownloadFileIfChanged downloads the file only if the digest of the artifact holding
+// it changed since the download which returned the given validators. It returns
+// ErrNotModified if the artifact didn't change, otherwise the downloaded file and its
+// new validators.
+func (c *OCIClient) DownloadFileIfChanged(name string, v FileValidators) (string, FileValidators, error) {
+	opts, err := OCIRemoteOptions(c.RepoData, c.context.GetConfig().General.HTTPTimeout)
+	if err != nil {
+		return "", v, err
+	}
+
+	for _, uri := range c.RepoData.Urls {
+		ref := OCIReference(uri, name)
+		if v.Digest != "" {
+			var digest string
+			digest, err = oci.Digest(ref, opts...)
+			if err != nil {
+				c.context.Debug("Failed retrieving the digest of", ref, ":", err.Error())
+				continue
+			}
+			if digest == v.Digest {
+				c.context.Debug(ref, "not modified")
+				return "", v, ErrNotModified
+			}
+		}
+
+		var file *os.File
+		file, err = c.context.TempFile("OCIClient")
+		if err != nil {
+			return "", v, err
+		}
+		file.Close()
+
+		c.context.Debug("Pulling", ref)
+		var digest string
+		digest, err = oci.Pull(ref, file.Name(), opts...)
+		if err == nil {
+			c.context.Info("Downloaded", name, "from", uri)
+			return file.Name(), FileValidators{Digest: digest}, nil
+		}
+		os.RemoveAll(file.Name())
+		c.context.Debug("Failed pulling", ref, ":", err.Error())
+	}
+	if err == nil {
+		err = errors.New("no repository urls")
+	}
+	return "", v, errors.Wrap(err, "file not available in any of the specified url locations")
+}
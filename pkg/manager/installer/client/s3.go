@@ -0,0 +1,186 @@
+package client
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"io"
+	"os"
+	"path"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
+	"github.com/bhojpur/iso/pkg/manager/helpers/s3"
+	"github.com/pkg/errors"
+)
+
+// S3Client downloads the repository files from buckets of S3 compatible object
+// storages. The repository URLs are in the s3://bucket/prefix form.
+type S3Client struct {
+	RepoData RepoData
+	Cache    *artifact.ArtifactCache
+	context  types.Context
+}
+
+func NewS3Client(r RepoData, ctx types.Context) *S3Client {
+	return &S3Client{
+		RepoData: r,
+		Cache:    artifact.NewCache(ctx.GetConfig().System.PkgsCachePath),
+		context:  ctx,
+	}
+}
+
+// S3Credentials returns the credentials set in the access_key_id, secret_access_key and
+// session_token authentication entries of the repository (which can be read from files or
+// environment variables as the other credentials), or otherwise in the AWS environment variables
+func S3Credentials(auth map[string]string) (s3.Credentials, error) {
+	creds := s3.Credentials{}
+	for key, dst := range map[string]*string{
+		"access_key_id":     &creds.AccessKeyID,
+		"secret_access_key": &creds.SecretAccessKey,
+		"session_token":     &creds.SessionToken,
+	} {
+		val, _, err := credential(auth, key)
+		if err != nil {
+			return creds, err
+		}
+		*dst = val
+	}
+	if creds.AccessKeyID == "" {
+		return s3.EnvCredentials(), nil
+	}
+	return creds, nil
+}
+
+// S3Bucket returns the client of the bucket referenced by the s3://bucket/prefix
+// URL and the prefix of the repository files, configured from the repository data
+func S3Bucket(r RepoData, uri string, timeout int) (*s3.Client, string, error) {
+	bucket, prefix, err := s3.ParseURL(uri)
+	if err != nil {
+		return nil, "", err
+	}
+	creds, err := S3Credentials(r.Authentication)
+	if err != nil {
+		return nil, "", err
+	}
+	httpClient, err := newHTTPClient(r, timeout)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c := &s3.Client{
+		Bucket:      bucket,
+		Credentials: creds,
+		HTTPClient:  httpClient,
+	}
+	if r.S3 != nil {
+		c.Endpoint, c.Region, c.PathStyle = r.S3.Endpoint, r.S3.Region, r.S3.PathStyle
+	}
+	return c, prefix, nil
+}
+
+func (c *S3Client) DownloadArtifact(a *artifact.PackageArtifact) (*artifact.PackageArtifact, error) {
+	artifactName := path.Base(a.Path)
+
+	newart, err := c.CacheGet(a)
+	// Check if file is already in cache
+	if err == nil {
+		return newart, nil
+	}
+
+	d, err := downloadDelta(c.context, c, a)
+	if err != nil {
+		d, err = c.DownloadFile(artifactName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed downloading %s", artifactName)
+		}
+	}
+
+	defer os.RemoveAll(d)
+	newart.Path = d
+	c.Cache.Put(newart)
+
+	return c.CacheGet(newart)
+}
+
+func (c *S3Client) CacheGet(a *artifact.PackageArtifact) (*artifact.PackageArtifact, error) {
+	newart := a.ShallowCopy()
+
+	fileName, err := c.Cache.Get(a)
+
+	newart.Path = fileName
+
+	return newart, err
+}
+
+func (c *S3Client) DownloadFile(name string) (string, error) {
+	file, _, err := c.DownloadFileIfChanged(name, FileValidators{})
+	return file, err
+}
+
+// DownloadFileIfChanged downloads the file only if its ETag changed since the download
+// which returned the given validators. It returns ErrNotModified if the file didn't
+// change, otherwise the downloaded file and its new validators.
+func (c *S3Client) DownloadFileIfChanged(name string, v FileValidators) (string, FileValidators, error) {
+	var err error
+	for _, uri := range c.RepoData.Urls {
+		var file string
+		var updated FileValidators
+		file, updated, err = c.downloadFileFrom(uri, name, v)
+		if err == nil || err == ErrNotModified {
+			return file, updated, err
+		}
+		c.context.Debug("Failed downloading", name, "from", uri, ":", err.Error())
+	}
+	if err == nil {
+		err = errors.New("no repository urls")
+	}
+	return "", v, errors.Wrap(err, "file not available in any of the specified url locations")
+}
+
+func (c *S3Client) downloadFileFrom(uri, name string, v FileValidators) (string, FileValidators, error) {
+	bucket, prefix, err := S3Bucket(c.RepoData, uri, c.context.GetConfig().General.HTTPTimeout)
+	if err != nil {
+		return "", v, err
+	}
+
+	c.context.Debug("Downloading", name, "from", uri)
+	resp, err := bucket.Get(s3.Key(prefix, name), v.ETag)
+	if err == s3.ErrNotModified {
+		c.context.Debug(name, "not modified on", uri)
+		return "", v, ErrNotModified
+	} else if err != nil {
+		return "", v, err
+	}
+	defer resp.Body.Close()
+
+	file, err := c.context.TempFile("S3Client")
+	if err != nil {
+		return "", v, err
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		os.RemoveAll(file.Name())
+		return "", v, errors.Wrapf(err, "while downloading %s", name)
+	}
+
+	c.context.Info("Downloaded", name, "from", uri)
+	return file.Name(), FileValidators{ETag: resp.Header.Get("ETag")}, nil
+}
@@ -0,0 +1,136 @@
+package client
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// authSchemes maps the Authentication entries of a repository to the scheme of
+// the Authorization header they set, in order of precedence. Each entry can also
+// be read from a file or from an environment variable, with the _file and _env
+// suffixes (e.g. bearer_file: /etc/isomgr/token), to keep it out of the repository
+// configuration.
+var authSchemes = []struct{ key, scheme string }{
+	{"token", "token"},
+	{"basic", "Basic"},
+	{"bearer", "Bearer"},
+}
+
+// authorization returns the value of the Authorization header for the given
+// repository authentication, or an empty string if none is configured
+func authorization(auth map[string]string) (string, error) {
+	for _, s := range authSchemes {
+		val, ok, err := credential(auth, s.key)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return s.scheme + " " + val, nil
+		}
+	}
+	return "", nil
+}
+
+// credential returns the authentication entry with the given key, reading it
+// from a file or from an environment variable if set with the _file or _env suffix
+func credential(auth map[string]string, key string) (string, bool, error) {
+	if val, ok := auth[key]; ok {
+		return val, true, nil
+	}
+	if file, ok := auth[key+"_file"]; ok {
+		dat, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", false, errors.Wrapf(err, "while reading %s credentials", key)
+		}
+		return strings.TrimSpace(string(dat)), true, nil
+	}
+	if env, ok := auth[key+"_env"]; ok {
+		val, ok := os.LookupEnv(env)
+		if !ok {
+			return "", false, errors.Errorf("environment variable %s with the %s credentials is not set", env, key)
+		}
+		return strings.TrimSpace(val), true, nil
+	}
+	return "", false, nil
+}
+
+// tlsConfig returns the TLS configuration of the repository, or nil
+// if the repository uses the default one
+func (r RepoData) tlsConfig() (*tls.Config, error) {
+	if r.TLS == nil {
+		return nil, nil
+	}
+
+	conf := &tls.Config{InsecureSkipVerify: r.TLS.InsecureSkipVerify}
+
+	if r.TLS.CACert != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := ioutil.ReadFile(r.TLS.CACert)
+		if err != nil {
+			return nil, errors.Wrap(err, "while reading the CA certificates")
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no valid certificates found in %s", r.TLS.CACert)
+		}
+		conf.RootCAs = pool
+	}
+
+	switch {
+	case r.TLS.ClientCert != "" && r.TLS.ClientKey != "":
+		cert, err := tls.LoadX509KeyPair(r.TLS.ClientCert, r.TLS.ClientKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "while loading the client certificate")
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	case r.TLS.ClientCert != "" || r.TLS.ClientKey != "":
+		return nil, errors.New("both client_cert and client_key are required for client authentication")
+	}
+
+	return conf, nil
+}
+
+// newHTTPClient returns an HTTP client using the proxy settings of the
+// environment and the TLS settings of the repository
+func newHTTPClient(r RepoData, timeout int) (*http.Client, error) {
+	conf, err := r.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Timeout: time.Duration(timeout) * time.Second,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: conf,
+		},
+	}, nil
+}
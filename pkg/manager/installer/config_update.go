@@ -0,0 +1,244 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/config"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	"github.com/bhojpur/iso/pkg/manager/helpers/diff"
+	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
+
+	"github.com/pkg/errors"
+)
+
+// ProtectedFilesDir is the folder under the database path keeping the last shipped
+// version of the config-protected files, used as base to merge their updates
+const ProtectedFilesDir = "protected"
+
+// pendingConfigRegexp matches the new versions of config-protected files written
+// by the installer next to the files changed in the system
+var pendingConfigRegexp = regexp.MustCompile(`^\._cfg[0-9]{4}_(.+)$`)
+
+// PendingConfig is a config-protected file with new versions shipped by packages,
+// which were not installed as the file in the system differs from them
+type PendingConfig struct {
+	// Path is the installed file, relative to the system target
+	Path string `json:"path" yaml:"path"`
+	// Updates are the new versions of the file, oldest first. The last one is the current.
+	Updates []string `json:"updates" yaml:"updates"`
+}
+
+// Update returns the path of the current new version of the file, relative to the system target
+func (p *PendingConfig) Update() string {
+	return p.Updates[len(p.Updates)-1]
+}
+
+// protectDirs returns the config-protected folders, from the configuration and the installed packages
+func (s *System) protectDirs(ctx types.Context) []string {
+	dirs := map[string]interface{}{}
+	for _, conf := range ctx.GetConfig().ConfigProtectConfFiles {
+		for _, d := range conf.Directories {
+			dirs[filepath.Clean("/"+d)] = nil
+		}
+	}
+	for _, p := range s.Database.World() {
+		if d, ok := p.Annotations[types.ConfigProtectAnnotation]; ok && d != "" {
+			dirs[filepath.Clean("/"+d)] = nil
+		}
+	}
+
+	res := []string{}
+	for d := range dirs {
+		res = append(res, d)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// PendingConfigs returns the config-protected files with updates waiting to be reviewed
+func (s *System) PendingConfigs(ctx types.Context) ([]*PendingConfig, error) {
+	pending := map[string]*PendingConfig{}
+	for _, d := range s.protectDirs(ctx) {
+		err := filepath.Walk(filepath.Join(s.Target, d), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			m := pendingConfigRegexp.FindStringSubmatch(info.Name())
+			if info.IsDir() || m == nil {
+				return nil
+			}
+			rel, err := filepath.Rel(s.Target, path)
+			if err != nil {
+				return err
+			}
+			installed := filepath.Join(filepath.Dir(rel), m[1])
+			if _, ok := pending[installed]; !ok {
+				pending[installed] = &PendingConfig{Path: installed}
+			}
+			pending[installed].Updates = append(pending[installed].Updates, rel)
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "while looking for config updates in %s", d)
+		}
+	}
+
+	res := []*PendingConfig{}
+	for _, p := range pending {
+		sort.Strings(p.Updates)
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Path < res[j].Path })
+	return res, nil
+}
+
+// ConfigDiff returns the unified diff between the installed file and its update
+func (s *System) ConfigDiff(p *PendingConfig) (string, error) {
+	installed, err := s.readTargetFile(p.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	update, err := s.readTargetFile(p.Update())
+	if err != nil {
+		return "", err
+	}
+	return diff.Unified(installed, update, "/"+p.Path, "/"+p.Update(), 3), nil
+}
+
+// MergeConfig merges the changes done to the installed file with the ones of its update,
+// taking as base the version shipped last. When the base is unknown, all the differences
+// are reported as conflicts.
+func (s *System) MergeConfig(p *PendingConfig) (merged string, conflicts bool, err error) {
+	installed, err := s.readTargetFile(p.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", false, err
+	}
+	update, err := s.readTargetFile(p.Update())
+	if err != nil {
+		return "", false, err
+	}
+	base := ""
+	if s.ProtectedDir != "" {
+		if b, err := ioutil.ReadFile(filepath.Join(s.ProtectedDir, p.Path)); err == nil {
+			base = string(b)
+		}
+	}
+
+	merged, conflicts = diff.Merge(base, installed, update)
+	return merged, conflicts, nil
+}
+
+// KeepConfig keeps the installed file, dropping its updates
+func (s *System) KeepConfig(p *PendingConfig) error {
+	return s.resolveConfig(p)
+}
+
+// ReplaceConfig replaces the installed file with its update
+func (s *System) ReplaceConfig(p *PendingConfig) error {
+	update, err := s.readTargetFile(p.Update())
+	if err != nil {
+		return err
+	}
+	return s.ApplyConfig(p, update)
+}
+
+// ApplyConfig writes the given content to the installed file, e.g. the result
+// of a merge, and drops its updates
+func (s *System) ApplyConfig(p *PendingConfig, content string) error {
+	target := filepath.Join(s.Target, p.Path)
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(filepath.Join(s.Target, p.Update())); err == nil {
+		mode = fi.Mode()
+	}
+	if fi, err := os.Stat(target); err == nil {
+		mode = fi.Mode()
+	}
+
+	tmp := target + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(content), mode); err != nil {
+		return errors.Wrapf(err, "while writing %s", target)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return errors.Wrapf(err, "while writing %s", target)
+	}
+	return s.resolveConfig(p)
+}
+
+// resolveConfig removes the updates of a file, keeping the current one as base for the next merge
+func (s *System) resolveConfig(p *PendingConfig) error {
+	if err := s.storeProtectedFile(p.Update(), p.Path); err != nil {
+		return err
+	}
+	for _, u := range p.Updates {
+		if err := os.Remove(filepath.Join(s.Target, u)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "while removing %s", u)
+		}
+	}
+	return nil
+}
+
+// storeProtectedFile keeps a copy of the file src of the system as the last shipped version of dst
+func (s *System) storeProtectedFile(src, dst string) error {
+	if s.ProtectedDir == "" {
+		return nil
+	}
+	stored := filepath.Join(s.ProtectedDir, dst)
+	if err := os.MkdirAll(filepath.Dir(stored), os.ModePerm); err != nil {
+		return errors.Wrapf(err, "while storing %s", dst)
+	}
+	return errors.Wrapf(fileHelper.CopyFile(filepath.Join(s.Target, src), stored), "while storing %s", dst)
+}
+
+func (s *System) readTargetFile(path string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(s.Target, path))
+	return string(b), err
+}
+
+// storeProtectedFiles keeps a copy of the config-protected files of a package which
+// were installed as shipped, to merge them with the versions shipped later
+func (l *BhojpurInstaller) storeProtectedFiles(cp *config.ConfigProtect, metadata []types.FileMetadata, s *System) {
+	if cp == nil || s.ProtectedDir == "" {
+		return
+	}
+	for _, m := range metadata {
+		if m.Sha256 == "" || !cp.Protected(m.Path) {
+			continue
+		}
+		// Files differing from the shipped ones were changed, and their update is pending
+		path := strings.TrimPrefix(m.Path, "/")
+		if sum, err := fileSha256(filepath.Join(s.Target, path)); err != nil || sum != m.Sha256 {
+			continue
+		}
+		if err := s.storeProtectedFile(path, path); err != nil {
+			l.Options.Context.Warning("Failed storing protected file", path, err.Error())
+		}
+	}
+}
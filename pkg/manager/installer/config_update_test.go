@@ -0,0 +1,144 @@
+package installer_test
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	pkg "github.com/bhojpur/iso/pkg/manager/database"
+	. "github.com/bhojpur/iso/pkg/manager/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config update", func() {
+	var s *System
+	var target, dbPath string
+	ctx := context.NewContext()
+
+	write := func(path, content string) {
+		Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+		Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+	read := func(path string) string {
+		b, err := ioutil.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		return string(b)
+	}
+
+	BeforeEach(func() {
+		var err error
+		target, err = ioutil.TempDir("", "target")
+		Expect(err).ToNot(HaveOccurred())
+		dbPath, err = ioutil.TempDir("", "db")
+		Expect(err).ToNot(HaveOccurred())
+
+		s = &System{
+			Database:     pkg.NewInMemoryDatabase(false),
+			Target:       target,
+			ProtectedDir: filepath.Join(dbPath, ProtectedFilesDir),
+		}
+		p := &types.Package{Name: "a", Version: "1", Category: "t"}
+		p.AddAnnotation(string(types.ConfigProtectAnnotation), "etc")
+		_, err = s.Database.CreatePackage(p)
+		Expect(err).ToNot(HaveOccurred())
+
+		// a.conf was shipped with "a\nb\nc\n", then changed locally,
+		// and a new version was shipped
+		write(filepath.Join(s.ProtectedDir, "etc", "a.conf"), "a\nb\nc\n")
+		write(filepath.Join(target, "etc", "a.conf"), "a\nlocal\nc\n")
+		write(filepath.Join(target, "etc", "._cfg0001_a.conf"), "a\nb\nc\nd\n")
+		write(filepath.Join(target, "etc", "._cfg0002_a.conf"), "a\nb\nc\nnew\n")
+		write(filepath.Join(target, "etc", "b.conf"), "b\n")
+		write(filepath.Join(target, "etc", "sub", "._cfg0001_c.conf"), "c\n")
+		write(filepath.Join(target, "usr", "._cfg0001_d.conf"), "d\n")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(target)
+		os.RemoveAll(dbPath)
+	})
+
+	It("finds the pending updates in the config-protected folders", func() {
+		pending, err := s.PendingConfigs(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pending).To(Equal([]*PendingConfig{
+			{Path: "etc/a.conf", Updates: []string{"etc/._cfg0001_a.conf", "etc/._cfg0002_a.conf"}},
+			{Path: "etc/sub/c.conf", Updates: []string{"etc/sub/._cfg0001_c.conf"}},
+		}))
+		Expect(pending[0].Update()).To(Equal("etc/._cfg0002_a.conf"))
+
+		d, err := s.ConfigDiff(pending[0])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(d).To(ContainSubstring("-local\n+b\n c\n+new\n"))
+	})
+
+	It("merges the local changes with the update", func() {
+		pending, err := s.PendingConfigs(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		merged, conflicts, err := s.MergeConfig(pending[0])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conflicts).To(BeFalse())
+		Expect(merged).To(Equal("a\nlocal\nc\nnew\n"))
+
+		Expect(s.ApplyConfig(pending[0], merged)).To(Succeed())
+		Expect(read(filepath.Join(target, "etc", "a.conf"))).To(Equal("a\nlocal\nc\nnew\n"))
+		Expect(read(filepath.Join(s.ProtectedDir, "etc", "a.conf"))).To(Equal("a\nb\nc\nnew\n"))
+		Expect(filepath.Join(target, "etc", "._cfg0001_a.conf")).ToNot(BeAnExistingFile())
+		Expect(filepath.Join(target, "etc", "._cfg0002_a.conf")).ToNot(BeAnExistingFile())
+
+		pending, err = s.PendingConfigs(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(pending)).To(Equal(1))
+	})
+
+	It("reports conflicts when the base is unknown", func() {
+		s.ProtectedDir = ""
+		pending, err := s.PendingConfigs(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, conflicts, err := s.MergeConfig(pending[0])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conflicts).To(BeTrue())
+	})
+
+	It("keeps or replaces the installed files", func() {
+		pending, err := s.PendingConfigs(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(s.KeepConfig(pending[0])).To(Succeed())
+		Expect(read(filepath.Join(target, "etc", "a.conf"))).To(Equal("a\nlocal\nc\n"))
+		Expect(read(filepath.Join(s.ProtectedDir, "etc", "a.conf"))).To(Equal("a\nb\nc\nnew\n"))
+
+		Expect(s.ReplaceConfig(pending[1])).To(Succeed())
+		Expect(read(filepath.Join(target, "etc", "sub", "c.conf"))).To(Equal("c\n"))
+
+		pending, err = s.PendingConfigs(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pending).To(BeEmpty())
+	})
+})
@@ -21,23 +21,72 @@ package installer
 // THE SOFTWARE.
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"time"
 
+	"github.com/bhojpur/iso/pkg/manager/api/core/template"
 	"github.com/bhojpur/iso/pkg/manager/api/core/types"
 	box "github.com/bhojpur/iso/pkg/manager/box"
+	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
+	"github.com/bhojpur/iso/pkg/manager/tree"
 	"github.com/ghodss/yaml"
 
 	"github.com/pkg/errors"
 )
 
+// finalizerOutputAnnotation is the context annotation holding the finalizerOutputFunc
+// notified with the output of the finalizers
+const finalizerOutputAnnotation = "finalizer_output"
+
+type finalizerOutputFunc func(p *types.Package, output string, err error)
+
+// notifyFinalizerOutput passes the output of the finalizer of a package to the
+// function set in the context, if any
+func notifyFinalizerOutput(ctx types.Context, p *types.Package, output string, err error) {
+	if notify, ok := ctx.GetAnnotation(finalizerOutputAnnotation).(finalizerOutputFunc); ok {
+		notify(p, output, err)
+	}
+}
+
 type BhojpurFinalizer struct {
 	Shell     []string `json:"shell"`
 	Install   []string `json:"install"`
-	Uninstall []string `json:"uninstall"` // TODO: Where to store?
+	Uninstall []string `json:"uninstall"`
+	// Timeout is the maximum time the finalizer steps can run (e.g. 5m),
+	// it overrides the finalizer_timeout of the configuration
+	Timeout string `json:"timeout"`
+}
+
+// RunInstall runs the install steps of the finalizer in the system target,
+// and returns their output
+func (f *BhojpurFinalizer) RunInstall(ctx types.Context, s *System) (string, error) {
+	return f.run(ctx, s, f.Install)
 }
 
-func (f *BhojpurFinalizer) RunInstall(ctx types.Context, s *System) error {
+// RunUnInstall runs the uninstall steps of the finalizer in the system target,
+// and returns their output
+func (f *BhojpurFinalizer) RunUnInstall(ctx types.Context, s *System) (string, error) {
+	return f.run(ctx, s, f.Uninstall)
+}
+
+// timeout returns the time the finalizer is allowed to run, 0 if unbounded
+func (f *BhojpurFinalizer) timeout(ctx types.Context) (time.Duration, error) {
+	if f.Timeout == "" {
+		return ctx.GetConfig().GetFinalizerTimeout()
+	}
+	timeout, err := time.ParseDuration(f.Timeout)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid finalizer timeout '%s'", f.Timeout)
+	}
+	return timeout, nil
+}
+
+func (f *BhojpurFinalizer) run(ctx types.Context, s *System, steps []string) (string, error) {
 	var cmd string
 	var args []string
 	if len(f.Shell) == 0 {
@@ -51,40 +100,65 @@ func (f *BhojpurFinalizer) RunInstall(ctx types.Context, s *System) error {
 		}
 	}
 
-	for _, c := range f.Install {
+	timeout, err := f.timeout(ctx)
+	if err != nil {
+		return "", err
+	}
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	output := &bytes.Buffer{}
+	for _, c := range steps {
 		toRun := append(args, c)
 		ctx.Info(":shell: Executing finalizer on ", s.Target, cmd, toRun)
-		if s.Target == string(os.PathSeparator) {
-			cmd := exec.Command(cmd, toRun...)
-			cmd.Env = ctx.GetConfig().FinalizerEnvs.Slice()
-			stdoutStderr, err := cmd.CombinedOutput()
-			if err != nil {
-				return errors.Wrap(err, "Failed running command: "+string(stdoutStderr))
-			}
-			ctx.Info(string(stdoutStderr))
-		} else {
-			b := box.NewBox(cmd, toRun, []string{}, ctx.GetConfig().FinalizerEnvs.Slice(), s.Target, false, true, true)
-			err := b.Run()
-			if err != nil {
-				return errors.Wrap(err, "Failed running command ")
+
+		remaining := time.Duration(0)
+		if !deadline.IsZero() {
+			if remaining = time.Until(deadline); remaining <= 0 {
+				return output.String(), fmt.Errorf("finalizer timed out after %s", timeout)
 			}
 		}
+
+		stepOutput := &bytes.Buffer{}
+		err := runFinalizerStep(ctx, s, cmd, toRun, remaining, stepOutput)
+		output.Write(stepOutput.Bytes())
+		if errors.Is(err, context.DeadlineExceeded) {
+			return output.String(), fmt.Errorf("finalizer timed out after %s", timeout)
+		}
+		if err != nil {
+			return output.String(), errors.Wrap(err, "Failed running command: "+stepOutput.String())
+		}
+		ctx.Info(stepOutput.String())
 	}
-	return nil
+	return output.String(), nil
 }
 
-// TODO: We don't store uninstall finalizers ?!
-func (f *BhojpurFinalizer) RunUnInstall(ctx types.Context) error {
-	for _, c := range f.Uninstall {
-		ctx.Debug("finalizer:", "sh", "-c", c)
-		cmd := exec.Command("sh", "-c", c)
-		stdoutStderr, err := cmd.CombinedOutput()
-		if err != nil {
-			return errors.Wrap(err, "Failed running command: "+string(stdoutStderr))
+// runFinalizerStep runs a finalizer command in the system target, chrooted with box
+// when the target is not the host. As box maps the current user in a user namespace,
+// this is also how finalizers run in rootless mode, which always has a target prefix.
+// It returns context.DeadlineExceeded on timeout.
+func runFinalizerStep(ctx types.Context, s *System, cmd string, args []string, timeout time.Duration, out io.Writer) error {
+	if s.Target != string(os.PathSeparator) {
+		b := &box.DefaultBox{
+			Cmd:     cmd,
+			Args:    args,
+			Env:     ctx.GetConfig().FinalizerEnvs.Slice(),
+			Root:    s.Target,
+			Stdout:  true,
+			Stderr:  true,
+			Output:  out,
+			Timeout: timeout,
 		}
-		ctx.Info(string(stdoutStderr))
+		return b.Run()
 	}
-	return nil
+
+	c := exec.Command(cmd, args...)
+	c.Env = ctx.GetConfig().FinalizerEnvs.Slice()
+	c.Stdout = out
+	c.Stderr = out
+	return box.RunWithTimeout(c, timeout)
 }
 
 func NewBhojpurFinalizerFromYaml(data []byte) (*BhojpurFinalizer, error) {
@@ -95,3 +169,12 @@ func NewBhojpurFinalizerFromYaml(data []byte) (*BhojpurFinalizer, error) {
 	}
 	return &p, err
 }
+
+// renderFinalizer renders the finalizer of a package of a tree.
+// It returns an empty string if the package has no finalizer.
+func renderFinalizer(p *types.Package) (string, error) {
+	if !fileHelper.Exists(p.Rel(tree.FinalizerFile)) {
+		return "", nil
+	}
+	return template.RenderWithValues([]string{p.Rel(tree.FinalizerFile)}, p.Rel(types.PackageDefinitionFile))
+}
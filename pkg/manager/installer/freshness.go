@@ -0,0 +1,167 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
+	"github.com/bhojpur/iso/pkg/manager/helpers/sign"
+	"github.com/bhojpur/iso/pkg/manager/installer/client"
+	"github.com/ghodss/yaml"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// syncTimeFile is the file of the repository database folder holding the time of the last sync
+	syncTimeFile = "SYNCTIME"
+	// validatorsSuffix is the suffix of the file holding the validators of the repository file
+	validatorsSuffix = ".validators"
+)
+
+// RepositoryFreshness describes how fresh the cached metadata of a repository are
+type RepositoryFreshness struct {
+	Name            string        `json:"name" yaml:"name"`
+	LastSync        time.Time     `json:"last_sync,omitempty" yaml:"last_sync,omitempty"`
+	RefreshInterval time.Duration `json:"refresh_interval" yaml:"refresh_interval"`
+	// Expired is set when the refresh interval elapsed since the last sync
+	Expired bool `json:"expired" yaml:"expired"`
+	// Checked is set when the remote metadata were compared with the cached ones,
+	// which requires a client supporting conditional requests
+	Checked bool `json:"checked" yaml:"checked"`
+	// Changed is set when the remote metadata differ from the cached ones
+	Changed bool `json:"changed" yaml:"changed"`
+}
+
+// Stale returns true if the repository needs to be synced
+func (f RepositoryFreshness) Stale() bool {
+	return f.LastSync.IsZero() || f.Expired || f.Changed
+}
+
+// CheckFreshness reports if the cached metadata of the repository are stale. When the client
+// of the repository supports conditional requests, the remote metadata are checked as well.
+func (r *BhojpurSystemRepository) CheckFreshness(ctx types.Context) (RepositoryFreshness, error) {
+	interval, err := r.GetRefreshInterval()
+	if err != nil {
+		return RepositoryFreshness{}, err
+	}
+
+	f := RepositoryFreshness{Name: r.GetName(), RefreshInterval: interval}
+	f.LastSync, _ = r.lastSync(ctx)
+	f.Expired = !f.LastSync.IsZero() && time.Now().After(f.LastSync.Add(interval))
+
+	repoFile := filepath.Join(ctx.GetConfig().System.GetRepoDatabaseDirPath(r.GetName()), r.referenceID())
+	v := readValidators(repoFile)
+	if !fileHelper.Exists(repoFile) || v == (client.FileValidators{}) {
+		return f, nil
+	}
+	cc, ok := r.Client(ctx).(client.ConditionalClient)
+	if !ok {
+		return f, nil
+	}
+
+	file, _, err := cc.DownloadFileIfChanged(r.referenceID(), v)
+	switch err {
+	case client.ErrNotModified:
+		f.Checked = true
+	case nil:
+		os.RemoveAll(file)
+		f.Checked, f.Changed = true, true
+	default:
+		return f, errors.Wrapf(err, "while checking repository %s", r.GetName())
+	}
+	return f, nil
+}
+
+// lastSync returns the time of the last sync of the repository, and false if it was never synced
+func (r *BhojpurSystemRepository) lastSync(ctx types.Context) (time.Time, bool) {
+	dat, err := ioutil.ReadFile(filepath.Join(ctx.GetConfig().System.GetRepoDatabaseDirPath(r.GetName()), syncTimeFile))
+	if err != nil {
+		return time.Time{}, false
+	}
+	parsed, _ := time.Parse(time.RFC3339, string(dat))
+	return parsed, true
+}
+
+func (r *BhojpurSystemRepository) touchSyncTime(ctx types.Context) {
+	now := time.Now().Format(time.RFC3339)
+	ioutil.WriteFile(filepath.Join(ctx.GetConfig().System.GetRepoDatabaseDirPath(r.GetName()), syncTimeFile), []byte(now), os.ModePerm)
+}
+
+// downloadSpecFile downloads the repository file, and its signature if the keyring isn't
+// empty. With clients supporting conditional requests, it returns client.ErrNotModified
+// if the repository file didn't change since the download which returned the validators.
+func (r *BhojpurSystemRepository) downloadSpecFile(ctx types.Context, c Client, keyring sign.Keyring, v client.FileValidators) (file, signatureFile string, updated client.FileValidators, err error) {
+	id := r.referenceID()
+	if cc, ok := c.(client.ConditionalClient); ok {
+		file, updated, err = cc.DownloadFileIfChanged(id, v)
+		if err == client.ErrNotModified {
+			return
+		}
+		if err != nil {
+			ctx.Debug("Conditional download of", id, "failed:", err.Error())
+		}
+	}
+	if file == "" {
+		updated = client.FileValidators{}
+		file, err = c.DownloadFile(id)
+		if err != nil {
+			return "", "", updated, errors.Wrap(err, "while downloading "+id)
+		}
+	}
+
+	if len(keyring) > 0 {
+		signatureFile, err = c.DownloadFile(id + sign.SignatureSuffix)
+		if err != nil {
+			os.RemoveAll(file)
+			return "", "", updated, errors.Wrap(err, "while downloading the signature of "+id)
+		}
+	}
+	return
+}
+
+func readValidators(repoFile string) client.FileValidators {
+	v := client.FileValidators{}
+	dat, err := ioutil.ReadFile(repoFile + validatorsSuffix)
+	if err == nil {
+		yaml.Unmarshal(dat, &v)
+	}
+	return v
+}
+
+// writeValidators stores the validators of the repository file. They have to
+// be written only once the local copy of the repository is up to date.
+func writeValidators(repoFile string, v client.FileValidators) error {
+	if v == (client.FileValidators{}) {
+		os.RemoveAll(repoFile + validatorsSuffix)
+		return nil
+	}
+	dat, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(repoFile+validatorsSuffix, dat, os.ModePerm)
+}
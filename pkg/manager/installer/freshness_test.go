@@ -0,0 +1,150 @@
+package installer_test
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	. "github.com/bhojpur/iso/pkg/manager/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Freshness", func() {
+	var ctx *context.Context
+	var dir, repoDir string
+	var server *httptest.Server
+	var mu sync.Mutex
+	var specStatus []int
+
+	repository := func() *BhojpurSystemRepository {
+		return NewSystemRepository(types.BhojpurRepository{
+			Name:            "test",
+			Type:            "http",
+			Urls:            []string{server.URL},
+			Cached:          true,
+			Enable:          true,
+			RefreshInterval: "1h",
+		})
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "freshness")
+		Expect(err).ToNot(HaveOccurred())
+		repoDir = filepath.Join(dir, "repo")
+
+		ctx = context.NewContext()
+		ctx.Config.System.DatabasePath = filepath.Join(dir, "db")
+		ctx.Config.System.PkgsCachePath = filepath.Join(dir, "cache")
+		writeTestRepository(ctx, dir, repoDir)
+
+		specStatus = []int{}
+		files := http.FileServer(http.Dir(repoDir))
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := httptest.NewRecorder()
+			files.ServeHTTP(rec, r)
+			if strings.HasSuffix(r.URL.Path, REPOSITORY_SPECFILE) {
+				mu.Lock()
+				specStatus = append(specStatus, rec.Code)
+				mu.Unlock()
+			}
+			for k, v := range rec.Header() {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(rec.Code)
+			w.Write(rec.Body.Bytes())
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("uses the refresh interval of the repository", func() {
+		r := repository()
+		f, err := r.CheckFreshness(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.Stale()).To(BeTrue())
+		Expect(f.RefreshInterval).To(Equal(time.Hour))
+
+		_, err = r.Sync(ctx, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(specStatus).To(Equal([]int{http.StatusOK}))
+
+		// Within the refresh interval the cached metadata are used
+		_, err = repository().Sync(ctx, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(specStatus).To(Equal([]int{http.StatusOK}))
+
+		r.RefreshInterval = "foo"
+		_, err = r.Sync(ctx, false)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("downloads the repository metadata only when they change", func() {
+		r := repository()
+		_, err := r.Sync(ctx, false)
+		Expect(err).ToNot(HaveOccurred())
+
+		f, err := r.CheckFreshness(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.Checked).To(BeTrue())
+		Expect(f.Stale()).To(BeFalse())
+
+		r.RefreshInterval = "0s"
+		synced, err := r.Sync(ctx, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(synced.GetIndex())).To(Equal(1))
+		Expect(specStatus).To(Equal([]int{http.StatusOK, http.StatusNotModified, http.StatusNotModified}))
+
+		future := time.Now().Add(time.Hour)
+		Expect(os.Chtimes(filepath.Join(repoDir, REPOSITORY_SPECFILE), future, future)).To(Succeed())
+		f, err = repository().CheckFreshness(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.Changed).To(BeTrue())
+		Expect(f.Stale()).To(BeTrue())
+	})
+
+	It("uses the cached metadata when the refresh fails", func() {
+		r := repository()
+		_, err := r.Sync(ctx, false)
+		Expect(err).ToNot(HaveOccurred())
+		server.Close()
+
+		r.RefreshInterval = "0s"
+		synced, err := r.Sync(ctx, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(synced.GetIndex())).To(Equal(1))
+
+		_, err = r.Sync(ctx, true)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("cached metadata"))
+	})
+})
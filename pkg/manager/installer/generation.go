@@ -0,0 +1,294 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	"github.com/bhojpur/iso/pkg/manager/helpers/pkgdiff"
+
+	"github.com/pkg/errors"
+)
+
+// GenerationsDir is the folder under the database path holding the
+// generations of the system
+const GenerationsDir = "generations"
+
+// GenerationPackage is a package installed in a system generation
+type GenerationPackage struct {
+	Category   string `json:"category" yaml:"category"`
+	Name       string `json:"name" yaml:"name"`
+	Version    string `json:"version" yaml:"version"`
+	Repository string `json:"repository,omitempty" yaml:"repository,omitempty"`
+	// Reason is the install reason of the package, empty if it wasn't recorded
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// Generation is a snapshot of the packages installed in a system,
+// taken after every successful transaction
+type Generation struct {
+	ID        int                 `json:"id" yaml:"id"`
+	Timestamp time.Time           `json:"timestamp" yaml:"timestamp"`
+	Command   string              `json:"command" yaml:"command"`
+	Packages  []GenerationPackage `json:"packages" yaml:"packages"`
+}
+
+// GenerationChange is a package whose version differs between two generations
+type GenerationChange struct {
+	Category string `json:"category" yaml:"category"`
+	Name     string `json:"name" yaml:"name"`
+	From     string `json:"from" yaml:"from"`
+	To       string `json:"to" yaml:"to"`
+}
+
+// GenerationDiff lists the package changes between two generations
+type GenerationDiff struct {
+	Added   []GenerationPackage `json:"added,omitempty" yaml:"added,omitempty"`
+	Removed []GenerationPackage `json:"removed,omitempty" yaml:"removed,omitempty"`
+	Changed []GenerationChange  `json:"changed,omitempty" yaml:"changed,omitempty"`
+}
+
+// Empty returns true if the two compared generations have the same packages
+func (d *GenerationDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func (p GenerationPackage) key() string {
+	return p.Category + "/" + p.Name
+}
+
+// Package returns the package definition matching the generation package
+func (p GenerationPackage) Package() *types.Package {
+	pack := types.NewPackage(p.Name, p.Version, []*types.Package{}, []*types.Package{})
+	pack.SetCategory(p.Category)
+	return pack
+}
+
+// restoreReasons returns the options installing the given packages with their
+// recorded install reason. Packages recorded without a reason are explicit.
+func restoreReasons(packages []GenerationPackage) Option {
+	o := Option{Reasons: map[string]string{}}
+	for _, p := range packages {
+		pack := p.Package()
+		if p.Reason == "" {
+			o.Explicit = append(o.Explicit, pack)
+			continue
+		}
+		o.Reasons[pack.GetPackageName()] = p.Reason
+	}
+	return o
+}
+
+// DiffGenerations reports the package changes needed to go from the generation src to dst
+func DiffGenerations(src, dst *Generation) *GenerationDiff {
+	res := &GenerationDiff{}
+	srcPkgs, dstPkgs := src.packagesByKey(), dst.packagesByKey()
+	diff := pkgdiff.Compare(src.packageSet(), dst.packageSet())
+	for _, p := range diff.Added {
+		res.Added = append(res.Added, dstPkgs[p.Key()])
+	}
+	for _, p := range diff.Removed {
+		res.Removed = append(res.Removed, srcPkgs[p.Key()])
+	}
+	for _, c := range diff.Changed {
+		res.Changed = append(res.Changed, GenerationChange(c))
+	}
+	return res
+}
+
+func (g *Generation) packageSet() []pkgdiff.Package {
+	res := make([]pkgdiff.Package, len(g.Packages))
+	for i, p := range g.Packages {
+		res[i] = pkgdiff.Package{Category: p.Category, Name: p.Name, Version: p.Version}
+	}
+	return res
+}
+
+func (g *Generation) packagesByKey() map[string]GenerationPackage {
+	res := map[string]GenerationPackage{}
+	for _, p := range g.Packages {
+		res[p.key()] = p
+	}
+	return res
+}
+
+func sortGenerationPackages(p []GenerationPackage) {
+	sort.SliceStable(p, func(i, j int) bool { return p[i].key() < p[j].key() })
+}
+
+// Generations returns the recorded generations of the system, oldest first
+func (s *System) Generations() ([]*Generation, error) {
+	res := []*Generation{}
+	if s.GenerationsDir == "" {
+		return res, nil
+	}
+
+	files, err := ioutil.ReadDir(s.GenerationsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return res, nil
+		}
+		return nil, errors.Wrap(err, "while reading generations")
+	}
+
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSuffix(f.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		g, err := s.Generation(id)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, g)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res, nil
+}
+
+// Generation returns the generation with the given number
+func (s *System) Generation(id int) (*Generation, error) {
+	if s.GenerationsDir == "" {
+		return nil, errors.New("the system doesn't record generations")
+	}
+
+	b, err := ioutil.ReadFile(s.generationPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("generation %d not found", id)
+		}
+		return nil, errors.Wrapf(err, "while reading generation %d", id)
+	}
+
+	g := &Generation{}
+	if err := json.Unmarshal(b, g); err != nil {
+		return nil, errors.Wrapf(err, "while reading generation %d", id)
+	}
+	return g, nil
+}
+
+// CurrentGeneration returns a generation describing the packages currently installed in the system.
+// Repositories are taken from the given generation, if any.
+func (s *System) CurrentGeneration(last *Generation) *Generation {
+	return s.snapshot(last, map[string]string{})
+}
+
+func (s *System) generationPath(id int) string {
+	return filepath.Join(s.GenerationsDir, fmt.Sprintf("%d.json", id))
+}
+
+func (s *System) snapshot(last *Generation, repositories map[string]string) *Generation {
+	known := map[string]string{}
+	if last != nil {
+		for _, p := range last.Packages {
+			known[p.key()+"@"+p.Version] = p.Repository
+		}
+	}
+
+	g := &Generation{Timestamp: time.Now().UTC(), Command: strings.Join(os.Args, " ")}
+	for _, p := range s.Database.World() {
+		gp := GenerationPackage{Category: p.GetCategory(), Name: p.GetName(), Version: p.GetVersion(), Reason: p.GetInstallReason()}
+		if r, ok := repositories[p.GetFingerPrint()]; ok {
+			gp.Repository = r
+		} else {
+			gp.Repository = known[gp.key()+"@"+gp.Version]
+		}
+		g.Packages = append(g.Packages, gp)
+	}
+	sortGenerationPackages(g.Packages)
+	return g
+}
+
+// recordBaselineGeneration stores the packages currently installed as the first
+// generation of the system, if none is recorded yet, so that the state preceding
+// its first transaction can be rolled back to. The baseline has no command.
+func (s *System) recordBaselineGeneration() error {
+	if s.GenerationsDir == "" {
+		return nil
+	}
+
+	generations, err := s.Generations()
+	if err != nil || len(generations) > 0 {
+		return err
+	}
+
+	g := s.snapshot(nil, map[string]string{})
+	g.ID = 1
+	g.Command = ""
+	return s.writeGeneration(g)
+}
+
+// recordGeneration stores a new generation with the packages currently installed in the system.
+// repositories maps the fingerprint of the packages installed during the transaction
+// to the repository they were installed from.
+func (s *System) recordGeneration(repositories map[string]string) error {
+	if s.GenerationsDir == "" {
+		return nil
+	}
+
+	generations, err := s.Generations()
+	if err != nil {
+		return err
+	}
+
+	var last *Generation
+	if len(generations) > 0 {
+		last = generations[len(generations)-1]
+	}
+
+	g := s.snapshot(last, repositories)
+	if last != nil {
+		g.ID = last.ID + 1
+	} else {
+		g.ID = 1
+	}
+	return s.writeGeneration(g)
+}
+
+func (s *System) writeGeneration(g *Generation) error {
+	b, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.GenerationsDir, os.ModePerm); err != nil {
+		return errors.Wrap(err, "while creating generations folder")
+	}
+
+	// Write to a temporary file first, so an interrupted write doesn't leave a broken generation behind
+	tmp := s.generationPath(g.ID) + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return errors.Wrapf(err, "while writing generation %d", g.ID)
+	}
+	return os.Rename(tmp, s.generationPath(g.ID))
+}
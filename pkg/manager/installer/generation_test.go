@@ -0,0 +1,136 @@
+package installer_test
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	pkg "github.com/bhojpur/iso/pkg/manager/database"
+	. "github.com/bhojpur/iso/pkg/manager/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Generations", func() {
+	Context("Recording", func() {
+		var s *System
+		var db types.PackageDatabase
+		var a, b *types.Package
+		var target, dbPath string
+		ctx := context.NewContext()
+
+		BeforeEach(func() {
+			var err error
+			target, err = ioutil.TempDir("", "target")
+			Expect(err).ToNot(HaveOccurred())
+			dbPath, err = ioutil.TempDir("", "db")
+			Expect(err).ToNot(HaveOccurred())
+
+			db = pkg.NewInMemoryDatabase(false)
+			s = &System{
+				Database:       db,
+				Target:         target,
+				JournalDir:     filepath.Join(dbPath, TransactionJournalDir),
+				GenerationsDir: filepath.Join(dbPath, GenerationsDir),
+			}
+
+			a = &types.Package{Name: "a", Version: "1", Category: "t"}
+			b = &types.Package{Name: "b", Version: "1", Category: "t"}
+			for _, p := range []*types.Package{a, b} {
+				_, err := db.CreatePackage(p)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ioutil.WriteFile(filepath.Join(target, p.GetName()), []byte(p.GetName()), os.ModePerm)).To(Succeed())
+				Expect(db.SetPackageFiles(&types.PackageFile{PackageFingerprint: p.GetFingerPrint(), Files: []string{p.GetName()}})).To(Succeed())
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(target)
+			os.RemoveAll(dbPath)
+		})
+
+		It("has no generations before any transaction", func() {
+			generations, err := s.Generations()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generations).To(BeEmpty())
+
+			_, err = s.Generation(1)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("records a baseline generation and a generation after each successful transaction", func() {
+			inst := NewBhojpurInstaller(BhojpurInstallerOptions{Concurrency: 1, Context: ctx})
+
+			Expect(inst.Uninstall(s, b)).To(Succeed())
+			Expect(inst.Uninstall(s, a)).To(Succeed())
+
+			generations, err := s.Generations()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(generations)).To(Equal(3))
+
+			Expect(generations[0].ID).To(Equal(1))
+			Expect(generations[0].Command).To(BeEmpty())
+			Expect(generations[0].Packages).To(Equal([]GenerationPackage{{Category: "t", Name: "a", Version: "1", Reason: types.InstallReasonExplicit}, {Category: "t", Name: "b", Version: "1", Reason: types.InstallReasonExplicit}}))
+			Expect(generations[1].ID).To(Equal(2))
+			Expect(generations[1].Command).ToNot(BeEmpty())
+			Expect(generations[1].Packages).To(Equal([]GenerationPackage{{Category: "t", Name: "a", Version: "1", Reason: types.InstallReasonExplicit}}))
+			Expect(generations[2].ID).To(Equal(3))
+			Expect(generations[2].Packages).To(BeEmpty())
+
+			diff := DiffGenerations(generations[2], generations[1])
+			Expect(diff.Added).To(Equal([]GenerationPackage{{Category: "t", Name: "a", Version: "1", Reason: types.InstallReasonExplicit}}))
+			Expect(diff.Removed).To(BeEmpty())
+			Expect(diff.Changed).To(BeEmpty())
+		})
+
+		It("doesn't record generations without a generations folder", func() {
+			s.GenerationsDir = ""
+			inst := NewBhojpurInstaller(BhojpurInstallerOptions{Concurrency: 1, Context: ctx})
+			Expect(inst.Uninstall(s, b)).To(Succeed())
+			Expect(filepath.Join(dbPath, GenerationsDir)).ToNot(BeADirectory())
+		})
+	})
+
+	Context("Diff", func() {
+		It("reports added, removed and changed packages", func() {
+			src := &Generation{Packages: []GenerationPackage{
+				{Category: "t", Name: "a", Version: "1", Repository: "main"},
+				{Category: "t", Name: "b", Version: "1"},
+			}}
+			dst := &Generation{Packages: []GenerationPackage{
+				{Category: "t", Name: "a", Version: "2", Repository: "main"},
+				{Category: "t", Name: "c", Version: "1"},
+			}}
+
+			diff := DiffGenerations(src, dst)
+			Expect(diff.Empty()).To(BeFalse())
+			Expect(diff.Added).To(Equal([]GenerationPackage{{Category: "t", Name: "c", Version: "1"}}))
+			Expect(diff.Removed).To(Equal([]GenerationPackage{{Category: "t", Name: "b", Version: "1"}}))
+			Expect(diff.Changed).To(Equal([]GenerationChange{{Category: "t", Name: "a", From: "1", To: "2"}}))
+			Expect(DiffGenerations(src, src).Empty()).To(BeTrue())
+		})
+	})
+})
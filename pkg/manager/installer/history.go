@@ -0,0 +1,279 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// HistorySucceeded is the outcome of the transactions applied to the system.
+	// Failing finalizers don't make a transaction fail, their error is recorded.
+	HistorySucceeded = "succeeded"
+	// HistoryFailed is the outcome of the transactions which failed. When the
+	// system is journaled, their changes were rolled back.
+	HistoryFailed = "failed"
+)
+
+// HistoryRecord describes an installer operation and the package changes it applied to the system
+type HistoryRecord struct {
+	ID        int            `json:"id" yaml:"id"`
+	Timestamp time.Time      `json:"timestamp" yaml:"timestamp"`
+	User      string         `json:"user" yaml:"user"`
+	Command   string         `json:"command" yaml:"command"`
+	Operation string         `json:"operation" yaml:"operation"`
+	Changes   GenerationDiff `json:"changes" yaml:"changes"`
+	Outcome   string         `json:"outcome" yaml:"outcome"`
+	Error     string         `json:"error,omitempty" yaml:"error,omitempty"`
+
+	before *Generation
+}
+
+func newHistoryRecord(t *types.TransactionRecord) (*HistoryRecord, error) {
+	r := &HistoryRecord{
+		ID:        t.ID,
+		Timestamp: t.Timestamp,
+		User:      t.User,
+		Command:   t.Command,
+		Operation: t.Operation,
+		Outcome:   t.Outcome,
+		Error:     t.Error,
+	}
+	if len(t.Changes) > 0 {
+		if err := json.Unmarshal(t.Changes, &r.Changes); err != nil {
+			return nil, errors.Wrapf(err, "while reading transaction %d", t.ID)
+		}
+	}
+	return r, nil
+}
+
+func (r *HistoryRecord) transactionRecord() (*types.TransactionRecord, error) {
+	changes, err := json.Marshal(r.Changes)
+	if err != nil {
+		return nil, err
+	}
+	return &types.TransactionRecord{
+		ID:        r.ID,
+		Timestamp: r.Timestamp,
+		User:      r.User,
+		Command:   r.Command,
+		Operation: r.Operation,
+		Changes:   changes,
+		Outcome:   r.Outcome,
+		Error:     r.Error,
+	}, nil
+}
+
+// History returns the transactions recorded in the system database, oldest first
+func (s *System) History() ([]*HistoryRecord, error) {
+	res := []*HistoryRecord{}
+	if !s.KeepHistory {
+		return res, nil
+	}
+
+	records, err := s.Database.GetTransactionRecords()
+	if err != nil {
+		return nil, errors.Wrap(err, "while reading history")
+	}
+	for _, t := range records {
+		r, err := newHistoryRecord(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res, nil
+}
+
+// HistoryRecord returns the transaction with the given id
+func (s *System) HistoryRecord(id int) (*HistoryRecord, error) {
+	if !s.KeepHistory {
+		return nil, errors.New("the system doesn't record the transactions history")
+	}
+
+	history, err := s.History()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range history {
+		if r.ID == id {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("transaction %d not found", id)
+}
+
+// beginHistory starts recording an installer operation. It returns nil if the
+// system has no history, or if an operation is already being recorded.
+func (s *System) beginHistory(operation string) *HistoryRecord {
+	s.Lock()
+	running := s.history != nil
+	s.Unlock()
+	if !s.KeepHistory || running {
+		return nil
+	}
+
+	r := &HistoryRecord{
+		Timestamp: time.Now().UTC(),
+		User:      currentUser(),
+		Command:   strings.Join(os.Args, " "),
+		Operation: operation,
+		before:    s.CurrentGeneration(nil),
+	}
+	s.Lock()
+	s.history = r
+	s.Unlock()
+	return r
+}
+
+// recordHistory stores the record of an installer operation in the system database,
+// with the package changes it applied to the system. Operations which didn't change
+// anything and didn't fail are not recorded. A record already stored is updated.
+// When the transaction is still running the record is journaled, so that it is marked
+// as failed if the commit is interrupted and the changes are rolled back.
+func (s *System) recordHistory(r *HistoryRecord, err error) error {
+	if r == nil {
+		return nil
+	}
+	s.Lock()
+	s.history = nil
+	s.Unlock()
+
+	r.Changes = *DiffGenerations(r.before, s.CurrentGeneration(nil))
+	r.Outcome = HistorySucceeded
+	var ferr *finalizerError
+	if err != nil && !errors.As(err, &ferr) {
+		r.Outcome = HistoryFailed
+	}
+	r.Error = ""
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if r.ID == 0 && r.Changes.Empty() && err == nil {
+		return nil
+	}
+
+	t, herr := r.transactionRecord()
+	if herr != nil {
+		return herr
+	}
+	recorded := r.ID != 0
+	if err := s.Database.SetTransactionRecord(t); err != nil {
+		return errors.Wrap(err, "while storing transaction record")
+	}
+	r.ID = t.ID
+	if recorded {
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if s.tx == nil {
+		return nil
+	}
+	return s.tx.append(journalEntry{Op: journalHistoryRecorded, Record: r.ID})
+}
+
+// failHistoryRecord marks the record of an interrupted transaction as failed
+func (s *System) failHistoryRecord(id int, reason string) error {
+	records, err := s.Database.GetTransactionRecords()
+	if err != nil {
+		return err
+	}
+	for _, t := range records {
+		if t.ID != id {
+			continue
+		}
+		r, err := newHistoryRecord(t)
+		if err != nil {
+			return err
+		}
+		r.Outcome = HistoryFailed
+		r.Error = reason
+		r.Changes = GenerationDiff{}
+		t, err = r.transactionRecord()
+		if err != nil {
+			return err
+		}
+		return s.Database.SetTransactionRecord(t)
+	}
+	return fmt.Errorf("transaction %d not found", id)
+}
+
+// currentUser returns the user running the operation, looking through sudo
+func currentUser() string {
+	if u := os.Getenv("SUDO_USER"); u != "" {
+		return u
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return strconv.Itoa(os.Getuid())
+}
+
+// Undo reverts the package changes of a recorded transaction, by swapping the
+// packages it installed or upgraded with the ones it removed or replaced
+func (l *BhojpurInstaller) Undo(s *System, id int) (err error) {
+	r, err := s.HistoryRecord(id)
+	if err != nil {
+		return err
+	}
+	if r.Changes.Empty() {
+		l.Options.Context.Info(fmt.Sprintf("Transaction %d didn't change any package, nothing to undo", id))
+		return nil
+	}
+
+	commit, err := l.beginTransaction(s, "undo")
+	if err != nil {
+		return err
+	}
+	defer func() { err = commit(err) }()
+
+	toRemove, toInstall := types.Packages{}, types.Packages{}
+	for _, p := range r.Changes.Added {
+		toRemove = append(toRemove, p.Package())
+	}
+	for _, p := range r.Changes.Removed {
+		toInstall = append(toInstall, p.Package())
+	}
+	for _, c := range r.Changes.Changed {
+		toRemove = append(toRemove, GenerationPackage{Category: c.Category, Name: c.Name, Version: c.To}.Package())
+		toInstall = append(toInstall, GenerationPackage{Category: c.Category, Name: c.Name, Version: c.From}.Package())
+	}
+
+	// Removed packages get back the install reason they had, the downgraded
+	// ones keep the reason of the version they replace
+	l.Options.Context.Info(fmt.Sprintf(":back: Undoing transaction %d (%s, %s)", r.ID, r.Operation, r.Timestamp.Local().Format(time.RFC1123)))
+	return l.swapPackages(restoreReasons(r.Changes.Removed), toRemove, toInstall, s)
+}
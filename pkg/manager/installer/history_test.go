@@ -0,0 +1,137 @@
+package installer_test
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	pkg "github.com/bhojpur/iso/pkg/manager/database"
+	. "github.com/bhojpur/iso/pkg/manager/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("History", func() {
+	var s *System
+	var db types.PackageDatabase
+	var a, b *types.Package
+	var target, dbPath string
+	ctx := context.NewContext()
+
+	BeforeEach(func() {
+		var err error
+		target, err = ioutil.TempDir("", "target")
+		Expect(err).ToNot(HaveOccurred())
+		dbPath, err = ioutil.TempDir("", "db")
+		Expect(err).ToNot(HaveOccurred())
+
+		db = pkg.NewInMemoryDatabase(false)
+		s = &System{
+			Database:    db,
+			Target:      target,
+			JournalDir:  filepath.Join(dbPath, TransactionJournalDir),
+			KeepHistory: true,
+		}
+
+		a = &types.Package{Name: "a", Version: "1", Category: "t"}
+		b = &types.Package{Name: "b", Version: "1", Category: "t"}
+		b.SetInstallReason(types.InstallReasonDependency)
+		for _, p := range []*types.Package{a, b} {
+			_, err := db.CreatePackage(p)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ioutil.WriteFile(filepath.Join(target, p.GetName()), []byte(p.GetName()), os.ModePerm)).To(Succeed())
+			Expect(db.SetPackageFiles(&types.PackageFile{PackageFingerprint: p.GetFingerPrint(), Files: []string{p.GetName()}})).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(target)
+		os.RemoveAll(dbPath)
+	})
+
+	It("records the transactions applied to the system", func() {
+		inst := NewBhojpurInstaller(BhojpurInstallerOptions{Concurrency: 1, Context: ctx})
+
+		Expect(inst.Uninstall(s, b)).To(Succeed())
+		Expect(inst.Uninstall(s, &types.Package{Name: "c", Version: "1", Category: "t"})).ToNot(Succeed())
+
+		history, err := s.History()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(history)).To(Equal(2))
+
+		Expect(history[0].ID).To(Equal(1))
+		Expect(history[0].Operation).To(Equal("uninstall"))
+		Expect(history[0].Outcome).To(Equal(HistorySucceeded))
+		Expect(history[0].User).ToNot(BeEmpty())
+		Expect(history[0].Command).ToNot(BeEmpty())
+		Expect(history[0].Changes.Removed).To(Equal([]GenerationPackage{{Category: "t", Name: "b", Version: "1", Reason: types.InstallReasonDependency}}))
+		Expect(history[0].Changes.Added).To(BeEmpty())
+
+		Expect(history[1].ID).To(Equal(2))
+		Expect(history[1].Outcome).To(Equal(HistoryFailed))
+		Expect(history[1].Error).To(ContainSubstring("not found"))
+		Expect(history[1].Changes.Empty()).To(BeTrue())
+
+		r, err := s.HistoryRecord(1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(r).To(Equal(history[0]))
+		_, err = s.HistoryRecord(3)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("records transactions of systems without a journal", func() {
+		s.JournalDir = ""
+		inst := NewBhojpurInstaller(BhojpurInstallerOptions{Concurrency: 1, Context: ctx})
+
+		Expect(inst.Uninstall(s, a, b)).To(Succeed())
+
+		history, err := s.History()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(history)).To(Equal(1))
+		Expect(history[0].Changes.Removed).To(Equal([]GenerationPackage{
+			{Category: "t", Name: "a", Version: "1", Reason: types.InstallReasonExplicit},
+			{Category: "t", Name: "b", Version: "1", Reason: types.InstallReasonDependency},
+		}))
+	})
+
+	It("doesn't undo transactions which didn't change packages", func() {
+		inst := NewBhojpurInstaller(BhojpurInstallerOptions{Concurrency: 1, Context: ctx})
+		Expect(inst.Uninstall(s, &types.Package{Name: "c", Version: "1", Category: "t"})).ToNot(Succeed())
+
+		Expect(inst.Undo(s, 1)).To(Succeed())
+		Expect(inst.Undo(s, 2)).ToNot(Succeed())
+		Expect(len(db.World())).To(Equal(2))
+	})
+
+	It("doesn't record the history when disabled", func() {
+		s.KeepHistory = false
+		inst := NewBhojpurInstaller(BhojpurInstallerOptions{Concurrency: 1, Context: ctx})
+		Expect(inst.Uninstall(s, b)).To(Succeed())
+		records, err := db.GetTransactionRecords()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(records).To(BeEmpty())
+	})
+})
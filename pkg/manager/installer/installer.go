@@ -28,9 +28,9 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/bhojpur/iso/pkg/manager/api/core/config"
-	"github.com/bhojpur/iso/pkg/manager/api/core/logger"
 	"github.com/bhojpur/iso/pkg/manager/helpers"
 	"github.com/hashicorp/go-multierror"
 
@@ -40,7 +40,6 @@ import (
 	pkg "github.com/bhojpur/iso/pkg/manager/database"
 	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
 	"github.com/bhojpur/iso/pkg/manager/solver"
-	"github.com/pterm/pterm"
 
 	"github.com/pkg/errors"
 )
@@ -60,12 +59,18 @@ type BhojpurInstallerOptions struct {
 	Relaxed                                                        bool
 	PackageRepositories                                            types.BhojpurRepositories
 	AutoOSCheck                                                    bool
+	// Pretend computes the plan of the operations without applying it
+	Pretend bool
+	// Observer is notified of the progress of the operations
+	Observer Observer
 
 	Context types.Context
 }
 
 type BhojpurInstaller struct {
 	Options BhojpurInstallerOptions
+
+	plan *Plan
 }
 
 type ArtifactMatch struct {
@@ -74,7 +79,12 @@ type ArtifactMatch struct {
 	Repository Repository
 }
 
+// NewBhojpurInstaller returns an installer with the given options. Its progress is
+// displayed in the terminal unless an observer is set.
 func NewBhojpurInstaller(opts BhojpurInstallerOptions) *BhojpurInstaller {
+	if opts.Observer == nil {
+		opts.Observer = NewTerminalObserver()
+	}
 	return &BhojpurInstaller{Options: opts}
 }
 
@@ -87,25 +97,32 @@ func (l *BhojpurInstaller) computeUpgrade(syncedRepos Repositories, s *System) (
 	// First match packages against repositories by priority
 	allRepos := pkg.NewInMemoryDatabase(false)
 	syncedRepos.SyncDatabase(allRepos)
+
+	pins, err := s.PinnedPackages()
+	if err != nil {
+		return uninstall, toInstall, err
+	}
+	l.reportHeldBack(pins, allRepos, s)
+
 	// compute a "big" world
 	solv := solver.NewResolver(
 		types.SolverOptions{
 			Type:        l.Options.SolverOptions.Implementation,
-			Concurrency: l.Options.Concurrency},
+			Concurrency: l.Options.Concurrency,
+			Pins:        pins},
 		s.Database, allRepos, pkg.NewInMemoryDatabase(false),
 		solver.NewSolverFromOptions(l.Options.SolverOptions))
 	var solution types.PackagesAssertions
 
+	l.observer().SolveStarted(types.Packages{})
 	if l.Options.SolverUpgrade {
 		uninstall, solution, err = solv.UpgradeUniverse(l.Options.RemoveUnavailableOnUpgrade)
-		if err != nil {
-			return uninstall, toInstall, errors.Wrap(err, "Failed solving solution for upgrade")
-		}
 	} else {
 		uninstall, solution, err = solv.Upgrade(l.Options.FullUninstall, true)
-		if err != nil {
-			return uninstall, toInstall, errors.Wrap(err, "Failed solving solution for upgrade")
-		}
+	}
+	l.observer().SolveFinished(append(assertRemoved(uninstall), solution...), err)
+	if err != nil {
+		return uninstall, toInstall, errors.Wrap(err, "Failed solving solution for upgrade")
 	}
 
 	for _, assertion := range solution {
@@ -139,8 +156,14 @@ func (l *BhojpurInstaller) computeUpgrade(syncedRepos Repositories, s *System) (
 }
 
 // Upgrade upgrades a System based on the Installer options. Returns error in case of failure
-func (l *BhojpurInstaller) Upgrade(s *System) error {
+func (l *BhojpurInstaller) Upgrade(s *System) (err error) {
 	l.Options.Context.Screen("Upgrade")
+	commit, err := l.beginTransaction(s, "upgrade")
+	if err != nil {
+		return err
+	}
+	defer func() { err = commit(err) }()
+
 	syncedRepos, err := l.SyncRepositories()
 	if err != nil {
 		return err
@@ -154,6 +177,26 @@ func (l *BhojpurInstaller) Upgrade(s *System) error {
 	return l.checkAndUpgrade(syncedRepos, s)
 }
 
+// reportHeldBack informs about the installed packages which have newer versions
+// available in the repositories, not allowed by their pin
+func (l *BhojpurInstaller) reportHeldBack(pins types.Packages, allRepos types.PackageDatabase, s *System) {
+	for _, pin := range pins {
+		installed, err := s.Database.FindPackageVersions(pin)
+		if err != nil || len(installed) == 0 {
+			continue
+		}
+		available, err := allRepos.FindPackageVersions(pin)
+		if err != nil || len(available) == 0 {
+			continue
+		}
+		best := available.Best(nil)
+		if !pin.AllowsVersion(best) && !installed.Best(nil).Matches(best) {
+			l.Options.Context.Info(":pushpin: Holding back", installed.Best(nil).HumanReadableString(),
+				"pinned to version", pin.GetVersion(), "( available:", best.GetVersion(), ")")
+		}
+	}
+}
+
 func (l *BhojpurInstaller) SyncRepositories() (Repositories, error) {
 	l.Options.Context.Spinner()
 	defer l.Options.Context.SpinnerStop()
@@ -176,7 +219,19 @@ func (l *BhojpurInstaller) SyncRepositories() (Repositories, error) {
 	return syncedRepos, errs
 }
 
-func (l *BhojpurInstaller) Swap(toRemove types.Packages, toInstall types.Packages, s *System) error {
+func (l *BhojpurInstaller) Swap(toRemove types.Packages, toInstall types.Packages, s *System) (err error) {
+	return l.swapPackages(Option{Explicit: toInstall}, toRemove, toInstall, s)
+}
+
+// swapPackages is Swap, installing packages with the explicit packages,
+// install reasons and repositories of the given option
+func (l *BhojpurInstaller) swapPackages(opt Option, toRemove types.Packages, toInstall types.Packages, s *System) (err error) {
+	commit, err := l.beginTransaction(s, "replace")
+	if err != nil {
+		return err
+	}
+	defer func() { err = commit(err) }()
+
 	syncedRepos, err := l.SyncRepositories()
 	if err != nil {
 		return err
@@ -199,16 +254,82 @@ func (l *BhojpurInstaller) Swap(toRemove types.Packages, toInstall types.Package
 		FullCleanUninstall: false,
 		NoDeps:             l.Options.NoDeps,
 		OnlyDeps:           false,
+		Explicit:           opt.Explicit,
+		Reasons:            opt.Reasons,
+		Repositories:       opt.Repositories,
 	}
 
 	return l.swap(o, syncedRepos, toRemoveFinal, toInstall, s)
 }
 
+// Rollback brings the system back to the package set of the given generation,
+// swapping the packages which were added, removed or changed since then
+func (l *BhojpurInstaller) Rollback(s *System, id int) (err error) {
+	g, err := s.Generation(id)
+	if err != nil {
+		return err
+	}
+
+	diff := DiffGenerations(s.CurrentGeneration(nil), g)
+	if diff.Empty() {
+		l.Options.Context.Info(fmt.Sprintf("System already matches generation %d", id))
+		return nil
+	}
+
+	commit, err := l.beginTransaction(s, "rollback")
+	if err != nil {
+		return err
+	}
+	defer func() { err = commit(err) }()
+
+	// Packages are installed back from the repository recorded in the generation, when available
+	repositories := map[string]string{}
+	install := func(p GenerationPackage) *types.Package {
+		pack := p.Package()
+		if p.Repository != "" {
+			repositories[pack.GetFingerPrint()] = p.Repository
+		}
+		return pack
+	}
+
+	packages := g.packagesByKey()
+	restored := []GenerationPackage{}
+	toRemove, toInstall := types.Packages{}, types.Packages{}
+	for _, p := range diff.Removed {
+		toRemove = append(toRemove, p.Package())
+	}
+	for _, p := range diff.Added {
+		toInstall = append(toInstall, install(p))
+		restored = append(restored, p)
+	}
+	for _, c := range diff.Changed {
+		p := packages[c.Category+"/"+c.Name]
+		toRemove = append(toRemove, GenerationPackage{Category: c.Category, Name: c.Name, Version: c.From}.Package())
+		toInstall = append(toInstall, install(p))
+		restored = append(restored, p)
+	}
+
+	// Packages get back the install reason they had in the generation
+	o := restoreReasons(restored)
+	o.Repositories = repositories
+
+	l.Options.Context.Info(fmt.Sprintf(":back: Rolling back to generation %d (%s)", g.ID, g.Timestamp.Local().Format(time.RFC1123)))
+	return l.swapPackages(o, toRemove, toInstall, s)
+}
+
 func (l *BhojpurInstaller) computeSwap(o Option, syncedRepos Repositories, toRemove types.Packages, toInstall types.Packages, s *System) (map[string]ArtifactMatch, types.Packages, types.PackagesAssertions, types.PackageDatabase, error) {
 
 	allRepos := pkg.NewInMemoryDatabase(false)
 	syncedRepos.SyncDatabase(allRepos)
 
+	pins, err := s.PinnedPackages()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	toInstall, err = applyPins(pins, toInstall)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
 	toInstall = syncedRepos.ResolveSelectors(toInstall)
 
 	// First check what would have been done
@@ -217,7 +338,8 @@ func (l *BhojpurInstaller) computeSwap(o Option, syncedRepos Repositories, toRem
 		return nil, nil, nil, nil, errors.Wrap(err, "Failed create temporary in-memory db")
 	}
 
-	systemAfterChanges := &System{Database: installedtmp}
+	// Keep the pins so that the solver honours them for the swapped packages
+	systemAfterChanges := &System{Database: installedtmp, PinsDir: s.PinsDir}
 
 	packs, err := l.computeUninstall(o, systemAfterChanges, toRemove...)
 	if err != nil && !o.Force {
@@ -245,6 +367,14 @@ func (l *BhojpurInstaller) swap(o Option, syncedRepos Repositories, toRemove typ
 		return errors.Wrap(err, "failed computing package replacement")
 	}
 
+	if l.Options.Pretend {
+		toFinalize, err := l.getFinalizers(allRepos, assertions, match, o.NoDeps)
+		if err != nil {
+			return errors.Wrap(err, "failed getting package to finalize")
+		}
+		return l.addToPlan(match, toRemove, toFinalize)
+	}
+
 	if l.Options.Ask {
 		// if len(toRemove) > 0 {
 		// 	l.Options.Context.Info(":recycle: Packages that are going to be removed from the system:\n ", Yellow(packsToList(toRemove)).BgBlack().String())
@@ -283,19 +413,31 @@ func (l *BhojpurInstaller) swap(o Option, syncedRepos Repositories, toRemove typ
 		return nil
 	}
 
+	// Replacing packages keep the install reason of the replaced ones, unless given
+	reasons := installReasons(toRemove, s)
+	for name, reason := range o.Reasons {
+		reasons[name] = reason
+	}
+
 	ops, err := l.generateRunOps(toRemove, match, Option{
 		Force:              o.Force,
 		NoDeps:             false,
 		OnlyDeps:           o.OnlyDeps,
 		RunFinalizers:      false,
 		CheckFileConflicts: false,
+		Explicit:           o.Explicit,
+		Reasons:            reasons,
 	}, o, syncedRepos, packages, assertions, allRepos, s)
 	if err != nil {
 		return errors.Wrap(err, "failed computing installer options")
 	}
 
+	var finalizerErrs error
 	err = l.runOps(ops, s)
-	if err != nil {
+	var ferr *finalizerError
+	if errors.As(err, &ferr) {
+		finalizerErrs = ferr.error
+	} else if err != nil {
 		return errors.Wrap(err, "failed running installer options")
 	}
 
@@ -304,7 +446,13 @@ func (l *BhojpurInstaller) swap(o Option, syncedRepos Repositories, toRemove typ
 		return errors.Wrap(err, "failed getting package to finalize")
 	}
 
-	return s.ExecuteFinalizers(l.Options.Context, toFinalize)
+	if err := s.ExecuteFinalizers(l.finalizerContext(), toFinalize); err != nil {
+		finalizerErrs = multierror.Append(finalizerErrs, err)
+	}
+	if finalizerErrs != nil {
+		return &finalizerError{finalizerErrs}
+	}
+	return nil
 }
 
 type Option struct {
@@ -317,6 +465,15 @@ type Option struct {
 	RunFinalizers      bool
 
 	CheckFileConflicts bool
+
+	// Explicit are the packages requested by the user, the other packages
+	// are recorded in the system as installed dependencies
+	Explicit types.Packages
+	// Reasons keeps the install reason of the packages being replaced, by package name
+	Reasons map[string]string
+	// Repositories are the repositories to install packages from, by package fingerprint,
+	// when they still provide them. The other packages are matched by repository priority.
+	Repositories map[string]string
 }
 
 type operation struct {
@@ -345,11 +502,12 @@ func (l *BhojpurInstaller) runOps(ops []installerOp, s *System) error {
 
 	wg := new(sync.WaitGroup)
 	systemLock := &sync.Mutex{}
+	errs := &opErrors{}
 
 	// Do the real install
 	for i := 0; i < l.Options.Concurrency; i++ {
 		wg.Add(1)
-		go l.installerOpWorker(i, wg, systemLock, all, s)
+		go l.installerOpWorker(i, wg, systemLock, all, s, errs)
 	}
 
 	for _, c := range ops {
@@ -358,12 +516,34 @@ func (l *BhojpurInstaller) runOps(ops []installerOp, s *System) error {
 	close(all)
 	wg.Wait()
 
-	return nil
+	if errs.err == nil && errs.finalizers != nil {
+		return &finalizerError{errs.finalizers}
+	}
+	return errs.err
+}
+
+// opErrors collects the failures of the installer workers
+type opErrors struct {
+	sync.Mutex
+	err error
+	// finalizers collects the failures of the finalizers, which don't abort the operations
+	finalizers error
+}
+
+func (e *opErrors) append(err error) {
+	e.Lock()
+	defer e.Unlock()
+	var ferr *finalizerError
+	if errors.As(err, &ferr) {
+		e.finalizers = multierror.Append(e.finalizers, ferr.error)
+		return
+	}
+	e.err = multierror.Append(e.err, err)
 }
 
 // TODO: use installerOpWorker in place of all the other workers.
 // This one is general enough to read a list of operations and execute them.
-func (l *BhojpurInstaller) installerOpWorker(i int, wg *sync.WaitGroup, systemLock *sync.Mutex, c <-chan installerOp, s *System) error {
+func (l *BhojpurInstaller) installerOpWorker(i int, wg *sync.WaitGroup, systemLock *sync.Mutex, c <-chan installerOp, s *System, errs *opErrors) {
 	defer wg.Done()
 
 	for p := range c {
@@ -399,8 +579,14 @@ func (l *BhojpurInstaller) installerOpWorker(i int, wg *sync.WaitGroup, systemLo
 			err = uninstall()
 			systemLock.Unlock()
 
-			if err != nil {
+			var ferr *finalizerError
+			if errors.As(err, &ferr) {
+				errs.append(err)
+			} else if err != nil {
 				l.Options.Context.Error("Failed uninstall for ", packsToList(toUninstall))
+				if !l.Options.Force {
+					errs.append(errors.Wrap(err, "failed uninstall for "+packsToList(toUninstall)))
+				}
 				continue
 			}
 		}
@@ -422,11 +608,12 @@ func (l *BhojpurInstaller) installerOpWorker(i int, wg *sync.WaitGroup, systemLo
 			systemLock.Unlock()
 			if err != nil {
 				l.Options.Context.Error(err)
+				if !l.Options.Force {
+					errs.append(err)
+				}
 			}
 		}
 	}
-
-	return nil
 }
 
 // checks wheter we can uninstall and install in place and compose installer worker ops
@@ -469,7 +656,7 @@ func (l *BhojpurInstaller) checkAndUpgrade(r Repositories, s *System) error {
 	if len(toInstall) == 0 && len(uninstall) == 0 {
 		l.Options.Context.Info("Nothing to upgrade")
 		return nil
-	} else {
+	} else if !l.Options.Pretend {
 		l.Options.Context.Info(":zap: Proposed version changes to the system:\n ")
 		printUpgradeList(toInstall, uninstall)
 	}
@@ -490,6 +677,10 @@ func (l *BhojpurInstaller) checkAndUpgrade(r Repositories, s *System) error {
 		OnlyDeps:           false,
 	}
 
+	if l.Options.Pretend {
+		return l.swap(o, r, uninstall, toInstall, s)
+	}
+
 	if l.Options.Ask {
 		l.Options.Context.Info("By going forward, you are also accepting the licenses of the packages that you are going to install in your system.")
 		if l.Options.Context.Ask() {
@@ -530,8 +721,14 @@ func (l *BhojpurInstaller) checkAndUpgrade(r Repositories, s *System) error {
 	return err
 }
 
-func (l *BhojpurInstaller) Install(cp types.Packages, s *System) error {
+func (l *BhojpurInstaller) Install(cp types.Packages, s *System) (err error) {
 	l.Options.Context.Screen("Install")
+	commit, err := l.beginTransaction(s, "install")
+	if err != nil {
+		return err
+	}
+	defer func() { err = commit(err) }()
+
 	syncedRepos, err := l.SyncRepositories()
 	if err != nil {
 		return err
@@ -550,14 +747,24 @@ func (l *BhojpurInstaller) Install(cp types.Packages, s *System) error {
 		OnlyDeps:           l.Options.OnlyDeps,
 		CheckFileConflicts: true,
 		RunFinalizers:      true,
+		Explicit:           cp,
 	}
 	match, packages, assertions, allRepos, err := l.computeInstall(o, syncedRepos, cp, s)
 	if err != nil {
 		return err
 	}
 
+	// Packages already installed as dependencies are now wanted by the user,
+	// they are marked once the operation is confirmed
+	markExplicit := func() {
+		if !l.Options.OnlyDeps && !l.Options.Pretend {
+			l.markExplicit(cp, s)
+		}
+	}
+
 	// Check if we have to process something, or return to the user an error
 	if len(match) == 0 {
+		markExplicit()
 		l.Options.Context.Info("No packages to install")
 		return nil
 	}
@@ -587,6 +794,15 @@ func (l *BhojpurInstaller) Install(cp types.Packages, s *System) error {
 			}
 		}
 	}
+
+	if l.Options.Pretend {
+		toFinalize, err := l.getFinalizers(allRepos, assertions, match, o.NoDeps)
+		if err != nil {
+			return errors.Wrap(err, "failed getting package to finalize")
+		}
+		return l.addToPlan(match, nil, toFinalize)
+	}
+
 	l.Options.Context.Info("Packages that are going to be installed in the system:")
 	//l.Options.Context.Info("Packages that are going to be installed in the system: \n ", Green(matchesToList(match)).BgBlack().String())
 
@@ -594,13 +810,12 @@ func (l *BhojpurInstaller) Install(cp types.Packages, s *System) error {
 
 	if l.Options.Ask {
 		l.Options.Context.Info("By going forward, you are also accepting the licenses of the packages that you are going to install in your system.")
-		if l.Options.Context.Ask() {
-			l.Options.Ask = false // Don't prompt anymore
-			return l.install(o, syncedRepos, match, packages, assertions, allRepos, s)
-		} else {
+		if !l.Options.Context.Ask() {
 			return errors.New("Aborted by user")
 		}
+		l.Options.Ask = false // Don't prompt anymore
 	}
+	markExplicit()
 	return l.install(o, syncedRepos, match, packages, assertions, allRepos, s)
 }
 
@@ -626,26 +841,13 @@ func (l *BhojpurInstaller) download(syncedRepos Repositories, toDownload map[str
 
 	var wg = new(sync.WaitGroup)
 
-	ctx := l.Options.Context.Copy()
-
-	// Check if the terminal is big enough to display a progress bar
-	// https://github.com/pterm/pterm/blob/4c725e56bfd9eb38e1c7b9dec187b50b93baa8bd/progressbar_printer.go#L190
-	w, _, err := logger.GetTerminalSize()
-
-	var pb *pterm.ProgressbarPrinter
-	if logger.IsTerminal() && err == nil && w > 100 {
-		area, _ := pterm.DefaultArea.Start()
-		pb = pterm.DefaultProgressbar.WithPrintTogether(area).WithTotal(len(toDownload)).WithTitle("Downloading packages")
-		pb, _ = pb.Start()
-		ctx.SetAnnotation("progressbar", pb)
-
-		defer area.Stop()
-	}
+	l.observer().DownloadStarted(len(toDownload))
+	defer l.observer().DownloadFinished()
 
 	// Download
 	for i := 0; i < l.Options.Concurrency; i++ {
 		wg.Add(1)
-		go l.downloadWorker(i, wg, pb, all, ctx)
+		go l.downloadWorker(i, wg, all, l.Options.Context)
 	}
 	for _, c := range toDownload {
 		all <- c
@@ -658,7 +860,13 @@ func (l *BhojpurInstaller) download(syncedRepos Repositories, toDownload map[str
 // Reclaim adds packages to the system database
 // if files from artifacts in the repositories are found
 // in the system target
-func (l *BhojpurInstaller) Reclaim(s *System) error {
+func (l *BhojpurInstaller) Reclaim(s *System) (err error) {
+	commit, err := l.beginTransaction(s, "reclaim")
+	if err != nil {
+		return err
+	}
+	defer func() { err = commit(err) }()
+
 	syncedRepos, err := l.SyncRepositories()
 	if err != nil {
 		return err
@@ -694,11 +902,17 @@ func (l *BhojpurInstaller) Reclaim(s *System) error {
 			l.Options.Context.Warning("Filtering out package " + pack.HumanReadableString() + ", already reclaimed")
 			continue
 		}
+		if err := s.journalPackage(journalPackageAdded, pack, nil); err != nil {
+			return err
+		}
 		_, err := s.Database.CreatePackage(pack)
 		if err != nil && !l.Options.Force {
 			return errors.Wrap(err, "Failed creating package")
 		}
 		s.Database.SetPackageFiles(&types.PackageFile{PackageFingerprint: pack.GetFingerPrint(), Files: match.Artifact.Files})
+		if err := s.storeFinalizer(pack); err != nil {
+			l.Options.Context.Warning("Failed storing finalizer for ", pack.HumanReadableString(), err.Error())
+		}
 		l.Options.Context.Info(":zap:Reclaimed package:", pack.HumanReadableString())
 	}
 	l.Options.Context.Info("Done!")
@@ -733,25 +947,36 @@ func (l *BhojpurInstaller) computeInstall(o Option, syncedRepos Repositories, cp
 	// First match packages against repositories by priority
 	//	matches := syncedRepos.PackageMatches(p)
 
+	pins, err := s.PinnedPackages()
+	if err != nil {
+		return toInstall, p, solution, allRepos, err
+	}
+	p, err = applyPins(pins, p)
+	if err != nil {
+		return toInstall, p, solution, allRepos, err
+	}
+
 	// compute a "big" world
 	syncedRepos.SyncDatabase(allRepos)
 	p = syncedRepos.ResolveSelectors(p)
 	var packagesToInstall types.Packages
-	var err error
 
 	if !o.NoDeps {
 		solv := solver.NewResolver(types.SolverOptions{
 			Type:        l.Options.SolverOptions.Implementation,
-			Concurrency: l.Options.Concurrency},
+			Concurrency: l.Options.Concurrency,
+			Pins:        pins},
 			s.Database, allRepos, pkg.NewInMemoryDatabase(false),
 			solver.NewSolverFromOptions(l.Options.SolverOptions),
 		)
 
+		l.observer().SolveStarted(p)
 		if l.Options.Relaxed {
 			solution, err = solv.RelaxedInstall(p)
 		} else {
 			solution, err = solv.Install(p)
 		}
+		l.observer().SolveFinished(solution, err)
 		/// TODO: PackageAssertions needs to be a map[fingerprint]pack so lookup is in O(1)
 		if err != nil && !o.Force {
 			return toInstall, p, solution, allRepos, errors.Wrap(err, "Failed solving solution for package")
@@ -780,6 +1005,11 @@ func (l *BhojpurInstaller) computeInstall(o Option, syncedRepos Repositories, cp
 		// Check if package is already installed.
 
 		matches := syncedRepos.PackageMatches(types.Packages{currentPack})
+		if name, ok := o.Repositories[currentPack.GetFingerPrint()]; ok {
+			if m := syncedRepos.packageMatchIn(name, currentPack); m != nil {
+				matches = []PackageMatch{*m}
+			}
+		}
 		if len(matches) == 0 {
 			return toInstall, p, solution, allRepos, errors.New("Failed matching solutions against repository for " + currentPack.HumanReadableString() + " where are definitions coming from?!")
 		}
@@ -912,11 +1142,12 @@ func (l *BhojpurInstaller) install(o Option, syncedRepos Repositories, toInstall
 
 	wg := new(sync.WaitGroup)
 	installLock := &sync.Mutex{}
+	errs := &opErrors{}
 
 	// Do the real install
 	for i := 0; i < l.Options.Concurrency; i++ {
 		wg.Add(1)
-		go l.installerWorker(i, wg, installLock, all, s)
+		go l.installerWorker(i, wg, installLock, all, s, errs)
 	}
 
 	for _, c := range toInstall {
@@ -925,12 +1156,19 @@ func (l *BhojpurInstaller) install(o Option, syncedRepos Repositories, toInstall
 	close(all)
 	wg.Wait()
 
+	if errs.err != nil {
+		return errs.err
+	}
+
 	for _, c := range toInstall {
 		// Annotate to the system that the package was installed
-		_, err := s.Database.CreatePackage(c.Package)
+		pack := c.Package.Clone()
+		pack.SetInstallReason(installReason(o, c.Package))
+		_, err := s.Database.CreatePackage(pack)
 		if err != nil && !o.Force {
 			return errors.Wrap(err, "Failed creating package")
 		}
+		l.storeFinalizer(c, s)
 		bus.Manager.Publish(bus.EventPackageInstall, c)
 	}
 
@@ -943,10 +1181,93 @@ func (l *BhojpurInstaller) install(o Option, syncedRepos Repositories, toInstall
 		return errors.Wrap(err, "failed getting package to finalize")
 	}
 
-	return s.ExecuteFinalizers(l.Options.Context, toFinalize)
+	return l.executeFinalizers(s, toFinalize)
+}
+
+// installReason tells why a package is installed: packages replacing an installed
+// one keep its reason, the others are explicit only if requested by the user
+func installReason(o Option, p *types.Package) string {
+	if r, ok := o.Reasons[p.GetPackageName()]; ok {
+		return r
+	}
+	for _, e := range o.Explicit {
+		if e.GetPackageName() == p.GetPackageName() {
+			return types.InstallReasonExplicit
+		}
+		for _, provide := range p.GetProvides() {
+			if e.GetPackageName() == provide.GetPackageName() {
+				return types.InstallReasonExplicit
+			}
+		}
+	}
+	return types.InstallReasonDependency
+}
+
+// installReasons returns the install reasons of the given installed packages, by package name
+func installReasons(packs types.Packages, s *System) map[string]string {
+	reasons := map[string]string{}
+	for _, p := range packs {
+		installed, err := s.Database.FindPackage(p)
+		if err != nil {
+			continue
+		}
+		reasons[p.GetPackageName()] = installed.GetInstallReason()
+	}
+	return reasons
+}
+
+// markExplicit records as explicit the given packages, if already installed as dependencies
+func (l *BhojpurInstaller) markExplicit(packs types.Packages, s *System) {
+	for _, p := range packs {
+		installed, err := s.Database.FindPackageVersions(p)
+		if err != nil {
+			continue
+		}
+		for _, i := range installed {
+			if i.IsExplicit() {
+				continue
+			}
+			if err := s.SetInstallReason(i, types.InstallReasonExplicit); err != nil {
+				l.Options.Context.Warning("Failed marking", i.HumanReadableString(), "as explicitly installed:", err.Error())
+			}
+		}
+	}
+}
+
+// storeFinalizer records in the system database the finalizer of an installed package
+func (l *BhojpurInstaller) storeFinalizer(m ArtifactMatch, s *System) {
+	if m.Repository == nil {
+		return
+	}
+	treePackage, err := m.Repository.GetTree().GetDatabase().FindPackage(m.Package)
+	if err != nil {
+		l.Options.Context.Debug("Package", m.Package.HumanReadableString(), "not found in the repository tree, not storing its finalizer")
+		return
+	}
+	if err := s.storeFinalizer(treePackage); err != nil {
+		l.Options.Context.Warning("Failed storing finalizer for ", m.Package.HumanReadableString(), err.Error())
+	}
 }
 
 func (l *BhojpurInstaller) getPackage(a ArtifactMatch, ctx types.Context) (artifact *artifact.PackageArtifact, err error) {
+	// Artifacts signatures are optional, as the index holding the checksums
+	// is already covered by the repository signature.
+	// They are checked here rather than by the clients, as the keyring belongs
+	// to the repository (its trusted keys and the ones added to its keys folder)
+	// while clients only fetch files. Signatures cover the index metadata, so
+	// they are checked before downloading anything.
+	if len(a.Artifact.Signatures) > 0 {
+		keyring, err := a.Repository.Keyring(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(keyring) > 0 {
+			if err := a.Artifact.VerifySignatures(keyring); err != nil {
+				return nil, errors.Wrap(err, "Artifact signature check failure")
+			}
+		}
+	}
+
 	cli := a.Repository.Client(ctx)
 
 	artifact, err = cli.DownloadArtifact(a.Artifact)
@@ -968,42 +1289,57 @@ func (l *BhojpurInstaller) installPackage(m ArtifactMatch, s *System) error {
 		return errors.Wrap(err, "Failed downloading package")
 	}
 
-	files, err := a.FileList()
+	metadata, err := a.FileMetadata()
 	if err != nil && !l.Options.Force {
 		return errors.Wrap(err, "Could not open package archive")
 	}
+	files := []string{}
+	for _, f := range metadata {
+		files = append(files, f.Path)
+	}
+
+	if err := s.journalPackage(journalPackageAdded, m.Package, nil); err != nil {
+		return err
+	}
+	if m.Repository != nil {
+		s.journalRepository(m.Package, m.Repository.GetName())
+	}
+	if err := s.journalInstallFiles(files); err != nil {
+		return errors.Wrap(err, "while journaling package files")
+	}
 
+	l.observer().UnpackStarted(m.Package)
 	err = a.Unpack(l.Options.Context, s.Target, true)
+	l.observer().UnpackFinished(m.Package, err)
 	if err != nil && !l.Options.Force {
 		return errors.Wrap(err, "error met while unpacking package "+a.Path)
 	}
+	l.storeProtectedFiles(l.configProtectForPackage(m.Package, s, files), metadata, s)
 
 	// First create client and download
 	// Then unpack to system
-	return s.Database.SetPackageFiles(&types.PackageFile{PackageFingerprint: m.Package.GetFingerPrint(), Files: files})
+	return s.Database.SetPackageFiles(&types.PackageFile{PackageFingerprint: m.Package.GetFingerPrint(), Files: files, Metadata: metadata})
 }
 
-func (l *BhojpurInstaller) downloadWorker(i int, wg *sync.WaitGroup, pb *pterm.ProgressbarPrinter, c <-chan ArtifactMatch, ctx types.Context) error {
+func (l *BhojpurInstaller) downloadWorker(i int, wg *sync.WaitGroup, c <-chan ArtifactMatch, ctx types.Context) error {
 	defer wg.Done()
 
 	for p := range c {
 		// TODO: Keep trace of what was added from the tar, and save it into system
-		_, err := l.getPackage(p, ctx)
+		_, err := l.getPackage(p, l.downloadContext(ctx, p.Package))
+		l.observer().Downloaded(p.Package, err)
 		if err != nil {
 			l.Options.Context.Error("Failed downloading package "+p.Package.GetName(), err.Error())
 			return errors.Wrap(err, "Failed downloading package "+p.Package.GetName())
 		} else {
 			l.Options.Context.Success(":package: Package ", p.Package.HumanReadableString(), "downloaded")
 		}
-		if pb != nil {
-			pb.Increment()
-		}
 	}
 
 	return nil
 }
 
-func (l *BhojpurInstaller) installerWorker(i int, wg *sync.WaitGroup, installLock *sync.Mutex, c <-chan ArtifactMatch, s *System) error {
+func (l *BhojpurInstaller) installerWorker(i int, wg *sync.WaitGroup, installLock *sync.Mutex, c <-chan ArtifactMatch, s *System, errs *opErrors) {
 	defer wg.Done()
 
 	for p := range c {
@@ -1012,9 +1348,9 @@ func (l *BhojpurInstaller) installerWorker(i int, wg *sync.WaitGroup, installLoc
 		err := l.installPackage(p, s)
 		installLock.Unlock()
 		if err != nil && !l.Options.Force {
-			//TODO: Uninstall, rollback.
 			l.Options.Context.Error("Failed installing package "+p.Package.GetName(), err.Error())
-			return errors.Wrap(err, "Failed installing package "+p.Package.GetName())
+			errs.append(errors.Wrap(err, "Failed installing package "+p.Package.GetName()))
+			continue
 		}
 		if err == nil {
 			l.Options.Context.Info(":package: Package ", p.Package.HumanReadableString(), "installed")
@@ -1022,8 +1358,6 @@ func (l *BhojpurInstaller) installerWorker(i int, wg *sync.WaitGroup, installLoc
 			l.Options.Context.Info(":package: Package ", p.Package.HumanReadableString(), "installed with failures (forced install)")
 		}
 	}
-
-	return nil
 }
 
 func checkAndPrunePath(ctx types.Context, target, path string) {
@@ -1116,6 +1450,11 @@ func (l *BhojpurInstaller) pruneFile(f string, s *System, cp *config.ConfigProte
 		}
 	}
 
+	if err := s.journalRemoveFile(f); err != nil {
+		l.Options.Context.Warning("Preserving", target, "as it could not be saved in the transaction journal:", err.Error())
+		return
+	}
+
 	if err = os.Remove(target); err != nil {
 		l.Options.Context.Debug("Failed removing file (maybe not present in the system target anymore ?)", target, err.Error())
 	} else {
@@ -1168,10 +1507,17 @@ func (l *BhojpurInstaller) uninstall(p *types.Package, s *System) error {
 }
 
 func (l *BhojpurInstaller) removePackage(p *types.Package, s *System) error {
+	files, _ := s.Database.GetPackageFiles(p)
+	if err := s.journalPackage(journalPackageRemoved, p, files); err != nil {
+		return err
+	}
+
 	err := s.Database.RemovePackageFiles(p)
 	if err != nil {
 		return errors.Wrap(err, "Failed removing package files from database")
 	}
+	// Packages installed without a finalizer have nothing to remove
+	s.Database.RemovePackageFinalizer(p)
 	err = s.Database.RemovePackage(p)
 	if err != nil {
 		return errors.Wrap(err, "Failed removing package from database")
@@ -1213,13 +1559,16 @@ func (l *BhojpurInstaller) computeUninstall(o Option, s *System, packs ...*types
 			solver.NewSolverFromOptions(l.Options.SolverOptions))
 		var solution types.Packages
 		var err error
+		l.observer().SolveStarted(packs)
 		if o.FullCleanUninstall {
 			solution, err = solv.UninstallUniverse(packs)
+			l.observer().SolveFinished(assertRemoved(solution), err)
 			if err != nil {
 				return toUninstall, errors.Wrap(err, "Could not solve the uninstall constraints. Tip: try with --solver-type qlearning or with --force, or by removing packages excluding their dependencies with --nodeps")
 			}
 		} else {
 			solution, err = solv.Uninstall(checkConflicts, full, packs...)
+			l.observer().SolveFinished(assertRemoved(solution), err)
 			if err != nil && !l.Options.Force {
 				return toUninstall, errors.Wrap(err, "Could not solve the uninstall constraints. Tip: try with --solver-type qlearning or with --force, or by removing packages excluding their dependencies with --nodeps")
 			}
@@ -1246,6 +1595,10 @@ func (l *BhojpurInstaller) generateUninstallFn(o Option, s *System, filesToInsta
 	}
 
 	uninstall := func() error {
+		// Uninstall finalizers run while the package files are still in place.
+		// As for install finalizers, their failures don't stop the removal.
+		finalizerErrs := s.ExecuteUninstallFinalizers(l.finalizerContext(), toUninstall)
+
 		for _, p := range toUninstall {
 			if len(filesToInstall) == 0 {
 				err := l.uninstall(p, s)
@@ -1277,14 +1630,22 @@ func (l *BhojpurInstaller) generateUninstallFn(o Option, s *System, filesToInsta
 
 			}
 		}
+		if finalizerErrs != nil {
+			return &finalizerError{finalizerErrs}
+		}
 		return nil
 	}
 
 	return toUninstall, uninstall, nil
 }
 
-func (l *BhojpurInstaller) Uninstall(s *System, packs ...*types.Package) error {
+func (l *BhojpurInstaller) Uninstall(s *System, packs ...*types.Package) (err error) {
 	l.Options.Context.Screen("Uninstall")
+	commit, err := l.beginTransaction(s, "uninstall")
+	if err != nil {
+		return err
+	}
+	defer func() { err = commit(err) }()
 
 	l.Options.Context.Spinner()
 	o := Option{
@@ -1304,6 +1665,10 @@ func (l *BhojpurInstaller) Uninstall(s *System, packs ...*types.Package) error {
 		return nil
 	}
 
+	if l.Options.Pretend {
+		return l.addToPlan(nil, toUninstall, nil)
+	}
+
 	if l.Options.Ask {
 		l.Options.Context.Info(":recycle: Packages that are going to be removed from the system:")
 		printList(toUninstall)
@@ -1316,3 +1681,96 @@ func (l *BhojpurInstaller) Uninstall(s *System, packs ...*types.Package) error {
 	}
 	return uninstall()
 }
+
+// finalizerError is returned when packages were installed but their finalizers failed.
+// It does not revert the transaction, as the files are in place already.
+type finalizerError struct {
+	error
+}
+
+func (e *finalizerError) Unwrap() error {
+	return e.error
+}
+
+func (l *BhojpurInstaller) executeFinalizers(s *System, packs []*types.Package) error {
+	if err := s.ExecuteFinalizers(l.finalizerContext(), packs); err != nil {
+		return &finalizerError{err}
+	}
+	return nil
+}
+
+// beginTransaction starts an operation on the system, journaled if the system has a
+// journal. The returned function has to be called with the outcome of the operation:
+// it commits the transaction on success, and rolls it back otherwise. Nested calls join
+// the running operation. Once the outer operation is committed the packages cache is
+// pruned and the triggers fired by the files touched are run, whether the system has a
+// journal or not. The operation is recorded in the system history.
+func (l *BhojpurInstaller) beginTransaction(s *System, operation string) (func(error) error, error) {
+	if l.Options.Pretend {
+		// Nothing is applied while pretending, the plan of the outer operation is extended
+		if l.plan == nil {
+			l.plan = newPlan(operation)
+		}
+		return func(err error) error { return err }, nil
+	}
+
+	outer := s.beginOperation()
+	started, err := s.beginTransaction()
+	if err != nil {
+		if outer {
+			s.endOperation()
+		}
+		return nil, errors.Wrap(err, "while starting transaction")
+	}
+
+	record := s.beginHistory(operation)
+	history := func(err error) error {
+		if herr := s.recordHistory(record, err); herr != nil {
+			l.Options.Context.Warning("Failed recording the transaction history:", herr.Error())
+		}
+		return err
+	}
+	if !outer {
+		return history, nil
+	}
+	done := func(err error) error {
+		err = history(err)
+		l.observer().TransactionCommitted(operation, err)
+		return err
+	}
+
+	return func(err error) error {
+		touched := s.endOperation()
+		var ferr *finalizerError
+		if err == nil || errors.As(err, &ferr) {
+			// The history is recorded with the transaction, before its journal is dropped
+			if herr := s.recordHistory(record, err); herr != nil {
+				l.Options.Context.Warning("Failed recording the transaction history:", herr.Error())
+			}
+			if cerr := s.commitTransaction(); cerr != nil {
+				return done(multierror.Append(err, errors.Wrap(cerr, "while committing transaction")))
+			}
+			if _, perr := PruneCache(l.Options.Context); perr != nil {
+				l.Options.Context.Warning("Failed pruning the packages cache:", perr.Error())
+			}
+			// Triggers run once the files of all the packages are in place.
+			// Like finalizers, their failures don't revert the transaction.
+			if terr := s.ExecuteTriggers(l.Options.Context, touched); terr != nil {
+				if ferr != nil {
+					terr = multierror.Append(ferr.error, terr)
+				}
+				err = &finalizerError{terr}
+			}
+			return done(err)
+		}
+
+		if !started {
+			return done(err)
+		}
+		l.Options.Context.Warning("Operation failed, rolling back the changes:", err.Error())
+		if rerr := s.rollbackTransaction(l.Options.Context); rerr != nil {
+			return done(multierror.Append(err, errors.Wrap(rerr, "while rolling back transaction")))
+		}
+		return done(err)
+	}, nil
+}
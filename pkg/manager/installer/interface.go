@@ -23,6 +23,7 @@ package installer
 import (
 	"github.com/bhojpur/iso/pkg/manager/api/core/types"
 	artifact "github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
+	"github.com/bhojpur/iso/pkg/manager/helpers/sign"
 	"github.com/bhojpur/iso/pkg/manager/tree"
 	//"github.com/bhojpur/iso/pkg/manager/solver"
 )
@@ -39,4 +40,5 @@ type Repository interface {
 	GetTree() tree.Builder
 	Client(types.Context) Client
 	GetName() string
+	Keyring(types.Context) (sign.Keyring, error)
 }
@@ -0,0 +1,156 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	"github.com/bhojpur/iso/pkg/manager/helpers/sign"
+
+	"github.com/pkg/errors"
+)
+
+// TrustedKey is a public key trusted to sign a repository
+type TrustedKey struct {
+	Repository string `json:"repository" yaml:"repository"`
+	ID         string `json:"id" yaml:"id"`
+	Key        string `json:"key" yaml:"key"`
+	// Configured is set for the keys listed in the repository configuration,
+	// which can't be removed with RemoveTrustedKey
+	Configured bool `json:"configured" yaml:"configured"`
+}
+
+// Keyring returns the public keys trusted to sign the repository: the ones of its
+// configuration and the ones added with AddTrustedKey. An empty keyring disables
+// the signature checks of the repository.
+func (r *BhojpurSystemRepository) Keyring(ctx types.Context) (sign.Keyring, error) {
+	k, err := sign.NewKeyring(r.TrustedKeys...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid trusted keys for repository %s", r.GetName())
+	}
+	added, err := sign.LoadKeyring(ctx.GetConfig().System.GetRepoKeysDirPath(r.GetName()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "while reading the trusted keys of repository %s", r.GetName())
+	}
+	k.Merge(added)
+	return k, nil
+}
+
+// ListTrustedKeys returns the public keys trusted to sign the repository
+func (r *BhojpurSystemRepository) ListTrustedKeys(ctx types.Context) ([]TrustedKey, error) {
+	configured, err := sign.NewKeyring(r.TrustedKeys...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid trusted keys for repository %s", r.GetName())
+	}
+	k, err := r.Keyring(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []TrustedKey{}
+	for _, id := range k.IDs() {
+		_, isConfigured := configured[id]
+		res = append(res, TrustedKey{
+			Repository: r.GetName(),
+			ID:         id,
+			Key:        sign.EncodePublicKey(k[id]),
+			Configured: isConfigured,
+		})
+	}
+	return res, nil
+}
+
+// AddTrustedKey trusts the public key to sign the repository with the given name.
+// It returns the key ID.
+func AddTrustedKey(ctx types.Context, repository string, pub ed25519.PublicKey) (string, error) {
+	dir := ctx.GetConfig().System.GetRepoKeysDirPath(repository)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", errors.Wrap(err, "while creating the keys folder")
+	}
+	id := sign.KeyID(pub)
+	return id, sign.WritePublicKey(filepath.Join(dir, id+".pub"), pub)
+}
+
+// RemoveTrustedKey revokes the trust in the key with the given ID, added with AddTrustedKey
+func RemoveTrustedKey(ctx types.Context, repository, id string) error {
+	file := filepath.Join(ctx.GetConfig().System.GetRepoKeysDirPath(repository), id+".pub")
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return fmt.Errorf("key %s is not trusted by repository %s", id, repository)
+	}
+	return os.Remove(file)
+}
+
+// verifyRepositoryFile checks the repository file against its signature file
+func verifyRepositoryFile(k sign.Keyring, file, signatureFile string) error {
+	if err := k.VerifyFile(file, signatureFile); err != nil {
+		return errors.Wrapf(err, "signature check of %s failed", filepath.Base(file))
+	}
+	return nil
+}
+
+// signIndex signs the artifacts of the repository index with the signing keys,
+// when artifacts signing is enabled
+func (r *BhojpurSystemRepository) signIndex() error {
+	if !r.SignArtifacts || len(r.signingKeys) == 0 {
+		return nil
+	}
+	for _, a := range r.Index {
+		if err := a.Sign(r.signingKeys...); err != nil {
+			return errors.Wrapf(err, "while signing %s", filepath.Base(a.Path))
+		}
+	}
+	return nil
+}
+
+// signFile writes the signatures of a repository file with the signing keys, if any.
+// It returns the path of the signature file, or an empty string if the repository isn't signed.
+func (r *BhojpurSystemRepository) signFile(file string) (string, error) {
+	if len(r.signingKeys) == 0 {
+		return "", nil
+	}
+	if err := sign.SignFile(file, r.signingKeys...); err != nil {
+		return "", errors.Wrapf(err, "while signing %s", filepath.Base(file))
+	}
+	return file + sign.SignatureSuffix, nil
+}
+
+// publishSignedFile signs the repository file staged at staged and moves it to file.
+// The signature is moved in place first, so clients never read the new file with
+// the signature of the previous one. A signature left by a previous signed revision
+// is removed if the repository isn't signed anymore.
+func (r *BhojpurSystemRepository) publishSignedFile(staged, file string) error {
+	signature, err := r.signFile(staged)
+	if err != nil {
+		return err
+	}
+	if signature != "" {
+		if err := os.Rename(signature, file+sign.SignatureSuffix); err != nil {
+			return errors.Wrapf(err, "while publishing the signature of %s", filepath.Base(file))
+		}
+	} else if err := os.RemoveAll(file + sign.SignatureSuffix); err != nil {
+		return err
+	}
+	return errors.Wrapf(os.Rename(staged, file), "while publishing %s", filepath.Base(file))
+}
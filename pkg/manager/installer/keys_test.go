@@ -0,0 +1,192 @@
+package installer_test
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
+	compilerspec "github.com/bhojpur/iso/pkg/manager/compiler/types/spec"
+	pkg "github.com/bhojpur/iso/pkg/manager/database"
+	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
+	"github.com/bhojpur/iso/pkg/manager/helpers/sign"
+	. "github.com/bhojpur/iso/pkg/manager/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// writeTestRepository writes in repoDir a repository holding a test/app package,
+// a disk one unless the type is changed with the options
+func writeTestRepository(ctx *context.Context, dir, repoDir string, opts ...RepositoryOption) {
+	content := filepath.Join(dir, "content")
+	Expect(os.MkdirAll(content, os.ModePerm)).To(Succeed())
+	Expect(ioutil.WriteFile(filepath.Join(content, "app"), []byte("app"), 0644)).To(Succeed())
+	Expect(os.MkdirAll(filepath.Join(dir, "packages"), os.ModePerm)).To(Succeed())
+	treeDir := filepath.Join(dir, "tree")
+	Expect(os.MkdirAll(filepath.Join(treeDir, "app"), os.ModePerm)).To(Succeed())
+	Expect(ioutil.WriteFile(filepath.Join(treeDir, "app", "definition.yaml"), []byte(`
+category: "test"
+name: "app"
+version: "1.0"
+`), 0644)).To(Succeed())
+
+	a := artifact.NewPackageArtifact(filepath.Join(dir, "packages", "app-test-1.0.package.tar"))
+	Expect(a.Compress(content, 1)).To(Succeed())
+	a.CompileSpec = &compilerspec.BhojpurCompilationSpec{
+		Package: &types.Package{Name: "app", Category: "test", Version: "1.0", Path: filepath.Join(treeDir, "app")},
+	}
+	Expect(a.WriteYAML(filepath.Join(dir, "packages"))).To(Succeed())
+
+	repo, err := GenerateRepository(append([]RepositoryOption{
+		WithName("test"),
+		WithType("disk"),
+		WithUrls(repoDir),
+		WithSource(filepath.Join(dir, "packages")),
+		WithTree(treeDir),
+		WithDatabase(pkg.NewInMemoryDatabase(false)),
+		WithContext(ctx),
+	}, opts...)...)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(repo.Write(ctx, repoDir, false, true)).To(Succeed())
+}
+
+var _ = Describe("Keys", func() {
+	var ctx *context.Context
+	var dir, repoDir string
+
+	syncRepository := func(trusted ...string) error {
+		repo := NewSystemRepository(ctx.Config.SystemRepositories[0])
+		repo.TrustedKeys = trusted
+		_, err := repo.Sync(ctx, true)
+		return err
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "keys")
+		Expect(err).ToNot(HaveOccurred())
+		repoDir = filepath.Join(dir, "repo")
+
+		ctx = context.NewContext()
+		ctx.Config.System.DatabasePath = filepath.Join(dir, "db")
+		ctx.Config.System.PkgsCachePath = filepath.Join(dir, "cache")
+		ctx.Config.SystemRepositories = nil
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Context("Signed repository", func() {
+		var pub, otherPub string
+
+		BeforeEach(func() {
+			p, priv, err := sign.GenerateKey()
+			Expect(err).ToNot(HaveOccurred())
+			pub = sign.EncodePublicKey(p)
+			o, _, err := sign.GenerateKey()
+			Expect(err).ToNot(HaveOccurred())
+			otherPub = sign.EncodePublicKey(o)
+
+			writeTestRepository(ctx, dir, repoDir, WithSigningKeys(priv), WithSignedArtifacts(true))
+			Expect(fileHelper.Exists(filepath.Join(repoDir, REPOSITORY_SPECFILE+sign.SignatureSuffix))).To(BeTrue())
+
+			r, err := NewBhojpurSystemRepositoryFromYaml([]byte(`
+name: "test"
+type: "disk"
+urls:
+  - "`+repoDir+`"
+`), pkg.NewInMemoryDatabase(false))
+			Expect(err).ToNot(HaveOccurred())
+			ctx.Config.SystemRepositories = append(ctx.Config.SystemRepositories, *r.BhojpurRepository)
+		})
+
+		It("syncs without trusted keys", func() {
+			Expect(syncRepository()).To(Succeed())
+		})
+
+		It("syncs when signed by a trusted key", func() {
+			Expect(syncRepository(otherPub, pub)).To(Succeed())
+		})
+
+		It("signs the artifacts", func() {
+			repo := NewSystemRepository(ctx.Config.SystemRepositories[0])
+			repo.TrustedKeys = []string{pub}
+			synced, err := repo.Sync(ctx, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(synced.GetIndex())).To(Equal(1))
+
+			keyring, err := synced.Keyring(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			a := synced.GetIndex()[0]
+			Expect(a.Signatures).ToNot(BeEmpty())
+			Expect(a.VerifySignatures(keyring)).To(Succeed())
+
+			a.Checksums["sha256"] = "tampered"
+			Expect(a.VerifySignatures(keyring)).ToNot(Succeed())
+		})
+
+		It("fails to sync when not signed by a trusted key", func() {
+			Expect(syncRepository(otherPub)).ToNot(Succeed())
+		})
+
+		It("fails to sync when the repository is tampered", func() {
+			f := filepath.Join(repoDir, REPOSITORY_SPECFILE)
+			b, err := ioutil.ReadFile(f)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ioutil.WriteFile(f, append(b, []byte("\n# tampered\n")...), 0644)).To(Succeed())
+
+			Expect(syncRepository(pub)).ToNot(Succeed())
+		})
+
+		It("publishes the repository file along with its signature", func() {
+			Expect(filepath.Join(repoDir, REPOSITORY_SPECFILE+".new")).ToNot(BeAnExistingFile())
+			Expect(filepath.Join(repoDir, REPOSITORY_SPECFILE+".new"+sign.SignatureSuffix)).ToNot(BeAnExistingFile())
+
+			// The signature of the previous revision is dropped once the repository isn't signed
+			writeTestRepository(ctx, dir, repoDir)
+			Expect(filepath.Join(repoDir, REPOSITORY_SPECFILE)).To(BeAnExistingFile())
+			Expect(filepath.Join(repoDir, REPOSITORY_SPECFILE+sign.SignatureSuffix)).ToNot(BeAnExistingFile())
+		})
+
+		It("trusts the added keys until they are removed", func() {
+			k, err := sign.DecodePublicKey(pub)
+			Expect(err).ToNot(HaveOccurred())
+			id, err := AddTrustedKey(ctx, "test", k)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(id).To(Equal(sign.KeyID(k)))
+
+			keys, err := NewSystemRepository(ctx.Config.SystemRepositories[0]).ListTrustedKeys(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(keys).To(Equal([]TrustedKey{{Repository: "test", ID: id, Key: pub}}))
+			Expect(syncRepository()).To(Succeed())
+
+			o, err := sign.DecodePublicKey(otherPub)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = AddTrustedKey(ctx, "test", o)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(RemoveTrustedKey(ctx, "test", id)).To(Succeed())
+			Expect(syncRepository()).ToNot(Succeed())
+		})
+	})
+})
@@ -0,0 +1,179 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"sync"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/logger"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	"github.com/bhojpur/iso/pkg/manager/installer/client"
+	"github.com/pterm/pterm"
+)
+
+// Observer is notified of the progress of the installer operations.
+// Its methods can be called concurrently by the installer workers.
+type Observer interface {
+	// SolveStarted is called before solving the constraints of the given packages,
+	// which are empty when solving the upgrade of the system
+	SolveStarted(packages types.Packages)
+	// SolveFinished is called with the computed solution: packages asserted true
+	// are going to be installed, packages asserted false are going to be removed
+	SolveFinished(solution types.PackagesAssertions, err error)
+
+	// DownloadStarted is called before downloading the given number of artifacts
+	DownloadStarted(total int)
+	// DownloadProgress is called periodically while downloading the artifact of
+	// a package, with the bytes downloaded and the artifact size
+	DownloadProgress(p *types.Package, current, total int64)
+	// Downloaded is called once the artifact of a package is downloaded and verified
+	Downloaded(p *types.Package, err error)
+	// DownloadFinished is called once all the artifacts are downloaded
+	DownloadFinished()
+
+	// UnpackStarted is called before unpacking a package in the system target
+	UnpackStarted(p *types.Package)
+	// UnpackFinished is called once a package is unpacked
+	UnpackFinished(p *types.Package, err error)
+
+	// FinalizerOutput is called with the output of the finalizer of a package
+	FinalizerOutput(p *types.Package, output string, err error)
+
+	// TransactionCommitted is called once the transaction of an operation is over, with
+	// its error. The changes are rolled back on errors, unless only finalizers or triggers failed.
+	TransactionCommitted(operation string, err error)
+}
+
+// NopObserver is an Observer ignoring all the events. It can be embedded
+// by observers interested only in some of them.
+type NopObserver struct{}
+
+func (NopObserver) SolveStarted(types.Packages)                   {}
+func (NopObserver) SolveFinished(types.PackagesAssertions, error) {}
+func (NopObserver) DownloadStarted(int)                           {}
+func (NopObserver) DownloadProgress(*types.Package, int64, int64) {}
+func (NopObserver) Downloaded(*types.Package, error)              {}
+func (NopObserver) DownloadFinished()                             {}
+func (NopObserver) UnpackStarted(*types.Package)                  {}
+func (NopObserver) UnpackFinished(*types.Package, error)          {}
+func (NopObserver) FinalizerOutput(*types.Package, string, error) {}
+func (NopObserver) TransactionCommitted(string, error)            {}
+
+// TerminalObserver displays the progress of the downloads with progress bars,
+// when the terminal is big enough. It is the observer used by default.
+type TerminalObserver struct {
+	NopObserver
+
+	sync.Mutex
+	area      *pterm.AreaPrinter
+	progress  *pterm.ProgressbarPrinter
+	downloads map[string]*pterm.ProgressbarPrinter
+}
+
+// NewTerminalObserver returns an observer displaying the progress in the terminal
+func NewTerminalObserver() *TerminalObserver {
+	return &TerminalObserver{downloads: map[string]*pterm.ProgressbarPrinter{}}
+}
+
+func (o *TerminalObserver) DownloadStarted(total int) {
+	o.Lock()
+	defer o.Unlock()
+
+	// Check if the terminal is big enough to display a progress bar
+	// https://github.com/pterm/pterm/blob/4c725e56bfd9eb38e1c7b9dec187b50b93baa8bd/progressbar_printer.go#L190
+	w, _, err := logger.GetTerminalSize()
+	if !logger.IsTerminal() || err != nil || w <= 100 {
+		return
+	}
+	o.area, _ = pterm.DefaultArea.Start()
+	o.progress, _ = pterm.DefaultProgressbar.WithPrintTogether(o.area).WithTotal(total).WithTitle("Downloading packages").Start()
+}
+
+func (o *TerminalObserver) DownloadProgress(p *types.Package, current, total int64) {
+	o.Lock()
+	defer o.Unlock()
+	if o.progress == nil {
+		return
+	}
+
+	pb, ok := o.downloads[p.GetFingerPrint()]
+	if !ok {
+		pb, _ = o.progress.WithTotal(int(total)).WithTitle(p.HumanReadableString()).Start()
+		o.downloads[p.GetFingerPrint()] = pb
+	}
+	pb.Increment().Current = int(current)
+}
+
+func (o *TerminalObserver) Downloaded(p *types.Package, err error) {
+	o.Lock()
+	defer o.Unlock()
+	if pb, ok := o.downloads[p.GetFingerPrint()]; ok {
+		pb.Stop()
+		delete(o.downloads, p.GetFingerPrint())
+	}
+	if o.progress != nil {
+		o.progress.Increment()
+	}
+}
+
+func (o *TerminalObserver) DownloadFinished() {
+	o.Lock()
+	defer o.Unlock()
+	if o.area != nil {
+		o.area.Stop()
+	}
+	o.area, o.progress = nil, nil
+}
+
+// observer returns the observer of the installer, which is never nil
+func (l *BhojpurInstaller) observer() Observer {
+	if l.Options.Observer == nil {
+		return NopObserver{}
+	}
+	return l.Options.Observer
+}
+
+// downloadContext returns a copy of the installer context notifying the
+// download progress of the artifact of a package to the observer
+func (l *BhojpurInstaller) downloadContext(ctx types.Context, p *types.Package) types.Context {
+	ctx = ctx.Copy()
+	ctx.SetAnnotation(client.DownloadProgressAnnotation, client.DownloadProgressFunc(func(current, total int64) {
+		l.observer().DownloadProgress(p, current, total)
+	}))
+	return ctx
+}
+
+// finalizerContext returns a copy of the installer context notifying the
+// output of the finalizers to the observer
+func (l *BhojpurInstaller) finalizerContext() types.Context {
+	ctx := l.Options.Context.Copy()
+	ctx.SetAnnotation(finalizerOutputAnnotation, finalizerOutputFunc(l.observer().FinalizerOutput))
+	return ctx
+}
+
+// assertRemoved returns the assertions removing the given packages
+func assertRemoved(packs types.Packages) types.PackagesAssertions {
+	res := types.PackagesAssertions{}
+	for _, p := range packs {
+		res = append(res, types.PackageAssert{Package: p, Value: false})
+	}
+	return res
+}
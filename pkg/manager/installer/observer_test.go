@@ -0,0 +1,115 @@
+package installer_test
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	pkg "github.com/bhojpur/iso/pkg/manager/database"
+	. "github.com/bhojpur/iso/pkg/manager/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type recordingObserver struct {
+	NopObserver
+	sync.Mutex
+	events []string
+	output string
+}
+
+func (o *recordingObserver) record(e string) {
+	o.Lock()
+	defer o.Unlock()
+	o.events = append(o.events, e)
+}
+
+func (o *recordingObserver) SolveStarted(types.Packages) { o.record("solve started") }
+func (o *recordingObserver) SolveFinished(s types.PackagesAssertions, err error) {
+	o.record("solve finished")
+}
+func (o *recordingObserver) FinalizerOutput(p *types.Package, output string, err error) {
+	o.record("finalizer " + p.GetName())
+	o.output += output
+}
+func (o *recordingObserver) TransactionCommitted(operation string, err error) {
+	o.record("committed " + operation)
+}
+
+var _ = Describe("Observer", func() {
+	var s *System
+	var dir string
+	var app, lib *types.Package
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "observer")
+		Expect(err).ToNot(HaveOccurred())
+		s = &System{
+			Database:   pkg.NewInMemoryDatabase(false),
+			Target:     "/",
+			JournalDir: filepath.Join(dir, TransactionJournalDir),
+		}
+
+		lib = types.NewPackage("lib", "1.0", []*types.Package{}, []*types.Package{})
+		app = types.NewPackage("app", "1.0", []*types.Package{{Name: "lib", Version: ">=0"}}, []*types.Package{})
+		for _, p := range []*types.Package{app, lib} {
+			_, err := s.Database.CreatePackage(p)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(s.Database.SetPackageFiles(&types.PackageFile{PackageFingerprint: p.GetFingerPrint()})).To(Succeed())
+		}
+		Expect(s.Database.SetPackageFinalizer(&types.PackageFinalizer{
+			PackageFingerprint: app.GetFingerPrint(),
+			Finalizer:          "uninstall:\n- echo removing app\n",
+		})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("is notified of the progress of the operations", func() {
+		o := &recordingObserver{}
+		inst := NewBhojpurInstaller(BhojpurInstallerOptions{Concurrency: 1, Observer: o, Context: context.NewContext()})
+
+		Expect(inst.Uninstall(s, app)).To(Succeed())
+		Expect(o.events).To(Equal([]string{"solve started", "solve finished", "finalizer app", "committed uninstall"}))
+		Expect(o.output).To(Equal("removing app\n"))
+	})
+
+	It("is notified of the committed operations without a journal", func() {
+		s.JournalDir = ""
+		o := &recordingObserver{}
+		inst := NewBhojpurInstaller(BhojpurInstallerOptions{Concurrency: 1, Observer: o, Context: context.NewContext()})
+
+		Expect(inst.Uninstall(s, app)).To(Succeed())
+		Expect(o.events).To(ContainElement("committed uninstall"))
+	})
+
+	It("is optional", func() {
+		inst := &BhojpurInstaller{Options: BhojpurInstallerOptions{Concurrency: 1, Context: context.NewContext()}}
+		Expect(inst.Uninstall(s, app)).To(Succeed())
+		Expect(s.Database.World()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,198 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/config"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+)
+
+const (
+	FileMissing     = "missing"
+	FileModified    = "modified"
+	FilePermissions = "permissions"
+)
+
+// permissionBits are the mode bits compared when checking installed files
+const permissionBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// FileCheck is an installed file which differs from the one in the package artifact
+type FileCheck struct {
+	Path   string `json:"path" yaml:"path"`
+	Status string `json:"status" yaml:"status"`
+	Detail string `json:"detail,omitempty" yaml:"detail,omitempty"`
+}
+
+// PackageCheck reports the installed files of a package which don't match its artifact.
+// Changes to config-protected files are reported apart, as they are expected to be edited.
+type PackageCheck struct {
+	Package         string      `json:"package" yaml:"package"`
+	Files           []FileCheck `json:"files,omitempty" yaml:"files,omitempty"`
+	ConfigProtected []FileCheck `json:"config_protected,omitempty" yaml:"config_protected,omitempty"`
+	// Unverified is set for packages installed without recording their files metadata,
+	// for which only missing files are reported
+	Unverified bool `json:"unverified,omitempty" yaml:"unverified,omitempty"`
+
+	pack *types.Package
+}
+
+// GetPackage returns the installed package checked
+func (c *PackageCheck) GetPackage() *types.Package {
+	return c.pack
+}
+
+// Broken returns true if files of the package, besides the config-protected ones, are missing or changed
+func (c *PackageCheck) Broken() bool {
+	return len(c.Files) > 0
+}
+
+// OSCheckContent compares the files of the installed packages with the checksum, the mode,
+// the owner and the symlink target recorded from their artifacts. It returns the packages
+// with missing or changed files, and the ones which files metadata is unknown.
+func (s *System) OSCheckContent(ctx types.Context) []*PackageCheck {
+	res := []*PackageCheck{}
+	for _, p := range s.Database.World() {
+		c := s.checkPackageContent(ctx, p)
+		if c.Unverified || len(c.Files) > 0 || len(c.ConfigProtected) > 0 {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+func (s *System) checkPackageContent(ctx types.Context, p *types.Package) *PackageCheck {
+	c := &PackageCheck{Package: p.HumanReadableString(), pack: p}
+
+	files, _ := s.Database.GetPackageFiles(p)
+	metadata, err := s.Database.GetPackageFileMetadata(p)
+	if err != nil || len(metadata) == 0 {
+		c.Unverified = true
+		metadata = []types.FileMetadata{}
+		for _, f := range files {
+			metadata = append(metadata, types.FileMetadata{Path: f})
+		}
+	}
+
+	annotationDir, _ := p.Annotations[types.ConfigProtectAnnotation]
+	cp := config.NewConfigProtect(annotationDir)
+	cp.Map(files, ctx.GetConfig().ConfigProtectConfFiles)
+
+	for _, m := range metadata {
+		var check *FileCheck
+		if c.Unverified {
+			check = s.checkFileExists(m)
+		} else {
+			check = s.checkFile(m)
+		}
+		if check == nil {
+			continue
+		}
+		ctx.Debugf("File '%s' from '%s' is %s %s", m.Path, p.HumanReadableString(), check.Status, check.Detail)
+		if cp.Protected(m.Path) {
+			c.ConfigProtected = append(c.ConfigProtected, *check)
+		} else {
+			c.Files = append(c.Files, *check)
+		}
+	}
+	return c
+}
+
+func (s *System) checkFileExists(m types.FileMetadata) *FileCheck {
+	if _, err := os.Lstat(filepath.Join(s.Target, m.Path)); err != nil {
+		return &FileCheck{Path: m.Path, Status: FileMissing}
+	}
+	return nil
+}
+
+// checkFile compares an installed file with its metadata, returning nil if they match
+func (s *System) checkFile(m types.FileMetadata) *FileCheck {
+	target := filepath.Join(s.Target, m.Path)
+	fi, err := os.Lstat(target)
+	if err != nil {
+		return &FileCheck{Path: m.Path, Status: FileMissing}
+	}
+
+	modified := func(detail string) *FileCheck {
+		return &FileCheck{Path: m.Path, Status: FileModified, Detail: detail}
+	}
+
+	switch {
+	case m.Mode&os.ModeSymlink != 0:
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return modified("not a symlink anymore")
+		}
+		link, err := os.Readlink(target)
+		if err != nil {
+			return modified(err.Error())
+		}
+		if link != m.Link {
+			return modified(fmt.Sprintf("symlink to %s instead of %s", link, m.Link))
+		}
+		// Symlinks permissions are not meaningful
+		return nil
+	case m.Sha256 != "":
+		if !fi.Mode().IsRegular() {
+			return modified("not a regular file anymore")
+		}
+		sum, err := fileSha256(target)
+		if err != nil {
+			return modified(err.Error())
+		}
+		if sum != m.Sha256 {
+			return modified("checksum mismatch")
+		}
+	}
+
+	if fi.Mode()&permissionBits != m.Mode&permissionBits {
+		return &FileCheck{Path: m.Path, Status: FilePermissions,
+			Detail: fmt.Sprintf("mode %s instead of %s", fi.Mode()&permissionBits, m.Mode&permissionBits)}
+	}
+
+	// Files are unpacked with their owner only when running as root
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok && os.Geteuid() == 0 {
+		if int(stat.Uid) != m.Uid || int(stat.Gid) != m.Gid {
+			return &FileCheck{Path: m.Path, Status: FilePermissions,
+				Detail: fmt.Sprintf("owner %d:%d instead of %d:%d", stat.Uid, stat.Gid, m.Uid, m.Gid)}
+		}
+	}
+	return nil
+}
+
+func fileSha256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
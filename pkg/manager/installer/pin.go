@@ -0,0 +1,190 @@
+package installer
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	"github.com/ghodss/yaml"
+
+	"github.com/pkg/errors"
+)
+
+// PinsDir is the folder under the database path holding the pinned packages,
+// one file for each pin
+const PinsDir = "pins"
+
+// Pin freezes a package at a version, or a version range
+type Pin struct {
+	Category string `json:"category" yaml:"category"`
+	Name     string `json:"name" yaml:"name"`
+	Version  string `json:"version" yaml:"version"`
+}
+
+// Package returns the package selector of the pin
+func (p Pin) Package() *types.Package {
+	return &types.Package{Category: p.Category, Name: p.Name, Version: p.Version}
+}
+
+func (p Pin) String() string {
+	return fmt.Sprintf("%s/%s %s", p.Category, p.Name, p.Version)
+}
+
+// Pins returns the packages pinned in the system
+func (s *System) Pins() ([]Pin, error) {
+	res := []Pin{}
+	if s.PinsDir == "" {
+		return res, nil
+	}
+
+	files, err := ioutil.ReadDir(s.PinsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return res, nil
+		}
+		return nil, errors.Wrap(err, "while reading pins")
+	}
+
+	for _, f := range files {
+		if f.IsDir() || (filepath.Ext(f.Name()) != ".yaml" && filepath.Ext(f.Name()) != ".yml") {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(s.PinsDir, f.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading pin %s", f.Name())
+		}
+		pin := Pin{}
+		if err := yaml.Unmarshal(b, &pin); err != nil {
+			return nil, errors.Wrapf(err, "while reading pin %s", f.Name())
+		}
+		if pin.Name == "" || pin.Version == "" {
+			return nil, fmt.Errorf("invalid pin %s: name and version are required", f.Name())
+		}
+		res = append(res, pin)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].String() < res[j].String() })
+	return res, nil
+}
+
+// PinnedPackages returns the package selectors of the pins of the system
+func (s *System) PinnedPackages() (types.Packages, error) {
+	pins, err := s.Pins()
+	if err != nil {
+		return nil, err
+	}
+	res := types.Packages{}
+	for _, p := range pins {
+		res = append(res, p.Package())
+	}
+	return res, nil
+}
+
+// AddPin pins a package to the version or version range of the given package.
+// Any other pin of the same package is replaced.
+func (s *System) AddPin(p *types.Package) error {
+	if s.PinsDir == "" {
+		return errors.New("the system doesn't support pins")
+	}
+	if err := s.RemovePin(p); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.PinsDir, os.ModePerm); err != nil {
+		return errors.Wrap(err, "while creating pins directory")
+	}
+
+	b, err := yaml.Marshal(Pin{Category: p.GetCategory(), Name: p.GetName(), Version: p.GetVersion()})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.pinPath(p), b, 0644)
+}
+
+// RemovePin removes the pins of a package, if any
+func (s *System) RemovePin(p *types.Package) error {
+	if s.PinsDir == "" {
+		return nil
+	}
+
+	files, err := ioutil.ReadDir(s.PinsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrap(err, "while reading pins")
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(s.PinsDir, f.Name()))
+		if err != nil {
+			continue
+		}
+		pin := Pin{}
+		if err := yaml.Unmarshal(b, &pin); err != nil {
+			continue
+		}
+		if pin.Package().AtomMatches(p) {
+			if err := os.Remove(filepath.Join(s.PinsDir, f.Name())); err != nil {
+				return errors.Wrapf(err, "while removing pin %s", f.Name())
+			}
+		}
+	}
+	return nil
+}
+
+func (s *System) pinPath(p *types.Package) string {
+	name := p.GetName()
+	if p.GetCategory() != "" {
+		name = p.GetCategory() + "-" + name
+	}
+	return filepath.Join(s.PinsDir, strings.ReplaceAll(name, string(os.PathSeparator), "-")+".yaml")
+}
+
+// applyPins narrows the version of the requested packages to the one of their pin.
+// It fails if a package is requested at a version its pin doesn't allow.
+func applyPins(pins, packs types.Packages) (types.Packages, error) {
+	res := types.Packages{}
+	for _, p := range packs {
+		pin := pins.FindPin(p)
+		if pin == nil {
+			res = append(res, p)
+			continue
+		}
+		switch {
+		case p.GetVersion() == ">=0" || p.GetVersion() == ">0":
+			pinned := p.Clone()
+			pinned.SetVersion(pin.GetVersion())
+			res = append(res, pinned)
+		case !p.IsSelector() && !pin.AllowsVersion(p):
+			return nil, fmt.Errorf("package %s is pinned to version '%s', remove the pin with 'isomgr pin remove %s/%s' first",
+				p.HumanReadableString(), pin.GetVersion(), pin.GetCategory(), pin.GetName())
+		default:
+			res = append(res, p)
+		}
+	}
+	return res, nil
+}
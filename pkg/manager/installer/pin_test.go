@@ -0,0 +1,101 @@
+package installer_test
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	pkg "github.com/bhojpur/iso/pkg/manager/database"
+	. "github.com/bhojpur/iso/pkg/manager/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pins", func() {
+	var s *System
+	var dbPath string
+
+	BeforeEach(func() {
+		var err error
+		dbPath, err = ioutil.TempDir("", "db")
+		Expect(err).ToNot(HaveOccurred())
+
+		s = &System{
+			Database: pkg.NewInMemoryDatabase(false),
+			PinsDir:  filepath.Join(dbPath, PinsDir),
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dbPath)
+	})
+
+	It("adds, replaces and removes pins", func() {
+		pins, err := s.Pins()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pins).To(BeEmpty())
+
+		Expect(s.AddPin(&types.Package{Name: "kernel", Category: "system", Version: ">=5.10,<5.11"})).To(Succeed())
+		Expect(s.AddPin(&types.Package{Name: "glibc", Category: "system", Version: "2.33"})).To(Succeed())
+		Expect(s.AddPin(&types.Package{Name: "glibc", Category: "system", Version: "2.34"})).To(Succeed())
+
+		pins, err = s.Pins()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pins).To(Equal([]Pin{
+			{Category: "system", Name: "glibc", Version: "2.34"},
+			{Category: "system", Name: "kernel", Version: ">=5.10,<5.11"},
+		}))
+
+		Expect(s.RemovePin(&types.Package{Name: "kernel", Category: "system", Version: ">=0"})).To(Succeed())
+		pins, err = s.Pins()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pins).To(Equal([]Pin{{Category: "system", Name: "glibc", Version: "2.34"}}))
+	})
+
+	It("reads pins dropped in the pins folder", func() {
+		Expect(os.MkdirAll(s.PinsDir, os.ModePerm)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(s.PinsDir, "kernel.yaml"), []byte("category: system\nname: kernel\nversion: '<6'\n"), 0644)).To(Succeed())
+
+		pins, err := s.PinnedPackages()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pins).To(HaveLen(1))
+		Expect(pins[0].AllowsVersion(&types.Package{Name: "kernel", Category: "system", Version: "5.15"})).To(BeTrue())
+		Expect(pins[0].AllowsVersion(&types.Package{Name: "kernel", Category: "system", Version: "6.1"})).To(BeFalse())
+	})
+
+	It("refuses to replace pinned packages with other versions", func() {
+		a := &types.Package{Name: "a", Version: "1", Category: "t"}
+		_, err := s.Database.CreatePackage(a)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s.AddPin(a)).To(Succeed())
+
+		inst := NewBhojpurInstaller(BhojpurInstallerOptions{Concurrency: 1, Context: context.NewContext()})
+		err = inst.Swap(types.Packages{a}, types.Packages{&types.Package{Name: "a", Version: "2", Category: "t"}}, s)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("pinned"))
+
+		_, err = s.Database.FindPackage(a)
+		Expect(err).ToNot(HaveOccurred())
+	})
+})
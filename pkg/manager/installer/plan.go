@@ -0,0 +1,162 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"sort"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+)
+
+// Plan describes the changes an operation would apply to the system.
+// It is computed instead of applying them when the installer pretends.
+type Plan struct {
+	Operation string        `json:"operation" yaml:"operation"`
+	Install   []PlanPackage `json:"install" yaml:"install"`
+	Remove    []PlanPackage `json:"remove" yaml:"remove"`
+	// Changes are the packages replaced by another version
+	Changes []PlanChange `json:"changes" yaml:"changes"`
+	// Finalizers are the packages whose finalizer would run
+	Finalizers []string `json:"finalizers" yaml:"finalizers"`
+	// DownloadSize is the size in bytes of the artifacts missing from the cache
+	DownloadSize int64 `json:"download_size" yaml:"download_size"`
+}
+
+// PlanPackage is a package installed or removed by a plan
+type PlanPackage struct {
+	Category   string `json:"category" yaml:"category"`
+	Name       string `json:"name" yaml:"name"`
+	Version    string `json:"version" yaml:"version"`
+	Repository string `json:"repository,omitempty" yaml:"repository,omitempty"`
+	// Size is the size in bytes of the package artifact, when known
+	Size   int64 `json:"size,omitempty" yaml:"size,omitempty"`
+	Cached bool  `json:"cached,omitempty" yaml:"cached,omitempty"`
+}
+
+// PlanChange is a version transition of a package
+type PlanChange struct {
+	Category string `json:"category" yaml:"category"`
+	Name     string `json:"name" yaml:"name"`
+	From     string `json:"from" yaml:"from"`
+	To       string `json:"to" yaml:"to"`
+}
+
+func newPlan(operation string) *Plan {
+	return &Plan{
+		Operation:  operation,
+		Install:    []PlanPackage{},
+		Remove:     []PlanPackage{},
+		Changes:    []PlanChange{},
+		Finalizers: []string{},
+	}
+}
+
+// Empty returns true if the plan doesn't change the system
+func (p *Plan) Empty() bool {
+	return len(p.Install) == 0 && len(p.Remove) == 0
+}
+
+// Plan returns the changes computed by the operations run while pretending.
+// It is nil if the installer doesn't pretend.
+func (l *BhojpurInstaller) Plan() *Plan {
+	if l.plan == nil && l.Options.Pretend {
+		return newPlan("")
+	}
+	return l.plan
+}
+
+// addToPlan records in the plan the packages to install and remove, and the finalizers to run
+func (l *BhojpurInstaller) addToPlan(match map[string]ArtifactMatch, toRemove types.Packages, toFinalize []*types.Package) error {
+	if l.plan == nil {
+		l.plan = newPlan("")
+	}
+
+	installed := map[string]PlanPackage{}
+	for _, m := range match {
+		p := PlanPackage{
+			Category: m.Package.GetCategory(),
+			Name:     m.Package.GetName(),
+			Version:  m.Package.GetVersion(),
+		}
+		if m.Repository != nil {
+			p.Repository = m.Repository.GetName()
+			if _, err := m.Repository.Client(l.Options.Context).CacheGet(m.Artifact); err == nil {
+				p.Cached = true
+			}
+		}
+		if m.Artifact != nil {
+			p.Size = m.Artifact.Size
+		}
+		if !p.Cached {
+			l.plan.DownloadSize += p.Size
+		}
+		installed[m.Package.GetPackageName()] = p
+		l.plan.Install = append(l.plan.Install, p)
+	}
+
+	for _, r := range toRemove {
+		l.plan.Remove = append(l.plan.Remove, PlanPackage{
+			Category: r.GetCategory(),
+			Name:     r.GetName(),
+			Version:  r.GetVersion(),
+		})
+		if p, ok := installed[r.GetPackageName()]; ok {
+			l.plan.Changes = append(l.plan.Changes, PlanChange{
+				Category: r.GetCategory(),
+				Name:     r.GetName(),
+				From:     r.GetVersion(),
+				To:       p.Version,
+			})
+		}
+	}
+
+	finalizers := map[string]bool{}
+	for _, f := range l.plan.Finalizers {
+		finalizers[f] = true
+	}
+	for _, p := range toFinalize {
+		out, err := renderFinalizer(p)
+		if err != nil {
+			return err
+		}
+		if out != "" && !finalizers[p.HumanReadableString()] {
+			finalizers[p.HumanReadableString()] = true
+			l.plan.Finalizers = append(l.plan.Finalizers, p.HumanReadableString())
+		}
+	}
+
+	sortPlanPackages(l.plan.Install)
+	sortPlanPackages(l.plan.Remove)
+	sort.Slice(l.plan.Changes, func(i, j int) bool {
+		a, b := l.plan.Changes[i], l.plan.Changes[j]
+		return a.Category+"/"+a.Name < b.Category+"/"+b.Name
+	})
+	return nil
+}
+
+func sortPlanPackages(p []PlanPackage) {
+	sort.Slice(p, func(i, j int) bool {
+		if p[i].Category+"/"+p[i].Name == p[j].Category+"/"+p[j].Name {
+			return p[i].Version < p[j].Version
+		}
+		return p[i].Category+"/"+p[i].Name < p[j].Category+"/"+p[j].Name
+	})
+}
@@ -0,0 +1,98 @@
+package installer_test
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	pkg "github.com/bhojpur/iso/pkg/manager/database"
+	. "github.com/bhojpur/iso/pkg/manager/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Plan", func() {
+	var s *System
+	var inst *BhojpurInstaller
+	var app, lib, old *types.Package
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "plan")
+		Expect(err).ToNot(HaveOccurred())
+		s = &System{
+			Database:    pkg.NewInMemoryDatabase(false),
+			Target:      "/",
+			JournalDir:  filepath.Join(dir, TransactionJournalDir),
+			KeepHistory: true,
+		}
+		inst = NewBhojpurInstaller(BhojpurInstallerOptions{Concurrency: 1, Pretend: true, Context: context.NewContext()})
+
+		lib = types.NewPackage("lib", "1.0", []*types.Package{}, []*types.Package{})
+		app = types.NewPackage("app", "1.0", []*types.Package{{Name: "lib", Version: ">=0"}}, []*types.Package{})
+		old = types.NewPackage("old", "1.0", []*types.Package{}, []*types.Package{})
+		old.SetInstallReason(types.InstallReasonDependency)
+		for _, p := range []*types.Package{app, lib, old} {
+			_, err := s.Database.CreatePackage(p)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(s.Database.SetPackageFiles(&types.PackageFile{PackageFingerprint: p.GetFingerPrint()})).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("is nil when not pretending", func() {
+		Expect(NewBhojpurInstaller(BhojpurInstallerOptions{}).Plan()).To(BeNil())
+	})
+
+	It("computes the packages to uninstall without removing them", func() {
+		Expect(inst.Uninstall(s, app)).To(Succeed())
+
+		plan := inst.Plan()
+		Expect(plan.Operation).To(Equal("uninstall"))
+		Expect(plan.Remove).To(Equal([]PlanPackage{
+			{Category: app.GetCategory(), Name: "app", Version: "1.0"},
+			{Category: lib.GetCategory(), Name: "lib", Version: "1.0"},
+		}))
+		Expect(plan.Install).To(BeEmpty())
+		Expect(plan.Changes).To(BeEmpty())
+
+		Expect(len(s.Database.World())).To(Equal(3))
+		Expect(s.HasPendingTransaction()).To(BeFalse())
+		history, err := s.History()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(history).To(BeEmpty())
+	})
+
+	It("computes the orphans to remove", func() {
+		Expect(inst.Autoremove(s)).To(Succeed())
+
+		plan := inst.Plan()
+		Expect(plan.Operation).To(Equal("autoremove"))
+		Expect(plan.Remove).To(Equal([]PlanPackage{{Category: old.GetCategory(), Name: "old", Version: "1.0"}}))
+		Expect(len(s.Database.World())).To(Equal(3))
+	})
+})
@@ -21,6 +21,7 @@ package installer
 // THE SOFTWARE.
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -36,6 +37,7 @@ import (
 	artifact "github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
 	compression "github.com/bhojpur/iso/pkg/manager/compiler/types/compression"
 	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
+	"github.com/bhojpur/iso/pkg/manager/helpers/sign"
 	"go.uber.org/multierr"
 
 	"github.com/bhojpur/iso/pkg/manager/api/core/types"
@@ -44,6 +46,7 @@ import (
 
 	pkg "github.com/bhojpur/iso/pkg/manager/database"
 	tree "github.com/bhojpur/iso/pkg/manager/tree"
+	version "github.com/bhojpur/iso/pkg/manager/versioner"
 
 	"github.com/ghodss/yaml"
 	"github.com/pkg/errors"
@@ -52,6 +55,7 @@ import (
 const (
 	REPOSITORY_METAFILE  = "repository.meta.yaml"
 	REPOSITORY_SPECFILE  = "repository.yaml"
+	SNAPSHOTS_FILE       = "snapshots.yaml"
 	TREE_TARBALL         = "tree.tar"
 	COMPILERTREE_TARBALL = "compilertree.tar"
 
@@ -62,6 +66,8 @@ const (
 	DiskRepositoryType   = "disk"
 	HttpRepositoryType   = "http"
 	DockerRepositoryType = "docker"
+	S3RepositoryType     = "s3"
+	OCIRepositoryType    = "oci"
 )
 
 type BhojpurRepositoryFile struct {
@@ -79,8 +85,21 @@ type BhojpurSystemRepository struct {
 	Backend         compiler.CompilerBackend         `json:"-"`
 	PushImages      bool                             `json:"-"`
 	ForcePush       bool                             `json:"-"`
-
-	imagePrefix, snapshotID string
+	// Diffs are the archives with the changes of the tree and of the artifact
+	// index between consecutive revisions, from the oldest to the newest
+	Diffs []BhojpurRepositoryDiff `json:"diffs,omitempty"`
+	// Deltas enables the generation of binary deltas between consecutive
+	// versions of the packages when writing the repository
+	Deltas bool `json:"-"`
+	// SignArtifacts enables the signature of each artifact of the index
+	// with the signing keys, besides the repository files
+	SignArtifacts bool `json:"-"`
+	// DiffHistory is the number of diffs between consecutive revisions kept
+	// in the repository when writing it. 0 disables them
+	DiffHistory int `json:"-"`
+
+	imagePrefix, snapshotID, src string
+	signingKeys                  []ed25519.PrivateKey
 }
 
 type BhojpurSystemRepositoryMetadata struct {
@@ -365,7 +384,18 @@ func GenerateRepository(p ...RepositoryOption) (*BhojpurSystemRepository, error)
 		PushImages:        c.PushImages,
 		ForcePush:         c.Force,
 		Backend:           c.CompilerBackend,
+		Deltas:            c.Deltas,
+		DiffHistory:       c.DiffHistory,
+		SignArtifacts:     c.SignArtifacts,
 		imagePrefix:       c.ImagePrefix,
+		snapshotID:        c.SnapshotID,
+		src:               c.Src,
+		signingKeys:       c.SigningKeys,
+	}
+
+	repo.S3 = c.S3
+	if c.Authentication != nil {
+		repo.Authentication = c.Authentication
 	}
 
 	if err := repo.initialize(c.context, c.Src); err != nil {
@@ -603,17 +633,164 @@ func (r *BhojpurSystemRepository) AddTree(ctx types.Context, t tree.Builder, dst
 	return a, nil
 }
 
+// AddDeltas generates in dst a binary delta between the artifacts of each pair
+// of consecutive versions of the packages in the repository index, and records
+// them in the index of the newer artifacts. Deltas which are not smaller than the
+// artifact they rebuild are discarded. It returns the generated deltas.
+// The deltas of published, the index of the revision being replaced, are reused
+// when both artifacts they go from and to are unchanged, and their file, if found
+// in dst, is intact.
+func (r *BhojpurSystemRepository) AddDeltas(ctx types.Context, dst string, published []*artifact.PackageArtifact) ([]*artifact.PackageArtifact, error) {
+	publishedArtifacts := map[string]*artifact.PackageArtifact{}
+	for _, a := range published {
+		if a.CompileSpec != nil && a.CompileSpec.Package != nil {
+			publishedArtifacts[a.CompileSpec.Package.GetFingerPrint()] = a
+		}
+	}
+
+	versions := map[string][]*artifact.PackageArtifact{}
+	for _, a := range r.Index {
+		if a.CompileSpec == nil || a.CompileSpec.Package == nil {
+			continue
+		}
+		key := a.CompileSpec.Package.GetPackageName()
+		versions[key] = append(versions[key], a)
+	}
+
+	var deltas []*artifact.PackageArtifact
+	for _, arts := range versions {
+		if len(arts) < 2 {
+			continue
+		}
+		byVersion := map[string]*artifact.PackageArtifact{}
+		raw := []string{}
+		for _, a := range arts {
+			byVersion[a.CompileSpec.Package.GetVersion()] = a
+			raw = append(raw, a.CompileSpec.Package.GetVersion())
+		}
+		sorted := (&version.WrappedVersioner{}).Sort(raw)
+
+		for i := 1; i < len(sorted); i++ {
+			prev, cur := byVersion[sorted[i-1]], byVersion[sorted[i]]
+			if reuseDelta(ctx, prev, cur, publishedArtifacts[cur.CompileSpec.Package.GetFingerPrint()], dst) {
+				continue
+			}
+			d, err := r.addDelta(ctx, prev, cur, dst)
+			if err != nil {
+				return deltas, errors.Wrapf(err, "while generating delta for %s", cur.CompileSpec.Package.HumanReadableString())
+			}
+			if d != nil {
+				deltas = append(deltas, d)
+			}
+		}
+	}
+	return deltas, nil
+}
+
+// reuseDelta records in cur the delta from prev of its published artifact, if the
+// artifacts didn't change since it was generated. It returns false if the delta
+// has to be generated.
+func reuseDelta(ctx types.Context, prev, cur, published *artifact.PackageArtifact, dst string) bool {
+	if published == nil || !sameChecksums(published.Checksums, cur.Checksums) {
+		return false
+	}
+	for _, d := range published.Deltas {
+		if d.FromVersion != prev.CompileSpec.Package.GetVersion() || !sameChecksums(d.FromChecksums, prev.Checksums) {
+			continue
+		}
+		if f := filepath.Join(dst, d.Path); fileHelper.Exists(f) {
+			deltaArtifact := artifact.NewPackageArtifact(f)
+			deltaArtifact.Checksums = d.Checksums
+			if deltaArtifact.Verify() != nil {
+				return false
+			}
+		}
+
+		ctx.Debug("Reusing delta", d.Path)
+		deltas := []artifact.ArtifactDelta{}
+		for _, existing := range cur.Deltas {
+			if existing.FromVersion != d.FromVersion {
+				deltas = append(deltas, existing)
+			}
+		}
+		cur.Deltas = append(deltas, d)
+		return true
+	}
+	return false
+}
+
+// sameChecksums returns true if the checksums are known and equal
+func sameChecksums(a, b artifact.Checksums) bool {
+	if len(a) == 0 || len(a) != len(b) {
+		return false
+	}
+	for t, sum := range a {
+		if b[t] != sum {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *BhojpurSystemRepository) addDelta(ctx types.Context, prev, cur *artifact.PackageArtifact, dst string) (*artifact.PackageArtifact, error) {
+	from := filepath.Join(r.src, prev.GetFileName())
+	to := filepath.Join(r.src, cur.GetFileName())
+	if !fileHelper.Exists(from) || !fileHelper.Exists(to) {
+		ctx.Debug("Artifacts not found, skipping delta from", from, "to", to)
+		return nil, nil
+	}
+
+	name := fmt.Sprintf("%s.from-%s.delta", cur.GetFileName(), prev.CompileSpec.Package.GetVersion())
+	d := artifact.NewPackageArtifact(filepath.Join(dst, name))
+	if err := artifact.GenerateDelta(from, to, d.Path); err != nil {
+		return nil, err
+	}
+
+	deltaInfo, err := os.Stat(d.Path)
+	if err != nil {
+		return nil, err
+	}
+	toInfo, err := os.Stat(to)
+	if err != nil {
+		return nil, err
+	}
+	if deltaInfo.Size() >= toInfo.Size() {
+		ctx.Debug("Delta", name, "is not smaller than the artifact, skipping it")
+		return nil, os.Remove(d.Path)
+	}
+
+	if err := d.Hash(); err != nil {
+		return nil, err
+	}
+	ctx.Info(fmt.Sprintf("Generated delta %s (%d bytes, artifact is %d bytes)", name, deltaInfo.Size(), toInfo.Size()))
+
+	deltas := []artifact.ArtifactDelta{}
+	for _, existing := range cur.Deltas {
+		if existing.FromVersion != prev.CompileSpec.Package.GetVersion() {
+			deltas = append(deltas, existing)
+		}
+	}
+	cur.Deltas = append(deltas, artifact.ArtifactDelta{
+		FromVersion:   prev.CompileSpec.Package.GetVersion(),
+		FromChecksums: prev.Checksums,
+		Path:          name,
+		Checksums:     d.Checksums,
+	})
+	return d, nil
+}
+
 // Snapshot creates a copy of the current Bhojpur ISO repository index into dst.
 // The copy will be prefixed with "id".
-// This allows the clients to refer to old versions of the repository by using the reference_id
+// This allows the clients to refer to old versions of the repository by pinning the snapshot.
+// The snapshot is recorded in the SNAPSHOTS_FILE of the repository, which is returned among the artifacts.
 func (r *BhojpurSystemRepository) Snapshot(id, dst string) (artifacts []*artifact.PackageArtifact, snapshotIndex string, err error) {
 
 	var snapshotFmt string = "%s-%s"
 
 	repospec := filepath.Join(dst, REPOSITORY_SPECFILE)
-	snapshotIndex = filepath.Join(dst, fmt.Sprintf(snapshotFmt, id, REPOSITORY_SPECFILE))
+	snapshotIndex = filepath.Join(dst, SnapshotIndexFile(id))
 
-	err = fileHelper.CopyFile(repospec, filepath.Join(dst, snapshotIndex))
+	err = fileHelper.CopyFile(repospec, snapshotIndex)
 	if err != nil {
 		err = errors.Wrap(err, "while copying repo spec")
 		return
@@ -656,6 +833,16 @@ func (r *BhojpurSystemRepository) Snapshot(id, dst string) (artifacts []*artifac
 	}
 
 	err = ioutil.WriteFile(snapshotIndex, data, os.ModePerm)
+	if err != nil {
+		return
+	}
+
+	snapshots, err := r.addSnapshot(dst, RepositorySnapshot{ID: id, Revision: r.Revision, LastUpdate: r.LastUpdate})
+	if err != nil {
+		err = errors.Wrap(err, "while updating the snapshots index")
+		return
+	}
+	artifacts = append(artifacts, artifact.NewPackageArtifact(snapshots))
 
 	return
 }
@@ -739,6 +926,18 @@ func (r *BhojpurSystemRepository) getGenerator(ctx types.Context, snapshotID str
 	switch r.GetType() {
 	case DiskRepositoryType, HttpRepositoryType:
 		rg = &localRepositoryGenerator{context: ctx, snapshotID: snapshotID}
+	case S3RepositoryType:
+		rg = &s3RepositoryGenerator{
+			force:      r.ForcePush,
+			context:    ctx,
+			snapshotID: snapshotID,
+		}
+	case OCIRepositoryType:
+		rg = &ociRepositoryGenerator{
+			force:      r.ForcePush,
+			context:    ctx,
+			snapshotID: snapshotID,
+		}
 	case DockerRepositoryType:
 		rg = &dockerRepositoryGenerator{
 			b:           r.Backend,
@@ -773,6 +972,10 @@ func (r *BhojpurSystemRepository) Client(ctx types.Context) Client {
 			client.RepoData{
 				Urls:           r.GetUrls(),
 				Authentication: r.GetAuthentication(),
+				MirrorList:     r.MirrorList,
+				MirrorStats:    client.MirrorStatsFile(ctx.GetConfig().System.GetRepoDatabaseDirPath(r.GetName())),
+				TLS:            r.TLS,
+				Headers:        r.Headers,
 			}, ctx)
 
 	case DockerRepositoryType:
@@ -782,10 +985,27 @@ func (r *BhojpurSystemRepository) Client(ctx types.Context) Client {
 				Authentication: r.GetAuthentication(),
 				Verify:         r.Verify,
 			}, ctx)
+
+	case S3RepositoryType:
+		return client.NewS3Client(r.clientRepoData(), ctx)
+
+	case OCIRepositoryType:
+		return client.NewOCIClient(r.clientRepoData(), ctx)
 	}
 	return nil
 }
 
+// clientRepoData returns the settings used by the clients to access the repository
+func (r *BhojpurSystemRepository) clientRepoData() client.RepoData {
+	return client.RepoData{
+		Urls:           r.GetUrls(),
+		Authentication: r.GetAuthentication(),
+		TLS:            r.TLS,
+		Headers:        r.Headers,
+		S3:             r.S3,
+	}
+}
+
 func (r *BhojpurSystemRepository) SearchArtefact(p *types.Package) (*artifact.PackageArtifact, error) {
 	for _, a := range r.GetIndex() {
 		if a.CompileSpec.GetPackage().Matches(p) {
@@ -803,7 +1023,11 @@ func (r *BhojpurSystemRepository) getRepoFile(c Client, key string) (*artifact.P
 		return nil, errors.Wrapf(err, "key %s not present in the repository", key)
 	}
 
-	// Get Tree
+	return r.downloadRepositoryFile(c, treeFile)
+}
+
+// downloadRepositoryFile downloads the given repository file, checking its integrity
+func (r *BhojpurSystemRepository) downloadRepositoryFile(c Client, treeFile BhojpurRepositoryFile) (*artifact.PackageArtifact, error) {
 	downloadedTreeFile, err := c.DownloadFile(treeFile.GetFileName())
 	if err != nil {
 		return nil, errors.Wrap(err, "While downloading "+treeFile.GetFileName())
@@ -866,7 +1090,9 @@ func (r *BhojpurSystemRepository) SyncBuildMetadata(ctx types.Context, path stri
 
 func (r *BhojpurSystemRepository) referenceID() string {
 	repositoryReferenceID := REPOSITORY_SPECFILE
-	if r.ReferenceID != "" {
+	if r.BhojpurRepository.Snapshot != "" {
+		repositoryReferenceID = SnapshotIndexFile(r.BhojpurRepository.Snapshot)
+	} else if r.ReferenceID != "" {
 		repositoryReferenceID = r.ReferenceID
 	}
 	return repositoryReferenceID
@@ -878,16 +1104,14 @@ func (r *BhojpurSystemRepository) Sync(ctx types.Context, force bool) (*BhojpurS
 
 	repobasedir := ctx.GetConfig().System.GetRepoDatabaseDirPath(r.GetName())
 
-	toTimeSync := false
-	dat, err := ioutil.ReadFile(filepath.Join(repobasedir, "SYNCTIME"))
-	if err == nil {
-		parsed, _ := time.Parse(time.RFC3339, string(dat))
-		if time.Now().After(parsed.Add(24 * time.Hour)) {
-			toTimeSync = true
-			ctx.Debug(r.Name, "is old, refresh is suggested")
-		}
-	} else {
-		toTimeSync = true
+	refreshInterval, err := r.GetRefreshInterval()
+	if err != nil {
+		return nil, err
+	}
+	lastSync, synced := r.lastSync(ctx)
+	toTimeSync := !synced || time.Now().After(lastSync.Add(refreshInterval))
+	if synced && toTimeSync {
+		ctx.Debug(r.Name, "is old, refresh is suggested")
 	}
 
 	ctx.Debug("Sync of the repository", r.Name, "in progress...")
@@ -899,27 +1123,70 @@ func (r *BhojpurSystemRepository) Sync(ctx types.Context, force bool) (*BhojpurS
 
 	repositoryReferenceID := r.referenceID()
 
+	keyring, err := r.Keyring(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var downloadedRepoMeta *BhojpurSystemRepository
-	var file string
+	var file, signatureFile string
+	var validators client.FileValidators
 	repoFile := filepath.Join(repobasedir, repositoryReferenceID)
 
 	_, repoExistsErr := os.Stat(repoFile)
-	if toTimeSync || force || os.IsNotExist(repoExistsErr) {
+	// A local copy synced before trusting any key has no signature to check
+	_, signatureExistsErr := os.Stat(repoFile + sign.SignatureSuffix)
+	missingSignature := len(keyring) > 0 && os.IsNotExist(signatureExistsErr)
+	// The cached metadata can be used in place of the remote ones only when they
+	// are complete, and the sync isn't forced
+	cachedUsable := repoExistsErr == nil && !missingSignature && !force
+
+	refresh := toTimeSync || force || os.IsNotExist(repoExistsErr) || missingSignature
+	if refresh {
 		// Retrieve remote repository.yaml for retrieve revision and date
-		file, err = c.DownloadFile(repositoryReferenceID)
-		if err != nil {
-			return nil, errors.Wrap(err, "while downloading "+repositoryReferenceID)
+		previous := client.FileValidators{}
+		if cachedUsable {
+			previous = readValidators(repoFile)
+		}
+		file, signatureFile, validators, err = r.downloadSpecFile(ctx, c, keyring, previous)
+		switch {
+		case err == client.ErrNotModified:
+			ctx.Debug(r.Name, "metadata didn't change since the last sync")
+			r.touchSyncTime(ctx)
+			refresh = false
+		case err != nil && cachedUsable:
+			ctx.Warning(fmt.Sprintf(
+				"Failed refreshing repository %s, using the cached metadata synced on %s: %s",
+				r.Name, lastSync.Format(time.RFC1123), err.Error()))
+			refresh = false
+		case err != nil:
+			if repoExistsErr == nil {
+				return nil, errors.Wrapf(err, "while refreshing repository %s (cached metadata synced on %s are still available)",
+					r.Name, lastSync.Format(time.RFC1123))
+			}
+			return nil, err
+		}
+	}
+
+	if refresh {
+		defer os.RemoveAll(file)
+		if signatureFile != "" {
+			defer os.RemoveAll(signatureFile)
+			if err := verifyRepositoryFile(keyring, file, signatureFile); err != nil {
+				return nil, err
+			}
 		}
 		downloadedRepoMeta, err = r.ReadSpecFile(file)
 		if err != nil {
 			return nil, err
 		}
-		defer os.RemoveAll(file)
-		defer func() {
-			now := time.Now().Format(time.RFC3339)
-			ioutil.WriteFile(filepath.Join(repobasedir, "SYNCTIME"), []byte(now), os.ModePerm)
-		}()
+		defer r.touchSyncTime(ctx)
 	} else {
+		if len(keyring) > 0 {
+			if err := verifyRepositoryFile(keyring, repoFile, repoFile+sign.SignatureSuffix); err != nil {
+				return nil, err
+			}
+		}
 		downloadedRepoMeta, err = r.ReadSpecFile(repoFile)
 		if err != nil {
 			return nil, err
@@ -927,12 +1194,18 @@ func (r *BhojpurSystemRepository) Sync(ctx types.Context, force bool) (*BhojpurS
 		repoUpdated = true
 	}
 
+	// localRepo is the repository file of the cached tree, if any
+	var localRepo *BhojpurSystemRepository
 	if r.Cached {
 		if !force {
-			localRepo, _ := r.ReadSpecFile(filepath.Join(repobasedir, repositoryReferenceID))
+			localRepo, _ = r.ReadSpecFile(filepath.Join(repobasedir, repositoryReferenceID))
 			if localRepo != nil {
 				if localRepo.GetRevision() == downloadedRepoMeta.GetRevision() &&
 					localRepo.GetLastUpdate() == downloadedRepoMeta.GetLastUpdate() {
+					if !repoUpdated {
+						// The remote metadata are the cached ones
+						writeValidators(repoFile, validators)
+					}
 					repoUpdated = true
 				}
 			}
@@ -959,24 +1232,25 @@ func (r *BhojpurSystemRepository) Sync(ctx types.Context, force bool) (*BhojpurS
 		}
 	}
 
-	// treeFile and metaFile must be present, they aren't optional
 	if !repoUpdated {
-
-		treeFileArtifact, err := downloadedRepoMeta.getRepoFile(c, REPOFILE_TREE_KEY)
-		if err != nil {
-			return nil, errors.Wrapf(err, "while fetching '%s'", REPOFILE_TREE_KEY)
+		// The cached tree is updated applying the diffs published since its revision, if
+		// available, otherwise the whole tree and metadata are downloaded again
+		incremental := false
+		if localRepo != nil && len(downloadedRepoMeta.Diffs) > 0 {
+			err := downloadedRepoMeta.applyDiffs(ctx, c, localRepo.GetRevision(), treefs, metafs)
+			if err == nil {
+				incremental = true
+				ctx.Debug("Tree of the repository " + r.GetName() + " updated incrementally.")
+			} else {
+				ctx.Debug("Incremental update of the repository", r.GetName(), "not possible, downloading the whole tree:", err.Error())
+			}
 		}
-		defer os.Remove(treeFileArtifact.Path)
 
-		ctx.Debug("Tree tarball for the repository " + r.GetName() + " downloaded correctly.")
-
-		metaFileArtifact, err := downloadedRepoMeta.getRepoFile(c, REPOFILE_META_KEY)
-		if err != nil {
-			return nil, errors.Wrapf(err, "while fetching '%s'", REPOFILE_META_KEY)
+		if !incremental {
+			if err := downloadedRepoMeta.syncTree(ctx, c, treefs, metafs); err != nil {
+				return nil, err
+			}
 		}
-		defer os.Remove(metaFileArtifact.Path)
-
-		ctx.Debug("Metadata tarball for the repository " + r.GetName() + " downloaded correctly.")
 
 		if r.Cached {
 			// Copy updated repository.yaml file to repo dir now that the tree is synced.
@@ -984,28 +1258,17 @@ func (r *BhojpurSystemRepository) Sync(ctx types.Context, force bool) (*BhojpurS
 			if err != nil {
 				return nil, errors.Wrap(err, "Error on update "+repositoryReferenceID)
 			}
-			// Remove previous tree
-			os.RemoveAll(treefs)
-			// Remove previous meta dir
-			os.RemoveAll(metafs)
-		}
-		ctx.Debug("Decompress tree of the repository " + r.Name + "...")
-
-		if _, err := os.Lstat(treefs); os.IsNotExist(err) {
-			os.MkdirAll(treefs, 0600)
-		}
-
-		err = treeFileArtifact.Unpack(ctx, treefs, false)
-		if err != nil {
-			return nil, errors.Wrap(err, "Error met while unpacking tree")
-		}
-
-		// FIXME: It seems that tar with only one file doesn't create destination
-		//       directory. I create directory directly for now.
-		os.MkdirAll(metafs, os.ModePerm)
-		err = metaFileArtifact.Unpack(ctx, metafs, false)
-		if err != nil {
-			return nil, errors.Wrap(err, "Error met while unpacking metadata")
+			if signatureFile != "" {
+				err = fileHelper.CopyFile(signatureFile, filepath.Join(repobasedir, repositoryReferenceID+sign.SignatureSuffix))
+				if err != nil {
+					return nil, errors.Wrap(err, "Error on update "+repositoryReferenceID+sign.SignatureSuffix)
+				}
+			} else {
+				os.RemoveAll(repoFile + sign.SignatureSuffix)
+			}
+			if err := writeValidators(repoFile, validators); err != nil {
+				return nil, errors.Wrap(err, "Error on update "+repositoryReferenceID+validatorsSuffix)
+			}
 		}
 
 		tsec, _ := strconv.ParseInt(downloadedRepoMeta.GetLastUpdate(), 10, 64)
@@ -1050,6 +1313,52 @@ func (r *BhojpurSystemRepository) Sync(ctx types.Context, force bool) (*BhojpurS
 	return downloadedRepoMeta, nil
 }
 
+// syncTree downloads the tree and the metadata of the repository, replacing
+// the content of treefs and metafs
+func (r *BhojpurSystemRepository) syncTree(ctx types.Context, c Client, treefs, metafs string) error {
+	// treeFile and metaFile must be present, they aren't optional
+	treeFileArtifact, err := r.getRepoFile(c, REPOFILE_TREE_KEY)
+	if err != nil {
+		return errors.Wrapf(err, "while fetching '%s'", REPOFILE_TREE_KEY)
+	}
+	defer os.Remove(treeFileArtifact.Path)
+
+	ctx.Debug("Tree tarball for the repository " + r.GetName() + " downloaded correctly.")
+
+	metaFileArtifact, err := r.getRepoFile(c, REPOFILE_META_KEY)
+	if err != nil {
+		return errors.Wrapf(err, "while fetching '%s'", REPOFILE_META_KEY)
+	}
+	defer os.Remove(metaFileArtifact.Path)
+
+	ctx.Debug("Metadata tarball for the repository " + r.GetName() + " downloaded correctly.")
+
+	// Remove previous tree
+	os.RemoveAll(treefs)
+	// Remove previous meta dir
+	os.RemoveAll(metafs)
+
+	ctx.Debug("Decompress tree of the repository " + r.GetName() + "...")
+
+	if _, err := os.Lstat(treefs); os.IsNotExist(err) {
+		os.MkdirAll(treefs, 0600)
+	}
+
+	err = treeFileArtifact.Unpack(ctx, treefs, false)
+	if err != nil {
+		return errors.Wrap(err, "Error met while unpacking tree")
+	}
+
+	// FIXME: It seems that tar with only one file doesn't create destination
+	//       directory. I create directory directly for now.
+	os.MkdirAll(metafs, os.ModePerm)
+	err = metaFileArtifact.Unpack(ctx, metafs, false)
+	if err != nil {
+		return errors.Wrap(err, "Error met while unpacking metadata")
+	}
+	return nil
+}
+
 func (r *BhojpurSystemRepository) fill(r2 *BhojpurSystemRepository) {
 	r2.SetUrls(r.GetUrls())
 	r2.SetAuthentication(r.GetAuthentication())
@@ -1057,12 +1366,20 @@ func (r *BhojpurSystemRepository) fill(r2 *BhojpurSystemRepository) {
 	r2.SetPriority(r.GetPriority())
 	r2.SetName(r.GetName())
 	r2.SetVerify(r.GetVerify())
+	r2.TrustedKeys = r.TrustedKeys
+	r2.TLS = r.TLS
+	r2.Headers = r.Headers
+	r2.S3 = r.S3
+	r2.BhojpurRepository.Snapshot = r.BhojpurRepository.Snapshot
 }
 
 func (r *BhojpurSystemRepository) Serialize() (*BhojpurSystemRepositoryMetadata, BhojpurSystemRepository) {
 
 	serialized := *r
 	serialized.Authentication = nil
+	serialized.TLS = nil
+	serialized.Headers = nil
+	serialized.S3 = nil
 
 	serialized.Index = compiler.ArtifactIndex{}
 
@@ -1146,6 +1463,19 @@ PACKAGE:
 
 }
 
+// packageMatchIn returns the match of the package in the repository with the given name, if any
+func (re Repositories) packageMatchIn(name string, p *types.Package) *PackageMatch {
+	for _, r := range re {
+		if r.GetName() != name {
+			continue
+		}
+		if c, err := r.GetTree().GetDatabase().FindPackage(p); err == nil {
+			return &PackageMatch{Package: c, Repo: r}
+		}
+	}
+	return nil
+}
+
 func (re Repositories) ResolveSelectors(p types.Packages) types.Packages {
 	// If a selector is given, get the best from each repo
 	sort.Sort(re) // respect prio
@@ -0,0 +1,547 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	artifact "github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
+	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DIFF_SPECFILE is the file of a diff archive describing its changes
+	DIFF_SPECFILE = "diff.yaml"
+	// DIFF_TARBALL_FMT is the name of the diff archive between two revisions
+	DIFF_TARBALL_FMT = "repository-%d-%d.diff.tar"
+
+	// diffTreeDir is the directory of a diff archive holding the tree files
+	// added or changed
+	diffTreeDir = "tree"
+)
+
+// BhojpurRepositoryDiff references the archive with the changes of the tree
+// and of the artifact index between two consecutive revisions of a repository
+type BhojpurRepositoryDiff struct {
+	FromRevision int                   `json:"from_revision"`
+	Revision     int                   `json:"revision"`
+	File         BhojpurRepositoryFile `json:"file"`
+	// TreeDigest and IndexDigest identify the tree and the artifact index of the
+	// revision the diff updates to, so clients can verify the result of the update
+	TreeDigest  string `json:"tree_digest"`
+	IndexDigest string `json:"index_digest"`
+}
+
+// RepositoryDiff describes the changes between two revisions of a repository.
+// The tree files added or changed are stored next to it in the diff archive.
+type RepositoryDiff struct {
+	FromRevision int `json:"from_revision"`
+	Revision     int `json:"revision"`
+	// RemovedFiles are the tree files removed, relative to the tree root
+	RemovedFiles []string `json:"removed_files,omitempty"`
+	// Artifacts are the artifacts added to the index or changed
+	Artifacts []*artifact.PackageArtifact `json:"artifacts,omitempty"`
+	// RemovedArtifacts are the file names of the artifacts removed from the index
+	RemovedArtifacts []string `json:"removed_artifacts,omitempty"`
+}
+
+// previousRevision is the unpacked tree and artifact index of the
+// revision a repository is generated on top of
+type previousRevision struct {
+	revision  int
+	dir, tree string
+	meta      *BhojpurSystemRepositoryMetadata
+	diffs     []BhojpurRepositoryDiff
+}
+
+// index returns the artifact index of the previous revision, if any
+func (p *previousRevision) index() []*artifact.PackageArtifact {
+	if p == nil {
+		return nil
+	}
+	return p.meta.Index
+}
+
+func (p *previousRevision) clean() {
+	if p != nil {
+		os.RemoveAll(p.dir)
+	}
+}
+
+// previousRepositoryFiles returns the names of the files of the repository file
+// repospec which are needed to generate the diff with the next revision
+func previousRepositoryFiles(repospec string) []string {
+	names := []string{}
+	if !fileHelper.Exists(repospec) {
+		return names
+	}
+	prev, err := (&BhojpurSystemRepository{}).ReadSpecFile(repospec)
+	if err != nil {
+		return names
+	}
+	for _, key := range []string{REPOFILE_TREE_KEY, REPOFILE_META_KEY} {
+		f, _ := prev.GetRepositoryFile(key)
+		names = append(names, f.GetFileName())
+	}
+	return names
+}
+
+// loadPreviousRevision unpacks the tree and the artifact index of the repository
+// already available in dst. It returns nil if there is no previous revision to
+// generate a diff from.
+func (r *BhojpurSystemRepository) loadPreviousRevision(ctx types.Context, dst string) (*previousRevision, error) {
+	repospec := filepath.Join(dst, REPOSITORY_SPECFILE)
+	if !fileHelper.Exists(repospec) {
+		return nil, nil
+	}
+	prev, err := r.ReadSpecFile(repospec)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := ctx.TempDir("previous")
+	if err != nil {
+		return nil, errors.Wrap(err, "error met while creating tempdir for the previous revision")
+	}
+	p := &previousRevision{
+		revision: prev.GetRevision(),
+		dir:      dir,
+		tree:     filepath.Join(dir, REPOFILE_TREE_KEY),
+		diffs:    prev.Diffs,
+	}
+
+	metafs := filepath.Join(dir, REPOFILE_META_KEY)
+	for key, target := range map[string]string{REPOFILE_TREE_KEY: p.tree, REPOFILE_META_KEY: metafs} {
+		f, _ := prev.GetRepositoryFile(key)
+		a := artifact.NewPackageArtifact(filepath.Join(dst, f.GetFileName()))
+		a.CompressionType = f.GetCompressionType()
+		a.Checksums = f.GetChecksums()
+		if !fileHelper.Exists(a.Path) || a.Verify() != nil {
+			ctx.Debug("File", f.GetFileName(), "of revision", p.revision, "not available, skipping diff")
+			p.clean()
+			return nil, nil
+		}
+		os.MkdirAll(target, os.ModePerm)
+		if err := a.Unpack(ctx, target, false); err != nil {
+			p.clean()
+			return nil, errors.Wrapf(err, "while unpacking %s", f.GetFileName())
+		}
+	}
+
+	p.meta, err = NewBhojpurSystemRepositoryMetadata(filepath.Join(metafs, REPOSITORY_METAFILE), false)
+	if err != nil {
+		p.clean()
+		return nil, errors.Wrap(err, "While processing "+REPOSITORY_METAFILE)
+	}
+	return p, nil
+}
+
+// addDiff generates in dst the archive with the changes of the tree and of the
+// artifact index since the previous revision, and records it among the diffs of
+// the repository, keeping the last DiffHistory ones. Archives no longer referenced
+// are removed from dst. It returns the generated archive, or nil if there is no
+// previous revision.
+func (r *BhojpurSystemRepository) addDiff(ctx types.Context, prev *previousRevision, dst string) (*artifact.PackageArtifact, error) {
+	r.Diffs = nil
+	if prev == nil || r.DiffHistory <= 0 || prev.revision != r.Revision-1 {
+		return nil, nil
+	}
+
+	diffDir, err := ctx.TempDir("diff")
+	if err != nil {
+		return nil, errors.Wrap(err, "error met while creating tempdir for diff")
+	}
+	defer os.RemoveAll(diffDir) // clean up
+
+	current, err := ctx.TempDir("tree")
+	if err != nil {
+		return nil, errors.Wrap(err, "error met while creating tempdir for tree")
+	}
+	defer os.RemoveAll(current) // clean up
+
+	if err := r.GetTree().Save(current); err != nil {
+		return nil, errors.Wrap(err, "Error met while saving the tree")
+	}
+
+	diff := RepositoryDiff{FromRevision: prev.revision, Revision: r.Revision}
+
+	oldFiles, err := treeChecksums(prev.tree)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := treeChecksums(current)
+	if err != nil {
+		return nil, err
+	}
+	changed := 0
+	for name, sum := range newFiles {
+		if oldFiles[name] == sum {
+			continue
+		}
+		if err := fileHelper.CopyFile(filepath.Join(current, name), filepath.Join(diffDir, diffTreeDir, name)); err != nil {
+			return nil, errors.Wrapf(err, "while copying %s", name)
+		}
+		changed++
+	}
+	for name := range oldFiles {
+		if _, ok := newFiles[name]; !ok {
+			diff.RemovedFiles = append(diff.RemovedFiles, name)
+		}
+	}
+	sort.Strings(diff.RemovedFiles)
+
+	meta, _ := r.Serialize()
+	diff.Artifacts, diff.RemovedArtifacts, err = diffIndex(prev.meta.Index, meta.Index)
+	if err != nil {
+		return nil, errors.Wrap(err, "while comparing the artifact index")
+	}
+	indexSum, err := indexDigest(meta.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := yaml.Marshal(diff)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(diffDir, DIFF_SPECFILE), data, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	// Diffs are compressed as the tree they update
+	treeFile, _ := r.GetRepositoryFile(REPOFILE_TREE_KEY)
+	a := artifact.NewPackageArtifact(filepath.Join(dst, fmt.Sprintf(DIFF_TARBALL_FMT, prev.revision, r.Revision)))
+	a.CompressionType = treeFile.GetCompressionType()
+	if err := a.Compress(diffDir, 1); err != nil {
+		return nil, errors.Wrap(err, "Error met while creating diff archive")
+	}
+	if err := a.Hash(); err != nil {
+		return nil, errors.Wrap(err, "Failed generating checksums for diff")
+	}
+
+	// Keep the diffs leading to the previous revision, so clients
+	// can apply them in a chain
+	diffs := []BhojpurRepositoryDiff{}
+	expected := prev.revision
+	for i := len(prev.diffs) - 1; i >= 0 && prev.diffs[i].Revision == expected; i-- {
+		diffs = append([]BhojpurRepositoryDiff{prev.diffs[i]}, diffs...)
+		expected = prev.diffs[i].FromRevision
+	}
+	diffs = append(diffs, BhojpurRepositoryDiff{
+		FromRevision: prev.revision,
+		Revision:     r.Revision,
+		File: BhojpurRepositoryFile{
+			FileName:        path.Base(a.Path),
+			CompressionType: a.CompressionType,
+			Checksums:       a.Checksums,
+		},
+		TreeDigest:  digest(newFiles),
+		IndexDigest: indexSum,
+	})
+	if len(diffs) > r.DiffHistory {
+		diffs = diffs[len(diffs)-r.DiffHistory:]
+	}
+	r.Diffs = diffs
+
+	referenced := map[string]bool{}
+	for _, d := range diffs {
+		referenced[d.File.GetFileName()] = true
+	}
+	for _, d := range prev.diffs {
+		if !referenced[d.File.GetFileName()] {
+			os.Remove(filepath.Join(dst, d.File.GetFileName()))
+		}
+	}
+
+	ctx.Info(fmt.Sprintf("Generated diff %s from revision %d (%d tree files changed, %d removed, %d artifacts changed, %d removed)",
+		path.Base(a.Path), prev.revision, changed, len(diff.RemovedFiles), len(diff.Artifacts), len(diff.RemovedArtifacts)))
+	return a, nil
+}
+
+// treeChecksums returns the checksums of the files in dir by their path relative to dir
+func treeChecksums(dir string) (map[string]string, error) {
+	sums := map[string]string{}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		sums[filepath.ToSlash(rel)] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+	return sums, err
+}
+
+// digest returns a digest of the files checksums, as returned by treeChecksums
+func digest(sums map[string]string) string {
+	names := []string{}
+	for name := range sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s %s\n", sums[name], name)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// indexDigest returns a digest of the artifact index, independent of the order of its artifacts
+func indexDigest(index []*artifact.PackageArtifact) (string, error) {
+	sums := map[string]string{}
+	for _, a := range index {
+		data, err := normalizedArtifact(a)
+		if err != nil {
+			return "", err
+		}
+		h := sha256.Sum256(data)
+		sums[a.GetFileName()] = hex.EncodeToString(h[:])
+	}
+	return digest(sums), nil
+}
+
+// normalizedArtifact returns the artifact as written in the index, so that
+// artifacts read from an index compare equal to the ones generated
+func normalizedArtifact(a *artifact.PackageArtifact) ([]byte, error) {
+	data, err := yaml.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	n := &artifact.PackageArtifact{}
+	if err := yaml.Unmarshal(data, n); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(n)
+}
+
+// diffIndex returns the artifacts of cur added or changed since old, and the
+// file names of the artifacts of old not available anymore in cur
+func diffIndex(old, cur []*artifact.PackageArtifact) (changed []*artifact.PackageArtifact, removed []string, err error) {
+	previous := map[string][]byte{}
+	for _, a := range old {
+		data, err := normalizedArtifact(a)
+		if err != nil {
+			return nil, nil, err
+		}
+		previous[a.GetFileName()] = data
+	}
+
+	available := map[string]bool{}
+	for _, a := range cur {
+		available[a.GetFileName()] = true
+		data, err := normalizedArtifact(a)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !bytes.Equal(previous[a.GetFileName()], data) {
+			changed = append(changed, a)
+		}
+	}
+	for name := range previous {
+		if !available[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	return
+}
+
+// diffChain returns the chain of diffs which updates the given revision
+// to the one of the repository
+func (r *BhojpurSystemRepository) diffChain(from int) ([]BhojpurRepositoryDiff, error) {
+	if from >= r.GetRevision() {
+		return nil, fmt.Errorf("revision %d is not older than revision %d", from, r.GetRevision())
+	}
+	chain := []BhojpurRepositoryDiff{}
+	for rev := from; rev < r.GetRevision(); {
+		found := false
+		for _, d := range r.Diffs {
+			if d.FromRevision == rev && d.Revision > rev {
+				chain = append(chain, d)
+				rev = d.Revision
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no diff available from revision %d", rev)
+		}
+	}
+	return chain, nil
+}
+
+// applyDiffs updates the tree in treefs and the artifact index in metafs, synced
+// at the given revision, to the revision of the repository by applying the chain
+// of diffs between them. The result is verified against the digests published
+// with the last diff. treefs and metafs are left in an inconsistent state if it
+// fails.
+func (r *BhojpurSystemRepository) applyDiffs(ctx types.Context, c Client, from int, treefs, metafs string) error {
+	chain, err := r.diffChain(from)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(treefs); err != nil {
+		return err
+	}
+	metaFile := filepath.Join(metafs, REPOSITORY_METAFILE)
+	meta, err := NewBhojpurSystemRepositoryMetadata(metaFile, false)
+	if err != nil {
+		return errors.Wrap(err, "While processing "+REPOSITORY_METAFILE)
+	}
+
+	for _, d := range chain {
+		a, err := r.downloadRepositoryFile(c, d.File)
+		if err != nil {
+			return err
+		}
+		err = applyDiff(ctx, a, d, treefs, meta)
+		os.Remove(a.Path)
+		if err != nil {
+			return errors.Wrapf(err, "while applying %s", d.File.GetFileName())
+		}
+		ctx.Debug("Applied diff from revision", d.FromRevision, "to", d.Revision)
+	}
+
+	if err := verifyDiffResult(chain[len(chain)-1], treefs, meta); err != nil {
+		return err
+	}
+	return meta.WriteFile(metaFile)
+}
+
+// verifyDiffResult checks the tree and the artifact index updated by a chain of
+// diffs match the digests published with the last one
+func verifyDiffResult(d BhojpurRepositoryDiff, treefs string, meta *BhojpurSystemRepositoryMetadata) error {
+	if d.TreeDigest == "" || d.IndexDigest == "" {
+		return fmt.Errorf("diff to revision %d has no digest to verify the update", d.Revision)
+	}
+	sums, err := treeChecksums(treefs)
+	if err != nil {
+		return errors.Wrap(err, "while verifying the updated tree")
+	}
+	if sum := digest(sums); sum != d.TreeDigest {
+		return fmt.Errorf("updated tree doesn't match revision %d: digest %s, expected %s", d.Revision, sum, d.TreeDigest)
+	}
+	sum, err := indexDigest(meta.Index)
+	if err != nil {
+		return errors.Wrap(err, "while verifying the updated artifact index")
+	}
+	if sum != d.IndexDigest {
+		return fmt.Errorf("updated artifact index doesn't match revision %d: digest %s, expected %s", d.Revision, sum, d.IndexDigest)
+	}
+	return nil
+}
+
+// applyDiff applies the changes of the diff archive a to the tree in treefs
+// and to the artifact index meta
+func applyDiff(ctx types.Context, a *artifact.PackageArtifact, d BhojpurRepositoryDiff, treefs string, meta *BhojpurSystemRepositoryMetadata) error {
+	diffDir, err := ctx.TempDir("diff")
+	if err != nil {
+		return errors.Wrap(err, "error met while creating tempdir for diff")
+	}
+	defer os.RemoveAll(diffDir) // clean up
+
+	if err := a.Unpack(ctx, diffDir, false); err != nil {
+		return errors.Wrap(err, "Error met while unpacking diff")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(diffDir, DIFF_SPECFILE))
+	if err != nil {
+		return err
+	}
+	diff := &RepositoryDiff{}
+	if err := yaml.Unmarshal(data, diff); err != nil {
+		return errors.Wrap(err, "while reading "+DIFF_SPECFILE)
+	}
+	if diff.FromRevision != d.FromRevision || diff.Revision != d.Revision {
+		return fmt.Errorf("diff is from revision %d to %d, expected from %d to %d",
+			diff.FromRevision, diff.Revision, d.FromRevision, d.Revision)
+	}
+
+	for _, name := range diff.RemovedFiles {
+		// Names are cleaned as absolute paths so they can't point outside the tree
+		if err := os.RemoveAll(filepath.Join(treefs, filepath.Clean("/"+name))); err != nil {
+			return err
+		}
+	}
+	changedTree := filepath.Join(diffDir, diffTreeDir)
+	if fileHelper.Exists(changedTree) {
+		if err := fileHelper.CopyDir(changedTree, treefs); err != nil {
+			return errors.Wrap(err, "while updating the tree")
+		}
+	}
+
+	changed := map[string]*artifact.PackageArtifact{}
+	for _, art := range diff.Artifacts {
+		changed[art.GetFileName()] = art
+	}
+	removed := map[string]bool{}
+	for _, name := range diff.RemovedArtifacts {
+		removed[name] = true
+	}
+	index := []*artifact.PackageArtifact{}
+	for _, art := range meta.Index {
+		name := art.GetFileName()
+		switch {
+		case removed[name]:
+		case changed[name] != nil:
+			index = append(index, changed[name])
+			delete(changed, name)
+		default:
+			index = append(index, art)
+		}
+	}
+	for _, art := range diff.Artifacts {
+		if _, added := changed[art.GetFileName()]; added {
+			index = append(index, art)
+		}
+	}
+	meta.Index = index
+	return nil
+}
@@ -0,0 +1,205 @@
+package installer_test
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
+	compilerspec "github.com/bhojpur/iso/pkg/manager/compiler/types/spec"
+	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
+	. "github.com/bhojpur/iso/pkg/manager/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Repository diffs", func() {
+	var ctx *context.Context
+	var dir, repoDir string
+
+	repository := func() *BhojpurSystemRepository {
+		return NewSystemRepository(types.BhojpurRepository{
+			Name:            "test",
+			Type:            DiskRepositoryType,
+			Urls:            []string{repoDir},
+			Enable:          true,
+			Cached:          true,
+			RefreshInterval: "0s",
+		})
+	}
+
+	spec := func() *BhojpurSystemRepository {
+		r, err := repository().ReadSpecFile(filepath.Join(repoDir, REPOSITORY_SPECFILE))
+		Expect(err).ToNot(HaveOccurred())
+		return r
+	}
+
+	packages := func(r *BhojpurSystemRepository) []string {
+		names := []string{}
+		for _, p := range r.GetTree().GetDatabase().World() {
+			names = append(names, p.GetName())
+		}
+		return names
+	}
+
+	addLib := func() {
+		Expect(os.MkdirAll(filepath.Join(dir, "tree", "lib"), os.ModePerm)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "tree", "lib", "definition.yaml"), []byte(`
+category: "test"
+name: "lib"
+version: "1.0"
+`), 0644)).To(Succeed())
+		a := artifact.NewPackageArtifact(filepath.Join(dir, "packages", "lib-test-1.0.package.tar"))
+		Expect(a.Compress(filepath.Join(dir, "content"), 1)).To(Succeed())
+		a.CompileSpec = &compilerspec.BhojpurCompilationSpec{
+			Package: &types.Package{Name: "lib", Category: "test", Version: "1.0", Path: filepath.Join(dir, "tree", "lib")},
+		}
+		Expect(a.WriteYAML(filepath.Join(dir, "packages"))).To(Succeed())
+	}
+
+	// withoutTree hides the tree tarball of the repository while f runs,
+	// so the tree can only be synced applying the diffs
+	withoutTree := func(f func()) {
+		treeFile, err := spec().GetRepositoryFile(REPOFILE_TREE_KEY)
+		Expect(err).ToNot(HaveOccurred())
+		tarball := filepath.Join(repoDir, treeFile.GetFileName())
+		Expect(os.Rename(tarball, tarball+".hidden")).To(Succeed())
+		defer os.Rename(tarball+".hidden", tarball)
+		f()
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "diffs")
+		Expect(err).ToNot(HaveOccurred())
+		repoDir = filepath.Join(dir, "repo")
+
+		ctx = context.NewContext()
+		ctx.Config.System.DatabasePath = filepath.Join(dir, "db")
+		ctx.Config.System.PkgsCachePath = filepath.Join(dir, "cache")
+
+		writeTestRepository(ctx, dir, repoDir, WithDiffHistory(2))
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("publishes a bounded history of diffs", func() {
+		Expect(spec().Diffs).To(BeEmpty())
+
+		addLib()
+		writeTestRepository(ctx, dir, repoDir, WithDiffHistory(2))
+		diffs := spec().Diffs
+		Expect(diffs).To(HaveLen(1))
+		Expect(diffs[0].FromRevision).To(Equal(1))
+		Expect(diffs[0].Revision).To(Equal(2))
+		Expect(diffs[0].TreeDigest).ToNot(BeEmpty())
+		Expect(diffs[0].IndexDigest).ToNot(BeEmpty())
+		first := filepath.Join(repoDir, diffs[0].File.GetFileName())
+		Expect(fileHelper.Exists(first)).To(BeTrue())
+
+		writeTestRepository(ctx, dir, repoDir, WithDiffHistory(2))
+		writeTestRepository(ctx, dir, repoDir, WithDiffHistory(2))
+		diffs = spec().Diffs
+		Expect(diffs).To(HaveLen(2))
+		Expect(diffs[0].FromRevision).To(Equal(2))
+		Expect(diffs[1].Revision).To(Equal(4))
+		Expect(fileHelper.Exists(first)).To(BeFalse())
+
+		// The history restarts with the revision
+		writeTestRepository(ctx, dir, repoDir)
+		Expect(spec().Diffs).To(BeEmpty())
+	})
+
+	It("syncs the tree applying the diffs since the cached revision", func() {
+		synced, err := repository().Sync(ctx, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(packages(synced)).To(ConsistOf("app"))
+
+		addLib()
+		writeTestRepository(ctx, dir, repoDir, WithDiffHistory(2))
+		withoutTree(func() {
+			synced, err = repository().Sync(ctx, false)
+			Expect(err).ToNot(HaveOccurred())
+		})
+		Expect(synced.GetRevision()).To(Equal(2))
+		Expect(packages(synced)).To(ConsistOf("app", "lib"))
+		Expect(synced.GetIndex()).To(HaveLen(2))
+
+		Expect(os.RemoveAll(filepath.Join(dir, "tree", "lib"))).To(Succeed())
+		writeTestRepository(ctx, dir, repoDir, WithDiffHistory(2))
+		writeTestRepository(ctx, dir, repoDir, WithDiffHistory(2))
+		withoutTree(func() {
+			synced, err = repository().Sync(ctx, false)
+			Expect(err).ToNot(HaveOccurred())
+		})
+		Expect(synced.GetRevision()).To(Equal(4))
+		Expect(packages(synced)).To(ConsistOf("app"))
+		Expect(synced.GetIndex()).To(HaveLen(1))
+	})
+
+	It("downloads the whole tree when the tree updated by the diffs doesn't match", func() {
+		_, err := repository().Sync(ctx, false)
+		Expect(err).ToNot(HaveOccurred())
+
+		addLib()
+		writeTestRepository(ctx, dir, repoDir, WithDiffHistory(2))
+		file := filepath.Join(repoDir, REPOSITORY_SPECFILE)
+		content, err := ioutil.ReadFile(file)
+		Expect(err).ToNot(HaveOccurred())
+		treeDigest := spec().Diffs[0].TreeDigest
+		Expect(ioutil.WriteFile(file, []byte(strings.ReplaceAll(string(content), treeDigest, strings.Repeat("0", len(treeDigest)))), 0644)).To(Succeed())
+
+		withoutTree(func() {
+			_, err = repository().Sync(ctx, false)
+			Expect(err).To(HaveOccurred())
+		})
+
+		synced, err := repository().Sync(ctx, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(synced.GetRevision()).To(Equal(2))
+		Expect(packages(synced)).To(ConsistOf("app", "lib"))
+	})
+
+	It("downloads the whole tree when the diffs don't cover the cached revision", func() {
+		_, err := repository().Sync(ctx, false)
+		Expect(err).ToNot(HaveOccurred())
+
+		addLib()
+		for i := 0; i < 3; i++ {
+			writeTestRepository(ctx, dir, repoDir, WithDiffHistory(2))
+		}
+		withoutTree(func() {
+			_, err = repository().Sync(ctx, false)
+			Expect(err).To(HaveOccurred())
+		})
+
+		synced, err := repository().Sync(ctx, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(synced.GetRevision()).To(Equal(4))
+		Expect(packages(synced)).To(ConsistOf("app", "lib"))
+		Expect(synced.GetIndex()).To(HaveLen(2))
+	})
+})
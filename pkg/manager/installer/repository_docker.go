@@ -162,6 +162,16 @@ func (d *dockerRepositoryGenerator) pushRepoMetadata(repospec, tag string, r *Bh
 	return nil
 }
 
+// pushRepoSignature signs the repository file, if the repository has signing
+// keys, and pushes the signature file next to it
+func (d *dockerRepositoryGenerator) pushRepoSignature(repospec string, r *BhojpurSystemRepository) error {
+	signature, err := r.signFile(repospec)
+	if err != nil || signature == "" {
+		return err
+	}
+	return d.pushRepoMetadata(signature, filepath.Base(signature), r)
+}
+
 func (d *dockerRepositoryGenerator) pushImageFromArtifact(a *artifact.PackageArtifact, b compiler.CompilerBackend, checkIfExists bool) error {
 	// we generate a new archive containing the required compressed file.
 	// TODO: Bundle all the extra files in 1 docker image only, instead of an image for each file
@@ -178,6 +188,32 @@ func (d *dockerRepositoryGenerator) pushImageFromArtifact(a *artifact.PackageArt
 	}
 }
 
+// fetchRepoFile extracts into dst the image holding a file of the repository, if available
+func (d *dockerRepositoryGenerator) fetchRepoFile(r *BhojpurSystemRepository, imageName, dst string) error {
+	if !r.GetBackend().ImageAvailable(imageName) {
+		return nil
+	}
+
+	err := r.GetBackend().DownloadImage(backend.Options{ImageName: imageName})
+	if err != nil {
+		return errors.Wrapf(err, "while downloading '%s'", imageName)
+	}
+	img, err := r.GetBackend().ImageReference(imageName, true)
+	if err != nil {
+		return errors.Wrapf(err, "while downloading '%s'", imageName)
+	}
+	_, _, err = image.ExtractTo(
+		d.context,
+		img,
+		dst,
+		nil,
+	)
+	if err != nil {
+		return errors.Wrapf(err, "while extracting '%s'", imageName)
+	}
+	return nil
+}
+
 // Generate creates a Docker Bhojpur ISO repository
 func (d *dockerRepositoryGenerator) Generate(r *BhojpurSystemRepository, imagePrefix string, resetRevision bool) error {
 	// - Iterate over meta, build final images, push them if necessary
@@ -194,30 +230,32 @@ func (d *dockerRepositoryGenerator) Generate(r *BhojpurSystemRepository, imagePr
 	}
 	defer os.RemoveAll(repoTemp) // clean up
 
-	if r.GetBackend().ImageAvailable(imageRepository) {
+	if err := d.fetchRepoFile(r, imageRepository, repoTemp); err != nil {
+		return err
+	}
+	// The snapshots index is updated with the new snapshot
+	if err := d.fetchRepoFile(r, fmt.Sprintf("%s:%s", imagePrefix, SNAPSHOTS_FILE), repoTemp); err != nil {
+		return err
+	}
 
-		err := r.GetBackend().DownloadImage(backend.Options{ImageName: imageRepository})
-		if err != nil {
-			return errors.Wrapf(err, "while downloading '%s'", imageRepository)
-		}
-		img, err := r.GetBackend().ImageReference(imageRepository, true)
-		if err != nil {
-			return errors.Wrapf(err, "while downloading '%s'", imageRepository)
+	repospec := filepath.Join(repoTemp, REPOSITORY_SPECFILE)
+
+	// The tree and the index of the current revision are needed to generate the diff
+	// and to reuse the deltas
+	var previous *previousRevision
+	if (r.DiffHistory > 0 || r.Deltas) && !resetRevision {
+		for _, name := range previousRepositoryFiles(repospec) {
+			if err := d.fetchRepoFile(r, fmt.Sprintf("%s:%s", imagePrefix, helpers.SanitizeImageString(name)), repoTemp); err != nil {
+				return err
+			}
 		}
-		_, _, err = image.ExtractTo(
-			d.context,
-			img,
-			repoTemp,
-			nil,
-		)
+		previous, err = r.loadPreviousRevision(d.context, repoTemp)
 		if err != nil {
-			return errors.Wrapf(err, "while extracting '%s'", imageRepository)
+			return errors.Wrap(err, "while reading the previous revision")
 		}
-
+		defer previous.clean()
 	}
 
-	repospec := filepath.Join(repoTemp, REPOSITORY_SPECFILE)
-
 	// Increment the internal revision version by reading the one which is already available (if any)
 	if err := r.BumpRevision(repospec, resetRevision); err != nil {
 		return err
@@ -258,6 +296,32 @@ func (d *dockerRepositoryGenerator) Generate(r *BhojpurSystemRepository, imagePr
 		return errors.Wrap(err, "error met while pushing compiler tree")
 	}
 
+	if r.Deltas {
+		deltas, err := r.AddDeltas(d.context, repoTemp, previous.index())
+		if err != nil {
+			return errors.Wrap(err, "error met while adding deltas to repository")
+		}
+		for _, delta := range deltas {
+			if err := d.pushImageFromArtifact(delta, d.b, true); err != nil {
+				return errors.Wrap(err, "error met while pushing delta")
+			}
+		}
+	}
+
+	if err := r.signIndex(); err != nil {
+		return errors.Wrap(err, "failed signing the repository artifacts")
+	}
+
+	diff, err := r.addDiff(d.context, previous, repoTemp)
+	if err != nil {
+		return errors.Wrap(err, "error met while adding diff to repository")
+	}
+	if diff != nil {
+		if err := d.pushImageFromArtifact(diff, d.b, false); err != nil {
+			return errors.Wrap(err, "error met while pushing diff")
+		}
+	}
+
 	a, err = r.AddMetadata(d.context, repospec, repoTemp)
 	if err != nil {
 		return errors.Wrap(err, "failed adding Metadata file to repository")
@@ -267,6 +331,12 @@ func (d *dockerRepositoryGenerator) Generate(r *BhojpurSystemRepository, imagePr
 		return errors.Wrap(err, "error met while pushing docker image from artifact")
 	}
 
+	// Signatures are pushed before the files they sign, so clients never
+	// pull a repository file along with the signature of the previous one
+	if err := d.pushRepoSignature(repospec, r); err != nil {
+		return errors.Wrap(err, "while pushing repository signature")
+	}
+
 	if err := d.pushRepoMetadata(repospec, REPOSITORY_SPECFILE, r); err != nil {
 		return errors.Wrap(err, "while pushing repository metadata tree")
 	}
@@ -278,6 +348,9 @@ func (d *dockerRepositoryGenerator) Generate(r *BhojpurSystemRepository, imagePr
 	if err != nil {
 		return errors.Wrap(err, "while creating snapshot")
 	}
+	if err := d.pushRepoSignature(snapshotRepoFile, r); err != nil {
+		return errors.Wrap(err, "while pushing repository snapshot signature")
+	}
 	if err := d.pushRepoMetadata(snapshotRepoFile, filepath.Base(snapshotRepoFile), r); err != nil {
 		return errors.Wrap(err, "while pushing repository snapshot metadata tree")
 	}
@@ -25,12 +25,14 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/bhojpur/iso/pkg/manager/api/core/types"
 	artifact "github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
+	"github.com/bhojpur/iso/pkg/manager/helpers/sign"
 
 	"github.com/bhojpur/iso/pkg/manager/api/core/bus"
 	"github.com/pkg/errors"
@@ -116,6 +118,17 @@ func (g *localRepositoryGenerator) Generate(r *BhojpurSystemRepository, dst stri
 		Path: dst,
 	})
 
+	// The previous revision is read before its files are overwritten, to generate
+	// the diff and to reuse its deltas
+	var previous *previousRevision
+	if (r.DiffHistory > 0 || r.Deltas) && !resetRevision {
+		previous, err = r.loadPreviousRevision(g.context, dst)
+		if err != nil {
+			return errors.Wrap(err, "while reading the previous revision")
+		}
+		defer previous.clean()
+	}
+
 	if _, err := r.AddTree(g.context, r.GetTree(), dst, REPOFILE_TREE_KEY, NewDefaultTreeRepositoryFile()); err != nil {
 		return errors.Wrap(err, "error met while adding runtime tree to repository")
 	}
@@ -124,17 +137,45 @@ func (g *localRepositoryGenerator) Generate(r *BhojpurSystemRepository, dst stri
 		return errors.Wrap(err, "error met while adding compiler tree to repository")
 	}
 
-	if _, err := r.AddMetadata(g.context, repospec, dst); err != nil {
+	if r.Deltas {
+		if _, err := r.AddDeltas(g.context, dst, previous.index()); err != nil {
+			return errors.Wrap(err, "error met while adding deltas to repository")
+		}
+	}
+
+	if err := r.signIndex(); err != nil {
+		return errors.Wrap(err, "failed signing the repository artifacts")
+	}
+
+	if _, err := r.addDiff(g.context, previous, dst); err != nil {
+		return errors.Wrap(err, "error met while adding diff to repository")
+	}
+
+	// The repository file is staged and moved in place after its signature
+	staged := repospec + ".new"
+	if _, err := r.AddMetadata(g.context, staged, dst); err != nil {
+		os.RemoveAll(staged)
 		return errors.Wrap(err, "failed adding Metadata file to repository")
 	}
 
+	if err := r.publishSignedFile(staged, repospec); err != nil {
+		os.RemoveAll(staged)
+		os.RemoveAll(staged + sign.SignatureSuffix)
+		return err
+	}
+
 	// Create named snapshot.
 	// It edits the metadata pointing at the repository files associated with the snapshot
 	// And copies the new files
-	if _, _, err := r.Snapshot(g.snapshotID, dst); err != nil {
+	_, snapshotIndex, err := r.Snapshot(g.snapshotID, dst)
+	if err != nil {
 		return errors.Wrap(err, "while creating snapshot")
 	}
 
+	if _, err := r.signFile(snapshotIndex); err != nil {
+		return err
+	}
+
 	bus.Manager.Publish(bus.EventRepositoryPostBuild, struct {
 		Repo BhojpurSystemRepository
 		Path string
@@ -144,3 +185,110 @@ func (g *localRepositoryGenerator) Generate(r *BhojpurSystemRepository, dst stri
 	})
 	return nil
 }
+
+// repositoryPublisher stores the files of a repository in a remote storage
+type repositoryPublisher interface {
+	// fetch downloads the file with the given name into dst, returning false if it doesn't exist
+	fetch(name, dst string) (bool, error)
+	exists(name string) (bool, error)
+	// upload stores file with the given name, a is the artifact
+	// of the file or nil for the repository metadata files
+	upload(file, name string, a *artifact.PackageArtifact) error
+}
+
+// publishOrder ranks the repository files so that each one is published
+// after the files it references, and the signatures before the files they sign
+func publishOrder(name string) int {
+	switch {
+	case name == REPOSITORY_SPECFILE:
+		return 5
+	case name == REPOSITORY_SPECFILE+sign.SignatureSuffix:
+		return 4
+	case name == SNAPSHOTS_FILE:
+		return 3
+	case strings.HasSuffix(name, "-"+REPOSITORY_SPECFILE):
+		return 2
+	case strings.HasSuffix(name, "-"+REPOSITORY_SPECFILE+sign.SignatureSuffix):
+		return 1
+	}
+	return 0
+}
+
+// publishRepository generates the repository in a local directory and publishes it
+// to dst. The artifacts are published first and the repository file last, so clients
+// never see an index referencing missing files. Artifacts already published are
+// skipped, unless force is set.
+func publishRepository(ctx types.Context, r *BhojpurSystemRepository, snapshotID, dst string, resetRevision, force bool, p repositoryPublisher) error {
+	repoTemp, err := ctx.TempDir("repo")
+	if err != nil {
+		return errors.Wrap(err, "error met while creating tempdir for repository")
+	}
+	defer os.RemoveAll(repoTemp) // clean up
+
+	// Fetch the current repository file, to bump its revision,
+	// and the snapshots index, to record the new snapshot
+	for _, name := range []string{REPOSITORY_SPECFILE, SNAPSHOTS_FILE} {
+		if _, err := p.fetch(name, filepath.Join(repoTemp, name)); err != nil {
+			return errors.Wrapf(err, "while fetching %s from %s", name, dst)
+		}
+	}
+	// The tree and the index of the current revision are needed to generate the diff
+	if r.DiffHistory > 0 && !resetRevision {
+		for _, name := range previousRepositoryFiles(filepath.Join(repoTemp, REPOSITORY_SPECFILE)) {
+			if _, err := p.fetch(name, filepath.Join(repoTemp, name)); err != nil {
+				return errors.Wrapf(err, "while fetching %s from %s", name, dst)
+			}
+		}
+	}
+
+	local := &localRepositoryGenerator{context: ctx, snapshotID: snapshotID}
+	if err := local.Generate(r, repoTemp, resetRevision); err != nil {
+		return err
+	}
+
+	for _, a := range r.GetIndex() {
+		name := a.GetFileName()
+		if !force {
+			exists, err := p.exists(name)
+			if err != nil {
+				return errors.Wrapf(err, "while checking %s", name)
+			}
+			if exists {
+				ctx.Debug("Artifact", name, "already present, skipping. use --force-push to override")
+				continue
+			}
+		}
+		ctx.Info("Uploading", name)
+		if err := p.upload(filepath.Join(r.src, name), name, a); err != nil {
+			return errors.Wrapf(err, "while uploading %s", name)
+		}
+	}
+
+	files, err := ioutil.ReadDir(repoTemp)
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	for _, f := range files {
+		if !f.IsDir() {
+			names = append(names, f.Name())
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		if publishOrder(names[i]) != publishOrder(names[j]) {
+			return publishOrder(names[i]) < publishOrder(names[j])
+		}
+		return names[i] < names[j]
+	})
+
+	for _, name := range names {
+		file := filepath.Join(repoTemp, name)
+		ctx.Debug("Uploading", name)
+		if err := p.upload(file, name, nil); err != nil {
+			return errors.Wrapf(err, "while uploading %s", name)
+		}
+	}
+
+	ctx.Info("Repository", r.Name, "published to", dst)
+	return nil
+}
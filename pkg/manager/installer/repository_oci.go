@@ -0,0 +1,97 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	artifact "github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
+	"github.com/bhojpur/iso/pkg/manager/helpers/oci"
+	"github.com/bhojpur/iso/pkg/manager/installer/client"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/pkg/errors"
+)
+
+type ociRepositoryGenerator struct {
+	force      bool
+	context    types.Context
+	snapshotID string
+}
+
+func (g *ociRepositoryGenerator) Initialize(path string, db types.PackageDatabase) ([]*artifact.PackageArtifact, error) {
+	return buildPackageIndex(g.context, path, db)
+}
+
+// ociPublisher pushes the repository files as OCI artifacts
+type ociPublisher struct {
+	repository string
+	opts       []remote.Option
+}
+
+func (p *ociPublisher) fetch(name, dst string) (bool, error) {
+	_, err := oci.Pull(client.OCIReference(p.repository, name), dst, p.opts...)
+	if err == oci.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (p *ociPublisher) exists(name string) (bool, error) {
+	return oci.Exists(client.OCIReference(p.repository, name), p.opts...)
+}
+
+func (p *ociPublisher) upload(file, name string, a *artifact.PackageArtifact) error {
+	mediaType := oci.FileMediaType
+	annotations := map[string]string{}
+	if a != nil {
+		mediaType = oci.PackageMediaType
+		if a.CompileSpec != nil && a.CompileSpec.Package != nil {
+			annotations[oci.AnnotationPackageName] = a.CompileSpec.Package.GetName()
+			annotations[oci.AnnotationPackageCategory] = a.CompileSpec.Package.GetCategory()
+			annotations[oci.AnnotationPackageVersion] = a.CompileSpec.Package.GetVersion()
+		}
+		for algo, sum := range a.Checksums {
+			annotations[oci.AnnotationChecksumPrefix+string(algo)] = sum
+		}
+	}
+	_, err := oci.Push(client.OCIReference(p.repository, name), file, mediaType, annotations, p.opts...)
+	return err
+}
+
+// Generate creates an OCI Bhojpur ISO repository, pushing each file as an OCI
+// artifact tagged with its name in the dst registry repository (the first
+// repository URL if dst is empty)
+func (g *ociRepositoryGenerator) Generate(r *BhojpurSystemRepository, dst string, resetRevision bool) error {
+	if dst == "" {
+		if len(r.GetUrls()) == 0 {
+			return errors.New("no registry repository destination for the repository")
+		}
+		dst = r.GetUrls()[0]
+	}
+
+	// Pushes of big artifacts can take long, they are not bound to the HTTP timeout
+	opts, err := client.OCIRemoteOptions(r.clientRepoData(), 0)
+	if err != nil {
+		return errors.Wrap(err, "while configuring the registry client")
+	}
+
+	return publishRepository(g.context, r, g.snapshotID, dst, resetRevision, g.force, &ociPublisher{repository: dst, opts: opts})
+}
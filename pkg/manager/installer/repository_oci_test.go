@@ -0,0 +1,106 @@
+package installer_test
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	"github.com/bhojpur/iso/pkg/manager/helpers/oci"
+	. "github.com/bhojpur/iso/pkg/manager/installer"
+	"github.com/bhojpur/iso/pkg/manager/installer/client"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OCI repository", func() {
+	var ctx *context.Context
+	var dir, repository string
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "oci")
+		Expect(err).ToNot(HaveOccurred())
+
+		ctx = context.NewContext()
+		ctx.Config.System.DatabasePath = filepath.Join(dir, "db")
+		ctx.Config.System.PkgsCachePath = filepath.Join(dir, "cache")
+
+		server = httptest.NewServer(registry.New())
+		repository = strings.TrimPrefix(server.URL, "http://") + "/isomgr/repo"
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("pushes the repository as OCI artifacts and installs from it", func() {
+		writeTestRepository(ctx, dir, repository, WithType(OCIRepositoryType))
+
+		ref, err := name.ParseReference(repository + ":app-test-1.0.package.tar")
+		Expect(err).ToNot(HaveOccurred())
+		img, err := remote.Image(ref)
+		Expect(err).ToNot(HaveOccurred())
+		manifest, err := img.Manifest()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(manifest.Config.MediaType).To(Equal(oci.ConfigMediaType))
+		Expect(manifest.Layers[0].MediaType).To(Equal(oci.PackageMediaType))
+		Expect(manifest.Annotations).To(HaveKeyWithValue(oci.AnnotationPackageName, "app"))
+		Expect(manifest.Annotations).To(HaveKeyWithValue(oci.AnnotationPackageCategory, "test"))
+		Expect(manifest.Annotations).To(HaveKeyWithValue(oci.AnnotationPackageVersion, "1.0"))
+		Expect(manifest.Annotations).To(HaveKey(oci.AnnotationChecksumPrefix + "sha256"))
+
+		repo := NewSystemRepository(types.BhojpurRepository{
+			Name:   "test",
+			Type:   OCIRepositoryType,
+			Urls:   []string{repository},
+			Enable: true,
+		})
+		synced, err := repo.Sync(ctx, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(synced.GetIndex())).To(Equal(1))
+
+		a, err := synced.Client(ctx).DownloadArtifact(synced.GetIndex()[0])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(a.Verify()).To(Succeed())
+
+		c := synced.Client(ctx).(client.ConditionalClient)
+		file, v, err := c.DownloadFileIfChanged(REPOSITORY_SPECFILE, client.FileValidators{})
+		Expect(err).ToNot(HaveOccurred())
+		os.RemoveAll(file)
+		Expect(v.Digest).ToNot(BeEmpty())
+		_, _, err = c.DownloadFileIfChanged(REPOSITORY_SPECFILE, v)
+		Expect(err).To(Equal(client.ErrNotModified))
+
+		// A new revision changes the repository file
+		writeTestRepository(ctx, dir, repository, WithType(OCIRepositoryType))
+		_, _, err = c.DownloadFileIfChanged(REPOSITORY_SPECFILE, v)
+		Expect(err).ToNot(HaveOccurred())
+	})
+})
@@ -21,6 +21,8 @@ package installer
 // THE SOFTWARE.
 
 import (
+	"crypto/ed25519"
+
 	"github.com/bhojpur/iso/pkg/manager/api/core/types"
 	"github.com/bhojpur/iso/pkg/manager/compiler"
 )
@@ -39,6 +41,15 @@ type RepositoryConfig struct {
 
 	context                                         types.Context
 	PushImages, Force, FromRepository, FromMetadata bool
+	Deltas                                          bool
+	DiffHistory                                     int
+
+	SigningKeys   []ed25519.PrivateKey
+	SignArtifacts bool
+
+	Authentication map[string]string
+	S3             *types.BhojpurRepositoryS3
+	SnapshotID     string
 }
 
 // Apply applies the given options to the config, returning the first error
@@ -166,3 +177,64 @@ func WithPriority(b int) func(cfg *RepositoryConfig) error {
 		return nil
 	}
 }
+
+// WithDeltas when enabled generates binary deltas
+// between consecutive versions of the packages
+func WithDeltas(b bool) func(cfg *RepositoryConfig) error {
+	return func(cfg *RepositoryConfig) error {
+		cfg.Deltas = b
+		return nil
+	}
+}
+
+// WithDiffHistory keeps in the repository the diffs of the tree and of
+// the artifact index of the last n revisions. 0 disables them
+func WithDiffHistory(n int) func(cfg *RepositoryConfig) error {
+	return func(cfg *RepositoryConfig) error {
+		cfg.DiffHistory = n
+		return nil
+	}
+}
+
+// WithSigningKeys signs the repository files with the given
+// keys. More keys can be used while rotating them.
+func WithSigningKeys(keys ...ed25519.PrivateKey) func(cfg *RepositoryConfig) error {
+	return func(cfg *RepositoryConfig) error {
+		cfg.SigningKeys = append(cfg.SigningKeys, keys...)
+		return nil
+	}
+}
+
+// WithSignedArtifacts when enabled signs also each
+// artifact of the repository with the signing keys
+func WithSignedArtifacts(b bool) func(cfg *RepositoryConfig) error {
+	return func(cfg *RepositoryConfig) error {
+		cfg.SignArtifacts = b
+		return nil
+	}
+}
+
+// WithAuthentication sets the credentials used to access the repository
+func WithAuthentication(auth map[string]string) func(cfg *RepositoryConfig) error {
+	return func(cfg *RepositoryConfig) error {
+		cfg.Authentication = auth
+		return nil
+	}
+}
+
+// WithS3 sets the object storage settings of s3 repositories
+func WithS3(s *types.BhojpurRepositoryS3) func(cfg *RepositoryConfig) error {
+	return func(cfg *RepositoryConfig) error {
+		cfg.S3 = s
+		return nil
+	}
+}
+
+// WithSnapshotID sets the ID of the snapshot created with the repository,
+// the generation time if empty
+func WithSnapshotID(id string) func(cfg *RepositoryConfig) error {
+	return func(cfg *RepositoryConfig) error {
+		cfg.SnapshotID = id
+		return nil
+	}
+}
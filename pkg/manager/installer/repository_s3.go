@@ -0,0 +1,85 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"os"
+	"strings"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	artifact "github.com/bhojpur/iso/pkg/manager/api/core/types/artifact"
+	"github.com/bhojpur/iso/pkg/manager/helpers/s3"
+	"github.com/bhojpur/iso/pkg/manager/installer/client"
+
+	"github.com/pkg/errors"
+)
+
+type s3RepositoryGenerator struct {
+	force      bool
+	context    types.Context
+	snapshotID string
+}
+
+func (g *s3RepositoryGenerator) Initialize(path string, db types.PackageDatabase) ([]*artifact.PackageArtifact, error) {
+	return buildPackageIndex(g.context, path, db)
+}
+
+// s3Publisher uploads the repository files to a bucket
+type s3Publisher struct {
+	bucket *s3.Client
+	prefix string
+}
+
+func (p *s3Publisher) fetch(name, dst string) (bool, error) {
+	_, err := p.bucket.Download(s3.Key(p.prefix, name), dst)
+	if err == s3.ErrNotFound {
+		os.RemoveAll(dst)
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (p *s3Publisher) exists(name string) (bool, error) {
+	return p.bucket.Exists(s3.Key(p.prefix, name))
+}
+
+func (p *s3Publisher) upload(file, name string, a *artifact.PackageArtifact) error {
+	return p.bucket.Upload(file, s3.Key(p.prefix, name))
+}
+
+// Generate creates an S3 Bhojpur ISO repository, uploaded to dst, a s3://bucket/prefix
+// URL (the first repository URL if dst is not one)
+func (g *s3RepositoryGenerator) Generate(r *BhojpurSystemRepository, dst string, resetRevision bool) error {
+	if !strings.HasPrefix(dst, s3.Scheme+"://") {
+		if len(r.GetUrls()) == 0 {
+			return errors.New("no s3://bucket/prefix destination for the repository")
+		}
+		dst = r.GetUrls()[0]
+	}
+
+	// Uploads of big artifacts can take long, they are not bound to the HTTP timeout
+	bucket, prefix, err := client.S3Bucket(r.clientRepoData(), dst, 0)
+	if err != nil {
+		return errors.Wrap(err, "while configuring the bucket client")
+	}
+
+	return publishRepository(g.context, r, g.snapshotID, dst, resetRevision, g.force, &s3Publisher{bucket: bucket, prefix: prefix})
+}
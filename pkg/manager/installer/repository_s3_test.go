@@ -0,0 +1,123 @@
+package installer_test
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	. "github.com/bhojpur/iso/pkg/manager/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("S3 repository", func() {
+	var ctx *context.Context
+	var dir string
+	var server *httptest.Server
+	var mu sync.Mutex
+	var objects map[string][]byte
+	var puts []string
+
+	auth := map[string]string{"access_key_id": "AKID", "secret_access_key_env": "ISOMGR_TEST_S3_SECRET"}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "s3")
+		Expect(err).ToNot(HaveOccurred())
+
+		ctx = context.NewContext()
+		ctx.Config.System.DatabasePath = filepath.Join(dir, "db")
+		ctx.Config.System.PkgsCachePath = filepath.Join(dir, "cache")
+		os.Setenv("ISOMGR_TEST_S3_SECRET", "secret")
+
+		objects = map[string][]byte{}
+		puts = []string{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.Contains(r.Header.Get("Authorization"), "Credential=AKID/") {
+				http.Error(w, "access denied", http.StatusForbidden)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			switch r.Method {
+			case http.MethodPut:
+				objects[r.URL.Path], _ = ioutil.ReadAll(r.Body)
+				puts = append(puts, strings.TrimPrefix(r.URL.Path, "/isomgr/repo/"))
+			default:
+				obj, ok := objects[r.URL.Path]
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				w.Write(obj)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.Unsetenv("ISOMGR_TEST_S3_SECRET")
+		os.RemoveAll(dir)
+	})
+
+	It("uploads the repository and installs from it", func() {
+		s3 := &types.BhojpurRepositoryS3{Endpoint: server.URL, PathStyle: true}
+		writeTestRepository(ctx, dir, "s3://isomgr/repo", WithType(S3RepositoryType), WithS3(s3), WithAuthentication(auth))
+
+		Expect(objects).To(HaveKey("/isomgr/repo/app-test-1.0.package.tar"))
+		Expect(objects).To(HaveKey("/isomgr/repo/" + TREE_TARBALL + ".gz"))
+		Expect(puts[0]).To(Equal("app-test-1.0.package.tar"))
+		Expect(puts[len(puts)-1]).To(Equal(REPOSITORY_SPECFILE))
+		Expect(puts[len(puts)-2]).To(Equal(SNAPSHOTS_FILE))
+
+		repo := NewSystemRepository(types.BhojpurRepository{
+			Name:           "test",
+			Type:           S3RepositoryType,
+			Urls:           []string{"s3://isomgr/repo"},
+			Enable:         true,
+			S3:             s3,
+			Authentication: auth,
+		})
+		synced, err := repo.Sync(ctx, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(synced.GetIndex())).To(Equal(1))
+
+		a, err := synced.Client(ctx).DownloadArtifact(synced.GetIndex()[0])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(a.Verify()).To(Succeed())
+
+		// Artifacts already uploaded are skipped
+		puts = []string{}
+		writeTestRepository(ctx, dir, "s3://isomgr/repo", WithType(S3RepositoryType), WithS3(s3), WithAuthentication(auth))
+		Expect(puts).ToNot(ContainElement("app-test-1.0.package.tar"))
+		Expect(puts[len(puts)-1]).To(Equal(REPOSITORY_SPECFILE))
+
+		repo.Authentication = map[string]string{}
+		_, err = repo.Sync(ctx, true)
+		Expect(err).To(HaveOccurred())
+	})
+})
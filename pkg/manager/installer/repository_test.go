@@ -25,8 +25,10 @@ import (
 
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/bhojpur/iso/pkg/manager/api/core/context"
 	"github.com/bhojpur/iso/pkg/manager/api/core/types"
@@ -703,4 +705,101 @@ urls:
 			Expect(err).To(HaveOccurred())
 		})
 	})
+
+	Context("Deltas", func() {
+		var ctx *context.Context
+		var dir, repoDir, deltaFile string
+		var content []byte
+
+		// addVersion adds to the fixture repository a version of its app package,
+		// with an artifact of the given content
+		addVersion := func(version string, content []byte) {
+			definition := filepath.Join(dir, "tree", "app-"+version)
+			Expect(os.MkdirAll(definition, os.ModePerm)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(definition, "definition.yaml"), []byte(fmt.Sprintf(`
+category: "test"
+name: "app"
+version: "%s"
+`, version)), 0644)).To(Succeed())
+
+			src := filepath.Join(dir, "content-"+version)
+			Expect(os.MkdirAll(src, os.ModePerm)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(dir, "packages"), os.ModePerm)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(src, "app"), content, 0644)).To(Succeed())
+			a := artifact.NewPackageArtifact(filepath.Join(dir, "packages", fmt.Sprintf("app-test-%s.package.tar", version)))
+			Expect(a.Compress(src, 1)).To(Succeed())
+			a.CompileSpec = &compilerspec.BhojpurCompilationSpec{
+				Package: &types.Package{Name: "app", Category: "test", Version: version, Path: definition},
+			}
+			Expect(a.WriteYAML(filepath.Join(dir, "packages"))).To(Succeed())
+		}
+
+		// published returns the deltas of the app package in the repository index
+		published := func() []artifact.ArtifactDelta {
+			synced, err := NewSystemRepository(types.BhojpurRepository{
+				Name:            "test",
+				Type:            DiskRepositoryType,
+				Urls:            []string{repoDir},
+				Enable:          true,
+				Cached:          true,
+				RefreshInterval: "0s",
+			}).Sync(ctx, false)
+			Expect(err).ToNot(HaveOccurred())
+			for _, a := range synced.GetIndex() {
+				if a.CompileSpec.Package.GetVersion() == "3.0" {
+					return a.Deltas
+				}
+			}
+			return nil
+		}
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "deltas")
+			Expect(err).ToNot(HaveOccurred())
+			repoDir = filepath.Join(dir, "repo")
+			deltaFile = filepath.Join(repoDir, "app-test-3.0.package.tar.from-2.0.delta")
+			ctx = context.NewContext()
+			ctx.Config.System.DatabasePath = filepath.Join(dir, "db")
+			ctx.Config.System.PkgsCachePath = filepath.Join(dir, "cache")
+
+			content = make([]byte, 64*1024)
+			rand.New(rand.NewSource(1)).Read(content)
+			addVersion("2.0", content)
+			addVersion("3.0", append(content, []byte("3.0")...))
+			writeTestRepository(ctx, dir, repoDir, WithDeltas(true))
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("reuses the deltas of unchanged artifacts", func() {
+			deltas := published()
+			Expect(deltas).To(HaveLen(1))
+			Expect(deltas[0].FromVersion).To(Equal("2.0"))
+			Expect(deltaFile).To(BeARegularFile())
+
+			// The file would be written again if the delta was generated again
+			past := time.Now().Add(-time.Hour)
+			Expect(os.Chtimes(deltaFile, past, past)).To(Succeed())
+
+			writeTestRepository(ctx, dir, repoDir, WithDeltas(true))
+			Expect(published()).To(Equal(deltas))
+			info, err := os.Stat(deltaFile)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.ModTime().Equal(past)).To(BeTrue())
+		})
+
+		It("generates the deltas again when the artifacts change", func() {
+			deltas := published()
+			Expect(deltas).To(HaveLen(1))
+
+			addVersion("3.0", append(content, []byte("3.0 rebuilt")...))
+			writeTestRepository(ctx, dir, repoDir, WithDeltas(true))
+			regenerated := published()
+			Expect(regenerated).To(HaveLen(1))
+			Expect(regenerated[0].Checksums).ToNot(Equal(deltas[0].Checksums))
+		})
+	})
 })
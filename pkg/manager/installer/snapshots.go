@@ -0,0 +1,133 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// RepositorySnapshot describes a snapshot published in a repository
+type RepositorySnapshot struct {
+	ID         string `json:"id"`
+	Revision   int    `json:"revision"`
+	LastUpdate string `json:"last_update,omitempty"`
+}
+
+// RepositorySnapshots is the index of the snapshots of a repository, stored in
+// the SNAPSHOTS_FILE of the repository, from the oldest to the newest
+type RepositorySnapshots struct {
+	Snapshots []RepositorySnapshot `json:"snapshots"`
+}
+
+// SnapshotIndexFile returns the name of the repository file of the snapshot with the given ID
+func SnapshotIndexFile(id string) string {
+	return fmt.Sprintf("%s-%s", id, REPOSITORY_SPECFILE)
+}
+
+// Get returns the snapshot with the given ID
+func (s RepositorySnapshots) Get(id string) (RepositorySnapshot, bool) {
+	for _, snap := range s.Snapshots {
+		if snap.ID == id {
+			return snap, true
+		}
+	}
+	return RepositorySnapshot{}, false
+}
+
+func readSnapshots(file string) (RepositorySnapshots, error) {
+	s := RepositorySnapshots{}
+	dat, err := ioutil.ReadFile(file)
+	if err != nil {
+		return s, err
+	}
+	if err := yaml.Unmarshal(dat, &s); err != nil {
+		return s, errors.Wrapf(err, "while reading the snapshots of %s", file)
+	}
+	return s, nil
+}
+
+// addSnapshot records the snapshot in the snapshots index of the repository in dst.
+// Snapshots published before the index was introduced are found by their files.
+func (r *BhojpurSystemRepository) addSnapshot(dst string, snap RepositorySnapshot) (string, error) {
+	file := filepath.Join(dst, SNAPSHOTS_FILE)
+	index, err := readSnapshots(file)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	files, err := ioutil.ReadDir(dst)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		id := strings.TrimSuffix(f.Name(), "-"+REPOSITORY_SPECFILE)
+		if f.IsDir() || id == f.Name() || id == snap.ID {
+			continue
+		}
+		if _, ok := index.Get(id); ok {
+			continue
+		}
+		if old, err := r.ReadSpecFile(filepath.Join(dst, f.Name())); err == nil {
+			index.Snapshots = append(index.Snapshots, RepositorySnapshot{
+				ID: id, Revision: old.GetRevision(), LastUpdate: old.GetLastUpdate(),
+			})
+		}
+	}
+
+	snapshots := []RepositorySnapshot{}
+	for _, s := range index.Snapshots {
+		if s.ID != snap.ID {
+			snapshots = append(snapshots, s)
+		}
+	}
+	index.Snapshots = append(snapshots, snap)
+
+	dat, err := yaml.Marshal(index)
+	if err != nil {
+		return "", err
+	}
+	return file, ioutil.WriteFile(file, dat, os.ModePerm)
+}
+
+// Snapshots returns the snapshots published in the repository
+func (r *BhojpurSystemRepository) Snapshots(ctx types.Context) (RepositorySnapshots, error) {
+	c := r.Client(ctx)
+	if c == nil {
+		return RepositorySnapshots{}, errors.New("no client could be generated from repository")
+	}
+
+	file, err := c.DownloadFile(SNAPSHOTS_FILE)
+	if err != nil {
+		return RepositorySnapshots{}, errors.Wrapf(err, "while downloading the snapshots of repository %s", r.Name)
+	}
+	defer os.RemoveAll(file)
+
+	return readSnapshots(file)
+}
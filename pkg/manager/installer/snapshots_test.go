@@ -0,0 +1,109 @@
+package installer_test
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	. "github.com/bhojpur/iso/pkg/manager/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Snapshots", func() {
+	var ctx *context.Context
+	var dir, repoDir string
+
+	repository := func(snapshot string) *BhojpurSystemRepository {
+		return NewSystemRepository(types.BhojpurRepository{
+			Name:     "test",
+			Type:     DiskRepositoryType,
+			Urls:     []string{repoDir},
+			Enable:   true,
+			Cached:   true,
+			Snapshot: snapshot,
+		})
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "snapshots")
+		Expect(err).ToNot(HaveOccurred())
+		repoDir = filepath.Join(dir, "repo")
+
+		ctx = context.NewContext()
+		ctx.Config.System.DatabasePath = filepath.Join(dir, "db")
+		ctx.Config.System.PkgsCachePath = filepath.Join(dir, "cache")
+
+		writeTestRepository(ctx, dir, repoDir, WithSnapshotID("first"))
+		writeTestRepository(ctx, dir, repoDir, WithSnapshotID("second"))
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("lists the published snapshots", func() {
+		snapshots, err := repository("").Snapshots(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(snapshots.Snapshots).To(HaveLen(2))
+		Expect(snapshots.Snapshots[0].ID).To(Equal("first"))
+		Expect(snapshots.Snapshots[0].Revision).To(Equal(1))
+		Expect(snapshots.Snapshots[1].ID).To(Equal("second"))
+		Expect(snapshots.Snapshots[1].Revision).To(Equal(2))
+
+		_, ok := snapshots.Get("second")
+		Expect(ok).To(BeTrue())
+		_, ok = snapshots.Get("third")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("lists the snapshots published before the snapshots index", func() {
+		Expect(os.Remove(filepath.Join(repoDir, SNAPSHOTS_FILE))).To(Succeed())
+		writeTestRepository(ctx, dir, repoDir, WithSnapshotID("third"))
+
+		snapshots, err := repository("").Snapshots(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(snapshots.Snapshots).To(HaveLen(3))
+		Expect(snapshots.Snapshots[2].ID).To(Equal("third"))
+	})
+
+	It("syncs the pinned snapshot", func() {
+		synced, err := repository("first").Sync(ctx, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(synced.GetRevision()).To(Equal(1))
+		Expect(len(synced.GetIndex())).To(Equal(1))
+
+		synced, err = repository("").Sync(ctx, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(synced.GetRevision()).To(Equal(2))
+
+		// Promoting the pinned snapshot
+		synced, err = repository("second").Sync(ctx, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(synced.GetRevision()).To(Equal(2))
+
+		_, err = repository("missing").Sync(ctx, false)
+		Expect(err).To(HaveOccurred())
+	})
+})
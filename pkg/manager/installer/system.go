@@ -25,18 +25,36 @@ import (
 	"path/filepath"
 	"sync"
 
-	"github.com/bhojpur/iso/pkg/manager/api/core/template"
 	"github.com/bhojpur/iso/pkg/manager/api/core/types"
-	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
-	"github.com/bhojpur/iso/pkg/manager/tree"
+	"github.com/bhojpur/iso/pkg/manager/topsort"
 	"github.com/hashicorp/go-multierror"
+
+	"github.com/pkg/errors"
 )
 
 type System struct {
-	Database          types.PackageDatabase
-	Target            string
+	Database types.PackageDatabase
+	Target   string
+	// JournalDir is where the journal of the running transaction is kept.
+	// When empty, changes to the system are not journaled.
+	JournalDir string
+	// GenerationsDir is where the generations of the system are recorded.
+	// When empty, no generation is recorded.
+	GenerationsDir string
+	// PinsDir is where the pinned packages are stored.
+	// When empty, no package is pinned.
+	PinsDir string
+	// KeepHistory records the transactions applied to the system in its database
+	KeepHistory bool
+	// ProtectedDir is where the last shipped version of the config-protected files
+	// is kept. When empty, their updates can't be merged with the local changes.
+	ProtectedDir string
+
 	fileIndex         map[string]*types.Package
 	fileIndexPackages map[string]*types.Package
+	tx                *transaction
+	op                *runningOperation
+	history           *HistoryRecord
 	sync.Mutex
 }
 
@@ -63,37 +81,173 @@ func (s *System) OSCheck(ctx types.Context) (notFound types.Packages) {
 
 func (s *System) ExecuteFinalizers(ctx types.Context, packs []*types.Package) error {
 	var errs error
+	ordered, err := s.orderFinalizers(packs)
+	if err != nil {
+		ctx.Warning("Failed ordering finalizers, running them in install order:", err.Error())
+		ordered = packs
+	}
+
 	executedFinalizer := map[string]bool{}
-	for _, p := range packs {
-		if fileHelper.Exists(p.Rel(tree.FinalizerFile)) {
-			out, err := template.RenderWithValues([]string{p.Rel(tree.FinalizerFile)}, p.Rel(types.PackageDefinitionFile))
-			if err != nil {
-				ctx.Warning("Failed rendering finalizer for ", p.HumanReadableString(), err.Error())
+	for _, p := range ordered {
+		out, err := renderFinalizer(p)
+		if err != nil {
+			ctx.Warning("Failed rendering finalizer for ", p.HumanReadableString(), err.Error())
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		if out == "" {
+			continue
+		}
+
+		if _, exists := executedFinalizer[p.GetFingerPrint()]; !exists {
+			executedFinalizer[p.GetFingerPrint()] = true
+			ctx.Info("Executing finalizer for " + p.HumanReadableString())
+			if err := s.executeFinalizer(ctx, p, out); err != nil {
 				errs = multierror.Append(errs, err)
-				continue
 			}
+		}
+	}
+	return errs
+}
 
-			if _, exists := executedFinalizer[p.GetFingerPrint()]; !exists {
-				executedFinalizer[p.GetFingerPrint()] = true
-				ctx.Info("Executing finalizer for " + p.HumanReadableString())
-				finalizer, err := NewBhojpurFinalizerFromYaml([]byte(out))
-				if err != nil {
-					ctx.Warning("Failed reading finalizer for ", p.HumanReadableString(), err.Error())
-					errs = multierror.Append(errs, err)
-					continue
-				}
-				err = finalizer.RunInstall(ctx, s)
-				if err != nil {
-					ctx.Warning("Failed running finalizer for ", p.HumanReadableString(), err.Error())
-					errs = multierror.Append(errs, err)
-					continue
+// RunFinalizer runs again the finalizer stored for an installed package
+func (s *System) RunFinalizer(ctx types.Context, p *types.Package) error {
+	stored, err := s.Database.GetPackageFinalizer(p)
+	if err != nil {
+		return errors.Wrapf(err, "no finalizer found for %s", p.HumanReadableString())
+	}
+	ctx.Info("Executing finalizer for " + p.HumanReadableString())
+	return s.executeFinalizer(ctx, p, stored.Finalizer)
+}
+
+// executeFinalizer runs the install steps of a rendered finalizer, and records
+// their output in the system database next to the package
+func (s *System) executeFinalizer(ctx types.Context, p *types.Package, rendered string) error {
+	finalizer, err := NewBhojpurFinalizerFromYaml([]byte(rendered))
+	if err != nil {
+		ctx.Warning("Failed reading finalizer for ", p.HumanReadableString(), err.Error())
+		return err
+	}
+
+	output, err := finalizer.RunInstall(ctx, s)
+	notifyFinalizerOutput(ctx, p, output, err)
+	record := &types.PackageFinalizer{PackageFingerprint: p.GetFingerPrint(), Finalizer: rendered, Output: output}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if serr := s.Database.SetPackageFinalizer(record); serr != nil {
+		ctx.Warning("Failed storing finalizer output for ", p.HumanReadableString(), serr.Error())
+	}
+
+	if err != nil {
+		ctx.Warning("Failed running finalizer for ", p.HumanReadableString(), err.Error())
+		return err
+	}
+	return nil
+}
+
+// orderFinalizers sorts the packages so the finalizers of the dependencies run before the ones
+// of the packages requiring them. Requirements are resolved against the packages and the
+// installed set of the system.
+func (s *System) orderFinalizers(packs []*types.Package) ([]*types.Package, error) {
+	// root is the node pointing to all the packages to order, fingerprints are never empty
+	const root = ""
+
+	graph := topsort.NewGraph()
+	graph.AddNode(root)
+	nodes := map[string]*types.Package{}
+	byName := map[string][]string{}
+	for _, p := range append(append([]*types.Package{}, packs...), s.Database.World()...) {
+		if _, ok := nodes[p.GetFingerPrint()]; ok {
+			continue
+		}
+		nodes[p.GetFingerPrint()] = p
+		byName[p.GetPackageName()] = append(byName[p.GetPackageName()], p.GetFingerPrint())
+		graph.AddNode(p.GetFingerPrint())
+	}
+
+	for fingerprint, p := range nodes {
+		for _, r := range p.GetRequires() {
+			for _, required := range byName[r.GetPackageName()] {
+				if required != fingerprint {
+					graph.AddEdge(fingerprint, required)
 				}
 			}
 		}
 	}
+	for _, p := range packs {
+		graph.AddEdge(root, p.GetFingerPrint())
+	}
+
+	sorted, err := graph.TopSort(root)
+	if err != nil {
+		return nil, err
+	}
+
+	toOrder := map[string]interface{}{}
+	for _, p := range packs {
+		toOrder[p.GetFingerPrint()] = nil
+	}
+	ordered := []*types.Package{}
+	for _, fingerprint := range sorted {
+		if _, ok := toOrder[fingerprint]; ok {
+			ordered = append(ordered, nodes[fingerprint])
+		}
+	}
+	return ordered, nil
+}
+
+// ExecuteUninstallFinalizers runs the uninstall steps of the finalizers
+// stored in the system database for the given packages. Packages run their
+// uninstall steps before the ones of their dependencies.
+func (s *System) ExecuteUninstallFinalizers(ctx types.Context, packs []*types.Package) error {
+	var errs error
+	ordered, err := s.orderFinalizers(packs)
+	if err != nil {
+		ctx.Warning("Failed ordering finalizers, running them in removal order:", err.Error())
+		ordered = packs
+	} else {
+		for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		}
+	}
+
+	for _, p := range ordered {
+		stored, err := s.Database.GetPackageFinalizer(p)
+		if err != nil {
+			// The package was installed without a finalizer
+			continue
+		}
+		finalizer, err := NewBhojpurFinalizerFromYaml([]byte(stored.Finalizer))
+		if err != nil {
+			ctx.Warning("Failed reading finalizer for ", p.HumanReadableString(), err.Error())
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		if len(finalizer.Uninstall) == 0 {
+			continue
+		}
+		ctx.Info("Executing uninstall finalizer for " + p.HumanReadableString())
+		output, err := finalizer.RunUnInstall(ctx, s)
+		notifyFinalizerOutput(ctx, p, output, err)
+		if err != nil {
+			ctx.Warning("Failed running uninstall finalizer for ", p.HumanReadableString(), err.Error())
+			errs = multierror.Append(errs, err)
+		}
+	}
 	return errs
 }
 
+// storeFinalizer saves the rendered finalizer of a tree package in the system
+// database, so its uninstall steps can be run when the package is removed
+func (s *System) storeFinalizer(p *types.Package) error {
+	out, err := renderFinalizer(p)
+	if err != nil || out == "" {
+		return err
+	}
+	return s.Database.SetPackageFinalizer(&types.PackageFinalizer{PackageFingerprint: p.GetFingerPrint(), Finalizer: out})
+}
+
 func (s *System) buildFileIndex() {
 	// XXX: Replace with cache
 	s.Lock()
@@ -23,9 +23,13 @@ package installer_test
 import (
 	//	. "github.com/bhojpur/iso/pkg/manager/installer"
 
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/bhojpur/iso/pkg/manager/api/core/context"
 	"github.com/bhojpur/iso/pkg/manager/api/core/types"
@@ -91,4 +95,244 @@ var _ = Describe("System", func() {
 			Expect(len(notfound)).To(Equal(1))
 		})
 	})
+
+	Context("Finalizers", func() {
+		It("runs the uninstall steps of the stored finalizers", func() {
+			dir, err := ioutil.TempDir("", "test")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			db := pkg.NewInMemoryDatabase(false)
+			s := &System{Database: db, Target: string(os.PathSeparator)}
+			ctx := context.NewContext()
+
+			a := &types.Package{Name: "test", Version: "1", Category: "t"}
+			b := &types.Package{Name: "test2", Version: "1", Category: "t"}
+			db.CreatePackage(a)
+			db.CreatePackage(b)
+			Expect(db.SetPackageFinalizer(&types.PackageFinalizer{
+				PackageFingerprint: a.GetFingerPrint(),
+				Finalizer:          "uninstall:\n- echo removed > " + filepath.Join(dir, "removed"),
+			})).To(Succeed())
+
+			Expect(s.ExecuteUninstallFinalizers(ctx, []*types.Package{a, b})).To(Succeed())
+			Expect(filepath.Join(dir, "removed")).To(BeAnExistingFile())
+
+			Expect(db.SetPackageFinalizer(&types.PackageFinalizer{
+				PackageFingerprint: b.GetFingerPrint(),
+				Finalizer:          "uninstall:\n- exit 1",
+			})).To(Succeed())
+			Expect(s.ExecuteUninstallFinalizers(ctx, []*types.Package{b})).ToNot(Succeed())
+		})
+
+		It("runs the uninstall steps of packages before their dependencies", func() {
+			dir, err := ioutil.TempDir("", "test")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			db := pkg.NewInMemoryDatabase(false)
+			s := &System{Database: db, Target: string(os.PathSeparator)}
+			ctx := context.NewContext()
+
+			b := &types.Package{Name: "b", Version: "1", Category: "t"}
+			a := &types.Package{Name: "a", Version: "1", Category: "t", PackageRequires: []*types.Package{{Name: "b", Version: ">=0", Category: "t"}}}
+			for _, p := range []*types.Package{a, b} {
+				db.CreatePackage(p)
+				Expect(db.SetPackageFinalizer(&types.PackageFinalizer{
+					PackageFingerprint: p.GetFingerPrint(),
+					Finalizer:          "uninstall:\n- echo " + p.GetName() + " >> " + filepath.Join(dir, "order"),
+				})).To(Succeed())
+			}
+
+			Expect(s.ExecuteUninstallFinalizers(ctx, []*types.Package{b, a})).To(Succeed())
+			order, err := ioutil.ReadFile(filepath.Join(dir, "order"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(order)).To(Equal("a\nb\n"))
+		})
+
+		It("records the output of the finalizers and runs them again", func() {
+			dir, err := ioutil.TempDir("", "test")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			db := pkg.NewInMemoryDatabase(false)
+			s := &System{Database: db, Target: string(os.PathSeparator)}
+			ctx := context.NewContext()
+
+			a := &types.Package{Name: "test", Version: "1", Category: "t"}
+			db.CreatePackage(a)
+			Expect(db.SetPackageFinalizer(&types.PackageFinalizer{
+				PackageFingerprint: a.GetFingerPrint(),
+				Finalizer:          "install:\n- echo running\n- test -e " + filepath.Join(dir, "fixed"),
+			})).To(Succeed())
+
+			Expect(s.RunFinalizer(ctx, a)).ToNot(Succeed())
+			f, err := db.GetPackageFinalizer(a)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f.Output).To(Equal("running\n"))
+			Expect(f.Error).ToNot(BeEmpty())
+
+			Expect(ioutil.WriteFile(filepath.Join(dir, "fixed"), []byte{}, os.ModePerm)).To(Succeed())
+			Expect(s.RunFinalizer(ctx, a)).To(Succeed())
+			f, err = db.GetPackageFinalizer(a)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f.Error).To(BeEmpty())
+		})
+
+		It("stops finalizers after their timeout", func() {
+			db := pkg.NewInMemoryDatabase(false)
+			s := &System{Database: db, Target: string(os.PathSeparator)}
+			ctx := context.NewContext()
+
+			a := &types.Package{Name: "test", Version: "1", Category: "t"}
+			db.CreatePackage(a)
+			Expect(db.SetPackageFinalizer(&types.PackageFinalizer{
+				PackageFingerprint: a.GetFingerPrint(),
+				Finalizer:          "timeout: 100ms\ninstall:\n- while true; do :; done",
+			})).To(Succeed())
+
+			err := s.RunFinalizer(ctx, a)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("timed out"))
+		})
+
+		It("stops the processes started by finalizers after their timeout", func() {
+			dir, err := ioutil.TempDir("", "test")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			db := pkg.NewInMemoryDatabase(false)
+			s := &System{Database: db, Target: string(os.PathSeparator)}
+			ctx := context.NewContext()
+
+			a := &types.Package{Name: "test", Version: "1", Category: "t"}
+			db.CreatePackage(a)
+			// The background process keeps the output of the finalizer open
+			Expect(db.SetPackageFinalizer(&types.PackageFinalizer{
+				PackageFingerprint: a.GetFingerPrint(),
+				Finalizer:          "timeout: 200ms\ninstall:\n- echo hi; (sleep 3; touch " + filepath.Join(dir, "survived") + ") & sleep 3",
+			})).To(Succeed())
+
+			start := time.Now()
+			err = s.RunFinalizer(ctx, a)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("timed out"))
+			Expect(time.Since(start)).To(BeNumerically("<", 2*time.Second))
+
+			time.Sleep(3500 * time.Millisecond)
+			Expect(filepath.Join(dir, "survived")).ToNot(BeAnExistingFile())
+		})
+	})
+
+	Context("Content", func() {
+		var s *System
+		var db types.PackageDatabase
+		var a, b *types.Package
+		var target string
+		ctx := context.NewContext()
+
+		sha := func(content string) string {
+			return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+		}
+
+		BeforeEach(func() {
+			var err error
+			target, err = ioutil.TempDir("", "target")
+			Expect(err).ToNot(HaveOccurred())
+
+			db = pkg.NewInMemoryDatabase(false)
+			s = &System{Database: db, Target: target}
+
+			a = &types.Package{Name: "a", Version: "1", Category: "t"}
+			a.AddAnnotation(string(types.ConfigProtectAnnotation), "etc")
+			b = &types.Package{Name: "b", Version: "1", Category: "t"}
+			for _, p := range []*types.Package{a, b} {
+				_, err := db.CreatePackage(p)
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			Expect(db.SetPackageFiles(&types.PackageFile{
+				PackageFingerprint: a.GetFingerPrint(),
+				Files:              []string{"bin/a", "bin/b", "etc/a.conf", "lib/a"},
+				Metadata: []types.FileMetadata{
+					{Path: "bin/a", Sha256: sha("a"), Mode: 0755, Uid: os.Geteuid(), Gid: os.Getegid()},
+					{Path: "bin/b", Sha256: sha("b"), Mode: 0755, Uid: os.Geteuid(), Gid: os.Getegid()},
+					{Path: "etc/a.conf", Sha256: sha("conf"), Mode: 0644, Uid: os.Geteuid(), Gid: os.Getegid()},
+					{Path: "lib/a", Mode: os.ModeSymlink | 0777, Link: "../bin/a"},
+				},
+			})).To(Succeed())
+			Expect(db.SetPackageFiles(&types.PackageFile{PackageFingerprint: b.GetFingerPrint(), Files: []string{"bin/c"}})).To(Succeed())
+
+			for _, d := range []string{"bin", "etc", "lib"} {
+				Expect(os.MkdirAll(filepath.Join(target, d), os.ModePerm)).To(Succeed())
+			}
+			for f, content := range map[string]string{"bin/a": "a", "bin/b": "b", "etc/a.conf": "conf"} {
+				Expect(ioutil.WriteFile(filepath.Join(target, f), []byte(content), 0644)).To(Succeed())
+			}
+			Expect(os.Chmod(filepath.Join(target, "bin/a"), 0755)).To(Succeed())
+			Expect(os.Chmod(filepath.Join(target, "bin/b"), 0755)).To(Succeed())
+			Expect(os.Symlink("../bin/a", filepath.Join(target, "lib/a"))).To(Succeed())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(target)
+		})
+
+		It("reports packages without files metadata as unverified", func() {
+			checks := s.OSCheckContent(ctx)
+			Expect(len(checks)).To(Equal(1))
+			Expect(checks[0].Package).To(Equal(b.HumanReadableString()))
+			Expect(checks[0].Unverified).To(BeTrue())
+			Expect(checks[0].Broken()).To(BeTrue())
+			Expect(checks[0].Files).To(Equal([]FileCheck{{Path: "bin/c", Status: FileMissing}}))
+		})
+
+		It("reports modified, missing and permission-changed files", func() {
+			Expect(ioutil.WriteFile(filepath.Join(target, "bin/a"), []byte("changed"), 0755)).To(Succeed())
+			Expect(os.Chmod(filepath.Join(target, "bin/b"), 0700)).To(Succeed())
+			Expect(os.Remove(filepath.Join(target, "lib/a"))).To(Succeed())
+			Expect(os.Symlink("../bin/b", filepath.Join(target, "lib/a"))).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(target, "etc/a.conf"), []byte("edited"), 0644)).To(Succeed())
+
+			checks := s.OSCheckContent(ctx)
+			Expect(len(checks)).To(Equal(2))
+
+			var check *PackageCheck
+			for _, c := range checks {
+				if c.Package == a.HumanReadableString() {
+					check = c
+				}
+			}
+			Expect(check).ToNot(BeNil())
+			Expect(check.Unverified).To(BeFalse())
+			Expect(check.Broken()).To(BeTrue())
+			Expect(check.GetPackage().GetName()).To(Equal("a"))
+
+			statuses := map[string]string{}
+			for _, f := range check.Files {
+				statuses[f.Path] = f.Status
+			}
+			Expect(statuses).To(Equal(map[string]string{
+				"bin/a": FileModified,
+				"bin/b": FilePermissions,
+				"lib/a": FileModified,
+			}))
+			Expect(len(check.ConfigProtected)).To(Equal(1))
+			Expect(check.ConfigProtected[0].Path).To(Equal("etc/a.conf"))
+			Expect(check.ConfigProtected[0].Status).To(Equal(FileModified))
+		})
+
+		It("doesn't report packages which files match", func() {
+			Expect(db.RemovePackage(b)).To(Succeed())
+			Expect(s.OSCheckContent(ctx)).To(BeEmpty())
+			// Empty results are encoded as an empty list
+			Expect(json.Marshal(s.OSCheckContent(ctx))).To(Equal([]byte("[]")))
+
+			Expect(os.Remove(filepath.Join(target, "etc/a.conf"))).To(Succeed())
+			checks := s.OSCheckContent(ctx)
+			Expect(len(checks)).To(Equal(1))
+			Expect(checks[0].Broken()).To(BeFalse())
+			Expect(checks[0].ConfigProtected).To(Equal([]FileCheck{{Path: "etc/a.conf", Status: FileMissing}}))
+		})
+	})
 })
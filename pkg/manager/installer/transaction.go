@@ -0,0 +1,477 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	fileHelper "github.com/bhojpur/iso/pkg/manager/helpers/file"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// TransactionJournalDir is the folder under the database path holding the
+	// journal of the running transaction
+	TransactionJournalDir = "transaction"
+
+	journalFile = "journal"
+	backupDir   = "backup"
+)
+
+type journalOp string
+
+const (
+	journalBegin           journalOp = "begin"
+	journalFileAdded       journalOp = "file_added"
+	journalFileReplaced    journalOp = "file_replaced"
+	journalFileRemoved     journalOp = "file_removed"
+	journalPackageAdded    journalOp = "package_added"
+	journalPackageRemoved  journalOp = "package_removed"
+	journalReasonChanged   journalOp = "reason_changed"
+	journalHistoryRecorded journalOp = "history_recorded"
+)
+
+// journalEntry is a single change applied to the system during a transaction.
+// Entries are written to the journal before the change happens.
+type journalEntry struct {
+	Op        journalOp            `json:"op"`
+	Path      string               `json:"path,omitempty"`
+	Backup    string               `json:"backup,omitempty"`
+	Package   *types.Package       `json:"package,omitempty"`
+	Files     []string             `json:"files,omitempty"`
+	Metadata  []types.FileMetadata `json:"metadata,omitempty"`
+	Finalizer string               `json:"finalizer,omitempty"`
+	Pid       int                  `json:"pid,omitempty"`
+	// Reason is the previous install reason of the package, for reason changes
+	Reason string `json:"reason,omitempty"`
+	// Record is the id of the history record of the transaction
+	Record int `json:"record,omitempty"`
+}
+
+// transaction keeps track of the files and database changes applied to a System,
+// with a backup of the files replaced or removed, so they can be reverted
+type transaction struct {
+	sync.Mutex
+	dir     string
+	journal *os.File
+	entries []journalEntry
+	// repositories maps the packages installed during the transaction to their repository
+	repositories map[string]string
+}
+
+func (t *transaction) backupPath() string {
+	return filepath.Join(t.dir, backupDir, strconv.Itoa(len(t.entries)))
+}
+
+func (t *transaction) append(e journalEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := t.journal.Write(append(b, '\n')); err != nil {
+		return errors.Wrap(err, "while writing transaction journal")
+	}
+	if err := t.journal.Sync(); err != nil {
+		return errors.Wrap(err, "while syncing transaction journal")
+	}
+	t.entries = append(t.entries, e)
+	return nil
+}
+
+// backup saves a copy of the given file in the transaction backup folder.
+// The file is hardlinked when possible, as the installer always unlinks files
+// before writing them.
+func (t *transaction) backup(path string) (string, error) {
+	dst := t.backupPath()
+	if err := os.Link(path, dst); err == nil {
+		return dst, nil
+	}
+	if err := fileHelper.DeepCopyFile(path, dst); err != nil {
+		return "", errors.Wrapf(err, "while backing up %s", path)
+	}
+	return dst, nil
+}
+
+func readJournal(dir string) ([]journalEntry, error) {
+	f, err := os.Open(filepath.Join(dir, journalFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []journalEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		e := journalEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// A crash while writing can leave a truncated entry at the end,
+			// the change it describes was never applied.
+			break
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// HasPendingTransaction returns true if the journal of an interrupted
+// transaction is present in the system
+func (s *System) HasPendingTransaction() bool {
+	return s.JournalDir != "" && fileHelper.Exists(filepath.Join(s.JournalDir, journalFile))
+}
+
+// runningOperation is the installer operation running on the system, tracked
+// whether the system journals its changes or not, with the files it touched
+type runningOperation struct {
+	touched []string
+}
+
+// beginOperation marks the start of an installer operation on the system.
+// It returns false if an operation is already running.
+func (s *System) beginOperation() bool {
+	s.Lock()
+	defer s.Unlock()
+	if s.op != nil {
+		return false
+	}
+	s.op = &runningOperation{}
+	return true
+}
+
+// endOperation marks the end of the running installer operation,
+// returning the files it installed, replaced or removed
+func (s *System) endOperation() []string {
+	s.Lock()
+	defer s.Unlock()
+	if s.op == nil {
+		return nil
+	}
+	touched := s.op.touched
+	s.op = nil
+	return touched
+}
+
+// touch records files of the system target about to be changed by the running
+// operation. Directories are skipped. The lock of the system has to be held.
+func (s *System) touch(files ...string) {
+	if s.op == nil {
+		return
+	}
+	for _, f := range files {
+		if fi, err := os.Lstat(filepath.Join(s.Target, f)); err == nil && fi.IsDir() {
+			continue
+		}
+		s.op.touched = append(s.op.touched, f)
+	}
+}
+
+// beginTransaction starts journaling the changes applied to the system.
+// It returns false if a transaction is already running or if the system has no journal.
+func (s *System) beginTransaction() (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.JournalDir == "" || s.tx != nil {
+		return false, nil
+	}
+	if fileHelper.Exists(filepath.Join(s.JournalDir, journalFile)) {
+		return false, errors.New("an interrupted transaction is pending, it needs to be recovered first")
+	}
+	if err := s.recordBaselineGeneration(); err != nil {
+		return false, errors.Wrap(err, "while recording baseline generation")
+	}
+
+	if err := os.MkdirAll(filepath.Join(s.JournalDir, backupDir), os.ModePerm); err != nil {
+		return false, errors.Wrap(err, "while creating transaction journal")
+	}
+	f, err := os.OpenFile(filepath.Join(s.JournalDir, journalFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return false, errors.Wrap(err, "while creating transaction journal")
+	}
+
+	s.tx = &transaction{dir: s.JournalDir, journal: f, repositories: map[string]string{}}
+	if err := s.tx.append(journalEntry{Op: journalBegin, Path: s.Target, Pid: os.Getpid()}); err != nil {
+		f.Close()
+		s.tx = nil
+		return false, err
+	}
+	return true, nil
+}
+
+// commitTransaction drops the journal and the backups of the running transaction
+func (s *System) commitTransaction() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.tx == nil {
+		return nil
+	}
+	s.tx.journal.Close()
+	tx := s.tx
+	s.tx = nil
+	if err := os.RemoveAll(s.JournalDir); err != nil {
+		return err
+	}
+
+	if !tx.changedPackages() {
+		return nil
+	}
+	return errors.Wrap(s.recordGeneration(tx.repositories), "while recording generation")
+}
+
+func (t *transaction) changedPackages() bool {
+	for _, e := range t.entries {
+		if e.Op == journalPackageAdded || e.Op == journalPackageRemoved {
+			return true
+		}
+	}
+	return false
+}
+
+// rollbackTransaction reverts the changes of the running transaction
+func (s *System) rollbackTransaction(ctx types.Context) error {
+	s.Lock()
+	if s.tx == nil {
+		s.Unlock()
+		return nil
+	}
+	entries := s.tx.entries
+	s.tx.journal.Close()
+	s.tx = nil
+	s.Unlock()
+
+	return s.revert(ctx, entries)
+}
+
+// Recover reverts the changes of a transaction which was interrupted,
+// as found in the journal left in the system
+func (s *System) Recover(ctx types.Context) error {
+	if !s.HasPendingTransaction() {
+		return nil
+	}
+
+	entries, err := readJournal(s.JournalDir)
+	if err != nil {
+		return errors.Wrap(err, "while reading transaction journal")
+	}
+	if len(entries) > 0 && entries[0].Op == journalBegin {
+		if entries[0].Path != s.Target {
+			return fmt.Errorf("transaction journal refers to target '%s', not to '%s'", entries[0].Path, s.Target)
+		}
+		if processRunning(entries[0].Pid) {
+			return fmt.Errorf("transaction is still running (pid %d)", entries[0].Pid)
+		}
+	}
+
+	ctx.Warning("Found an interrupted transaction, rolling back its changes")
+	return s.revert(ctx, entries)
+}
+
+func (s *System) revert(ctx types.Context, entries []journalEntry) error {
+	var errs error
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		target := filepath.Join(s.Target, e.Path)
+
+		switch e.Op {
+		case journalFileAdded:
+			ctx.Debug("Rollback: removing", target)
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				errs = multierror.Append(errs, err)
+				continue
+			}
+			pruneEmptyFilePath(ctx, s.Target, target)
+		case journalFileReplaced, journalFileRemoved:
+			ctx.Debug("Rollback: restoring", target)
+			if err := restoreFile(e.Backup, target); err != nil {
+				errs = multierror.Append(errs, errors.Wrapf(err, "while restoring %s", target))
+			}
+		case journalPackageAdded:
+			ctx.Debug("Rollback: removing package", e.Package.HumanReadableString(), "from the database")
+			s.Database.RemovePackageFiles(e.Package)
+			s.Database.RemovePackageFinalizer(e.Package)
+			s.Database.RemovePackage(e.Package)
+		case journalReasonChanged:
+			ctx.Debug("Rollback: restoring the install reason of", e.Package.HumanReadableString())
+			installed, err := s.Database.FindPackage(e.Package)
+			if err != nil {
+				continue
+			}
+			installed.SetInstallReason(e.Reason)
+			if err := s.Database.UpdatePackage(installed); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		case journalHistoryRecorded:
+			ctx.Debug("Rollback: marking transaction", e.Record, "as failed")
+			if err := s.failHistoryRecord(e.Record, "transaction interrupted, its changes were rolled back"); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		case journalPackageRemoved:
+			ctx.Debug("Rollback: adding package", e.Package.HumanReadableString(), "to the database")
+			if _, err := s.Database.FindPackage(e.Package); err != nil {
+				if _, err := s.Database.CreatePackage(e.Package); err != nil {
+					errs = multierror.Append(errs, err)
+					continue
+				}
+			}
+			if err := s.Database.SetPackageFiles(&types.PackageFile{PackageFingerprint: e.Package.GetFingerPrint(), Files: e.Files, Metadata: e.Metadata}); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+			if e.Finalizer != "" {
+				if err := s.Database.SetPackageFinalizer(&types.PackageFinalizer{PackageFingerprint: e.Package.GetFingerPrint(), Finalizer: e.Finalizer}); err != nil {
+					errs = multierror.Append(errs, err)
+				}
+			}
+		}
+	}
+	s.Clean()
+
+	if errs != nil {
+		ctx.Error("Rollback failed, the transaction journal is kept in", s.JournalDir)
+		return errs
+	}
+
+	ctx.Info(":back: Changes rolled back")
+	return os.RemoveAll(s.JournalDir)
+}
+
+func processRunning(pid int) bool {
+	if pid <= 0 || pid == os.Getpid() {
+		return false
+	}
+	return syscall.Kill(pid, 0) == nil
+}
+
+func restoreFile(backup, target string) error {
+	if err := os.RemoveAll(target); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(backup, target); err == nil {
+		return nil
+	}
+	return fileHelper.DeepCopyFile(backup, target)
+}
+
+// journalInstallFiles records the files about to be written in the system target,
+// saving a backup of the ones already present
+func (s *System) journalInstallFiles(files []string) error {
+	s.Lock()
+	defer s.Unlock()
+	s.touch(files...)
+	if s.tx == nil {
+		return nil
+	}
+
+	for _, f := range files {
+		target := filepath.Join(s.Target, f)
+		e := journalEntry{Op: journalFileAdded, Path: f}
+
+		fi, err := os.Lstat(target)
+		if err == nil && !fi.IsDir() {
+			e.Op = journalFileReplaced
+			if e.Backup, err = s.tx.backup(target); err != nil {
+				return err
+			}
+		} else if err == nil {
+			continue
+		}
+
+		if err := s.tx.append(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// journalRemoveFile records a file about to be removed from the system target,
+// saving a backup of it
+func (s *System) journalRemoveFile(f string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	target := filepath.Join(s.Target, f)
+	fi, err := os.Lstat(target)
+	if err != nil || fi.IsDir() {
+		return nil
+	}
+	s.touch(f)
+	if s.tx == nil {
+		return nil
+	}
+
+	backup, err := s.tx.backup(target)
+	if err != nil {
+		return err
+	}
+	return s.tx.append(journalEntry{Op: journalFileRemoved, Path: f, Backup: backup})
+}
+
+// journalPackage records a package about to be added or removed from the system database
+func (s *System) journalPackage(op journalOp, p *types.Package, files []string) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.tx == nil {
+		return nil
+	}
+	e := journalEntry{Op: op, Package: p, Files: files}
+	if op == journalPackageRemoved {
+		if f, err := s.Database.GetPackageFinalizer(p); err == nil {
+			e.Finalizer = f.Finalizer
+		}
+		if m, err := s.Database.GetPackageFileMetadata(p); err == nil {
+			e.Metadata = m
+		}
+	}
+	return s.tx.append(e)
+}
+
+// journalReason records the install reason of a package about to be changed
+func (s *System) journalReason(p *types.Package) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.tx == nil {
+		return nil
+	}
+	return s.tx.append(journalEntry{Op: journalReasonChanged, Package: p, Reason: p.GetInstallReason()})
+}
+
+// journalRepository remembers the repository a package was installed from,
+// to record it in the generation created when the transaction is committed
+func (s *System) journalRepository(p *types.Package, repository string) {
+	s.Lock()
+	defer s.Unlock()
+	if s.tx == nil {
+		return
+	}
+	s.tx.repositories[p.GetFingerPrint()] = repository
+}
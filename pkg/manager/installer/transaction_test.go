@@ -0,0 +1,174 @@
+package installer_test
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	pkg "github.com/bhojpur/iso/pkg/manager/database"
+	. "github.com/bhojpur/iso/pkg/manager/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transactions", func() {
+	Context("Recovery", func() {
+		var s *System
+		var db types.PackageDatabase
+		var a, b *types.Package
+		var target, journal string
+		ctx := context.NewContext()
+
+		writeJournal := func(entries ...map[string]interface{}) {
+			Expect(os.MkdirAll(filepath.Join(journal, "backup"), os.ModePerm)).To(Succeed())
+			f, err := os.Create(filepath.Join(journal, "journal"))
+			Expect(err).ToNot(HaveOccurred())
+			defer f.Close()
+			for _, e := range entries {
+				b, err := json.Marshal(e)
+				Expect(err).ToNot(HaveOccurred())
+				f.Write(append(b, '\n'))
+			}
+		}
+
+		BeforeEach(func() {
+			var err error
+			target, err = ioutil.TempDir("", "target")
+			Expect(err).ToNot(HaveOccurred())
+			journal, err = ioutil.TempDir("", "journal")
+			Expect(err).ToNot(HaveOccurred())
+
+			db = pkg.NewInMemoryDatabase(false)
+			s = &System{Database: db, Target: target, JournalDir: journal}
+
+			a = &types.Package{Name: "a", Version: "2", Category: "t"}
+			b = &types.Package{Name: "b", Version: "1", Category: "t"}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(target)
+			os.RemoveAll(journal)
+		})
+
+		It("has no pending transaction without a journal", func() {
+			os.RemoveAll(journal)
+			Expect(s.HasPendingTransaction()).To(BeFalse())
+			Expect(s.Recover(ctx)).To(Succeed())
+		})
+
+		It("reverts the changes of an interrupted transaction", func() {
+			Expect(os.MkdirAll(filepath.Join(target, "etc"), os.ModePerm)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(target, "usr", "bin"), os.ModePerm)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(target, "etc", "a"), []byte("new"), os.ModePerm)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(target, "usr", "bin", "a"), []byte("new"), os.ModePerm)).To(Succeed())
+
+			_, err := db.CreatePackage(a)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(db.SetPackageFiles(&types.PackageFile{PackageFingerprint: a.GetFingerPrint(), Files: []string{"etc/a", "usr/bin/a"}})).To(Succeed())
+
+			writeJournal(
+				map[string]interface{}{"op": "begin", "path": target},
+				map[string]interface{}{"op": "package_removed", "package": b, "files": []string{"etc/a", "etc/b"}},
+				map[string]interface{}{"op": "file_removed", "path": "etc/b", "backup": filepath.Join(journal, "backup", "2")},
+				map[string]interface{}{"op": "package_added", "package": a},
+				map[string]interface{}{"op": "file_replaced", "path": "etc/a", "backup": filepath.Join(journal, "backup", "4")},
+				map[string]interface{}{"op": "file_added", "path": "usr/bin/a"},
+			)
+			Expect(ioutil.WriteFile(filepath.Join(journal, "backup", "2"), []byte("b"), os.ModePerm)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(journal, "backup", "4"), []byte("old"), os.ModePerm)).To(Succeed())
+
+			Expect(s.HasPendingTransaction()).To(BeTrue())
+			Expect(s.Recover(ctx)).To(Succeed())
+			Expect(s.HasPendingTransaction()).To(BeFalse())
+
+			content, err := ioutil.ReadFile(filepath.Join(target, "etc", "a"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(content)).To(Equal("old"))
+			content, err = ioutil.ReadFile(filepath.Join(target, "etc", "b"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(content)).To(Equal("b"))
+			Expect(filepath.Join(target, "usr", "bin", "a")).ToNot(BeAnExistingFile())
+			Expect(filepath.Join(target, "usr")).ToNot(BeADirectory())
+
+			_, err = db.FindPackage(a)
+			Expect(err).To(HaveOccurred())
+			_, err = db.FindPackage(b)
+			Expect(err).ToNot(HaveOccurred())
+			files, err := db.GetPackageFiles(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(files).To(Equal([]string{"etc/a", "etc/b"}))
+		})
+
+		It("restores the install reasons changed by an interrupted transaction", func() {
+			b.SetInstallReason(types.InstallReasonExplicit)
+			_, err := db.CreatePackage(b)
+			Expect(err).ToNot(HaveOccurred())
+
+			writeJournal(
+				map[string]interface{}{"op": "begin", "path": target},
+				map[string]interface{}{"op": "reason_changed", "package": b, "reason": types.InstallReasonDependency},
+			)
+			Expect(s.Recover(ctx)).To(Succeed())
+
+			p, err := db.FindPackage(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.GetInstallReason()).To(Equal(types.InstallReasonDependency))
+		})
+
+		It("marks the history record of an interrupted transaction as failed", func() {
+			s.KeepHistory = true
+			Expect(db.SetTransactionRecord(&types.TransactionRecord{
+				Operation: "install",
+				Changes:   []byte(`{"added":[{"category":"t","name":"a","version":"2"}]}`),
+				Outcome:   HistorySucceeded,
+			})).To(Succeed())
+			_, err := db.CreatePackage(a)
+			Expect(err).ToNot(HaveOccurred())
+
+			writeJournal(
+				map[string]interface{}{"op": "begin", "path": target},
+				map[string]interface{}{"op": "package_added", "package": a},
+				map[string]interface{}{"op": "history_recorded", "record": 1},
+			)
+			Expect(s.Recover(ctx)).To(Succeed())
+
+			r, err := s.HistoryRecord(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(r.Outcome).To(Equal(HistoryFailed))
+			Expect(r.Error).To(ContainSubstring("interrupted"))
+			Expect(r.Changes.Empty()).To(BeTrue())
+			_, err = db.FindPackage(a)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("refuses to recover a journal of another target", func() {
+			writeJournal(map[string]interface{}{"op": "begin", "path": "/another"})
+			Expect(s.Recover(ctx)).ToNot(Succeed())
+			Expect(s.HasPendingTransaction()).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,82 @@
+package installer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	"github.com/hashicorp/go-multierror"
+)
+
+// triggerKey identifies a trigger: packages declaring triggers with the same name share them
+func triggerKey(t *types.PackageTrigger) string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return strings.Join(t.Run, "\n")
+}
+
+// FiredTriggers returns the triggers of the installed packages watching any of the given files.
+// Triggers sharing the same name are returned once, sorted by name.
+func (s *System) FiredTriggers(files []string) []*types.PackageTrigger {
+	fired := map[string]*types.PackageTrigger{}
+	for _, p := range s.Database.World() {
+		for _, t := range p.GetTriggers() {
+			if _, ok := fired[triggerKey(t)]; ok {
+				continue
+			}
+			for _, f := range files {
+				if t.Matches(f) {
+					fired[triggerKey(t)] = t
+					break
+				}
+			}
+		}
+	}
+
+	res := []*types.PackageTrigger{}
+	for _, t := range fired {
+		res = append(res, t)
+	}
+	sort.Slice(res, func(i, j int) bool { return triggerKey(res[i]) < triggerKey(res[j]) })
+	return res
+}
+
+// ExecuteTriggers runs once each trigger fired by the given files, in the system target.
+// As for finalizers, a failing trigger doesn't stop the others.
+func (s *System) ExecuteTriggers(ctx types.Context, files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	var errs error
+	for _, t := range s.FiredTriggers(files) {
+		ctx.Info("Executing trigger", triggerKey(t))
+		finalizer := &BhojpurFinalizer{Shell: t.Shell, Install: t.Run, Timeout: t.Timeout}
+		if _, err := finalizer.RunInstall(ctx, s); err != nil {
+			ctx.Warning("Failed running trigger", triggerKey(t), err.Error())
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,132 @@
+package installer_test
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bhojpur/iso/pkg/manager/api/core/context"
+	"github.com/bhojpur/iso/pkg/manager/api/core/types"
+	pkg "github.com/bhojpur/iso/pkg/manager/database"
+	. "github.com/bhojpur/iso/pkg/manager/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Triggers", func() {
+	var s *System
+	var dir string
+	ctx := context.NewContext()
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "triggers")
+		Expect(err).ToNot(HaveOccurred())
+		s = &System{Database: pkg.NewInMemoryDatabase(false), Target: string(os.PathSeparator)}
+
+		ldconfig := &types.PackageTrigger{
+			Name:  "ldconfig",
+			Paths: []string{"/usr/lib/*.so*"},
+			Run:   []string{"echo -n ldconfig >> " + filepath.Join(dir, "out")},
+		}
+		for _, p := range []*types.Package{
+			{Name: "glibc", Version: "1", Category: "sys", Triggers: []*types.PackageTrigger{ldconfig}},
+			{Name: "musl", Version: "1", Category: "sys", Triggers: []*types.PackageTrigger{ldconfig}},
+			{Name: "fontconfig", Version: "1", Category: "media", Triggers: []*types.PackageTrigger{{
+				Name:  "fc-cache",
+				Paths: []string{"usr/share/fonts"},
+				Run:   []string{"echo -n fc-cache >> " + filepath.Join(dir, "out")},
+			}}},
+		} {
+			_, err := s.Database.CreatePackage(p)
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("matches the files and their parent folders", func() {
+		t := &types.PackageTrigger{Paths: []string{"usr/lib/*.so*", "/usr/share/fonts"}}
+		Expect(t.Matches("usr/lib/libc.so.6")).To(BeTrue())
+		Expect(t.Matches("/usr/share/fonts/TTF/a.ttf")).To(BeTrue())
+		Expect(t.Matches("usr/lib/modules/a.ko")).To(BeFalse())
+		Expect(t.Matches("usr/share/fonts.conf")).To(BeFalse())
+	})
+
+	It("fires each trigger once", func() {
+		fired := s.FiredTriggers([]string{"usr/lib/libc.so.6", "usr/lib/libm.so.6", "usr/bin/ls"})
+		Expect(len(fired)).To(Equal(1))
+		Expect(fired[0].Name).To(Equal("ldconfig"))
+
+		fired = s.FiredTriggers([]string{"usr/share/fonts/TTF/a.ttf", "usr/lib/libc.so.6"})
+		Expect(len(fired)).To(Equal(2))
+		Expect(fired[0].Name).To(Equal("fc-cache"))
+		Expect(fired[1].Name).To(Equal("ldconfig"))
+
+		Expect(s.FiredTriggers([]string{"etc/passwd"})).To(BeEmpty())
+	})
+
+	It("runs the fired triggers", func() {
+		Expect(s.ExecuteTriggers(ctx, []string{"usr/lib/libc.so.6", "usr/lib/libm.so.6", "usr/share/fonts/a.ttf"})).To(Succeed())
+		out, err := ioutil.ReadFile(filepath.Join(dir, "out"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(out)).To(Equal("fc-cacheldconfig"))
+	})
+
+	It("runs the triggers of the files touched by the operations without a journal", func() {
+		// Files are removed from the host, so the package ships them in the test folder
+		lib := filepath.Join(dir, "lib", "libz.so.1")
+		Expect(os.MkdirAll(filepath.Dir(lib), os.ModePerm)).To(Succeed())
+		Expect(ioutil.WriteFile(lib, []byte{}, 0644)).To(Succeed())
+		// Keeps the test folder, as the empty folders of the removed files are dropped
+		Expect(ioutil.WriteFile(filepath.Join(dir, "keep"), []byte{}, 0644)).To(Succeed())
+
+		zlib := &types.Package{Name: "zlib", Version: "1", Category: "sys", Triggers: []*types.PackageTrigger{{
+			Name:  "zlib-cache",
+			Paths: []string{filepath.Join(dir, "lib")},
+			Run:   []string{"echo -n zlib-cache >> " + filepath.Join(dir, "out")},
+		}}}
+		other := &types.Package{Name: "other", Version: "1", Category: "sys"}
+		for _, p := range []*types.Package{zlib, other} {
+			_, err := s.Database.CreatePackage(p)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(s.Database.SetPackageFiles(&types.PackageFile{PackageFingerprint: zlib.GetFingerPrint()})).To(Succeed())
+		Expect(s.Database.SetPackageFiles(&types.PackageFile{
+			PackageFingerprint: other.GetFingerPrint(),
+			Files:              []string{strings.TrimPrefix(lib, string(os.PathSeparator))},
+		})).To(Succeed())
+
+		inst := NewBhojpurInstaller(BhojpurInstallerOptions{Concurrency: 1, Context: ctx})
+		Expect(inst.Uninstall(s, other)).To(Succeed())
+		Expect(lib).ToNot(BeAnExistingFile())
+
+		out, err := ioutil.ReadFile(filepath.Join(dir, "out"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(out)).To(Equal("zlib-cache"))
+	})
+})
@@ -40,6 +40,8 @@ type Solver struct {
 	SolverDatabase     types.PackageDatabase
 	Wanted             types.Packages
 	InstalledDatabase  types.PackageDatabase
+	// Pins are hard constraints on the versions of the packages
+	Pins types.Packages
 
 	Resolver types.PackageResolver
 }
@@ -71,7 +73,7 @@ func NewResolver(t types.SolverOptions, installed types.PackageDatabase, definit
 	var s types.PackageSolver
 	switch t.Type {
 	default:
-		s = &Solver{InstalledDatabase: installed, DefinitionDatabase: definitiondb, SolverDatabase: solverdb, Resolver: re}
+		s = &Solver{InstalledDatabase: installed, DefinitionDatabase: definitiondb, SolverDatabase: solverdb, Resolver: re, Pins: t.Pins}
 	}
 
 	return s
@@ -209,6 +211,9 @@ func (s *Solver) getList(db types.PackageDatabase, lsp types.Packages) (types.Pa
 		cp, err := db.FindPackage(pp)
 		if err != nil {
 			packages, err := pp.Expand(db)
+			if allowed := s.allowed(packages); len(allowed) != 0 {
+				packages = allowed
+			}
 			// Expand, and relax search - if not found pick the same one
 			if err != nil || len(packages) == 0 {
 				cp = pp
@@ -405,6 +410,12 @@ func (s *Solver) UpgradeUniverse(dropremoved bool) (types.Packages, types.Packag
 			continue
 		}
 
+		// Pinned packages can only be replaced by the versions allowed by their pin
+		available = s.allowed(available)
+		if len(available) == 0 {
+			continue
+		}
+
 		bestmatch := available.Best(nil)
 		// Found a better version available
 		if !bestmatch.Matches(p) {
@@ -481,6 +492,46 @@ func (s *Solver) UpgradeUniverse(dropremoved bool) (types.Packages, types.Packag
 	return markedForRemoval, assertion, nil
 }
 
+// allowed filters out the packages whose version is not allowed by their pin
+func (s *Solver) allowed(packages types.Packages) types.Packages {
+	if len(s.Pins) == 0 {
+		return packages
+	}
+	res := types.Packages{}
+	for _, p := range packages {
+		if pin := s.Pins.FindPin(p); pin == nil || pin.AllowsVersion(p) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// buildPins returns the formulas forbidding the versions of the packages not allowed by their pin
+func (s *Solver) buildPins() ([]bf.Formula, error) {
+	var formulas []bf.Formula
+	if len(s.Pins) == 0 {
+		return formulas, nil
+	}
+	for _, p := range s.World() {
+		if pin := s.Pins.FindPin(p); pin != nil && !pin.AllowsVersion(p) {
+			encodedP, err := p.Encode(s.SolverDatabase)
+			if err != nil {
+				return nil, err
+			}
+			formulas = append(formulas, bf.Not(bf.Var(encodedP)))
+		}
+	}
+	return formulas, nil
+}
+
+func pinsToList(pins types.Packages) string {
+	res := []string{}
+	for _, p := range pins {
+		res = append(res, fmt.Sprintf("%s/%s %s", p.GetCategory(), p.GetName(), p.GetVersion()))
+	}
+	return strings.Join(res, ", ")
+}
+
 func inPackage(list []*types.Package, p *types.Package) bool {
 	for _, l := range list {
 		if l.AtomMatches(p) {
@@ -502,6 +553,7 @@ func (s *Solver) computeUpgrade(ppsToUpgrade, ppsToNotUpgrade []*types.Package)
 		for _, p := range installDB.World() {
 			installedcopy.CreatePackage(p)
 			packages, err := universe.FindPackageVersions(p)
+			packages = s.allowed(packages)
 
 			if err == nil && len(packages) != 0 {
 				best := packages.Best(nil)
@@ -536,7 +588,7 @@ func assertionToMemDB(assertions types.PackagesAssertions) types.PackageDatabase
 func (s *Solver) upgrade(psToUpgrade, psToNotUpgrade types.Packages, fn func(defDB types.PackageDatabase, installDB types.PackageDatabase) (types.Packages, types.Packages, types.PackageDatabase, []*types.Package), defDB types.PackageDatabase, installDB types.PackageDatabase, checkconflicts, full bool) (types.Packages, types.PackagesAssertions, error) {
 
 	toUninstall, toInstall, installedcopy, packsToUpgrade := fn(defDB, installDB)
-	s2 := NewSolver(types.SolverOptions{Type: types.SolverSingleCoreSimple}, installedcopy, defDB, pkg.NewInMemoryDatabase(false))
+	s2 := NewSolver(types.SolverOptions{Type: types.SolverSingleCoreSimple, Pins: s.Pins}, installedcopy, defDB, pkg.NewInMemoryDatabase(false))
 	s2.SetResolver(s.Resolver)
 	if !full {
 		ass := types.PackagesAssertions{}
@@ -741,6 +793,12 @@ func (s *Solver) BuildFormula() (bf.Formula, error) {
 	}
 
 	formulas = append(formulas, r)
+
+	pins, err := s.buildPins()
+	if err != nil {
+		return nil, err
+	}
+	formulas = append(formulas, pins...)
 	return bf.And(formulas...), nil
 }
 
@@ -787,6 +845,12 @@ func (s *Solver) RelaxedInstall(c types.Packages) (types.PackagesAssertions, err
 
 	s.Wanted = coll
 
+	for _, w := range s.Wanted {
+		if pin := s.Pins.FindPin(w); pin != nil && !pin.AllowsVersion(w) {
+			return nil, fmt.Errorf("package %s is pinned to version '%s'", w.HumanReadableString(), pin.GetVersion())
+		}
+	}
+
 	if s.noRulesWorld() {
 		var ass types.PackagesAssertions
 		for _, p := range s.Installed() {
@@ -800,6 +864,9 @@ func (s *Solver) RelaxedInstall(c types.Packages) (types.PackagesAssertions, err
 	}
 	assertions, err := s.Solve()
 	if err != nil {
+		if len(s.Pins) > 0 {
+			return nil, errors.Wrapf(err, "the solution is constrained by the pinned packages (%s)", pinsToList(s.Pins))
+		}
 		return nil, err
 	}
 
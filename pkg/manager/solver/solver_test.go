@@ -1507,6 +1507,56 @@ var _ = Describe("Solver", func() {
 			Expect(len(solution)).To(Equal(3))
 		})
 
+		It("doesn't upgrade pinned packages", func() {
+			for _, p := range []*types.Package{A1, B, C} {
+				_, err := dbDefinitions.CreatePackage(p)
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			for _, p := range []*types.Package{A, B} {
+				_, err := dbInstalled.CreatePackage(p)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			s = NewSolver(types.SolverOptions{Type: types.SolverSingleCoreSimple, Pins: types.Packages{{Name: "a", Category: "test", Version: "<1.2"}}}, dbInstalled, dbDefinitions, db)
+
+			uninstall, _, err := s.Upgrade(true, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uninstall).To(BeEmpty())
+
+			uninstall, _, err = s.UpgradeUniverse(true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uninstall).To(BeEmpty())
+		})
+
+		It("installs the versions allowed by the pins", func() {
+			for _, p := range []*types.Package{A, A1, B} {
+				_, err := dbDefinitions.CreatePackage(p)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			s = NewSolver(types.SolverOptions{Type: types.SolverSingleCoreSimple, Pins: types.Packages{{Name: "a", Category: "test", Version: "1.1"}}}, dbInstalled, dbDefinitions, db)
+
+			solution, err := s.Install([]*types.Package{{Name: "a", Category: "test", Version: ">=0"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(solution).To(ContainElement(types.PackageAssert{Package: A, Value: true}))
+			Expect(solution).ToNot(ContainElement(types.PackageAssert{Package: A1, Value: true}))
+
+			_, err = s.Install([]*types.Package{A1})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("pinned"))
+		})
+
+		It("explains when a pin blocks a dependency", func() {
+			for _, p := range []*types.Package{A, A1, B, C} {
+				_, err := dbDefinitions.CreatePackage(p)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			s = NewSolver(types.SolverOptions{Type: types.SolverSingleCoreSimple, Pins: types.Packages{{Name: "b", Category: "test", Version: ">1.0"}}}, dbInstalled, dbDefinitions, db)
+
+			_, err := s.Install([]*types.Package{C})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("pinned packages (test/b >1.0)"))
+		})
+
 		It("Suggests to remove untracked packages", func() {
 			for _, p := range []*types.Package{E} {
 				_, err := dbDefinitions.CreatePackage(p)
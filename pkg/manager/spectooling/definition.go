@@ -46,6 +46,8 @@ type PackageSanitized struct {
 	Hidden      bool     `json:"hidden,omitempty" yaml:"hidden,omitempty"`
 
 	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+
+	Triggers []*types.PackageTrigger `json:"triggers,omitempty" yaml:"triggers,omitempty"`
 }
 
 func NewDefaultPackageSanitizedFromYaml(data []byte) (*PackageSanitized, error) {
@@ -79,6 +81,7 @@ func NewDefaultPackageSanitized(p *types.Package) (ans *PackageSanitized) {
 		License:     p.GetLicense(),
 		Labels:      p.GetLabels(),
 		Annotations: ann,
+		Triggers:    p.GetTriggers(),
 	}
 
 	if p.GetRequires() != nil && len(p.GetRequires()) > 0 {
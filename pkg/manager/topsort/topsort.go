@@ -75,6 +75,11 @@ func (g *Graph) visit(name string, results *orderedset, visited *orderedset) err
 		visited = newOrderedSet()
 	}
 
+	// Already sorted along with all its edges
+	if results.index(name) != -1 {
+		return nil
+	}
+
 	added := visited.add(name)
 	if !added {
 		index := visited.index(name)